@@ -52,6 +52,7 @@ func setupCloseHandler() {
 		<-c
 		log.Printf("Execution aborted - %v", "SIGTERM")
 		probeengine.CleanupTmp()
+		pack.Teardown()
 		// TODO: Additional cleanup may be needed. For instance, any pods created during tests are not being dropped if aborted.
 		os.Exit(0)
 	}()
@@ -61,6 +62,7 @@ func setupCloseHandler() {
 func ProbrCoreLogic() (err error) {
 	log.Printf("[INFO] message from ProbCoreLogic: %s", "Start")
 	defer probeengine.CleanupTmp()
+	defer pack.Teardown()
 	setupCloseHandler()
 
 	err = config.Init("") // Create default config
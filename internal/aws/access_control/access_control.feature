@@ -0,0 +1,28 @@
+@s-awsac
+Feature: Object Storage Can Only Be Accessed By Authorized Users
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that my organisation's data can only be accessed by authorized users
+
+    Background:
+      Given an AWS account is available
+
+    @s-awsac-001
+    Scenario: Detect Accounts Without S3 Block Public Access
+      Given the scenario requires AWS account level settings
+      Then S3 Block Public Access is enabled for the account
+
+    @s-awsac-002
+    Scenario: Detect S3 Buckets Which May Be Made Public
+      Then every S3 bucket has Block Public Access enabled, ACLs disabled and no public bucket policy
+
+    @s-awsac-003
+    Scenario Outline: Prevent Object Storage from Being Made Public
+      Given an S3 bucket is created with default settings
+      Then an attempt to make the bucket public using a "<Method>" "fails"
+
+      Examples:
+        | Method               |
+        | public-read ACL      |
+        | public bucket policy |
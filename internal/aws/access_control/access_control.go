@@ -0,0 +1,478 @@
+package awsac
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/cucumber/godog"
+
+	awsutil "github.com/citihub/probr-pack-storage/internal/aws"
+	"github.com/citihub/probr-pack-storage/internal/aws/bucketpolicy"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+type scenarioState struct {
+	name        string
+	currentStep string
+	audit       *audit.ScenarioAudit
+	probe       *audit.Probe
+	ctx         context.Context
+	bucketName  string
+	buckets     []string
+}
+
+// ProbeStruct meets the interface allowing this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+// publicAccessBlock records the four Block Public Access settings at account or bucket level
+type publicAccessBlock struct {
+	BlockPublicAcls       bool
+	IgnorePublicAcls      bool
+	BlockPublicPolicy     bool
+	RestrictPublicBuckets bool
+}
+
+// bucketResult records the evaluation of the public access settings of an existing bucket
+type bucketResult struct {
+	BucketName        string
+	PublicAccessBlock publicAccessBlock
+	ObjectOwnership   string
+	PublicPolicy      bool
+	Compliant         bool
+	Error             string
+}
+
+// Probe meets the interface allowing this probe to be added to the ProbeStore
+var Probe probeStruct
+var scenario scenarioState       // Local container of scenario state
+var awsConnection connection.AWS // Provides functionality to interact with AWS
+
+func (scenario *scenarioState) anAWSAccountIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that the AWS account in the SDK configuration is available; "))
+
+	payload = struct {
+		Provider   string
+		Region     string
+		S3Endpoint string
+	}{
+		config.Vars.ServicePacks.Storage.Provider,
+		awsConnection.Region(),
+		awsutil.S3Endpoint(),
+	}
+
+	stepTrace.WriteString("Check that an endpoint is configured when running against an S3 compatible server; ")
+	if err = awsutil.RequireS3Endpoint(); err != nil {
+		return err
+	}
+
+	err = awsConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) s3BlockPublicAccessIsEnabledForTheAccount() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Get the account ID of the caller; ")
+	accountID, idErr := awsConnection.AccountID()
+	if idErr != nil {
+		err = utils.ReformatError("Failed to get AWS account ID: %v", idErr)
+		return err
+	}
+
+	stepTrace.WriteString("Get the account level S3 Block Public Access settings; ")
+	configuration, blockErr := awsConnection.GetAccountPublicAccessBlock()
+	if blockErr != nil {
+		err = utils.ReformatError("Failed to get account level S3 Block Public Access settings: %v", blockErr)
+		return err
+	}
+
+	var settings publicAccessBlock
+	if configuration != nil {
+		settings = publicAccessBlock{
+			BlockPublicAcls:       aws.BoolValue(configuration.BlockPublicAcls),
+			IgnorePublicAcls:      aws.BoolValue(configuration.IgnorePublicAcls),
+			BlockPublicPolicy:     aws.BoolValue(configuration.BlockPublicPolicy),
+			RestrictPublicBuckets: aws.BoolValue(configuration.RestrictPublicBuckets),
+		}
+	}
+
+	// Audit log
+	payload = struct {
+		AccountID         string
+		PublicAccessBlock publicAccessBlock
+	}{
+		AccountID:         accountID,
+		PublicAccessBlock: settings,
+	}
+
+	if !settings.enabled() {
+		err = utils.ReformatError("S3 Block Public Access is not fully enabled for account '%s': %+v", accountID, settings)
+	}
+	return err
+}
+
+func (scenario *scenarioState) everyS3BucketHasBlockPublicAccessEnabledACLsDisabledAndNoPublicBucketPolicy() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("List the S3 buckets owned by the account; ")
+	bucketNames, listErr := awsConnection.ListBuckets()
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list S3 buckets: %v", listErr)
+		return err
+	}
+
+	stepTrace.WriteString("Evaluate the Block Public Access settings, object ownership and policy of each bucket; ")
+	results := make([]bucketResult, 0, len(bucketNames))
+	var flagged []string
+	for _, bucketName := range bucketNames {
+		result := evaluateBucket(bucketName)
+		if !result.Compliant {
+			flagged = append(flagged, bucketName)
+		}
+		results = append(results, result)
+	}
+
+	// Audit log
+	payload = struct {
+		Buckets []bucketResult
+	}{
+		Buckets: results,
+	}
+
+	if len(flagged) > 0 {
+		err = utils.ReformatError("%d of %d S3 buckets may be made public: %s", len(flagged), len(bucketNames), strings.Join(flagged, ", "))
+	}
+	return err
+}
+
+// evaluateBucket reads the public access settings of a bucket. A bucket is compliant if all four Block Public Access
+// settings are enabled, ACLs are disabled by the BucketOwnerEnforced object ownership setting, and its policy is not public.
+// S3 compatible servers do not implement Block Public Access or object ownership, so there only the policy is evaluated.
+func evaluateBucket(bucketName string) (result bucketResult) {
+	result.BucketName = bucketName
+
+	if !awsutil.S3Compatible() {
+		configuration, blockErr := awsConnection.GetPublicAccessBlock(bucketName)
+		if blockErr != nil {
+			result.Error = blockErr.Error()
+			return
+		}
+		if configuration != nil {
+			result.PublicAccessBlock = publicAccessBlock{
+				BlockPublicAcls:       aws.BoolValue(configuration.BlockPublicAcls),
+				IgnorePublicAcls:      aws.BoolValue(configuration.IgnorePublicAcls),
+				BlockPublicPolicy:     aws.BoolValue(configuration.BlockPublicPolicy),
+				RestrictPublicBuckets: aws.BoolValue(configuration.RestrictPublicBuckets),
+			}
+		}
+
+		var ownershipErr error
+		result.ObjectOwnership, ownershipErr = awsConnection.GetBucketObjectOwnership(bucketName)
+		if ownershipErr != nil {
+			result.Error = ownershipErr.Error()
+			return
+		}
+	}
+
+	policy, policyErr := awsConnection.GetBucketPolicy(bucketName)
+	if policyErr != nil {
+		result.Error = policyErr.Error()
+		return
+	}
+	if policy != "" {
+		document, parseErr := bucketpolicy.Parse(policy)
+		if parseErr != nil {
+			result.Error = parseErr.Error()
+			return
+		}
+		result.PublicPolicy = document.AllowsPublicAccess(bucketName)
+	}
+
+	if awsutil.S3Compatible() {
+		result.Compliant = !result.PublicPolicy
+		return
+	}
+	result.Compliant = result.PublicAccessBlock.enabled() &&
+		result.ObjectOwnership == s3.ObjectOwnershipBucketOwnerEnforced &&
+		!result.PublicPolicy
+	return
+}
+
+func (scenario *scenarioState) theScenarioRequiresAWSAccountLevelSettings() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check that the probes run against Amazon S3, otherwise skip the scenario; ")
+
+	// Audit log
+	payload = struct {
+		Provider string
+	}{
+		Provider: config.Vars.ServicePacks.Storage.Provider,
+	}
+
+	err = awsutil.RequireAWS()
+	return err
+}
+
+func (scenario *scenarioState) anS3BucketIsCreatedWithDefaultSettings() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	bucketName := awsutil.BucketName()
+
+	// Audit log
+	payload = struct {
+		BucketName string
+	}{
+		BucketName: bucketName,
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Create S3 bucket '%s' without public access settings, so that account level settings and defaults apply; ", bucketName))
+	createErr := awsConnection.CreateBucket(bucketName)
+	if createErr != nil {
+		err = utils.ReformatError("Failed to create S3 bucket '%s': %v", bucketName, createErr)
+		return err
+	}
+	scenario.buckets = append(scenario.buckets, bucketName)
+	scenario.bucketName = bucketName
+
+	return nil
+}
+
+func (scenario *scenarioState) anAttemptToMakeTheBucketPublicUsingAXY(method, expectedResult string) error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values
+	var shouldSucceed bool
+	switch expectedResult {
+	case "succeeds":
+		shouldSucceed = true
+	case "fails":
+		shouldSucceed = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	var attemptErr error
+	var policy string
+	var refusalCodes []string // Error codes which show that Block Public Access or Object Ownership refused the attempt
+	switch method {
+	case "public-read ACL":
+		stepTrace.WriteString(fmt.Sprintf("Apply the public-read canned ACL to S3 bucket '%s'; ", scenario.bucketName))
+		attemptErr = awsConnection.PutBucketACL(scenario.bucketName, s3.BucketCannedACLPublicRead)
+		refusalCodes = []string{"AccessDenied", "AccessControlListNotSupported"}
+	case "public bucket policy":
+		policy = bucketpolicy.New(bucketpolicy.AllowPublicRead(scenario.bucketName)).String()
+		stepTrace.WriteString(fmt.Sprintf("Set a bucket policy allowing any principal to read objects in S3 bucket '%s'; ", scenario.bucketName))
+		attemptErr = awsConnection.PutBucketPolicy(scenario.bucketName, policy)
+		refusalCodes = []string{"AccessDenied"}
+	default:
+		err = utils.ReformatError("Unexpected value provided for method: '%s' Expected values: ['public-read ACL', 'public bucket policy']", method)
+		return err
+	}
+
+	// Audit log
+	payload = struct {
+		BucketName           string
+		Method               string
+		Policy               string
+		ExpectedRefusalCodes []string
+		ErrorCode            string
+		Error                string
+	}{
+		BucketName:           scenario.bucketName,
+		Method:               method,
+		Policy:               policy,
+		ExpectedRefusalCodes: refusalCodes,
+		ErrorCode:            errorCode(attemptErr),
+		Error:                fmt.Sprintf("%v", attemptErr),
+	}
+
+	if shouldSucceed && attemptErr != nil {
+		err = utils.ReformatError("Attempt to make the bucket public using a %s failed: %v", method, attemptErr)
+	} else if !shouldSucceed && attemptErr == nil {
+		err = utils.ReformatError("Attempt to make the bucket public using a %s succeeded, expected it to be refused", method)
+	} else if !shouldSucceed && !containsCode(refusalCodes, errorCode(attemptErr)) {
+		err = utils.ReformatError("Attempt to make the bucket public using a %s failed with error code '%s', expected one of [%s]: %v", method, errorCode(attemptErr), strings.Join(refusalCodes, ", "), attemptErr)
+	}
+	return err
+}
+
+// enabled returns whether all four Block Public Access settings are enabled
+func (b publicAccessBlock) enabled() bool {
+	return b.BlockPublicAcls && b.IgnorePublicAcls && b.BlockPublicPolicy && b.RestrictPublicBuckets
+}
+
+// errorCode returns the S3 error code of a failed request, such as AccessDenied
+func errorCode(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return ""
+}
+
+// containsCode returns whether an error code is one of the expected codes
+func containsCode(codes []string, code string) bool {
+	for _, expected := range codes {
+		if code == expected {
+			return true
+		}
+	}
+	return false
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.bucketName = ""
+	s.buckets = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "access_control"
+}
+
+// Path returns the probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "aws", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize aws connection
+		awsConnection = connection.NewAWSConnection(
+			context.Background(),
+			awsutil.S3Endpoint(),
+			awsutil.S3ForcePathStyle(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an AWS account is available$`, scenario.anAWSAccountIsAvailable)
+
+	// Steps
+	ctx.Step(`^the scenario requires AWS account level settings$`, scenario.theScenarioRequiresAWSAccountLevelSettings)
+	ctx.Step(`^S3 Block Public Access is enabled for the account$`, scenario.s3BlockPublicAccessIsEnabledForTheAccount)
+	ctx.Step(`^every S3 bucket has Block Public Access enabled, ACLs disabled and no public bucket policy$`, scenario.everyS3BucketHasBlockPublicAccessEnabledACLsDisabledAndNoPublicBucketPolicy)
+	ctx.Step(`^an S3 bucket is created with default settings$`, scenario.anS3BucketIsCreatedWithDefaultSettings)
+	ctx.Step(`^an attempt to make the bucket public using a "([^"]*)" "([^"]*)"$`, scenario.anAttemptToMakeTheBucketPublicUsingAXY)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing S3 buckets used during tests")
+
+	for _, bucketName := range scenario.buckets {
+		log.Printf("[DEBUG] need to delete the S3 bucket: %s", bucketName)
+		err := awsConnection.DeleteBucket(bucketName)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the S3 bucket: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
@@ -0,0 +1,21 @@
+@s-awsana
+Feature: Object Storage Has Allowed Network Access Measures Enforced
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that my organisation's data can only be accessed from allowed network IP addresses
+
+  #Rule: CHC2-SVD030 - protect cloud service network access by limiting access from the appropriate source network only
+
+    Background:
+      Given an AWS account is available
+      And a list with allowed and disallowed network segments is provided in config
+
+    @s-awsana-001
+    Scenario: Detect S3 Buckets Without Allowed Network Access Measures
+      Then every S3 bucket has a bucket policy restricting access to the allowed network segments and VPC endpoints
+
+    @s-awsana-002
+    Scenario: Verify a Bucket Policy Restricting Network Access Is Enforced as Written
+      Given an S3 bucket is created with a bucket policy denying access from outside the allowed network segments and VPC endpoints
+      Then the effective bucket policy restricts access to the allowed network segments and VPC endpoints
@@ -0,0 +1,350 @@
+package awsana
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cucumber/godog"
+
+	awsutil "github.com/citihub/probr-pack-storage/internal/aws"
+	"github.com/citihub/probr-pack-storage/internal/aws/bucketpolicy"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-pack-storage/internal/network"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+type scenarioState struct {
+	name                string
+	currentStep         string
+	audit               *audit.ScenarioAudit
+	probe               *audit.Probe
+	ctx                 context.Context
+	networkSegments     network.Segments
+	allowedVpcEndpoints []string
+	bucketName          string
+	buckets             []string
+}
+
+// ProbeStruct meets the interface allowing this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+// bucketResult records the evaluation of the policy of a bucket against the network segments
+type bucketResult struct {
+	BucketName             string
+	HasPolicy              bool
+	RestrictsNetworkAccess bool
+	Checks                 []bucketpolicy.NetworkCheck
+	Error                  string
+}
+
+// Probe meets the interface allowing this probe to be added to the ProbeStore
+var Probe probeStruct
+var scenario scenarioState       // Local container of scenario state
+var awsConnection connection.AWS // Provides functionality to interact with AWS
+
+func (scenario *scenarioState) anAWSAccountIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that the AWS account in the SDK configuration is available; "))
+
+	payload = struct {
+		Provider   string
+		Region     string
+		S3Endpoint string
+	}{
+		config.Vars.ServicePacks.Storage.Provider,
+		awsConnection.Region(),
+		awsutil.S3Endpoint(),
+	}
+
+	stepTrace.WriteString("Check that an endpoint is configured when running against an S3 compatible server; ")
+	if err = awsutil.RequireS3Endpoint(); err != nil {
+		return err
+	}
+
+	err = awsConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) aListWithAllowedAndDisallowedNetworkSegmentsIsProvidedInConfig() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Validate that allowed and disallowed network segments are provided in config, and that no disallowed segment overlaps an allowed segment; ")
+
+	scenario.networkSegments = network.ConfiguredSegments()
+	scenario.allowedVpcEndpoints = awsutil.AllowedVpcEndpoints()
+
+	if !(len(scenario.networkSegments.Allowed) > 0) || !(len(scenario.networkSegments.Disallowed) > 0) {
+		err = utils.ReformatError("The list of allowed and disallowed network segments has not been defined in config")
+	} else {
+		err = scenario.networkSegments.Validate()
+	}
+
+	//Audit log
+	payload = struct {
+		NetworkSegments     network.Segments
+		AllowedVpcEndpoints []string
+	}{
+		NetworkSegments:     scenario.networkSegments,
+		AllowedVpcEndpoints: scenario.allowedVpcEndpoints,
+	}
+
+	return err
+}
+
+func (scenario *scenarioState) everyS3BucketHasABucketPolicyRestrictingAccessToTheAllowedNetworkSegmentsAndVPCEndpoints() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("List the S3 buckets owned by the account; ")
+	bucketNames, listErr := awsConnection.ListBuckets()
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list S3 buckets: %v", listErr)
+		return err
+	}
+
+	stepTrace.WriteString("Evaluate the policy of each bucket for requests from each network segment and VPC endpoint; ")
+	results := make([]bucketResult, 0, len(bucketNames))
+	var flagged []string
+	for _, bucketName := range bucketNames {
+		result := scenario.evaluateBucket(bucketName)
+		if !result.RestrictsNetworkAccess {
+			flagged = append(flagged, bucketName)
+		}
+		results = append(results, result)
+	}
+
+	// Audit log
+	payload = struct {
+		Buckets []bucketResult
+	}{
+		Buckets: results,
+	}
+
+	if len(flagged) > 0 {
+		err = utils.ReformatError("%d of %d S3 buckets do not have a bucket policy restricting access to the allowed network segments: %s", len(flagged), len(bucketNames), strings.Join(flagged, ", "))
+	}
+	return err
+}
+
+func (scenario *scenarioState) anS3BucketIsCreatedWithABucketPolicyDenyingAccessFromOutsideTheAllowedNetworkSegmentsAndVPCEndpoints() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	bucketName := awsutil.BucketName()
+	policy := bucketpolicy.New(bucketpolicy.DenyOutsideNetwork(bucketName, scenario.networkSegments.Allowed, scenario.allowedVpcEndpoints)).String()
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		Policy     string
+	}{
+		BucketName: bucketName,
+		Policy:     policy,
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Create S3 bucket '%s'; ", bucketName))
+	createErr := awsConnection.CreateBucket(bucketName)
+	if createErr != nil {
+		err = utils.ReformatError("Failed to create S3 bucket '%s': %v", bucketName, createErr)
+		return err
+	}
+	scenario.buckets = append(scenario.buckets, bucketName)
+	scenario.bucketName = bucketName
+
+	stepTrace.WriteString("Set a bucket policy denying object requests from outside the allowed network segments and VPC endpoints; ")
+	policyErr := awsConnection.PutBucketPolicy(bucketName, policy)
+	if policyErr != nil {
+		err = utils.ReformatError("Failed to set policy of S3 bucket '%s': %v", bucketName, policyErr)
+	}
+	return err
+}
+
+func (scenario *scenarioState) theEffectiveBucketPolicyRestrictsAccessToTheAllowedNetworkSegmentsAndVPCEndpoints() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString(fmt.Sprintf("Read back the policy of S3 bucket '%s' and evaluate it for requests from each network segment and VPC endpoint; ", scenario.bucketName))
+	result := scenario.evaluateBucket(scenario.bucketName)
+
+	// Audit log
+	payload = struct {
+		Bucket bucketResult
+	}{
+		Bucket: result,
+	}
+
+	if result.Error != "" {
+		err = utils.ReformatError("Failed to evaluate policy of S3 bucket '%s': %s", scenario.bucketName, result.Error)
+	} else if !result.RestrictsNetworkAccess {
+		err = utils.ReformatError("Effective policy of S3 bucket '%s' does not restrict access to the allowed network segments", scenario.bucketName)
+	}
+	return err
+}
+
+// evaluateBucket reads the policy of a bucket and evaluates it offline for requests from each configured network source
+func (scenario *scenarioState) evaluateBucket(bucketName string) (result bucketResult) {
+	result.BucketName = bucketName
+
+	policy, policyErr := awsConnection.GetBucketPolicy(bucketName)
+	if policyErr != nil {
+		result.Error = policyErr.Error()
+		return
+	}
+	if policy == "" {
+		return
+	}
+	result.HasPolicy = true
+
+	document, parseErr := bucketpolicy.Parse(policy)
+	if parseErr != nil {
+		result.Error = parseErr.Error()
+		return
+	}
+
+	checks, checkErr := document.NetworkChecks(bucketName, scenario.networkSegments.Allowed, scenario.networkSegments.Disallowed, scenario.allowedVpcEndpoints)
+	if checkErr != nil {
+		result.Error = checkErr.Error()
+		return
+	}
+	result.Checks = checks
+	result.RestrictsNetworkAccess = bucketpolicy.RestrictsNetworkAccess(checks)
+	return
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.bucketName = ""
+	s.buckets = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "allowed_network_access"
+}
+
+// Path returns the probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "aws", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize aws connection
+		awsConnection = connection.NewAWSConnection(
+			context.Background(),
+			awsutil.S3Endpoint(),
+			awsutil.S3ForcePathStyle(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an AWS account is available$`, scenario.anAWSAccountIsAvailable)
+	ctx.Step(`^a list with allowed and disallowed network segments is provided in config$`, scenario.aListWithAllowedAndDisallowedNetworkSegmentsIsProvidedInConfig)
+
+	// Steps
+	ctx.Step(`^every S3 bucket has a bucket policy restricting access to the allowed network segments and VPC endpoints$`, scenario.everyS3BucketHasABucketPolicyRestrictingAccessToTheAllowedNetworkSegmentsAndVPCEndpoints)
+	ctx.Step(`^an S3 bucket is created with a bucket policy denying access from outside the allowed network segments and VPC endpoints$`, scenario.anS3BucketIsCreatedWithABucketPolicyDenyingAccessFromOutsideTheAllowedNetworkSegmentsAndVPCEndpoints)
+	ctx.Step(`^the effective bucket policy restricts access to the allowed network segments and VPC endpoints$`, scenario.theEffectiveBucketPolicyRestrictsAccessToTheAllowedNetworkSegmentsAndVPCEndpoints)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing S3 buckets used during tests")
+
+	for _, bucketName := range scenario.buckets {
+		log.Printf("[DEBUG] need to delete the S3 bucket: %s", bucketName)
+		err := awsConnection.DeleteBucket(bucketName)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the S3 bucket: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
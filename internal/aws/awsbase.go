@@ -0,0 +1,94 @@
+package aws
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/cucumber/godog"
+
+	"github.com/citihub/probr-pack-storage/internal/envvar"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// Supported values for the storage pack Provider which run the AWS probes
+const (
+	AWSProvider          = "AWS"          // Probes run against Amazon S3
+	S3CompatibleProvider = "S3Compatible" // Probes run against an S3 compatible server, such as MinIO or Ceph RGW, at the configured endpoint
+)
+
+//S3Compatible returns whether the probes run against an S3 compatible server rather than Amazon S3, when the storage pack Provider is S3Compatible.
+func S3Compatible() bool {
+	return config.Vars.ServicePacks.Storage.Provider == S3CompatibleProvider
+}
+
+//RequireS3Endpoint returns an error if the probes run against an S3 compatible server and no endpoint is configured, so that requests are not sent to Amazon S3 by mistake.
+func RequireS3Endpoint() error {
+	if S3Compatible() && S3Endpoint() == "" {
+		return utils.ReformatError("AWS_S3_ENDPOINT must be set for the %s provider", S3CompatibleProvider)
+	}
+	return nil
+}
+
+//RequireHTTPSEndpoint returns an error if a custom S3 endpoint is configured which does not use https, as requests over plain HTTP cannot then be told apart from requests over the configured endpoint.
+func RequireHTTPSEndpoint() error {
+	if S3Endpoint() != "" && !strings.HasPrefix(strings.ToLower(S3Endpoint()), "https://") {
+		return utils.ReformatError("AWS_S3_ENDPOINT must use https to verify that requests over plain HTTP are refused, but is '%s'", S3Endpoint())
+	}
+	return nil
+}
+
+//RequireAWS returns godog.ErrPending if the probes run against an S3 compatible server, so that steps which need AWS account level features, such as S3 Control and STS, are skipped.
+func RequireAWS() error {
+	if S3Compatible() {
+		return godog.ErrPending
+	}
+	return nil
+}
+
+//S3Endpoint returns a custom S3 endpoint URL, such as a LocalStack or MinIO server, set by the environment variable AWS_S3_ENDPOINT. When empty the regional AWS endpoint is resolved by the SDK.
+func S3Endpoint() string {
+	return envvar.GetOrDefault("AWS_S3_ENDPOINT", "")
+}
+
+//S3ForcePathStyle returns whether buckets are addressed in the URL path rather than as a subdomain, as most S3 stand-ins require. Defaults to true when a custom endpoint is set and may be set by the environment variable AWS_S3_FORCE_PATH_STYLE.
+func S3ForcePathStyle() bool {
+	defaultValue := strconv.FormatBool(S3Endpoint() != "")
+	forcePathStyle, err := strconv.ParseBool(envvar.GetOrDefault("AWS_S3_FORCE_PATH_STYLE", defaultValue))
+	if err != nil {
+		log.Printf("[ERROR] Unexpected value for AWS_S3_FORCE_PATH_STYLE: %v. Using '%s'", err, defaultValue)
+		forcePathStyle, _ = strconv.ParseBool(defaultValue)
+	}
+	return forcePathStyle
+}
+
+//ApprovedKMSKeys returns the KMS keys which may be used for default bucket encryption, as key ARNs, key IDs or alias names, set as a comma separated list by the environment variable AWS_APPROVED_KMS_KEYS. When empty any customer managed or AWS managed KMS key is accepted.
+func ApprovedKMSKeys() []string {
+	return envvar.SplitList(envvar.GetOrDefault("AWS_APPROVED_KMS_KEYS", ""))
+}
+
+//AllowedVpcEndpoints returns the IDs of the VPC endpoints through which buckets may be accessed, set as a comma separated list by the environment variable AWS_ALLOWED_VPC_ENDPOINTS.
+func AllowedVpcEndpoints() []string {
+	return envvar.SplitList(envvar.GetOrDefault("AWS_ALLOWED_VPC_ENDPOINTS", ""))
+}
+
+//RegulatedBuckets returns the names of the buckets holding regulated data, which may include '*' wildcards, set as a comma separated list by the environment variable AWS_REGULATED_BUCKETS. When empty every bucket is treated as regulated.
+func RegulatedBuckets() []string {
+	return envvar.SplitList(envvar.GetOrDefault("AWS_REGULATED_BUCKETS", ""))
+}
+
+//ObjectLockMinimumRetentionDays returns the minimum default Object Lock retention period for regulated buckets, defaults to 1 day and may be set by the environment variable AWS_OBJECT_LOCK_MINIMUM_RETENTION_DAYS.
+func ObjectLockMinimumRetentionDays() int {
+	days, err := strconv.Atoi(envvar.GetOrDefault("AWS_OBJECT_LOCK_MINIMUM_RETENTION_DAYS", "1"))
+	if err != nil || days < 1 {
+		log.Printf("[ERROR] Unexpected value for AWS_OBJECT_LOCK_MINIMUM_RETENTION_DAYS: %v. Using 1", err)
+		return 1
+	}
+	return days
+}
+
+//BucketName returns a new random name for a bucket created by a probe. S3 bucket names must be lower case and globally unique.
+func BucketName() string {
+	return "probr-" + strings.ToLower(utils.RandomString(10))
+}
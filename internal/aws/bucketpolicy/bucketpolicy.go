@@ -0,0 +1,592 @@
+// Package bucketpolicy parses S3 bucket policies and evaluates them offline against the requests made by the probes.
+// It predicts whether a request would be explicitly denied, allowed or implicitly denied by the bucket policy alone,
+// so that policy controls can be checked without sending requests to AWS.
+//
+// Only the subset of the policy language used by bucket policies is supported: Principal, Action and Resource
+// elements with wildcards, and the String, Numeric, Bool, IpAddress and Null condition operators, including their
+// IfExists forms. NotPrincipal, NotAction and NotResource statements never match. Statements using any other
+// condition operator, such as ArnLike or DateLessThan, are skipped by Parse and recorded in Document.Unsupported.
+package bucketpolicy
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/citihub/probr-pack-storage/internal/network"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// Decisions which may be returned by Evaluate
+const (
+	ExplicitDeny = "ExplicitDeny" // A Deny statement matches the request
+	Allow        = "Allow"        // An Allow statement and no Deny statement matches the request
+	ImplicitDeny = "ImplicitDeny" // No statement matches the request
+)
+
+// Anonymous is the principal of an unauthenticated request. Only statements with a '*' principal apply to it,
+// so a statement which applies to Anonymous applies to every caller.
+const Anonymous = "*"
+
+// Condition keys used by the probes
+const (
+	SecureTransportKey      = "aws:SecureTransport"
+	SourceIPKey             = "aws:SourceIp"
+	SourceVpceKey           = "aws:SourceVpce"
+	ServerSideEncryptionKey = "s3:x-amz-server-side-encryption"
+)
+
+// SSEKMS is the value of the server side encryption header for SSE-KMS encryption
+const SSEKMS = "aws:kms"
+
+// UnlistedVpcEndpoint is used as the source of requests through a VPC endpoint which is not allowed
+const UnlistedVpcEndpoint = "vpce-00000000000000000"
+
+// Version is the current policy language version
+const Version = "2012-10-17"
+
+// Document is an S3 bucket policy
+type Document struct {
+	Version   string
+	ID        string     `json:"Id,omitempty"`
+	Statement Statements `json:"Statement"`
+
+	// Unsupported holds the statements skipped by Parse because they use an unsupported condition operator
+	Unsupported Statements `json:"-"`
+}
+
+// Statements holds the statements of a policy, which may be written as a single object or as an array
+type Statements []Statement
+
+// Statement is a single statement of a bucket policy
+type Statement struct {
+	Sid          string     `json:",omitempty"`
+	Effect       string     `json:"Effect"`
+	Principal    Principal  `json:",omitempty"`
+	NotPrincipal Principal  `json:",omitempty"`
+	Action       Values     `json:",omitempty"`
+	NotAction    Values     `json:",omitempty"`
+	Resource     Values     `json:",omitempty"`
+	NotResource  Values     `json:",omitempty"`
+	Condition    Conditions `json:",omitempty"`
+}
+
+// Principal is the principal element of a statement keyed by principal type, such as 'AWS' or 'Service'.
+// A '*' principal is held under the key '*'.
+type Principal map[string]Values
+
+// Values holds the values of a policy element, which may be written as a single string or as an array
+type Values []string
+
+// Conditions maps condition operators to the condition keys and values they test
+type Conditions map[string]map[string]Values
+
+// Request is a request to evaluate against a bucket policy
+type Request struct {
+	Principal string            // The principal ARN making the request, or Anonymous
+	Action    string            // e.g. s3:GetObject
+	Resource  string            // e.g. arn:aws:s3:::bucket/key
+	Context   map[string]string // Condition key values of the request, e.g. aws:SecureTransport
+}
+
+// Parse reads a bucket policy document. Statements with a condition operator that cannot be evaluated are
+// skipped rather than failing the whole policy, and are recorded in Unsupported.
+func Parse(policy string) (*Document, error) {
+	var d Document
+	if err := json.Unmarshal([]byte(policy), &d); err != nil {
+		return nil, utils.ReformatError("Failed to parse bucket policy: %v", err)
+	}
+	var supported Statements
+	for _, statement := range d.Statement {
+		if statement.Effect != "Allow" && statement.Effect != "Deny" {
+			return nil, utils.ReformatError("Unexpected value provided for Effect: '%s' Expected values: ['Allow', 'Deny']", statement.Effect)
+		}
+		if err := statement.Condition.validate(); err != nil {
+			log.Printf("[WARN] Skipping bucket policy statement '%s': %v", statement.Sid, err)
+			d.Unsupported = append(d.Unsupported, statement)
+			continue
+		}
+		supported = append(supported, statement)
+	}
+	d.Statement = supported
+	return &d, nil
+}
+
+// New returns a bucket policy containing the given statements
+func New(statements ...Statement) *Document {
+	return &Document{Version: Version, Statement: statements}
+}
+
+// String returns the policy as JSON, as accepted by PutBucketPolicy
+func (d *Document) String() string {
+	b, _ := json.Marshal(d)
+	return string(b)
+}
+
+// BucketARN returns the ARN of a bucket
+func BucketARN(bucketName string) string {
+	return "arn:aws:s3:::" + bucketName
+}
+
+// ObjectARN returns the ARN of an object within a bucket
+func ObjectARN(bucketName, key string) string {
+	return BucketARN(bucketName) + "/" + key
+}
+
+// DenyInsecureTransport returns a statement denying all requests to a bucket and its objects which are not sent over TLS
+func DenyInsecureTransport(bucketName string) Statement {
+	return Statement{
+		Sid:       "DenyInsecureTransport",
+		Effect:    "Deny",
+		Principal: Principal{Anonymous: Values{Anonymous}},
+		Action:    Values{"s3:*"},
+		Resource:  Values{BucketARN(bucketName), ObjectARN(bucketName, "*")},
+		Condition: Conditions{"Bool": {SecureTransportKey: Values{"false"}}},
+	}
+}
+
+// DenyUploadsWithoutKMSEncryption returns a statement denying object uploads to a bucket which do not request SSE-KMS encryption
+func DenyUploadsWithoutKMSEncryption(bucketName string) Statement {
+	return Statement{
+		Sid:       "DenyUploadsWithoutKMSEncryption",
+		Effect:    "Deny",
+		Principal: Principal{Anonymous: Values{Anonymous}},
+		Action:    Values{"s3:PutObject"},
+		Resource:  Values{ObjectARN(bucketName, "*")},
+		Condition: Conditions{"StringNotEquals": {ServerSideEncryptionKey: Values{SSEKMS}}},
+	}
+}
+
+// AllowPublicRead returns a statement allowing anyone to read the objects in a bucket
+func AllowPublicRead(bucketName string) Statement {
+	return Statement{
+		Sid:       "AllowPublicRead",
+		Effect:    "Allow",
+		Principal: Principal{Anonymous: Values{Anonymous}},
+		Action:    Values{"s3:GetObject"},
+		Resource:  Values{ObjectARN(bucketName, "*")},
+	}
+}
+
+// DenyOutsideNetwork returns a statement denying requests to read, write and delete the objects in a bucket, unless they are
+// sent from one of the allowed network segments or through one of the allowed VPC endpoints. Requests to configure the bucket
+// are not denied, so that it can still be managed and deleted from other networks.
+func DenyOutsideNetwork(bucketName string, allowedSegments, allowedVpcEndpoints []string) Statement {
+	conditions := Conditions{"NotIpAddress": {SourceIPKey: Values(allowedSegments)}}
+	if len(allowedVpcEndpoints) > 0 {
+		conditions["StringNotEquals"] = map[string]Values{SourceVpceKey: Values(allowedVpcEndpoints)}
+	}
+	return Statement{
+		Sid:       "DenyOutsideNetwork",
+		Effect:    "Deny",
+		Principal: Principal{Anonymous: Values{Anonymous}},
+		Action:    Values{"s3:GetObject*", "s3:PutObject*", "s3:DeleteObject*"},
+		Resource:  Values{ObjectARN(bucketName, "*")},
+		Condition: conditions,
+	}
+}
+
+// Evaluate returns the decision of the policy for a request: ExplicitDeny, Allow or ImplicitDeny
+func (d *Document) Evaluate(request Request) string {
+	decision := ImplicitDeny
+	for _, statement := range d.Statement {
+		if !statement.Matches(request) {
+			continue
+		}
+		if statement.Effect == "Deny" {
+			return ExplicitDeny
+		}
+		decision = Allow
+	}
+	return decision
+}
+
+// DeniesInsecureTransport returns whether the policy denies reading and writing objects in the bucket over plain HTTP for every caller
+func (d *Document) DeniesInsecureTransport(bucketName string) bool {
+	for _, action := range []string{"s3:GetObject", "s3:PutObject"} {
+		request := Request{
+			Principal: Anonymous,
+			Action:    action,
+			Resource:  ObjectARN(bucketName, "probr"),
+			Context:   map[string]string{SecureTransportKey: "false"},
+		}
+		if d.Evaluate(request) != ExplicitDeny {
+			return false
+		}
+	}
+	return true
+}
+
+// DeniesUploadsWithoutKMSEncryption returns whether the policy denies object uploads to the bucket, for every caller,
+// which either omit the server side encryption header or request encryption other than SSE-KMS
+func (d *Document) DeniesUploadsWithoutKMSEncryption(bucketName string) bool {
+	for _, context := range []map[string]string{{}, {ServerSideEncryptionKey: "AES256"}} {
+		request := Request{
+			Principal: Anonymous,
+			Action:    "s3:PutObject",
+			Resource:  ObjectARN(bucketName, "probr"),
+			Context:   context,
+		}
+		if d.Evaluate(request) != ExplicitDeny {
+			return false
+		}
+	}
+	return true
+}
+
+// publicActions are the actions checked by AllowsPublicAccess
+var publicActions = []string{"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:ListBucket", "s3:GetBucketAcl", "s3:PutBucketPolicy"}
+
+// AllowsPublicAccess returns whether the policy allows an anonymous caller to read, write or list the bucket or its objects.
+// As in S3, a statement with a '*' principal is not public if its conditions restrict the caller, for example by source IP address,
+// because the condition keys are absent from the anonymous requests evaluated here.
+func (d *Document) AllowsPublicAccess(bucketName string) bool {
+	for _, action := range publicActions {
+		resource := ObjectARN(bucketName, "probr")
+		if action == "s3:ListBucket" || action == "s3:GetBucketAcl" || action == "s3:PutBucketPolicy" {
+			resource = BucketARN(bucketName)
+		}
+		request := Request{
+			Principal: Anonymous,
+			Action:    action,
+			Resource:  resource,
+		}
+		if d.Evaluate(request) == Allow {
+			return true
+		}
+	}
+	return false
+}
+
+// NetworkCheck records whether the policy denies requests to read and write objects from one network source as expected
+type NetworkCheck struct {
+	Source         string // The source IP address or VPC endpoint ID of the requests
+	Segment        string // The configured network segment or VPC endpoint the source represents
+	ShouldBeDenied bool
+	Denied         bool // Whether every request from the source is explicitly denied
+	PartlyDenied   bool // Whether some but not all requests from the source are explicitly denied
+	Passed         bool
+}
+
+// NetworkChecks evaluates requests to read and write objects in the bucket from each network segment and VPC endpoint, for every caller.
+// Requests from allowed segments and through allowed VPC endpoints must not be denied. Requests from disallowed segments, and through
+// a VPC endpoint which is not allowed, must be explicitly denied. An error is returned if a disallowed segment overlaps an allowed segment.
+func (d *Document) NetworkChecks(bucketName string, allowedSegments, disallowedSegments, allowedVpcEndpoints []string) ([]NetworkCheck, error) {
+	segments := network.Segments{Allowed: allowedSegments, Disallowed: disallowedSegments}
+	if err := segments.Validate(); err != nil {
+		return nil, err
+	}
+	var checks []NetworkCheck
+	addIPChecks := func(segments []string, shouldBeDenied bool) error {
+		for _, segment := range segments {
+			address, err := network.SampleAddress(segment)
+			if err != nil {
+				return err
+			}
+			checks = append(checks, d.networkCheck(bucketName, address, segment, map[string]string{SourceIPKey: address}, shouldBeDenied))
+		}
+		return nil
+	}
+	if err := addIPChecks(allowedSegments, false); err != nil {
+		return nil, err
+	}
+	if err := addIPChecks(disallowedSegments, true); err != nil {
+		return nil, err
+	}
+	for _, vpce := range allowedVpcEndpoints {
+		checks = append(checks, d.networkCheck(bucketName, vpce, vpce, map[string]string{SourceVpceKey: vpce}, false))
+	}
+	checks = append(checks, d.networkCheck(bucketName, UnlistedVpcEndpoint, "unlisted VPC endpoint", map[string]string{SourceVpceKey: UnlistedVpcEndpoint}, true))
+	return checks, nil
+}
+
+// RestrictsNetworkAccess returns whether every network check passed
+func RestrictsNetworkAccess(checks []NetworkCheck) bool {
+	for _, check := range checks {
+		if !check.Passed {
+			return false
+		}
+	}
+	return len(checks) > 0
+}
+
+func (d *Document) networkCheck(bucketName, source, segment string, context map[string]string, shouldBeDenied bool) NetworkCheck {
+	denied := 0
+	actions := []string{"s3:GetObject", "s3:PutObject"}
+	for _, action := range actions {
+		request := Request{
+			Principal: Anonymous,
+			Action:    action,
+			Resource:  ObjectARN(bucketName, "probr"),
+			Context:   context,
+		}
+		if d.Evaluate(request) == ExplicitDeny {
+			denied++
+		}
+	}
+	check := NetworkCheck{
+		Source:         source,
+		Segment:        segment,
+		ShouldBeDenied: shouldBeDenied,
+		Denied:         denied == len(actions),
+		PartlyDenied:   denied > 0 && denied < len(actions),
+	}
+	check.Passed = (shouldBeDenied && check.Denied) || (!shouldBeDenied && denied == 0)
+	return check
+}
+
+// Matches returns whether the statement applies to a request, regardless of its effect
+func (s Statement) Matches(request Request) bool {
+	if len(s.NotPrincipal) > 0 || len(s.NotAction) > 0 || len(s.NotResource) > 0 {
+		return false
+	}
+	return s.Principal.Matches(request.Principal) &&
+		s.Action.matchesIgnoreCase(request.Action) &&
+		s.Resource.matches(request.Resource) &&
+		s.Condition.Matches(request.Context)
+}
+
+// IsAnonymous returns whether the principal is '*', either directly or as an AWS principal
+func (p Principal) IsAnonymous() bool {
+	return p[Anonymous].contains(Anonymous) || p["AWS"].contains(Anonymous)
+}
+
+// Matches returns whether the principal includes the principal of a request
+func (p Principal) Matches(principal string) bool {
+	if p.IsAnonymous() {
+		return true
+	}
+	if principal == Anonymous {
+		return false
+	}
+	for _, values := range p {
+		if values.matches(principal) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalJSON reads a principal written either as '*' or as an object
+func (p *Principal) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		if wildcard != Anonymous {
+			return utils.ReformatError("Unexpected value provided for Principal: '%s' Expected values: ['*', object]", wildcard)
+		}
+		*p = Principal{Anonymous: Values{Anonymous}}
+		return nil
+	}
+	var principals map[string]Values
+	if err := json.Unmarshal(data, &principals); err != nil {
+		return err
+	}
+	*p = principals
+	return nil
+}
+
+// MarshalJSON writes a '*' principal as a string
+func (p Principal) MarshalJSON() ([]byte, error) {
+	if len(p) == 1 && p[Anonymous].contains(Anonymous) {
+		return json.Marshal(Anonymous)
+	}
+	return json.Marshal(map[string]Values(p))
+}
+
+// UnmarshalJSON reads values written either as a single string or as an array
+func (v *Values) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*v = Values{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*v = multiple
+	return nil
+}
+
+// UnmarshalJSON reads statements written either as a single object or as an array
+func (s *Statements) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var single Statement
+		if err := json.Unmarshal(data, &single); err != nil {
+			return err
+		}
+		*s = Statements{single}
+		return nil
+	}
+	var multiple []Statement
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*s = multiple
+	return nil
+}
+
+func (v Values) contains(value string) bool {
+	for _, item := range v {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (v Values) matches(value string) bool {
+	for _, pattern := range v {
+		if wildcardMatch(pattern, value, false) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v Values) matchesIgnoreCase(value string) bool {
+	for _, pattern := range v {
+		if wildcardMatch(pattern, value, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns whether every condition is satisfied by the condition key values of a request
+func (c Conditions) Matches(context map[string]string) bool {
+	for operator, keys := range c {
+		for key, values := range keys {
+			if !conditionMatches(operator, lookupKey(context, key), values) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validate returns an error if any condition operator is unsupported
+func (c Conditions) validate() error {
+	for operator := range c {
+		if _, _, err := parseOperator(operator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupKey returns the value of a condition key, which are case insensitive
+func lookupKey(context map[string]string, key string) *string {
+	for k, v := range context {
+		if strings.EqualFold(k, key) {
+			value := v
+			return &value
+		}
+	}
+	return nil
+}
+
+var negatedOperators = map[string]bool{
+	"StringNotEquals":           true,
+	"StringNotEqualsIgnoreCase": true,
+	"StringNotLike":             true,
+	"NumericNotEquals":          true,
+	"NotIpAddress":              true,
+}
+
+var operators = map[string]func(value, pattern string) bool{
+	"StringEquals":              func(value, pattern string) bool { return value == pattern },
+	"StringNotEquals":           func(value, pattern string) bool { return value == pattern },
+	"StringEqualsIgnoreCase":    strings.EqualFold,
+	"StringNotEqualsIgnoreCase": strings.EqualFold,
+	"StringLike":                func(value, pattern string) bool { return wildcardMatch(pattern, value, false) },
+	"StringNotLike":             func(value, pattern string) bool { return wildcardMatch(pattern, value, false) },
+	"Bool":                      strings.EqualFold,
+	"NumericEquals":             numeric(func(a, b float64) bool { return a == b }),
+	"NumericNotEquals":          numeric(func(a, b float64) bool { return a == b }),
+	"NumericLessThan":           numeric(func(a, b float64) bool { return a < b }),
+	"NumericLessThanEquals":     numeric(func(a, b float64) bool { return a <= b }),
+	"NumericGreaterThan":        numeric(func(a, b float64) bool { return a > b }),
+	"NumericGreaterThanEquals":  numeric(func(a, b float64) bool { return a >= b }),
+	"IpAddress":                 ipAddress,
+	"NotIpAddress":              ipAddress,
+}
+
+// parseOperator splits a condition operator into its base operator and whether it has the IfExists suffix.
+// The ForAnyValue and ForAllValues set qualifiers are accepted and treated as the base operator, as the
+// probes only evaluate single valued keys.
+func parseOperator(operator string) (base string, ifExists bool, err error) {
+	base = operator
+	if i := strings.Index(base, ":"); i >= 0 {
+		qualifier := base[:i]
+		if qualifier != "ForAnyValue" && qualifier != "ForAllValues" {
+			return "", false, utils.ReformatError("Unsupported condition operator qualifier: '%s'", qualifier)
+		}
+		base = base[i+1:]
+	}
+	if strings.HasSuffix(base, "IfExists") {
+		base = strings.TrimSuffix(base, "IfExists")
+		ifExists = true
+	}
+	if _, ok := operators[base]; !ok && base != "Null" {
+		return "", false, utils.ReformatError("Unsupported condition operator: '%s'", operator)
+	}
+	return base, ifExists, nil
+}
+
+func conditionMatches(operator string, value *string, patterns Values) bool {
+	base, ifExists, err := parseOperator(operator)
+	if err != nil {
+		return false
+	}
+	if base == "Null" {
+		// Null:true matches when the key is absent, Null:false when it is present
+		return patterns.contains(strconv.FormatBool(value == nil))
+	}
+	if value == nil {
+		return ifExists || negatedOperators[base]
+	}
+	compare := operators[base]
+	for _, pattern := range patterns {
+		if compare(*value, pattern) {
+			return !negatedOperators[base]
+		}
+	}
+	return negatedOperators[base]
+}
+
+func numeric(compare func(a, b float64) bool) func(value, pattern string) bool {
+	return func(value, pattern string) bool {
+		a, errA := strconv.ParseFloat(value, 64)
+		b, errB := strconv.ParseFloat(pattern, 64)
+		return errA == nil && errB == nil && compare(a, b)
+	}
+}
+
+func ipAddress(value, pattern string) bool {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return false
+	}
+	if !strings.Contains(pattern, "/") {
+		return ip.Equal(net.ParseIP(pattern))
+	}
+	_, network, err := net.ParseCIDR(pattern)
+	return err == nil && network.Contains(ip)
+}
+
+// wildcardMatch matches a value against a pattern in which '*' matches any sequence of characters and '?' any single character
+func wildcardMatch(pattern, value string, ignoreCase bool) bool {
+	expression := regexp.QuoteMeta(pattern)
+	expression = strings.Replace(expression, `\*`, ".*", -1)
+	expression = strings.Replace(expression, `\?`, ".", -1)
+	if ignoreCase {
+		expression = "(?i)" + expression
+	}
+	matched, err := regexp.MatchString("^"+expression+"$", value)
+	return err == nil && matched
+}
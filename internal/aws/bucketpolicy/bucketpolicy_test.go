@@ -0,0 +1,325 @@
+package bucketpolicy
+
+import (
+	"testing"
+
+	"github.com/citihub/probr-pack-storage/internal/network"
+)
+
+const secureTransportPolicy = `{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Sid": "DenyInsecureTransport",
+		"Effect": "Deny",
+		"Principal": "*",
+		"Action": "s3:*",
+		"Resource": ["arn:aws:s3:::probr-bucket", "arn:aws:s3:::probr-bucket/*"],
+		"Condition": {"Bool": {"aws:SecureTransport": "false"}}
+	}]
+}`
+
+const allowReadPolicy = `{
+	"Version": "2012-10-17",
+	"Statement": {
+		"Effect": "Allow",
+		"Principal": {"AWS": ["arn:aws:iam::111122223333:root"]},
+		"Action": ["s3:GetObject"],
+		"Resource": "arn:aws:s3:::probr-bucket/*"
+	}
+}`
+
+const targetedDenyPolicy = `{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Effect": "Deny",
+		"Principal": {"AWS": "arn:aws:iam::111122223333:user/probr"},
+		"Action": "s3:*",
+		"Resource": "arn:aws:s3:::probr-bucket/*",
+		"Condition": {"Bool": {"aws:SecureTransport": "false"}}
+	}]
+}`
+
+const getOnlyDenyPolicy = `{
+	"Version": "2012-10-17",
+	"Statement": [{
+		"Effect": "Deny",
+		"Principal": {"AWS": "*"},
+		"Action": "s3:Get*",
+		"Resource": "arn:aws:s3:::probr-bucket/*",
+		"Condition": {"BoolIfExists": {"aws:SecureTransport": "false"}}
+	}]
+}`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         string
+		wantErr        bool
+		wantStatements int
+	}{
+		{"TestCase1_StatementArray_ShouldParse", secureTransportPolicy, false, 1},
+		{"TestCase2_SingleStatementObject_ShouldParse", allowReadPolicy, false, 1},
+		{"TestCase3_InvalidJSON_ShouldReturnError", `{"Statement": [`, true, 0},
+		{"TestCase4_UnknownEffect_ShouldReturnError", `{"Statement": [{"Effect": "Audit", "Action": "s3:*"}]}`, true, 0},
+		{"TestCase5_UnsupportedOperator_ShouldSkipStatement", `{"Statement": [{"Effect": "Deny", "Action": "s3:*", "Condition": {"DateLessThan": {"aws:CurrentTime": "2020-01-01T00:00:00Z"}}}]}`, false, 0},
+		{"TestCase6_ArnLikeAlongsideSecureTransport_ShouldKeepSupportedStatement", `{"Statement": [
+			{"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::probr-bucket/*", "Condition": {"ArnLike": {"aws:SourceArn": "arn:aws:cloudfront::*"}}},
+			{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::probr-bucket/*", "Condition": {"Bool": {"aws:SecureTransport": "false"}}}
+		]}`, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Parse(tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && len(d.Statement) != tt.wantStatements {
+				t.Errorf("Parse() returned %d statements, want %d", len(d.Statement), tt.wantStatements)
+			}
+		})
+	}
+}
+
+func TestParseSkipsUnsupportedStatements(t *testing.T) {
+	policy := `{"Statement": [
+		{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::probr-bucket/*", "Condition": {"ArnEquals": {"aws:SourceArn": "arn:aws:iam::111122223333:role/probr"}}},
+		{"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::probr-bucket/*", "Condition": {"Bool": {"aws:SecureTransport": "false"}}}
+	]}`
+	d, err := Parse(policy)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(d.Unsupported) != 1 {
+		t.Errorf("Parse() recorded %d unsupported statements, want 1", len(d.Unsupported))
+	}
+	if !d.DeniesInsecureTransport("probr-bucket") {
+		t.Errorf("DeniesInsecureTransport() = false, want true")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	insecure := map[string]string{SecureTransportKey: "false"}
+	secure := map[string]string{SecureTransportKey: "true"}
+	tests := []struct {
+		name         string
+		policy       string
+		request      Request
+		wantDecision string
+	}{
+		{"TestCase1_InsecureRequest_ShouldBeExplicitlyDenied", secureTransportPolicy,
+			Request{Anonymous, "s3:GetObject", "arn:aws:s3:::probr-bucket/key", insecure}, ExplicitDeny},
+		{"TestCase2_SecureRequest_ShouldBeImplicitlyDenied", secureTransportPolicy,
+			Request{Anonymous, "s3:GetObject", "arn:aws:s3:::probr-bucket/key", secure}, ImplicitDeny},
+		{"TestCase3_OtherBucket_ShouldBeImplicitlyDenied", secureTransportPolicy,
+			Request{Anonymous, "s3:GetObject", "arn:aws:s3:::other-bucket/key", insecure}, ImplicitDeny},
+		{"TestCase4_AllowedPrincipal_ShouldBeAllowed", allowReadPolicy,
+			Request{"arn:aws:iam::111122223333:root", "s3:GetObject", "arn:aws:s3:::probr-bucket/key", secure}, Allow},
+		{"TestCase5_AnonymousPrincipal_ShouldBeImplicitlyDenied", allowReadPolicy,
+			Request{Anonymous, "s3:GetObject", "arn:aws:s3:::probr-bucket/key", secure}, ImplicitDeny},
+		{"TestCase6_ActionCaseInsensitive_ShouldBeAllowed", allowReadPolicy,
+			Request{"arn:aws:iam::111122223333:root", "S3:getobject", "arn:aws:s3:::probr-bucket/key", secure}, Allow},
+		{"TestCase7_MissingKeyWithIfExists_ShouldBeExplicitlyDenied", getOnlyDenyPolicy,
+			Request{Anonymous, "s3:GetObject", "arn:aws:s3:::probr-bucket/key", nil}, ExplicitDeny},
+		{"TestCase8_MissingKeyWithoutIfExists_ShouldBeImplicitlyDenied", secureTransportPolicy,
+			Request{Anonymous, "s3:GetObject", "arn:aws:s3:::probr-bucket/key", nil}, ImplicitDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Parse(tt.policy)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if decision := d.Evaluate(tt.request); decision != tt.wantDecision {
+				t.Errorf("Evaluate() = %s, want %s", decision, tt.wantDecision)
+			}
+		})
+	}
+}
+
+func TestConditionOperators(t *testing.T) {
+	tests := []struct {
+		name      string
+		operator  string
+		value     *string
+		patterns  Values
+		wantMatch bool
+	}{
+		{"TestCase1_StringEquals_ShouldMatch", "StringEquals", strPtr("aws:kms"), Values{"AES256", "aws:kms"}, true},
+		{"TestCase2_StringNotEquals_ShouldNotMatchListedValue", "StringNotEquals", strPtr("aws:kms"), Values{"aws:kms"}, false},
+		{"TestCase3_StringNotEqualsMissingKey_ShouldMatch", "StringNotEquals", nil, Values{"aws:kms"}, true},
+		{"TestCase4_StringLike_ShouldMatchWildcard", "StringLike", strPtr("vpce-0123abcd"), Values{"vpce-*"}, true},
+		{"TestCase5_IpAddressInRange_ShouldMatch", "IpAddress", strPtr("10.0.1.5"), Values{"10.0.0.0/16"}, true},
+		{"TestCase6_NotIpAddressInRange_ShouldNotMatch", "NotIpAddress", strPtr("10.0.1.5"), Values{"10.0.0.0/16"}, false},
+		{"TestCase7_IpAddressSingleHost_ShouldMatch", "IpAddress", strPtr("192.168.1.1"), Values{"192.168.1.1"}, true},
+		{"TestCase8_NullTrueMissingKey_ShouldMatch", "Null", nil, Values{"true"}, true},
+		{"TestCase9_NullTruePresentKey_ShouldNotMatch", "Null", strPtr("aws:kms"), Values{"true"}, false},
+		{"TestCase10_NumericLessThan_ShouldMatch", "NumericLessThan", strPtr("5"), Values{"30"}, true},
+		{"TestCase11_ForAnyValueStringEquals_ShouldMatch", "ForAnyValue:StringEquals", strPtr("a"), Values{"a"}, true},
+		{"TestCase12_StringEqualsMissingKey_ShouldNotMatch", "StringEquals", nil, Values{"aws:kms"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if match := conditionMatches(tt.operator, tt.value, tt.patterns); match != tt.wantMatch {
+				t.Errorf("conditionMatches(%s) = %v, want %v", tt.operator, match, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestDeniesInsecureTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		wantDenies bool
+	}{
+		{"TestCase1_DenyAllActionsForAnyone_ShouldDeny", secureTransportPolicy, true},
+		{"TestCase2_NoDenyStatement_ShouldNotDeny", allowReadPolicy, false},
+		{"TestCase3_DenyForSinglePrincipal_ShouldNotDeny", targetedDenyPolicy, false},
+		{"TestCase4_DenyReadsOnly_ShouldNotDeny", getOnlyDenyPolicy, false},
+		{"TestCase5_GeneratedPolicy_ShouldDeny", New(DenyInsecureTransport("probr-bucket")).String(), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Parse(tt.policy)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if denies := d.DeniesInsecureTransport("probr-bucket"); denies != tt.wantDenies {
+				t.Errorf("DeniesInsecureTransport() = %v, want %v", denies, tt.wantDenies)
+			}
+		})
+	}
+}
+
+func TestDeniesUploadsWithoutKMSEncryption(t *testing.T) {
+	nullConditionPolicy := `{
+		"Version": "2012-10-17",
+		"Statement": [{
+			"Effect": "Deny",
+			"Principal": "*",
+			"Action": "s3:PutObject",
+			"Resource": "arn:aws:s3:::probr-bucket/*",
+			"Condition": {"Null": {"s3:x-amz-server-side-encryption": "true"}}
+		}]
+	}`
+	tests := []struct {
+		name       string
+		policy     string
+		wantDenies bool
+	}{
+		{"TestCase1_GeneratedPolicy_ShouldDeny", New(DenyUploadsWithoutKMSEncryption("probr-bucket")).String(), true},
+		{"TestCase2_DenyMissingHeaderOnly_ShouldNotDeny", nullConditionPolicy, false},
+		{"TestCase3_SecureTransportOnly_ShouldNotDeny", secureTransportPolicy, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Parse(tt.policy)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if denies := d.DeniesUploadsWithoutKMSEncryption("probr-bucket"); denies != tt.wantDenies {
+				t.Errorf("DeniesUploadsWithoutKMSEncryption() = %v, want %v", denies, tt.wantDenies)
+			}
+		})
+	}
+}
+
+func TestAllowsPublicAccess(t *testing.T) {
+	restrictedPolicy := `{
+		"Version": "2012-10-17",
+		"Statement": [{
+			"Effect": "Allow",
+			"Principal": "*",
+			"Action": "s3:GetObject",
+			"Resource": "arn:aws:s3:::probr-bucket/*",
+			"Condition": {"IpAddress": {"aws:SourceIp": "10.0.0.0/8"}}
+		}]
+	}`
+	publicListPolicy := `{
+		"Version": "2012-10-17",
+		"Statement": [{
+			"Effect": "Allow",
+			"Principal": {"AWS": "*"},
+			"Action": "s3:List*",
+			"Resource": "arn:aws:s3:::probr-bucket"
+		}]
+	}`
+	tests := []struct {
+		name       string
+		policy     string
+		wantPublic bool
+	}{
+		{"TestCase1_AllowPublicRead_ShouldBePublic", New(AllowPublicRead("probr-bucket")).String(), true},
+		{"TestCase2_AllowPublicList_ShouldBePublic", publicListPolicy, true},
+		{"TestCase3_AllowFromSourceIP_ShouldNotBePublic", restrictedPolicy, false},
+		{"TestCase4_AllowNamedPrincipal_ShouldNotBePublic", allowReadPolicy, false},
+		{"TestCase5_DenyOnly_ShouldNotBePublic", secureTransportPolicy, false},
+		{"TestCase6_AllowOverriddenByDeny_ShouldNotBePublic", New(AllowPublicRead("probr-bucket"), Statement{
+			Effect: "Deny", Principal: Principal{Anonymous: Values{Anonymous}}, Action: Values{"s3:*"}, Resource: Values{"arn:aws:s3:::probr-bucket*"},
+		}).String(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Parse(tt.policy)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if public := d.AllowsPublicAccess("probr-bucket"); public != tt.wantPublic {
+				t.Errorf("AllowsPublicAccess() = %v, want %v", public, tt.wantPublic)
+			}
+		})
+	}
+}
+
+func TestNetworkChecks(t *testing.T) {
+	segments := network.ConfiguredSegments()
+	if err := segments.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	allowed, disallowed := segments.Allowed, segments.Disallowed
+	vpces := []string{"vpce-1a2b3c4d"}
+	sourceIPOnlyPolicy := New(Statement{
+		Effect:    "Deny",
+		Principal: Principal{Anonymous: Values{Anonymous}},
+		Action:    Values{"s3:*"},
+		Resource:  Values{BucketARN("probr-bucket"), ObjectARN("probr-bucket", "*")},
+		Condition: Conditions{"NotIpAddress": {SourceIPKey: Values(allowed)}},
+	}).String()
+	tests := []struct {
+		name         string
+		policy       string
+		vpces        []string
+		wantRestrict bool
+	}{
+		{"TestCase1_GeneratedPolicy_ShouldRestrict", New(DenyOutsideNetwork("probr-bucket", allowed, vpces)).String(), vpces, true},
+		{"TestCase2_SourceIPOnlyWithoutEndpoints_ShouldRestrict", sourceIPOnlyPolicy, nil, true},
+		{"TestCase3_SourceIPOnlyWithAllowedEndpoint_ShouldNotRestrict", sourceIPOnlyPolicy, vpces, false},
+		{"TestCase4_NoNetworkConditions_ShouldNotRestrict", secureTransportPolicy, nil, false},
+		{"TestCase5_DisallowedSegmentAllowed_ShouldNotRestrict", New(DenyOutsideNetwork("probr-bucket", append(append([]string{}, allowed...), disallowed[0]), vpces)).String(), vpces, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Parse(tt.policy)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			checks, err := d.NetworkChecks("probr-bucket", allowed, disallowed, tt.vpces)
+			if err != nil {
+				t.Fatalf("NetworkChecks() error = %v", err)
+			}
+			if restricts := RestrictsNetworkAccess(checks); restricts != tt.wantRestrict {
+				t.Errorf("RestrictsNetworkAccess() = %v, want %v: %+v", restricts, tt.wantRestrict, checks)
+			}
+		})
+	}
+
+	overlapping := New(DenyOutsideNetwork("probr-bucket", []string{"219.79.19.0/24"}, nil))
+	if _, err := overlapping.NetworkChecks("probr-bucket", []string{"219.79.19.0/24"}, []string{"219.79.19.1"}, nil); err == nil {
+		t.Errorf("NetworkChecks() with a disallowed segment inside an allowed segment returned no error")
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,40 @@
+@s-awsear
+Feature: Object Storage Encryption at Rest
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that my organisation is protected against data leakage due to misconfiguration
+
+    Background:
+      Given an AWS account is available
+
+    @s-awsear-001
+    Scenario: Detect S3 Buckets Without Default Encryption Using an Approved KMS Key
+
+      Security Standard References:
+        - CHC2-AGP140 : Ensure cryptographic controls are in place to protect the confidentiality and integrity of data in-transit, stored, generated and processed in the cloud
+
+      Then every S3 bucket has default encryption with an approved KMS key
+
+    @s-awsear-002
+    Scenario: Detect S3 Buckets Without a Bucket Policy Requiring SSE-KMS Uploads
+
+      Security Standard References:
+        - CHC2-AGP140 : Ensure cryptographic controls are in place to protect the confidentiality and integrity of data in-transit, stored, generated and processed in the cloud
+
+      Then every S3 bucket has a bucket policy denying uploads without SSE-KMS encryption
+
+    @s-awsear-003
+    Scenario Outline: Prevent Uploads to Object Storage Without Encryption at Rest Using Customer Managed Keys
+
+      Security Standard References:
+        - CHC2-AGP140 : Ensure cryptographic controls are in place to protect the confidentiality and integrity of data in-transit, stored, generated and processed in the cloud
+
+      Given an S3 bucket is created with default SSE-KMS encryption and a bucket policy denying uploads without SSE-KMS encryption
+      Then an upload to the bucket with server side encryption "<Encryption>" "<Result>"
+
+      Examples:
+        | Encryption | Result   |
+        | none       | fails    |
+        | AES256     | fails    |
+        | aws:kms    | succeeds |
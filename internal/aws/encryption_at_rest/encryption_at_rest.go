@@ -0,0 +1,427 @@
+package awsear
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/cucumber/godog"
+
+	awsutil "github.com/citihub/probr-pack-storage/internal/aws"
+	"github.com/citihub/probr-pack-storage/internal/aws/bucketpolicy"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+type scenarioState struct {
+	name        string
+	currentStep string
+	audit       *audit.ScenarioAudit
+	probe       *audit.Probe
+	ctx         context.Context
+	bucketName  string
+	kmsKeyID    string
+	buckets     []string
+}
+
+// ProbeStruct meets the interface allowing this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+// encryptionResult records the evaluation of the default encryption of an existing bucket
+type encryptionResult struct {
+	BucketName   string
+	SSEAlgorithm string
+	KMSKeyID     string
+	Approved     bool
+	Error        string
+}
+
+// policyResult records the evaluation of the policy of an existing bucket
+type policyResult struct {
+	BucketName                        string
+	HasPolicy                         bool
+	DeniesUploadsWithoutKMSEncryption bool
+	Error                             string
+}
+
+// Probe meets the interface allowing this probe to be added to the ProbeStore
+var Probe probeStruct
+var scenario scenarioState       // Local container of scenario state
+var awsConnection connection.AWS // Provides functionality to interact with AWS
+
+func (scenario *scenarioState) anAWSAccountIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that the AWS account in the SDK configuration is available; "))
+
+	payload = struct {
+		Provider        string
+		Region          string
+		S3Endpoint      string
+		ApprovedKMSKeys []string
+	}{
+		config.Vars.ServicePacks.Storage.Provider,
+		awsConnection.Region(),
+		awsutil.S3Endpoint(),
+		awsutil.ApprovedKMSKeys(),
+	}
+
+	stepTrace.WriteString("Check that an endpoint is configured when running against an S3 compatible server; ")
+	if err = awsutil.RequireS3Endpoint(); err != nil {
+		return err
+	}
+
+	err = awsConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) everyS3BucketHasDefaultEncryptionWithAnApprovedKMSKey() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("List the S3 buckets owned by the account; ")
+	bucketNames, listErr := awsConnection.ListBuckets()
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list S3 buckets: %v", listErr)
+		return err
+	}
+
+	stepTrace.WriteString("Evaluate whether the default encryption of each bucket is SSE-KMS with an approved key, resolving key aliases through KMS; ")
+	approvedKeys := awsutil.ApprovedKMSKeys()
+	results := make([]encryptionResult, 0, len(bucketNames))
+	var flagged []string
+	for _, bucketName := range bucketNames {
+		result := encryptionResult{BucketName: bucketName}
+		configuration, encErr := awsConnection.GetBucketEncryption(bucketName)
+		if encErr != nil {
+			result.Error = encErr.Error()
+		} else if configuration != nil {
+			for _, rule := range configuration.Rules {
+				if rule.ApplyServerSideEncryptionByDefault == nil {
+					continue
+				}
+				result.SSEAlgorithm = aws.StringValue(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm)
+				result.KMSKeyID = aws.StringValue(rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID)
+			}
+			result.Approved = result.SSEAlgorithm == s3.ServerSideEncryptionAwsKms && awsutil.IsApprovedKey(result.KMSKeyID, approvedKeys, awsConnection.KMSKeyARN)
+		}
+		if !result.Approved {
+			flagged = append(flagged, bucketName)
+		}
+		results = append(results, result)
+	}
+
+	// Audit log
+	payload = struct {
+		ApprovedKMSKeys []string
+		Buckets         []encryptionResult
+	}{
+		ApprovedKMSKeys: approvedKeys,
+		Buckets:         results,
+	}
+
+	if len(flagged) > 0 {
+		err = utils.ReformatError("%d of %d S3 buckets do not have default SSE-KMS encryption with an approved key: %s", len(flagged), len(bucketNames), strings.Join(flagged, ", "))
+	}
+	return err
+}
+
+func (scenario *scenarioState) everyS3BucketHasABucketPolicyDenyingUploadsWithoutSSEKMSEncryption() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("List the S3 buckets owned by the account; ")
+	bucketNames, listErr := awsConnection.ListBuckets()
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list S3 buckets: %v", listErr)
+		return err
+	}
+
+	stepTrace.WriteString("Evaluate whether the policy of each bucket denies uploads without the SSE-KMS encryption header; ")
+	results := make([]policyResult, 0, len(bucketNames))
+	var flagged []string
+	for _, bucketName := range bucketNames {
+		result := policyResult{BucketName: bucketName}
+		policy, policyErr := awsConnection.GetBucketPolicy(bucketName)
+		if policyErr != nil {
+			result.Error = policyErr.Error()
+		} else if policy != "" {
+			result.HasPolicy = true
+			document, parseErr := bucketpolicy.Parse(policy)
+			if parseErr != nil {
+				result.Error = parseErr.Error()
+			} else {
+				result.DeniesUploadsWithoutKMSEncryption = document.DeniesUploadsWithoutKMSEncryption(bucketName)
+			}
+		}
+		if !result.DeniesUploadsWithoutKMSEncryption {
+			flagged = append(flagged, bucketName)
+		}
+		results = append(results, result)
+	}
+
+	// Audit log
+	payload = struct {
+		Buckets []policyResult
+	}{
+		Buckets: results,
+	}
+
+	if len(flagged) > 0 {
+		err = utils.ReformatError("%d of %d S3 buckets do not have a bucket policy denying uploads without SSE-KMS encryption: %s", len(flagged), len(bucketNames), strings.Join(flagged, ", "))
+	}
+	return err
+}
+
+func (scenario *scenarioState) anS3BucketIsCreatedWithDefaultSSEKMSEncryptionAndABucketPolicyDenyingUploadsWithoutSSEKMSEncryption() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	bucketName := awsutil.BucketName()
+	policy := bucketpolicy.New(bucketpolicy.DenyUploadsWithoutKMSEncryption(bucketName)).String()
+
+	// The first approved key is used, or the AWS managed key if no keys are approved
+	if approvedKeys := awsutil.ApprovedKMSKeys(); len(approvedKeys) > 0 {
+		scenario.kmsKeyID = approvedKeys[0]
+	}
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		KMSKeyID   string
+		Policy     string
+	}{
+		BucketName: bucketName,
+		KMSKeyID:   scenario.kmsKeyID,
+		Policy:     policy,
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Create S3 bucket '%s'; ", bucketName))
+	createErr := awsConnection.CreateBucket(bucketName)
+	if createErr != nil {
+		err = utils.ReformatError("Failed to create S3 bucket '%s': %v", bucketName, createErr)
+		return err
+	}
+	scenario.buckets = append(scenario.buckets, bucketName)
+	scenario.bucketName = bucketName
+
+	stepTrace.WriteString("Set the default encryption of the bucket to SSE-KMS; ")
+	encErr := awsConnection.PutBucketEncryption(bucketName, s3.ServerSideEncryptionAwsKms, scenario.kmsKeyID)
+	if encErr != nil {
+		err = utils.ReformatError("Failed to set default encryption of S3 bucket '%s': %v", bucketName, encErr)
+		return err
+	}
+
+	stepTrace.WriteString("Set a bucket policy denying uploads without the SSE-KMS encryption header; ")
+	policyErr := awsConnection.PutBucketPolicy(bucketName, policy)
+	if policyErr != nil {
+		err = utils.ReformatError("Failed to set policy of S3 bucket '%s': %v", bucketName, policyErr)
+	}
+	return err
+}
+
+func (scenario *scenarioState) anUploadToTheBucketWithServerSideEncryptionXY(encryption, expectedResult string) error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values
+	var algorithm string
+	switch encryption {
+	case "none":
+		algorithm = ""
+	case s3.ServerSideEncryptionAes256, s3.ServerSideEncryptionAwsKms:
+		algorithm = encryption
+	default:
+		err = utils.ReformatError("Unexpected value provided for encryption: '%s' Expected values: ['none', 'AES256', 'aws:kms']", encryption)
+		return err
+	}
+
+	var shouldSucceed bool
+	switch expectedResult {
+	case "succeeds":
+		shouldSucceed = true
+	case "fails":
+		shouldSucceed = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	objectKey := "probr-" + strings.ToLower(utils.RandomString(6))
+
+	stepTrace.WriteString(fmt.Sprintf("Upload object '%s' to S3 bucket '%s' with server side encryption '%s'; ", objectKey, scenario.bucketName, encryption))
+	putErr := awsConnection.PutObjectWithEncryption(scenario.bucketName, objectKey, []byte("Probr encryption at rest test object"), algorithm, scenario.kmsKeyID)
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		ObjectKey  string
+		Encryption string
+		KMSKeyID   string
+		ErrorCode  string
+		Error      string
+	}{
+		BucketName: scenario.bucketName,
+		ObjectKey:  objectKey,
+		Encryption: encryption,
+		KMSKeyID:   scenario.kmsKeyID,
+		ErrorCode:  errorCode(putErr),
+		Error:      fmt.Sprintf("%v", putErr),
+	}
+
+	if shouldSucceed && putErr != nil {
+		err = utils.ReformatError("Upload with server side encryption '%s' failed: %v", encryption, putErr)
+	} else if !shouldSucceed && putErr == nil {
+		err = utils.ReformatError("Upload with server side encryption '%s' succeeded, expected it to be denied by the bucket policy", encryption)
+	} else if !shouldSucceed && errorCode(putErr) != "AccessDenied" {
+		err = utils.ReformatError("Upload with server side encryption '%s' failed with error code '%s', expected 'AccessDenied': %v", encryption, errorCode(putErr), putErr)
+	}
+	return err
+}
+
+// errorCode returns the S3 error code of a failed request, such as AccessDenied
+func errorCode(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return ""
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.bucketName = ""
+	s.kmsKeyID = ""
+	s.buckets = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "encryption_at_rest"
+}
+
+// Path returns the probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "aws", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize aws connection
+		awsConnection = connection.NewAWSConnection(
+			context.Background(),
+			awsutil.S3Endpoint(),
+			awsutil.S3ForcePathStyle(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an AWS account is available$`, scenario.anAWSAccountIsAvailable)
+
+	// Steps
+	ctx.Step(`^every S3 bucket has default encryption with an approved KMS key$`, scenario.everyS3BucketHasDefaultEncryptionWithAnApprovedKMSKey)
+	ctx.Step(`^every S3 bucket has a bucket policy denying uploads without SSE-KMS encryption$`, scenario.everyS3BucketHasABucketPolicyDenyingUploadsWithoutSSEKMSEncryption)
+	ctx.Step(`^an S3 bucket is created with default SSE-KMS encryption and a bucket policy denying uploads without SSE-KMS encryption$`, scenario.anS3BucketIsCreatedWithDefaultSSEKMSEncryptionAndABucketPolicyDenyingUploadsWithoutSSEKMSEncryption)
+	ctx.Step(`^an upload to the bucket with server side encryption "([^"]*)" "([^"]*)"$`, scenario.anUploadToTheBucketWithServerSideEncryptionXY)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing S3 buckets used during tests")
+
+	for _, bucketName := range scenario.buckets {
+		log.Printf("[DEBUG] need to delete the S3 bucket: %s", bucketName)
+		err := awsConnection.DeleteBucket(bucketName)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the S3 bucket: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
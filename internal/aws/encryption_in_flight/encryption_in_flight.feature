@@ -0,0 +1,27 @@
+@s-awseif
+Feature: Object Storage Encryption in Flight
+
+    As a Cloud Security Architect
+    I want to ensure that suitable security controls are applied to Object Storage
+    So that my organisation is not vulnerable to interception of data in transit
+
+    Background:
+      Given an AWS account is available
+
+    @s-awseif-001
+    Scenario: Detect S3 Buckets Without a Bucket Policy Denying Insecure Transport
+
+      Security Standard References:
+        - CHC2-AGP140 : Ensure cryptographic controls are in place to protect the confidentiality and integrity of data in-transit, stored, generated and processed in the cloud
+
+      Then every S3 bucket has a bucket policy denying insecure transport
+
+    @s-awseif-002
+    Scenario: Prevent Access to Object Storage Over Plain HTTP
+
+      Security Standard References:
+        - CHC2-AGP140 : Ensure cryptographic controls are in place to protect the confidentiality and integrity of data in-transit, stored, generated and processed in the cloud
+
+      Given an S3 bucket is created with a bucket policy denying insecure transport
+      And an object is uploaded to the bucket
+      Then a request for the object over plain HTTP "fails"
@@ -0,0 +1,381 @@
+package awseif
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/cucumber/godog"
+
+	awsutil "github.com/citihub/probr-pack-storage/internal/aws"
+	"github.com/citihub/probr-pack-storage/internal/aws/bucketpolicy"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+type scenarioState struct {
+	name        string
+	currentStep string
+	audit       *audit.ScenarioAudit
+	probe       *audit.Probe
+	ctx         context.Context
+	bucketName  string
+	objectKey   string
+	buckets     []string
+}
+
+// ProbeStruct allows this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+// bucketResult records the evaluation of the policy of an existing bucket
+type bucketResult struct {
+	BucketName              string
+	HasPolicy               bool
+	DeniesInsecureTransport bool
+	Error                   string
+}
+
+// Probe allows this probe to be added to the ProbeStore
+var Probe probeStruct
+var scenario scenarioState       // Local container of scenario state
+var awsConnection connection.AWS // Provides functionality to interact with AWS
+
+const testObjectContent = "Probr encryption in flight test object"
+
+func (scenario *scenarioState) anAWSAccountIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that the AWS account in the SDK configuration is available; "))
+
+	payload = struct {
+		Provider   string
+		Region     string
+		S3Endpoint string
+	}{
+		config.Vars.ServicePacks.Storage.Provider,
+		awsConnection.Region(),
+		awsutil.S3Endpoint(),
+	}
+
+	stepTrace.WriteString("Check that an endpoint is configured when running against an S3 compatible server; ")
+	if err = awsutil.RequireS3Endpoint(); err != nil {
+		return err
+	}
+
+	err = awsConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) everyS3BucketHasABucketPolicyDenyingInsecureTransport() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("List the S3 buckets owned by the account; ")
+	bucketNames, listErr := awsConnection.ListBuckets()
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list S3 buckets: %v", listErr)
+		return err
+	}
+
+	stepTrace.WriteString("Evaluate whether the policy of each bucket denies requests where aws:SecureTransport is false; ")
+	results := make([]bucketResult, 0, len(bucketNames))
+	var flagged []string
+	for _, bucketName := range bucketNames {
+		result := bucketResult{BucketName: bucketName}
+		policy, policyErr := awsConnection.GetBucketPolicy(bucketName)
+		if policyErr != nil {
+			result.Error = policyErr.Error()
+		} else if policy != "" {
+			result.HasPolicy = true
+			document, parseErr := bucketpolicy.Parse(policy)
+			if parseErr != nil {
+				result.Error = parseErr.Error()
+			} else {
+				result.DeniesInsecureTransport = document.DeniesInsecureTransport(bucketName)
+			}
+		}
+		if !result.DeniesInsecureTransport {
+			flagged = append(flagged, bucketName)
+		}
+		results = append(results, result)
+	}
+
+	// Audit log
+	payload = struct {
+		Buckets []bucketResult
+	}{
+		Buckets: results,
+	}
+
+	if len(flagged) > 0 {
+		err = utils.ReformatError("%d of %d S3 buckets do not have a bucket policy denying insecure transport: %s", len(flagged), len(bucketNames), strings.Join(flagged, ", "))
+	}
+	return err
+}
+
+func (scenario *scenarioState) anS3BucketIsCreatedWithABucketPolicyDenyingInsecureTransport() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	bucketName := awsutil.BucketName()
+	policy := bucketpolicy.New(bucketpolicy.DenyInsecureTransport(bucketName)).String()
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		Policy     string
+		S3Endpoint string
+	}{
+		BucketName: bucketName,
+		Policy:     policy,
+		S3Endpoint: awsutil.S3Endpoint(),
+	}
+
+	stepTrace.WriteString("Check that the configured endpoint uses https, so that requests over plain HTTP can be told apart; ")
+	if err = awsutil.RequireHTTPSEndpoint(); err != nil {
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Create S3 bucket '%s'; ", bucketName))
+	createErr := awsConnection.CreateBucket(bucketName)
+	if createErr != nil {
+		err = utils.ReformatError("Failed to create S3 bucket '%s': %v", bucketName, createErr)
+		return err
+	}
+	scenario.buckets = append(scenario.buckets, bucketName)
+	scenario.bucketName = bucketName
+
+	stepTrace.WriteString("Set a bucket policy denying requests where aws:SecureTransport is false; ")
+	policyErr := awsConnection.PutBucketPolicy(bucketName, policy)
+	if policyErr != nil {
+		err = utils.ReformatError("Failed to set policy of S3 bucket '%s': %v", bucketName, policyErr)
+	}
+	return err
+}
+
+func (scenario *scenarioState) anObjectIsUploadedToTheBucket() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	scenario.objectKey = "probr-" + strings.ToLower(utils.RandomString(6))
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		ObjectKey  string
+		S3Endpoint string
+	}{
+		BucketName: scenario.bucketName,
+		ObjectKey:  scenario.objectKey,
+		S3Endpoint: awsutil.S3Endpoint(),
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Upload object '%s' to S3 bucket '%s' using the configured endpoint; ", scenario.objectKey, scenario.bucketName))
+	putErr := awsConnection.PutObject(scenario.bucketName, scenario.objectKey, []byte(testObjectContent))
+	if putErr != nil {
+		err = utils.ReformatError("Failed to upload object '%s' to S3 bucket '%s': %v", scenario.objectKey, scenario.bucketName, putErr)
+	}
+	return err
+}
+
+func (scenario *scenarioState) aRequestForTheObjectOverPlainHTTPX(expectedResult string) error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values
+	var shouldSucceed bool
+	switch expectedResult {
+	case "succeeds":
+		shouldSucceed = true
+	case "fails":
+		shouldSucceed = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Request object '%s' from S3 bucket '%s' over plain HTTP; ", scenario.objectKey, scenario.bucketName))
+	getErr := awsConnection.GetObjectOverHTTP(scenario.bucketName, scenario.objectKey)
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		ObjectKey  string
+		ErrorCode  string
+		Error      string
+	}{
+		BucketName: scenario.bucketName,
+		ObjectKey:  scenario.objectKey,
+		ErrorCode:  errorCode(getErr),
+		Error:      fmt.Sprintf("%v", getErr),
+	}
+
+	if shouldSucceed && getErr != nil {
+		err = utils.ReformatError("Request for object over plain HTTP failed: %v", getErr)
+	} else if !shouldSucceed && getErr == nil {
+		err = utils.ReformatError("Request for object over plain HTTP succeeded, expected it to be refused")
+	} else if !shouldSucceed && !isAccessDenied(getErr) {
+		// Ensure the request reached the server and was refused by the bucket policy, rather than failing to connect
+		err = utils.ReformatError("Request for object over plain HTTP failed with an unexpected error, expected 403 AccessDenied from the bucket policy: %v", getErr)
+	} else if !shouldSucceed {
+		stepTrace.WriteString(fmt.Sprintf("Request was refused with error code '%s'; ", errorCode(getErr)))
+	}
+	return err
+}
+
+// errorCode returns the S3 error code of a failed request, such as AccessDenied
+func errorCode(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return ""
+}
+
+// isAccessDenied returns whether a request was refused with 403 AccessDenied, as it is by a bucket policy denying insecure transport
+func isAccessDenied(err error) bool {
+	if errorCode(err) != "AccessDenied" {
+		return false
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.StatusCode() == http.StatusForbidden
+	}
+	return true
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.bucketName = ""
+	s.objectKey = ""
+	s.buckets = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+// Name will return this probe's name
+func (probe probeStruct) Name() string {
+	return "encryption_in_flight"
+}
+
+// Path will return this probe's feature path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "aws", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize aws connection
+		awsConnection = connection.NewAWSConnection(
+			context.Background(),
+			awsutil.S3Endpoint(),
+			awsutil.S3ForcePathStyle(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an AWS account is available$`, scenario.anAWSAccountIsAvailable)
+
+	// Steps
+	ctx.Step(`^every S3 bucket has a bucket policy denying insecure transport$`, scenario.everyS3BucketHasABucketPolicyDenyingInsecureTransport)
+	ctx.Step(`^an S3 bucket is created with a bucket policy denying insecure transport$`, scenario.anS3BucketIsCreatedWithABucketPolicyDenyingInsecureTransport)
+	ctx.Step(`^an object is uploaded to the bucket$`, scenario.anObjectIsUploadedToTheBucket)
+	ctx.Step(`^a request for the object over plain HTTP "([^"]*)"$`, scenario.aRequestForTheObjectOverPlainHTTPX)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing S3 buckets used during tests")
+
+	for _, bucketName := range scenario.buckets {
+		log.Printf("[DEBUG] need to delete the S3 bucket: %s", bucketName)
+		err := awsConnection.DeleteBucket(bucketName)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the S3 bucket: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
@@ -0,0 +1,28 @@
+@s-awsis
+Feature: Object Storage Holding Regulated Data Is Immutable
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that my organisation's regulated data cannot be altered or deleted before its retention period ends
+
+    Background:
+      Given an AWS account is available
+      And the regulated S3 buckets in config are listed
+
+    @s-awsis-001
+    Scenario: Detect Regulated S3 Buckets Without Versioning
+      Then every regulated S3 bucket has versioning enabled
+
+    @s-awsis-002
+    Scenario: Detect Regulated S3 Buckets Without Object Lock in Compliance Mode
+      Then every regulated S3 bucket has Object Lock enabled with a default retention in "COMPLIANCE" mode of at least the minimum retention period
+
+    @s-awsis-003
+    Scenario: Detect Regulated S3 Buckets Without MFA Delete
+      Then every regulated S3 bucket has MFA delete enabled
+
+    @s-awsis-004
+    Scenario: Prevent Deletion of Locked Object Versions
+      Given an S3 bucket is created with Object Lock enabled
+      And an object is uploaded to the bucket with a "GOVERNANCE" mode retention of 1 days
+      Then an attempt to delete the locked object version "fails"
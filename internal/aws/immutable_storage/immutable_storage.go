@@ -0,0 +1,459 @@
+package awsis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/cucumber/godog"
+
+	awsutil "github.com/citihub/probr-pack-storage/internal/aws"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+type scenarioState struct {
+	name             string
+	currentStep      string
+	audit            *audit.ScenarioAudit
+	probe            *audit.Probe
+	ctx              context.Context
+	regulatedBuckets []string
+	bucketName       string
+	objectKey        string
+	versionID        string
+	buckets          []string
+}
+
+// ProbeStruct meets the interface allowing this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+// bucketResult records the versioning and Object Lock settings of a regulated bucket
+type bucketResult struct {
+	BucketName   string
+	Immutability awsutil.Immutability
+	Error        string
+}
+
+// Probe meets the interface allowing this probe to be added to the ProbeStore
+var Probe probeStruct
+var scenario scenarioState       // Local container of scenario state
+var awsConnection connection.AWS // Provides functionality to interact with AWS
+
+func (scenario *scenarioState) anAWSAccountIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that the AWS account in the SDK configuration is available; "))
+
+	payload = struct {
+		Provider   string
+		Region     string
+		S3Endpoint string
+	}{
+		config.Vars.ServicePacks.Storage.Provider,
+		awsConnection.Region(),
+		awsutil.S3Endpoint(),
+	}
+
+	stepTrace.WriteString("Check that an endpoint is configured when running against an S3 compatible server; ")
+	if err = awsutil.RequireS3Endpoint(); err != nil {
+		return err
+	}
+
+	err = awsConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) theRegulatedS3BucketsInConfigAreListed() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("List the S3 buckets owned by the account; ")
+	bucketNames, listErr := awsConnection.ListBuckets()
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list S3 buckets: %v", listErr)
+		return err
+	}
+
+	patterns := awsutil.RegulatedBuckets()
+	stepTrace.WriteString("Select the buckets matching the regulated bucket names in config; ")
+	scenario.regulatedBuckets = make([]string, 0)
+	for _, bucketName := range bucketNames {
+		if isRegulated(bucketName, patterns) {
+			scenario.regulatedBuckets = append(scenario.regulatedBuckets, bucketName)
+		}
+	}
+
+	// Audit log
+	payload = struct {
+		RegulatedBucketPatterns []string
+		RegulatedBuckets        []string
+	}{
+		RegulatedBucketPatterns: patterns,
+		RegulatedBuckets:        scenario.regulatedBuckets,
+	}
+
+	if len(scenario.regulatedBuckets) == 0 {
+		if len(patterns) > 0 {
+			err = utils.ReformatError("No S3 buckets match the regulated bucket names in config: %s", strings.Join(patterns, ", "))
+		} else {
+			// There are no buckets to evaluate, which would otherwise pass every check
+			log.Printf("[WARN] The account owns no S3 buckets, skipping regulated bucket checks")
+			err = godog.ErrPending
+		}
+	}
+	return err
+}
+
+func (scenario *scenarioState) everyRegulatedS3BucketHasVersioningEnabled() error {
+	return scenario.everyRegulatedBucket("versioning enabled", "", func(i awsutil.Immutability) bool {
+		return i.VersioningOK
+	})
+}
+
+func (scenario *scenarioState) everyRegulatedS3BucketHasObjectLockEnabledWithADefaultRetentionInXModeOfAtLeastTheMinimumRetentionPeriod(mode string) error {
+	return scenario.everyRegulatedBucket(fmt.Sprintf("Object Lock enabled with a default retention in '%s' mode of at least %d days", mode, awsutil.ObjectLockMinimumRetentionDays()), mode, func(i awsutil.Immutability) bool {
+		return i.ObjectLockOK
+	})
+}
+
+func (scenario *scenarioState) everyRegulatedS3BucketHasMFADeleteEnabled() error {
+	return scenario.everyRegulatedBucket("MFA delete enabled", "", func(i awsutil.Immutability) bool {
+		return i.MFADeleteOK
+	})
+}
+
+// everyRegulatedBucket evaluates the versioning and Object Lock settings of every regulated bucket, and fails if any bucket does not pass the check
+func (scenario *scenarioState) everyRegulatedBucket(control, mode string, passed func(awsutil.Immutability) bool) error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values
+	switch mode {
+	case "", s3.ObjectLockRetentionModeCompliance, s3.ObjectLockRetentionModeGovernance:
+	default:
+		err = utils.ReformatError("Unexpected value provided for mode: '%s' Expected values: ['%s', '%s']", mode, s3.ObjectLockRetentionModeCompliance, s3.ObjectLockRetentionModeGovernance)
+		return err
+	}
+
+	minimumDays := awsutil.ObjectLockMinimumRetentionDays()
+	stepTrace.WriteString(fmt.Sprintf("Check that every regulated bucket has %s; ", control))
+	results := make([]bucketResult, 0, len(scenario.regulatedBuckets))
+	var flagged []string
+	for _, bucketName := range scenario.regulatedBuckets {
+		result := bucketResult{BucketName: bucketName}
+		versioning, versioningErr := awsConnection.GetBucketVersioning(bucketName)
+		lock, lockErr := awsConnection.GetObjectLockConfiguration(bucketName)
+		switch {
+		case versioningErr != nil:
+			result.Error = versioningErr.Error()
+		case lockErr != nil:
+			result.Error = lockErr.Error()
+		default:
+			result.Immutability = awsutil.EvaluateImmutability(versioning, lock, mode, minimumDays)
+		}
+		if result.Error != "" || !passed(result.Immutability) {
+			flagged = append(flagged, bucketName)
+		}
+		results = append(results, result)
+	}
+
+	// Audit log
+	payload = struct {
+		Control              string
+		MinimumRetentionDays int
+		Buckets              []bucketResult
+	}{
+		Control:              control,
+		MinimumRetentionDays: minimumDays,
+		Buckets:              results,
+	}
+
+	if len(flagged) > 0 {
+		err = utils.ReformatError("%d of %d regulated S3 buckets do not have %s: %s", len(flagged), len(scenario.regulatedBuckets), control, strings.Join(flagged, ", "))
+	}
+	return err
+}
+
+func (scenario *scenarioState) anS3BucketIsCreatedWithObjectLockEnabled() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	bucketName := awsutil.BucketName()
+
+	// Audit log
+	payload = struct {
+		BucketName string
+	}{
+		BucketName: bucketName,
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Create S3 bucket '%s' with Object Lock enabled; ", bucketName))
+	createErr := awsConnection.CreateBucketWithObjectLock(bucketName)
+	if createErr != nil {
+		err = utils.ReformatError("Failed to create S3 bucket '%s' with Object Lock enabled: %v", bucketName, createErr)
+		return err
+	}
+	scenario.buckets = append(scenario.buckets, bucketName)
+	scenario.bucketName = bucketName
+
+	return nil
+}
+
+func (scenario *scenarioState) anObjectIsUploadedToTheBucketWithAXModeRetentionOfNDays(mode string, days int) error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values
+	switch mode {
+	case s3.ObjectLockRetentionModeCompliance, s3.ObjectLockRetentionModeGovernance:
+	default:
+		err = utils.ReformatError("Unexpected value provided for mode: '%s' Expected values: ['%s', '%s']", mode, s3.ObjectLockRetentionModeCompliance, s3.ObjectLockRetentionModeGovernance)
+		return err
+	}
+
+	scenario.objectKey = "probr-" + strings.ToLower(utils.RandomString(6))
+	retainUntil := time.Now().Add(time.Duration(days) * 24 * time.Hour)
+
+	stepTrace.WriteString(fmt.Sprintf("Upload object '%s' to S3 bucket '%s' locked in '%s' mode until %s; ", scenario.objectKey, scenario.bucketName, mode, retainUntil.Format(time.RFC3339)))
+	var putErr error
+	scenario.versionID, putErr = awsConnection.PutObjectWithRetention(scenario.bucketName, scenario.objectKey, []byte("Probr immutable storage test object"), mode, retainUntil)
+
+	// Audit log
+	payload = struct {
+		BucketName  string
+		ObjectKey   string
+		VersionID   string
+		Mode        string
+		RetainUntil string
+	}{
+		BucketName:  scenario.bucketName,
+		ObjectKey:   scenario.objectKey,
+		VersionID:   scenario.versionID,
+		Mode:        mode,
+		RetainUntil: retainUntil.Format(time.RFC3339),
+	}
+
+	if putErr != nil {
+		err = utils.ReformatError("Failed to upload locked object '%s' to S3 bucket '%s': %v", scenario.objectKey, scenario.bucketName, putErr)
+	}
+	return err
+}
+
+func (scenario *scenarioState) anAttemptToDeleteTheLockedObjectVersionX(expectedResult string) error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values
+	var shouldSucceed bool
+	switch expectedResult {
+	case "succeeds":
+		shouldSucceed = true
+	case "fails":
+		shouldSucceed = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Delete version '%s' of object '%s' from S3 bucket '%s' without bypassing retention; ", scenario.versionID, scenario.objectKey, scenario.bucketName))
+	deleteErr := awsConnection.DeleteObjectVersion(scenario.bucketName, scenario.objectKey, scenario.versionID)
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		ObjectKey  string
+		VersionID  string
+		ErrorCode  string
+		Error      string
+	}{
+		BucketName: scenario.bucketName,
+		ObjectKey:  scenario.objectKey,
+		VersionID:  scenario.versionID,
+		ErrorCode:  errorCode(deleteErr),
+		Error:      fmt.Sprintf("%v", deleteErr),
+	}
+
+	if shouldSucceed && deleteErr != nil {
+		err = utils.ReformatError("Deletion of the locked object version failed: %v", deleteErr)
+	} else if !shouldSucceed && deleteErr == nil {
+		err = utils.ReformatError("Deletion of the locked object version succeeded, expected it to be refused")
+	} else if !shouldSucceed && errorCode(deleteErr) != "AccessDenied" {
+		err = utils.ReformatError("Deletion of the locked object version failed with error code '%s', expected 'AccessDenied' from Object Lock retention: %v", errorCode(deleteErr), deleteErr)
+	}
+	return err
+}
+
+// isRegulated returns whether a bucket name matches one of the regulated bucket patterns. Every bucket is regulated if there are no patterns.
+func isRegulated(bucketName string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, bucketName); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// errorCode returns the S3 error code of a failed request, such as AccessDenied
+func errorCode(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return ""
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.regulatedBuckets = make([]string, 0)
+	s.bucketName = ""
+	s.objectKey = ""
+	s.versionID = ""
+	s.buckets = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "immutable_storage"
+}
+
+// Path returns the probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "aws", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize aws connection
+		awsConnection = connection.NewAWSConnection(
+			context.Background(),
+			awsutil.S3Endpoint(),
+			awsutil.S3ForcePathStyle(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an AWS account is available$`, scenario.anAWSAccountIsAvailable)
+	ctx.Step(`^the regulated S3 buckets in config are listed$`, scenario.theRegulatedS3BucketsInConfigAreListed)
+
+	// Steps
+	ctx.Step(`^every regulated S3 bucket has versioning enabled$`, scenario.everyRegulatedS3BucketHasVersioningEnabled)
+	ctx.Step(`^every regulated S3 bucket has Object Lock enabled with a default retention in "([^"]*)" mode of at least the minimum retention period$`, scenario.everyRegulatedS3BucketHasObjectLockEnabledWithADefaultRetentionInXModeOfAtLeastTheMinimumRetentionPeriod)
+	ctx.Step(`^every regulated S3 bucket has MFA delete enabled$`, scenario.everyRegulatedS3BucketHasMFADeleteEnabled)
+	ctx.Step(`^an S3 bucket is created with Object Lock enabled$`, scenario.anS3BucketIsCreatedWithObjectLockEnabled)
+	ctx.Step(`^an object is uploaded to the bucket with a "([^"]*)" mode retention of (\d+) days$`, scenario.anObjectIsUploadedToTheBucketWithAXModeRetentionOfNDays)
+	ctx.Step(`^an attempt to delete the locked object version "([^"]*)"$`, scenario.anAttemptToDeleteTheLockedObjectVersionX)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing S3 buckets used during tests")
+
+	for _, bucketName := range scenario.buckets {
+		log.Printf("[DEBUG] need to delete the S3 bucket: %s", bucketName)
+		err := awsConnection.DeleteBucket(bucketName)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the S3 bucket: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
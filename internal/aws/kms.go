@@ -0,0 +1,52 @@
+package aws
+
+import (
+	"log"
+	"strings"
+)
+
+// AWSManagedKey is the alias of the AWS managed key used for SSE-KMS when a bucket does not specify a key
+const AWSManagedKey = "alias/aws/s3"
+
+// KeyResolver returns the ARN of a KMS key given as a key ID, key ARN, alias name or alias ARN
+type KeyResolver func(keyID string) (string, error)
+
+// IsApprovedKey returns whether a KMS key, given as a key ID, key ARN, alias name or alias ARN, is one of the approved keys.
+// Both the key and the approved keys are resolved to key ARNs, so that an alias matches the key it points to. Where a key
+// cannot be resolved, for example on an S3 compatible server without KMS, the names are compared instead.
+// An empty key is the AWS managed key. Every key is approved if the approved list is empty.
+func IsApprovedKey(keyID string, approvedKeys []string, resolve KeyResolver) bool {
+	if len(approvedKeys) == 0 {
+		return true
+	}
+	if keyID == "" {
+		keyID = AWSManagedKey
+	}
+	keyARN, keyErr := resolve(keyID)
+	if keyErr != nil {
+		log.Printf("[WARN] Failed to resolve KMS key '%s', comparing key names instead: %v", keyID, keyErr)
+	}
+	for _, approved := range approvedKeys {
+		if keyErr == nil {
+			approvedARN, approvedErr := resolve(approved)
+			if approvedErr == nil {
+				if approvedARN == keyARN {
+					return true
+				}
+				continue
+			}
+			log.Printf("[WARN] Failed to resolve approved KMS key '%s', comparing key names instead: %v", approved, approvedErr)
+		}
+		if keyNamesMatch(keyID, approved) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyNamesMatch returns whether two key IDs, key ARNs or aliases name the same key without resolving them,
+// e.g. a key ID and the ARN ending with it
+func keyNamesMatch(a, b string) bool {
+	return a == b || strings.HasSuffix(a, "/"+b) || strings.HasSuffix(a, ":"+b) ||
+		strings.HasSuffix(b, "/"+a) || strings.HasSuffix(b, ":"+a)
+}
@@ -0,0 +1,54 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsApprovedKey(t *testing.T) {
+	const keyARN = "arn:aws:kms:eu-west-2:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	const otherARN = "arn:aws:kms:eu-west-2:111122223333:key/0987dcba-09fe-87dc-65ba-ab0987654321"
+	const managedARN = "arn:aws:kms:eu-west-2:111122223333:key/aaaa1111-22bb-33cc-44dd-555555eeeeee"
+	keys := map[string]string{
+		keyARN:                                 keyARN,
+		"1234abcd-12ab-34cd-56ef-1234567890ab": keyARN,
+		"alias/storage":                        keyARN,
+		"arn:aws:kms:eu-west-2:111122223333:alias/storage": keyARN,
+		otherARN:      otherARN,
+		AWSManagedKey: managedARN,
+	}
+	kms := func(keyID string) (string, error) {
+		if arn, ok := keys[keyID]; ok {
+			return arn, nil
+		}
+		return "", errors.New("NotFoundException")
+	}
+	noKMS := func(keyID string) (string, error) {
+		return "", errors.New("NotImplemented")
+	}
+
+	tests := []struct {
+		name     string
+		keyID    string
+		approved []string
+		resolve  KeyResolver
+		want     bool
+	}{
+		{"TestCase1_NoApprovedKeys_ShouldApprove", otherARN, nil, kms, true},
+		{"TestCase2_KeyARNApprovedByAlias_ShouldApprove", keyARN, []string{"alias/storage"}, kms, true},
+		{"TestCase3_AliasARNApprovedByKeyID_ShouldApprove", "arn:aws:kms:eu-west-2:111122223333:alias/storage", []string{"1234abcd-12ab-34cd-56ef-1234567890ab"}, kms, true},
+		{"TestCase4_OtherKeyApprovedByAlias_ShouldNotApprove", otherARN, []string{"alias/storage"}, kms, false},
+		{"TestCase5_EmptyKeyApprovedByManagedAlias_ShouldApprove", "", []string{AWSManagedKey}, kms, true},
+		{"TestCase6_EmptyKeyWithCustomerKeyApproved_ShouldNotApprove", "", []string{keyARN}, kms, false},
+		{"TestCase7_NoKMSKeyARNEndingWithApprovedID_ShouldApprove", keyARN, []string{"1234abcd-12ab-34cd-56ef-1234567890ab"}, noKMS, true},
+		{"TestCase8_NoKMSKeyARNApprovedByAlias_ShouldNotApprove", keyARN, []string{"alias/storage"}, noKMS, false},
+		{"TestCase9_UnresolvedApprovedKeyMatchingByName_ShouldApprove", "alias/legacy", []string{"alias/legacy"}, kms, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsApprovedKey(tt.keyID, tt.approved, tt.resolve); got != tt.want {
+				t.Errorf("IsApprovedKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
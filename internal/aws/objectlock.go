@@ -0,0 +1,42 @@
+package aws
+
+import (
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// daysPerYear is used to compare default retention periods given in years with a minimum in days
+const daysPerYear = 365
+
+// Immutability records the versioning and Object Lock settings of a bucket
+type Immutability struct {
+	Versioning    string // Enabled, Suspended, or empty if versioning was never enabled
+	MFADelete     string // Enabled, Disabled, or empty if never configured
+	ObjectLock    bool
+	RetentionMode string // Default retention mode of new object versions: GOVERNANCE, COMPLIANCE, or empty if none
+	RetentionDays int    // Default retention period of new object versions, with years converted to days
+	VersioningOK  bool
+	MFADeleteOK   bool
+	ObjectLockOK  bool // Object Lock is enabled with default retention of at least the minimum in the required mode
+}
+
+// EvaluateImmutability compares the versioning and Object Lock settings of a bucket with the required retention mode and minimum retention in days.
+// Either configuration may be nil when the bucket has none.
+func EvaluateImmutability(versioning *s3.GetBucketVersioningOutput, lock *s3.ObjectLockConfiguration, requiredMode string, minimumDays int) Immutability {
+	var i Immutability
+	if versioning != nil {
+		i.Versioning = awssdk.StringValue(versioning.Status)
+		i.MFADelete = awssdk.StringValue(versioning.MFADelete)
+	}
+	if lock != nil {
+		i.ObjectLock = awssdk.StringValue(lock.ObjectLockEnabled) == s3.ObjectLockEnabledEnabled
+		if lock.Rule != nil && lock.Rule.DefaultRetention != nil {
+			i.RetentionMode = awssdk.StringValue(lock.Rule.DefaultRetention.Mode)
+			i.RetentionDays = int(awssdk.Int64Value(lock.Rule.DefaultRetention.Days) + awssdk.Int64Value(lock.Rule.DefaultRetention.Years)*daysPerYear)
+		}
+	}
+	i.VersioningOK = i.Versioning == s3.BucketVersioningStatusEnabled
+	i.MFADeleteOK = i.MFADelete == s3.MFADeleteStatusEnabled
+	i.ObjectLockOK = i.ObjectLock && i.RetentionMode == requiredMode && i.RetentionDays >= minimumDays
+	return i
+}
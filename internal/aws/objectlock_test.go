@@ -0,0 +1,50 @@
+package aws
+
+import (
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestEvaluateImmutability(t *testing.T) {
+	enabled := &s3.GetBucketVersioningOutput{Status: awssdk.String("Enabled"), MFADelete: awssdk.String("Enabled")}
+	lock := func(mode string, days, years int64) *s3.ObjectLockConfiguration {
+		retention := &s3.DefaultRetention{Mode: awssdk.String(mode)}
+		if days > 0 {
+			retention.Days = awssdk.Int64(days)
+		}
+		if years > 0 {
+			retention.Years = awssdk.Int64(years)
+		}
+		return &s3.ObjectLockConfiguration{
+			ObjectLockEnabled: awssdk.String("Enabled"),
+			Rule:              &s3.ObjectLockRule{DefaultRetention: retention},
+		}
+	}
+
+	tests := []struct {
+		name           string
+		versioning     *s3.GetBucketVersioningOutput
+		lock           *s3.ObjectLockConfiguration
+		wantVersioning bool
+		wantMFADelete  bool
+		wantObjectLock bool
+	}{
+		{"TestCase1_ComplianceModeAboveMinimum_ShouldPass", enabled, lock("COMPLIANCE", 30, 0), true, true, true},
+		{"TestCase2_ComplianceModeInYears_ShouldPass", enabled, lock("COMPLIANCE", 0, 1), true, true, true},
+		{"TestCase3_ComplianceModeBelowMinimum_ShouldFail", enabled, lock("COMPLIANCE", 7, 0), true, true, false},
+		{"TestCase4_GovernanceMode_ShouldFail", enabled, lock("GOVERNANCE", 30, 0), true, true, false},
+		{"TestCase5_NoObjectLock_ShouldFail", &s3.GetBucketVersioningOutput{Status: awssdk.String("Suspended")}, nil, false, false, false},
+		{"TestCase6_NoConfiguration_ShouldFail", nil, nil, false, false, false},
+		{"TestCase7_ObjectLockWithoutDefaultRetention_ShouldFail", enabled, &s3.ObjectLockConfiguration{ObjectLockEnabled: awssdk.String("Enabled")}, true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := EvaluateImmutability(tt.versioning, tt.lock, "COMPLIANCE", 30)
+			if i.VersioningOK != tt.wantVersioning || i.MFADeleteOK != tt.wantMFADelete || i.ObjectLockOK != tt.wantObjectLock {
+				t.Errorf("EvaluateImmutability() = %+v, want versioning %v, MFA delete %v, object lock %v", i, tt.wantVersioning, tt.wantMFADelete, tt.wantObjectLock)
+			}
+		})
+	}
+}
@@ -10,9 +10,8 @@ Feature: Object Storage Can Only Be Accessed By Authorized Users
       And azure resource group specified in config exists
 
     @s-azac-001
-    Scenario Outline: Prevent Object Storage from Being Created With Anonymous Access
+    Scenario: Prevent Object Storage from Being Created With Anonymous Access
       Then an attempt to create a storage account "without" anonymous access "succeeds"
       But an attempt to create a storage account "with" anonymous access "fails"
-      #TODO: Implement
 
   
\ No newline at end of file
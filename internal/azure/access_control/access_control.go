@@ -0,0 +1,313 @@
+package azureac
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	azureStorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/cucumber/godog"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/azure/policyassignment"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// ProbeStruct allows this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+// creationOutcome records the result of a storage account creation attempt through one creation backend
+type creationOutcome struct {
+	Backend            string
+	StorageAccountName string
+	StorageAccount     azureStorage.Account
+	Error              string
+}
+
+type scenarioState struct {
+	name            string
+	currentStep     string
+	audit           *audit.ScenarioAudit
+	probe           *audit.Probe
+	ctx             context.Context
+	tags            map[string]*string
+	storageAccounts []string
+}
+
+// Probe ...
+var Probe probeStruct             // Probe allows this probe to be added to the ProbeStore
+var scenario scenarioState        // Local container of scenario state
+var azConnection connection.Azure // Provides functionality to interact with Azure
+
+var requiredPolicies = policyassignment.Require(Probe.Name(), azureutil.PublicAccessPolicy) // Assigned for the test run when ephemeral policy assignment is enabled
+
+func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that Azure subscription specified in config file is available; "))
+
+	payload = struct {
+		SubscriptionID string
+		TenantID       string
+		CreationMode   string
+	}{
+		azureutil.SubscriptionID(),
+		azureutil.TenantID(),
+		azureutil.CreationMode(),
+	}
+
+	err = azConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) azureResourceGroupSpecifiedInConfigExists() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check if value for Azure resource group is set in config vars; ")
+	if azureutil.ResourceGroup() == "" {
+		err = utils.ReformatError("Azure resource group config var not set")
+		return err
+	}
+
+	stepTrace.WriteString("Check the resource group exists in the specified azure subscription; ")
+	_, getGrpErr := azConnection.GetResourceGroupByName(azureutil.ResourceGroup())
+	if getGrpErr != nil {
+		err = utils.ReformatError("Azure resource group '%s' does not exists. Error: %v", azureutil.ResourceGroup(), getGrpErr)
+		return err
+	}
+
+	//Audit log
+	payload = struct {
+		SubscriptionID string
+		ResourceGroup  string
+	}{
+		SubscriptionID: azureutil.SubscriptionID(),
+		ResourceGroup:  azureutil.ResourceGroup(),
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) anAttemptToCreateAStorageAccountXAnonymousAccessY(anonymousAccessOption, expectedResult string) error {
+
+	// Supported values for 'anonymousAccessOption':
+	//	'with'
+	//  'without'
+
+	// Supported values for 'expectedResult':
+	//	'succeeds'
+	//	'fails'
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values - anonymousAccessOption
+	var allowBlobPublicAccess bool
+	switch anonymousAccessOption {
+	case "with":
+		allowBlobPublicAccess = true
+	case "without":
+		allowBlobPublicAccess = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for anonymousAccessOption: '%s' Expected values: ['with', 'without']", anonymousAccessOption)
+		return err
+	}
+
+	// Validate input values - expectedResult
+	var shouldCreate bool
+	switch expectedResult {
+	case "succeeds":
+		shouldCreate = true
+	case "fails":
+		shouldCreate = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	parameters := azureStorage.AccountCreateParameters{
+		AccountPropertiesCreateParameters: &azureStorage.AccountPropertiesCreateParameters{
+			EnableHTTPSTrafficOnly: to.BoolPtr(true),
+			AllowBlobPublicAccess:  to.BoolPtr(allowBlobPublicAccess),
+		},
+		Tags: scenario.tags,
+	}
+
+	var outcomes []creationOutcome
+	for _, backend := range azureutil.CreationBackends() {
+
+		bucketName := utils.RandomString(10)
+		stepTrace.WriteString(fmt.Sprintf("Generate a storage account name using a random string: '%s'; ", bucketName))
+
+		stepTrace.WriteString(fmt.Sprintf("Attempt to create Storage Account with AllowBlobPublicAccess: %v using the '%s' creation backend; ", allowBlobPublicAccess, backend))
+		storageAccount, creationErr := azConnection.CreateStorageAccountWithBackend(azureutil.CreationMode(), backend, bucketName, azureutil.ResourceGroup(), parameters)
+		if creationErr == nil {
+			scenario.storageAccounts = append(scenario.storageAccounts, bucketName) // Record for later cleanup
+		}
+
+		outcome := creationOutcome{
+			Backend:            backend,
+			StorageAccountName: bucketName,
+			StorageAccount:     storageAccount,
+		}
+		if creationErr != nil {
+			outcome.Error = creationErr.Error()
+		}
+		outcomes = append(outcomes, outcome)
+
+		var attemptErr error
+		stepTrace.WriteString(fmt.Sprintf("Validate that storage account creation %s; ", expectedResult))
+		switch shouldCreate {
+		case true:
+			if creationErr != nil {
+				attemptErr = utils.ReformatError("Creation of storage account did not succeed: %v", creationErr)
+			}
+		case false:
+			if creationErr == nil {
+				attemptErr = utils.ReformatError("Creation of storage account succeeded, but should have failed")
+			} else if !azureutil.IsPolicyDenial(creationErr) {
+				// Ensure failure is due to the public access policy
+				attemptErr = utils.ReformatError("Creation of storage account failed with unexpected reason: %v - %v", azureutil.ServiceErrorCode(creationErr), creationErr)
+			}
+		}
+		if attemptErr != nil && err == nil {
+			err = utils.ReformatError("Creation backend '%s': %v", backend, attemptErr)
+		}
+	}
+
+	//Audit log
+	payload = struct {
+		ResourceGroup         string
+		AllowBlobPublicAccess bool
+		Outcomes              []creationOutcome
+	}{
+		ResourceGroup:         azureutil.ResourceGroup(),
+		AllowBlobPublicAccess: allowBlobPublicAccess,
+		Outcomes:              outcomes,
+	}
+
+	return err
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.storageAccounts = make([]string, 0)
+	s.tags = policyassignment.Tags(probeName)
+	probeengine.LogScenarioStart(gs)
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "access_control"
+}
+
+// Path returns this probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "azure", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize azure connection
+		azConnection = connection.NewAzureConnection(
+			context.Background(),
+			azureutil.SubscriptionID(),
+			azureutil.TenantID(),
+			azureutil.ClientID(),
+			azureutil.ClientSecret(),
+		)
+
+		// Policies required by every probe are assigned once, by the first probe to run
+		if azureutil.EphemeralPolicyAssignment() {
+			if err := policyassignment.Ensure(azConnection); err != nil {
+				log.Printf("[ERROR] Ephemeral policy assignment failed: %v", err)
+			}
+		}
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an Azure subscription is available$`, scenario.anAzureSubscriptionIsAvailable)
+	ctx.Step(`^azure resource group specified in config exists$`, scenario.azureResourceGroupSpecifiedInConfigExists)
+
+	// Steps
+	ctx.Step(`^an attempt to create a storage account "([^"]*)" anonymous access "([^"]*)"$`, scenario.anAttemptToCreateAStorageAccountXAnonymousAccessY)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing storage accounts used during tests")
+
+	for _, account := range scenario.storageAccounts {
+		log.Printf("[DEBUG] need to delete the storageAccount: %s", account)
+		err := azConnection.DeleteStorageAccount(azureutil.ResourceGroup(), account)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the storageAccount: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
@@ -0,0 +1,22 @@
+@s-azaat
+Feature: Object Storage Is Only Created With Approved SKUs and Account Kinds
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that my organisation's data is only held in storage with approved redundancy, performance tier and feature set
+
+    Background:
+      Given an Azure subscription is available
+      And azure resource group specified in config exists
+
+    @s-azaat-001
+    Scenario: Prevent Object Storage from Being Created With a Disallowed SKU
+      Given a list of allowed and disallowed storage account SKUs and kinds is provided in config
+      When an attempt to create a storage account with each "allowed" SKU "succeeds"
+      Then an attempt to create a storage account with each "disallowed" SKU "fails"
+
+    @s-azaat-002
+    Scenario: Prevent Object Storage from Being Created With a Legacy or Disallowed Account Kind
+      Given a list of allowed and disallowed storage account SKUs and kinds is provided in config
+      When an attempt to create a storage account of each "allowed" kind "succeeds"
+      Then an attempt to create a storage account of each "disallowed" kind "fails"
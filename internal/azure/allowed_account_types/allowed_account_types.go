@@ -0,0 +1,495 @@
+package azureaat
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	azureStorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/cucumber/godog"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-pack-storage/internal/envvar"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// ProbeStruct allows this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+type scenarioState struct {
+	name            string
+	currentStep     string
+	audit           *audit.ScenarioAudit
+	probe           *audit.Probe
+	ctx             context.Context
+	tags            map[string]*string
+	storageAccounts []string
+	accountTypes    AccountTypes
+}
+
+// Probe ...
+var Probe probeStruct             // Probe allows this probe to be added to the ProbeStore
+var scenario scenarioState        // Local container of scenario state
+var azConnection connection.Azure // Provides functionality to interact with Azure
+
+func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that Azure subscription specified in config file is available; "))
+
+	payload = struct {
+		SubscriptionID string
+		TenantID       string
+		CreationMode   string
+	}{
+		azureutil.SubscriptionID(),
+		azureutil.TenantID(),
+		azureutil.CreationMode(),
+	}
+
+	err = azConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) azureResourceGroupSpecifiedInConfigExists() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check if value for Azure resource group is set in config vars; ")
+	if azureutil.ResourceGroup() == "" {
+		err = utils.ReformatError("Azure resource group config var not set")
+		return err
+	}
+
+	stepTrace.WriteString("Check the resource group exists in the specified azure subscription; ")
+	_, getGrpErr := azConnection.GetResourceGroupByName(azureutil.ResourceGroup())
+	if getGrpErr != nil {
+		err = utils.ReformatError("Azure resource group '%s' does not exists. Error: %v", azureutil.ResourceGroup(), getGrpErr)
+		return err
+	}
+
+	//Audit log
+	payload = struct {
+		SubscriptionID string
+		ResourceGroup  string
+	}{
+		SubscriptionID: azureutil.SubscriptionID(),
+		ResourceGroup:  azureutil.ResourceGroup(),
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) aListOfAllowedAndDisallowedSKUsAndKindsIsProvidedInConfig() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Validate that allowed and disallowed SKUs and kinds are provided in config; ")
+
+	scenario.accountTypes = getAccountTypes()
+
+	//Audit log
+	payload = struct {
+		AccountTypes AccountTypes
+	}{
+		AccountTypes: scenario.accountTypes,
+	}
+
+	if !(len(scenario.accountTypes.AllowedSkus) > 0) || !(len(scenario.accountTypes.AllowedKinds) > 0) {
+		err = utils.ReformatError("The list of allowed SKUs and kinds has not been defined in config")
+		return err
+	}
+	if !(len(scenario.accountTypes.DisallowedSkus) > 0) && !(len(scenario.accountTypes.DisallowedKinds) > 0) {
+		err = utils.ReformatError("The list of disallowed SKUs and kinds has not been defined in config")
+		return err
+	}
+
+	stepTrace.WriteString("Validate that configured SKUs and kinds are known to Azure; ")
+	for _, sku := range append(scenario.accountTypes.AllowedSkus, scenario.accountTypes.DisallowedSkus...) {
+		if !isKnownSku(sku) {
+			err = utils.ReformatError("Unknown storage account SKU '%s' provided in config. Expected values: %v", sku, azureStorage.PossibleSkuNameValues())
+			return err
+		}
+	}
+	for _, kind := range append(scenario.accountTypes.AllowedKinds, scenario.accountTypes.DisallowedKinds...) {
+		if !isKnownKind(kind) {
+			err = utils.ReformatError("Unknown storage account kind '%s' provided in config. Expected values: %v", kind, azureStorage.PossibleKindValues())
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) anAttemptToCreateAStorageAccountWithEachXSKUY(access, expectedResult string) error {
+
+	// Supported values for 'access':
+	//	'allowed'
+	//  'disallowed'
+
+	// Supported values for 'expectedResult':
+	//	'succeeds'
+	//	'fails'
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	var skus []string
+	switch access {
+	case "allowed":
+		skus = scenario.accountTypes.AllowedSkus
+	case "disallowed":
+		skus = scenario.accountTypes.DisallowedSkus
+	default:
+		err = utils.ReformatError("Unexpected value provided for access: '%s' Expected values: ['allowed', 'disallowed']", access)
+		return err
+	}
+
+	// An allowed kind is used so that only the SKU is under test
+	kind := azureStorage.Kind(scenario.accountTypes.AllowedKinds[0])
+
+	var attempts []creationAttempt
+	for _, sku := range skus {
+		stepTrace.WriteString(fmt.Sprintf("Attempt to create Storage Account with SKU '%s' and kind '%s'; ", sku, kind))
+		backendAttempts, attemptErr := scenario.attemptCreation(azureStorage.SkuName(sku), kind, expectedResult)
+		attempts = append(attempts, backendAttempts...)
+		if attemptErr != nil {
+			err = attemptErr
+			break
+		}
+	}
+
+	//Audit log
+	payload = struct {
+		ResourceGroup string
+		Attempts      []creationAttempt
+	}{
+		ResourceGroup: azureutil.ResourceGroup(),
+		Attempts:      attempts,
+	}
+
+	return err
+}
+
+func (scenario *scenarioState) anAttemptToCreateAStorageAccountOfEachXKindY(access, expectedResult string) error {
+
+	// Supported values for 'access':
+	//	'allowed'
+	//  'disallowed'
+
+	// Supported values for 'expectedResult':
+	//	'succeeds'
+	//	'fails'
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	var kinds []string
+	switch access {
+	case "allowed":
+		kinds = scenario.accountTypes.AllowedKinds
+	case "disallowed":
+		kinds = scenario.accountTypes.DisallowedKinds
+	default:
+		err = utils.ReformatError("Unexpected value provided for access: '%s' Expected values: ['allowed', 'disallowed']", access)
+		return err
+	}
+
+	// An allowed SKU is used so that only the kind is under test
+	sku := azureStorage.SkuName(scenario.accountTypes.AllowedSkus[0])
+
+	var attempts []creationAttempt
+	for _, kind := range kinds {
+		stepTrace.WriteString(fmt.Sprintf("Attempt to create Storage Account with SKU '%s' and kind '%s'; ", sku, kind))
+		backendAttempts, attemptErr := scenario.attemptCreation(sku, azureStorage.Kind(kind), expectedResult)
+		attempts = append(attempts, backendAttempts...)
+		if attemptErr != nil {
+			err = attemptErr
+			break
+		}
+	}
+
+	//Audit log
+	payload = struct {
+		ResourceGroup string
+		Attempts      []creationAttempt
+	}{
+		ResourceGroup: azureutil.ResourceGroup(),
+		Attempts:      attempts,
+	}
+
+	return err
+}
+
+// creationAttempt records the outcome of a single storage account creation through one creation backend for auditing
+type creationAttempt struct {
+	Backend            string
+	StorageAccountName string
+	Sku                azureStorage.SkuName
+	Kind               azureStorage.Kind
+	ExpectedResult     string
+	CreationError      string
+}
+
+// attemptCreation creates a storage account with the given SKU and kind through each configured creation backend, stopping at the first unexpected outcome
+func (scenario *scenarioState) attemptCreation(sku azureStorage.SkuName, kind azureStorage.Kind, expectedResult string) (attempts []creationAttempt, err error) {
+
+	// Validate input values
+	var shouldCreate bool
+	switch expectedResult {
+	case "succeeds":
+		shouldCreate = true
+	case "fails":
+		shouldCreate = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return
+	}
+
+	for _, backend := range azureutil.CreationBackends() {
+		attempt := creationAttempt{
+			Backend:            backend,
+			StorageAccountName: utils.RandomString(10),
+			Sku:                sku,
+			Kind:               kind,
+			ExpectedResult:     expectedResult,
+		}
+
+		_, creationErr := azConnection.CreateStorageAccountWithBackend(
+			azureutil.CreationMode(),
+			backend,
+			attempt.StorageAccountName,
+			azureutil.ResourceGroup(),
+			azureStorage.AccountCreateParameters{
+				Sku: &azureStorage.Sku{
+					Name: sku,
+				},
+				Kind: kind,
+				AccountPropertiesCreateParameters: &azureStorage.AccountPropertiesCreateParameters{
+					EnableHTTPSTrafficOnly: to.BoolPtr(true),
+				},
+				Tags: scenario.tags,
+			})
+		if creationErr == nil {
+			scenario.storageAccounts = append(scenario.storageAccounts, attempt.StorageAccountName) // Record for later cleanup
+		} else {
+			attempt.CreationError = creationErr.Error()
+		}
+		attempts = append(attempts, attempt)
+
+		switch shouldCreate {
+		case true:
+			if creationErr != nil {
+				err = utils.ReformatError("Creation of storage account with SKU '%s' and kind '%s' using the '%s' creation backend did not succeed: %v", sku, kind, backend, creationErr)
+			}
+		case false:
+			if creationErr == nil {
+				err = utils.ReformatError("Creation of storage account with SKU '%s' and kind '%s' using the '%s' creation backend succeeded, but should have failed", sku, kind, backend)
+			} else if !azureutil.IsPolicyDenial(creationErr) {
+				// Ensure failure is due to the allowed account types policy
+				err = utils.ReformatError("Creation of storage account with SKU '%s' and kind '%s' using the '%s' creation backend failed with unexpected reason: %v - %v", sku, kind, backend, azureutil.ServiceErrorCode(creationErr), creationErr)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func isKnownSku(sku string) bool {
+	for _, known := range azureStorage.PossibleSkuNameValues() {
+		if string(known) == sku {
+			return true
+		}
+	}
+	return false
+}
+
+func isKnownKind(kind string) bool {
+	for _, known := range azureStorage.PossibleKindValues() {
+		if string(known) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.storageAccounts = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "allowed_account_types"
+}
+
+// Path returns this probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "azure", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize azure connection
+		azConnection = connection.NewAzureConnection(
+			context.Background(),
+			azureutil.SubscriptionID(),
+			azureutil.TenantID(),
+			azureutil.ClientID(),
+			azureutil.ClientSecret(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an Azure subscription is available$`, scenario.anAzureSubscriptionIsAvailable)
+	ctx.Step(`^azure resource group specified in config exists$`, scenario.azureResourceGroupSpecifiedInConfigExists)
+
+	// Steps
+	ctx.Step(`^a list of allowed and disallowed storage account SKUs and kinds is provided in config$`, scenario.aListOfAllowedAndDisallowedSKUsAndKindsIsProvidedInConfig)
+	ctx.Step(`^an attempt to create a storage account with each "([^"]*)" SKU "([^"]*)"$`, scenario.anAttemptToCreateAStorageAccountWithEachXSKUY)
+	ctx.Step(`^an attempt to create a storage account of each "([^"]*)" kind "([^"]*)"$`, scenario.anAttemptToCreateAStorageAccountOfEachXKindY)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing storage accounts used during tests")
+
+	for _, account := range scenario.storageAccounts {
+		log.Printf("[DEBUG] need to delete the storageAccount: %s", account)
+		err := azConnection.DeleteStorageAccount(azureutil.ResourceGroup(), account)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the storageAccount: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
+
+// AccountTypes represents the required config settings for this probe. This shall be removed and replaced with actual config vars once sdk refactor is complete.
+type AccountTypes struct {
+	AllowedSkus     []string `yaml:"AllowedSkus"`     // A list of storage account SKUs which may be used when creating storage accounts
+	DisallowedSkus  []string `yaml:"DisallowedSkus"`  // A list of storage account SKUs which must be denied when creating storage accounts
+	AllowedKinds    []string `yaml:"AllowedKinds"`    // A list of storage account kinds which may be used when creating storage accounts
+	DisallowedKinds []string `yaml:"DisallowedKinds"` // A list of storage account kinds which must be denied when creating storage accounts
+}
+
+// getAccountTypes returns the allowed and disallowed SKUs and kinds. Each list may be overridden as a comma separated list by the
+// environment variables AZURE_ALLOWED_SKUS, AZURE_DISALLOWED_SKUS, AZURE_ALLOWED_KINDS and AZURE_DISALLOWED_KINDS.
+func getAccountTypes() AccountTypes {
+
+	accountTypes := AccountTypes{
+		AllowedSkus: []string{
+			"Standard_GRS",
+			"Standard_RAGRS",
+			"Standard_ZRS",
+		},
+		DisallowedSkus: []string{
+			"Standard_LRS",
+			"Premium_LRS",
+		},
+		AllowedKinds: []string{
+			"StorageV2",
+		},
+		DisallowedKinds: []string{
+			"Storage",
+		},
+	}
+	if allowedSkus := envvar.SplitList(envvar.GetOrDefault("AZURE_ALLOWED_SKUS", "")); len(allowedSkus) > 0 {
+		accountTypes.AllowedSkus = allowedSkus
+	}
+	if disallowedSkus := envvar.SplitList(envvar.GetOrDefault("AZURE_DISALLOWED_SKUS", "")); len(disallowedSkus) > 0 {
+		accountTypes.DisallowedSkus = disallowedSkus
+	}
+	if allowedKinds := envvar.SplitList(envvar.GetOrDefault("AZURE_ALLOWED_KINDS", "")); len(allowedKinds) > 0 {
+		accountTypes.AllowedKinds = allowedKinds
+	}
+	if disallowedKinds := envvar.SplitList(envvar.GetOrDefault("AZURE_DISALLOWED_KINDS", "")); len(disallowedKinds) > 0 {
+		accountTypes.DisallowedKinds = disallowedKinds
+	}
+	return accountTypes
+}
@@ -13,6 +13,18 @@ Feature: Object Storage Has Allowed Network Access Measures Enforced
 
     @s-azana-001
     Scenario: Prevent Object Storage from Being Created Without Allowed Network Source Address
-      Given a list with allowed and disallowed network segments is provided in config
+      Given the control is enforced by "deny"
+      And a list with allowed and disallowed network segments is provided in config
       When an attempt to create a storage account with a list of "allowed" network segments "succeeds"
-      Then an attempt to create a storage account with a list of "disallowed" network segments "fails"
\ No newline at end of file
+      Then an attempt to create a storage account with a list of "disallowed" network segments "fails"
+
+    @s-azana-002
+    Scenario: Remediate Object Storage Created Without Network Access Restrictions
+      Given the control is enforced by "remediate"
+      Then an attempt to create a storage account with network default action "Allow" succeeds and the effective "network default action" is remediated to "Deny" within 600 seconds
+
+    @s-azana-003
+    Scenario: Flag Object Storage Created Without Allowed Network Source Address
+      Given the control is enforced by "audit"
+      And a list with allowed and disallowed network segments is provided in config
+      Then an attempt to create a storage account with a list of "disallowed" network segments "is flagged non-compliant"
@@ -5,13 +5,17 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"time"
 
-	azureStorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-04-01/storage"
+	azureStorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/cucumber/godog"
 
 	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/azure/compliance"
+	"github.com/citihub/probr-pack-storage/internal/azure/policyassignment"
 	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-pack-storage/internal/network"
 	"github.com/citihub/probr-sdk/audit"
 	"github.com/citihub/probr-sdk/probeengine"
 
@@ -22,6 +26,15 @@ import (
 type probeStruct struct {
 }
 
+// creationOutcome records the result of a storage account creation attempt through one creation backend
+type creationOutcome struct {
+	Backend            string
+	StorageAccountName string
+	StorageAccount     azureStorage.Account
+	Error              string
+	Compliance         compliance.Result
+}
+
 type scenarioState struct {
 	name            string
 	currentStep     string
@@ -32,7 +45,7 @@ type scenarioState struct {
 	bucketName      string
 	storageAccount  azureStorage.Account
 	storageAccounts []string
-	networkSegments NetworkSegments
+	networkSegments network.Segments
 }
 
 // Probe ...
@@ -40,6 +53,10 @@ var Probe probeStruct             // Probe allows this probe to be added to the
 var scenario scenarioState        // Local container of scenario state
 var azConnection connection.Azure // Provides functionality to interact with Azure
 
+const remediationPollInterval = 15 * time.Second // How often a storage account is re-read while waiting for policy remediation
+
+var requiredPolicies = policyassignment.Require(Probe.Name(), azureutil.AllowedNetworkSegmentsPolicy) // Assigned for the test run when ephemeral policy assignment is enabled
+
 func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
 
 	// Standard auditing logic to ensures panics are also audited
@@ -56,9 +73,11 @@ func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
 	payload = struct {
 		SubscriptionID string
 		TenantID       string
+		CreationMode   string
 	}{
 		azureutil.SubscriptionID(),
 		azureutil.TenantID(),
+		azureutil.CreationMode(),
 	}
 
 	err = azConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
@@ -114,18 +133,20 @@ func (scenario *scenarioState) aListWithAllowedAndDisallowedNetworkSegmentsIsPro
 		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
 	}()
 
-	stepTrace.WriteString("Validate that allowed and disallowed network segments are provided in config; ")
+	stepTrace.WriteString("Validate that allowed and disallowed network segments are provided in config, and that no disallowed segment overlaps an allowed segment; ")
 
-	scenario.networkSegments = getNetworkSegments()
+	scenario.networkSegments = network.ConfiguredSegments()
 
 	if !(len(scenario.networkSegments.Allowed) > 0) || !(len(scenario.networkSegments.Disallowed) > 0) {
 		err = utils.ReformatError("The list of allowed and disallowed network segments has not been defined in config")
+	} else {
+		err = scenario.networkSegments.Validate()
 	}
 
 	//Audit log
 	payload = struct {
 		//NetworkSegments config.NetworkSegments
-		NetworkSegments NetworkSegments
+		NetworkSegments network.Segments
 	}{
 		NetworkSegments: scenario.networkSegments,
 	}
@@ -142,6 +163,14 @@ func (scenario *scenarioState) anAttemptToCreateAStorageAccountWithAListOfXNetwo
 	// Supported values for 'expectedResult':
 	//	'succeeds'
 	//	'fails'
+	//	'is flagged non-compliant'
+
+	// Compliance is only evaluated for provisioned resources, so skip before auditing the step
+	if expectedResult == "is flagged non-compliant" {
+		if err := azureutil.RequireRealStorageAccount(); err != nil {
+			return err
+		}
+	}
 
 	// Standard auditing logic to ensures panics are also audited
 	stepTrace, payload, err := utils.AuditPlaceholders()
@@ -154,14 +183,17 @@ func (scenario *scenarioState) anAttemptToCreateAStorageAccountWithAListOfXNetwo
 	}()
 
 	// Validate input values
-	var shouldCreate bool
+	var shouldCreate, shouldBeFlagged bool
 	switch expectedResult {
 	case "succeeds":
 		shouldCreate = true
 	case "fails":
 		shouldCreate = false
+	case "is flagged non-compliant":
+		shouldCreate = true
+		shouldBeFlagged = true
 	default:
-		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails', 'is flagged non-compliant']", expectedResult)
 		return err
 	}
 
@@ -176,9 +208,6 @@ func (scenario *scenarioState) anAttemptToCreateAStorageAccountWithAListOfXNetwo
 		return err
 	}
 
-	scenario.bucketName = utils.RandomString(10)
-	stepTrace.WriteString(fmt.Sprintf("Generate a storage account name using a random string: '%s'; ", scenario.bucketName))
-
 	stepTrace.WriteString(fmt.Sprintf("Set IP Rules to allow given IP Ranges %v; ", ipRangeList))
 	var ipRules []azureStorage.IPRule
 	for _, ipRange := range ipRangeList {
@@ -197,7 +226,7 @@ func (scenario *scenarioState) anAttemptToCreateAStorageAccountWithAListOfXNetwo
 		}
 
 		ipRule := azureStorage.IPRule{
-			Action:           azureStorage.Allow,
+			Action:           azureStorage.ActionAllow,
 			IPAddressOrRange: to.StringPtr(ipRange),
 		}
 
@@ -211,47 +240,154 @@ func (scenario *scenarioState) anAttemptToCreateAStorageAccountWithAListOfXNetwo
 		IPRules:       &ipRules,
 	}
 
-	stepTrace.WriteString(fmt.Sprintf("Attempt to create storage bucket with allowed network IP Ranges: %v; ", ipRangeList))
-	storageAccount, creationErr := azConnection.CreateStorageAccount(scenario.bucketName, azureutil.ResourceGroup(), scenario.tags, true, &networkRuleSet)
-
-	scenario.storageAccount = storageAccount
-	if creationErr == nil {
-		scenario.storageAccounts = append(scenario.storageAccounts, scenario.bucketName) // Record for later cleanup
+	parameters := azureStorage.AccountCreateParameters{
+		AccountPropertiesCreateParameters: &azureStorage.AccountPropertiesCreateParameters{
+			EnableHTTPSTrafficOnly: to.BoolPtr(true),
+			NetworkRuleSet:         &networkRuleSet,
+		},
+		Tags: scenario.tags,
 	}
 
-	stepTrace.WriteString(fmt.Sprintf("Validate storage account creation %s; ", expectedResult))
-	switch shouldCreate {
-	case true:
+	var outcomes []creationOutcome
+	for _, backend := range azureutil.CreationBackends() {
+
+		scenario.bucketName = utils.RandomString(10)
+		stepTrace.WriteString(fmt.Sprintf("Generate a storage account name using a random string: '%s'; ", scenario.bucketName))
+
+		stepTrace.WriteString(fmt.Sprintf("Attempt to create storage bucket with allowed network IP Ranges: %v using the '%s' creation backend; ", ipRangeList, backend))
+		storageAccount, creationErr := azConnection.CreateStorageAccountWithBackend(azureutil.CreationMode(), backend, scenario.bucketName, azureutil.ResourceGroup(), parameters)
+
+		scenario.storageAccount = storageAccount
+		if creationErr == nil {
+			scenario.storageAccounts = append(scenario.storageAccounts, scenario.bucketName) // Record for later cleanup
+		}
+
+		outcome := creationOutcome{
+			Backend:            backend,
+			StorageAccountName: scenario.bucketName,
+			StorageAccount:     storageAccount,
+		}
 		if creationErr != nil {
-			err = utils.ReformatError("Creation of storage account did not succeed: %v", creationErr)
+			outcome.Error = creationErr.Error()
 		}
-	case false:
-		if creationErr == nil {
-			err = utils.ReformatError("Creation of storage account succeeded, but should have failed")
+
+		var attemptErr error
+		stepTrace.WriteString(fmt.Sprintf("Validate storage account creation %s; ", expectedResult))
+		switch shouldCreate {
+		case true:
+			if creationErr != nil {
+				attemptErr = utils.ReformatError("Creation of storage account did not succeed: %v", creationErr)
+			}
+		case false:
+			if creationErr == nil {
+				attemptErr = utils.ReformatError("Creation of storage account succeeded, but should have failed")
+			} else if !azureutil.IsPolicyDenial(creationErr) {
+				// Ensure failure is due to the allowed network segments policy
+				attemptErr = utils.ReformatError("Creation of storage account failed with unexpected reason: %v - %v", azureutil.ServiceErrorCode(creationErr), creationErr)
+			}
+		}
+
+		if shouldBeFlagged && attemptErr == nil {
+			stepTrace.WriteString(fmt.Sprintf("Validate that Policy Insights flags the storage account as non-compliant with '%s'; ", azureutil.AllowedNetworkSegmentsPolicy.DisplayName))
+			outcome.Compliance, attemptErr = compliance.WaitForNonCompliance(azConnection, to.String(storageAccount.ID), policyassignment.Definition(Probe.Name(), azureutil.AllowedNetworkSegmentsPolicy), azureutil.ComplianceEvaluationTimeout())
+			if attemptErr == nil && !outcome.Compliance.NonCompliant {
+				attemptErr = utils.ReformatError("Storage account was not flagged non-compliant with '%s' after %s", azureutil.AllowedNetworkSegmentsPolicy.DisplayName, outcome.Compliance.Elapsed)
+			}
+		}
+
+		outcomes = append(outcomes, outcome)
+		if attemptErr != nil && err == nil {
+			err = utils.ReformatError("Creation backend '%s': %v", backend, attemptErr)
 		}
-		//TODO: Is this required? What is the appropriate error?
-		// } else {
-		// 	// stepTrace.WriteString(fmt.Sprintf("Check that storage account creation failed due to expected reason (403 Forbidden); "))
-		// 	// if !errors.IsStatusCode(403, creationErr) {
-		// 	// 	err = utils.ReformatError("Unexpected error during storage account creation : %v", creationErr)
-		// 	// }
-		// }
 	}
 
+	//Audit log
+	payload = struct {
+		ResourceGroup  string
+		NetworkRuleSet azureStorage.NetworkRuleSet
+		Tags           map[string]*string
+		Outcomes       []creationOutcome
+	}{
+		ResourceGroup:  azureutil.ResourceGroup(),
+		NetworkRuleSet: networkRuleSet,
+		Tags:           scenario.tags,
+		Outcomes:       outcomes,
+	}
+
+	return err
+}
+
+func (scenario *scenarioState) anAttemptToCreateAStorageAccountWithNetworkDefaultActionXSucceedsAndTheEffectiveYIsRemediatedToZWithinNSeconds(defaultAction, property, desiredValue string, timeoutSeconds int) error {
+
+	// Supported values for 'defaultAction':
+	//	'Allow'
+	//  'Deny'
+
+	// Supported values for 'property':
+	//	see azureutil.AccountProperty
+
+	// Remediation requires a real storage account, so skip before auditing the step
+	if err := azureutil.RequireRealStorageAccount(); err != nil {
+		return err
+	}
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values
+	var networkRuleSet azureStorage.NetworkRuleSet
+	switch defaultAction {
+	case "Allow":
+		networkRuleSet = azureStorage.NetworkRuleSet{DefaultAction: azureStorage.DefaultActionAllow}
+	case "Deny":
+		networkRuleSet = azureStorage.NetworkRuleSet{DefaultAction: azureStorage.DefaultActionDeny}
+	default:
+		err = utils.ReformatError("Unexpected value provided for defaultAction: '%s' Expected values: ['Allow', 'Deny']", defaultAction)
+		return err
+	}
+
+	scenario.bucketName = utils.RandomString(10)
+	stepTrace.WriteString(fmt.Sprintf("Generate a storage account name using a random string: '%s'; ", scenario.bucketName))
+
+	stepTrace.WriteString(fmt.Sprintf("Create storage account with network default action '%s'; ", defaultAction))
+	storageAccount, creationErr := azConnection.CreateStorageAccount(scenario.bucketName, azureutil.ResourceGroup(), scenario.tags, true, &networkRuleSet)
+	if creationErr != nil {
+		err = utils.ReformatError("Creation of storage account did not succeed: %v", creationErr)
+		return err
+	}
+	scenario.storageAccount = storageAccount
+	scenario.storageAccounts = append(scenario.storageAccounts, scenario.bucketName) // Record for later cleanup
+
+	stepTrace.WriteString(fmt.Sprintf("Re-read the storage account until '%s' is '%s' or %d seconds have elapsed; ", property, desiredValue, timeoutSeconds))
+	remediation, err := azureutil.WaitForRemediation(
+		func() (azureStorage.Account, error) {
+			return azConnection.GetStorageAccountProperties(azureutil.ResourceGroup(), scenario.bucketName)
+		},
+		property, desiredValue, time.Duration(timeoutSeconds)*time.Second, remediationPollInterval)
+
 	//Audit log
 	payload = struct {
 		StorageAccountName string
 		ResourceGroup      string
-		StorageAccount     azureStorage.Account
 		NetworkRuleSet     azureStorage.NetworkRuleSet
-		Tags               map[string]*string
+		Remediation        azureutil.Remediation
 	}{
 		StorageAccountName: scenario.bucketName,
 		ResourceGroup:      azureutil.ResourceGroup(),
-		StorageAccount:     scenario.storageAccount,
 		NetworkRuleSet:     networkRuleSet,
+		Remediation:        remediation,
 	}
 
+	if err == nil && !remediation.Remediated {
+		err = utils.ReformatError("Effective '%s' of storage account was '%s' after %s, expected it to be remediated to '%s'", property, remediation.Effective, remediation.Elapsed, desiredValue)
+	}
 	return err
 }
 
@@ -261,6 +397,7 @@ func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
 	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
 	s.ctx = context.Background()
 	s.storageAccounts = make([]string, 0)
+	s.tags = policyassignment.Tags(probeName)
 	probeengine.LogScenarioStart(gs)
 }
 
@@ -295,9 +432,13 @@ func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
 			azureutil.ClientID(),
 			azureutil.ClientSecret(),
 		)
-	})
 
-	ctx.AfterSuite(func() {
+		// Policies required by every probe are assigned once, by the first probe to run
+		if azureutil.EphemeralPolicyAssignment() {
+			if err := policyassignment.Ensure(azConnection); err != nil {
+				log.Printf("[ERROR] Ephemeral policy assignment failed: %v", err)
+			}
+		}
 	})
 }
 
@@ -313,8 +454,12 @@ func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
 	ctx.Step(`^azure resource group specified in config exists$`, scenario.azureResourceGroupSpecifiedInConfigExists)
 
 	// Steps
+	ctx.Step(`^the control is enforced by "([^"]*)"$`, func(enforcement string) error {
+		return azureutil.TheControlIsEnforcedByX(scenario.audit, scenario.currentStep, enforcement)
+	})
 	ctx.Step(`^a list with allowed and disallowed network segments is provided in config$`, scenario.aListWithAllowedAndDisallowedNetworkSegmentsIsProvidedInConfig)
 	ctx.Step(`^an attempt to create a storage account with a list of "([^"]*)" network segments "([^"]*)"$`, scenario.anAttemptToCreateAStorageAccountWithAListOfXNetworkSegmentsY)
+	ctx.Step(`^an attempt to create a storage account with network default action "([^"]*)" succeeds and the effective "([^"]*)" is remediated to "([^"]*)" within (\d+) seconds$`, scenario.anAttemptToCreateAStorageAccountWithNetworkDefaultActionXSucceedsAndTheEffectiveYIsRemediatedToZWithinNSeconds)
 
 	ctx.AfterScenario(func(s *godog.Scenario, err error) {
 		afterScenario(scenario, probe, s, err)
@@ -344,26 +489,3 @@ func teardown() {
 
 	log.Println("[DEBUG] Teardown completed")
 }
-
-// NetworkSegments represents the required config settings fr this probe. This shall be removed and replaced with actual config vars once sdk refactor is complete.
-type NetworkSegments struct {
-	Allowed    []string `yaml:"Allowed"`    // A list of allowed network segments to be used when creating storage accounts
-	Disallowed []string `yaml:"Disallowed"` // A list of disallowed network segments to be used when creating storage accounts
-}
-
-func getNetworkSegments() NetworkSegments {
-
-	//return config.Vars.ServicePacks.Storage.NetworkSegments
-
-	// TODO: This is here until config refactoring in SDK is finished
-	return NetworkSegments{
-		Allowed: []string{
-			"219.79.19.0/24",
-			"170.74.231.168",
-		},
-		Disallowed: []string{
-			"219.79.19.1",
-			"219.108.32.1",
-		},
-	}
-}
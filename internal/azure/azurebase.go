@@ -3,7 +3,13 @@ package azure
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/cucumber/godog"
+
+	"github.com/citihub/probr-pack-storage/internal/envvar"
 	"github.com/citihub/probr-sdk/config"
 	"github.com/citihub/probr-sdk/utils"
 )
@@ -11,6 +17,33 @@ import (
 var prefix string
 var rgName string
 
+// Supported values for ProbeMode
+const (
+	PreventiveMode = "preventive" // Probes create test resources and expect policy to act on them
+	DetectiveMode  = "detective"  // Probes evaluate existing storage accounts without creating resources
+	IaCMode        = "iac"        // Probes evaluate storage accounts defined in infrastructure as code files, without connecting to Azure
+)
+
+// Supported values for CreationMode
+const (
+	CreationModeCreate   = "create"   // Storage accounts are provisioned and deleted after each scenario
+	CreationModeValidate = "validate" // Storage accounts are submitted as ARM deployment validation requests and not provisioned
+	CreationModeWhatIf   = "whatif"   // Storage accounts are submitted as ARM what-if requests and not provisioned
+)
+
+// Supported values for Enforcement
+const (
+	EnforcementDeny      = "deny"      // Policies deny the creation of non-compliant storage accounts
+	EnforcementRemediate = "remediate" // Policies with a Modify, Append or DeployIfNotExists effect remediate non-compliant storage accounts after creation
+	EnforcementAudit     = "audit"     // Policies with an Audit effect flag non-compliant storage accounts in Policy Insights
+)
+
+// Supported values for CreationBackends
+const (
+	CreationBackendSDK      = "sdk"      // Storage accounts are created directly through the storage resource provider
+	CreationBackendTemplate = "template" // Storage accounts are created through an ARM template deployment to the resource group
+)
+
 //TenantID returns the azure Tenant in which the tests should be executed, configured by the user and may be set by the environment variable AZURE_TENANT_ID.
 func TenantID() string {
 	if config.Vars.CloudProviders.Azure.TenantID == "" {
@@ -64,6 +97,124 @@ func ManagementGroup() string {
 	return config.Vars.CloudProviders.Azure.ManagementGroup
 }
 
+//ProbeMode returns whether probes run in preventive, detective or IaC mode, defaults to preventive and may be set by the environment variable PROBR_STORAGE_MODE.
+func ProbeMode() string {
+	mode := strings.ToLower(envvar.GetOrDefault("PROBR_STORAGE_MODE", PreventiveMode))
+	if mode != PreventiveMode && mode != DetectiveMode && mode != IaCMode {
+		log.Printf("[ERROR] Unexpected value for PROBR_STORAGE_MODE: '%s'. Expected values: ['%s', '%s', '%s']. Using '%s'", mode, PreventiveMode, DetectiveMode, IaCMode, PreventiveMode)
+		return PreventiveMode
+	}
+	return mode
+}
+
+//IaCDirectory returns the directory of ARM template and Terraform plan JSON files evaluated in IaC mode, set by the environment variable PROBR_IAC_DIRECTORY.
+func IaCDirectory() string {
+	return envvar.GetOrDefault("PROBR_IAC_DIRECTORY", "")
+}
+
+//CreationMode returns how probes submit the storage accounts they create, defaults to create and may be set by the environment variable AZURE_CREATION_MODE.
+func CreationMode() string {
+	mode := strings.ToLower(envvar.GetOrDefault("AZURE_CREATION_MODE", CreationModeCreate))
+	if mode != CreationModeCreate && mode != CreationModeValidate && mode != CreationModeWhatIf {
+		log.Printf("[ERROR] Unexpected value for AZURE_CREATION_MODE: '%s'. Expected values: ['%s', '%s', '%s']. Using '%s'", mode, CreationModeCreate, CreationModeValidate, CreationModeWhatIf, CreationModeCreate)
+		return CreationModeCreate
+	}
+	return mode
+}
+
+//CreationBackends returns the paths through which probes create storage accounts, as policy enforcement can differ between them. Defaults to sdk and may be set as a comma separated list by the environment variable AZURE_CREATION_BACKENDS. Only the template backend is returned in validate and whatif creation modes.
+func CreationBackends() []string {
+	var backends []string
+	for _, backend := range envvar.SplitList(envvar.GetOrDefault("AZURE_CREATION_BACKENDS", CreationBackendSDK)) {
+		backend = strings.ToLower(backend)
+		if backend != CreationBackendSDK && backend != CreationBackendTemplate {
+			log.Printf("[ERROR] Unexpected value for AZURE_CREATION_BACKENDS: '%s'. Expected values: ['%s', '%s']", backend, CreationBackendSDK, CreationBackendTemplate)
+			continue
+		}
+		backends = append(backends, backend)
+	}
+	if len(backends) == 0 {
+		backends = []string{CreationBackendSDK}
+	}
+	if CreationMode() != CreationModeCreate {
+		// ARM deployment validation and what-if requests are always templates, whichever backend is configured
+		log.Printf("[DEBUG] Storage accounts are submitted as ARM deployment requests in '%s' creation mode. Using the '%s' creation backend instead of %v", CreationMode(), CreationBackendTemplate, backends)
+		return []string{CreationBackendTemplate}
+	}
+	return backends
+}
+
+//Enforcement returns how the policies assigned to the subscription enforce controls, which selects the scenarios that run. Defaults to deny and may be set by the environment variable AZURE_ENFORCEMENT.
+func Enforcement() string {
+	enforcement := strings.ToLower(envvar.GetOrDefault("AZURE_ENFORCEMENT", EnforcementDeny))
+	if enforcement != EnforcementDeny && enforcement != EnforcementRemediate && enforcement != EnforcementAudit {
+		log.Printf("[ERROR] Unexpected value for AZURE_ENFORCEMENT: '%s'. Expected values: ['%s', '%s', '%s']. Using '%s'", enforcement, EnforcementDeny, EnforcementRemediate, EnforcementAudit, EnforcementDeny)
+		return EnforcementDeny
+	}
+	return enforcement
+}
+
+//RequireEnforcement returns godog.ErrPending if the configured Enforcement is not the one a scenario tests, so that scenarios for other enforcement mechanisms are skipped. Steps should check it before auditing, so that skipped steps are not recorded as failures.
+func RequireEnforcement(enforcement string) error {
+	if enforcement != EnforcementDeny && enforcement != EnforcementRemediate && enforcement != EnforcementAudit {
+		return utils.ReformatError("Unexpected value provided for enforcement: '%s' Expected values: ['%s', '%s', '%s']", enforcement, EnforcementDeny, EnforcementRemediate, EnforcementAudit)
+	}
+	if Enforcement() != enforcement {
+		log.Printf("[WARN] Skipping scenario for '%s' enforcement, as AZURE_ENFORCEMENT is '%s'", enforcement, Enforcement())
+		return godog.ErrPending
+	}
+	return nil
+}
+
+//RequireRealStorageAccount returns godog.ErrPending if storage accounts are not provisioned in the configured CreationMode, so that steps which need a real storage account are skipped. Steps should check it before auditing, so that skipped steps are not recorded as failures.
+func RequireRealStorageAccount() error {
+	if CreationMode() != CreationModeCreate {
+		log.Printf("[WARN] Skipping step which requires a real storage account, as AZURE_CREATION_MODE is '%s'", CreationMode())
+		return godog.ErrPending
+	}
+	return nil
+}
+
+//DetectiveResourceGroups returns the resource groups whose storage accounts are evaluated in detective mode. If empty, every storage account in the subscription is evaluated. May be set as a comma separated list by the environment variable AZURE_DETECTIVE_RESOURCE_GROUPS.
+func DetectiveResourceGroups() []string {
+	return envvar.SplitList(envvar.GetOrDefault("AZURE_DETECTIVE_RESOURCE_GROUPS", ""))
+}
+
+//InventorySubscriptions returns the subscriptions whose storage accounts are evaluated in detective mode using a single Azure Resource Graph query, instead of listing accounts subscription by subscription. May be set as a comma separated list by the environment variable AZURE_INVENTORY_SUBSCRIPTIONS.
+func InventorySubscriptions() []string {
+	return envvar.SplitList(envvar.GetOrDefault("AZURE_INVENTORY_SUBSCRIPTIONS", ""))
+}
+
+//EphemeralPolicyAssignment returns whether the policies required by each probe are assigned to the Probr resource group for the duration of the test run. Intended for sandbox subscriptions, may be set by the environment variable AZURE_EPHEMERAL_POLICY_ASSIGNMENT.
+func EphemeralPolicyAssignment() bool {
+	enabled, err := strconv.ParseBool(envvar.GetOrDefault("AZURE_EPHEMERAL_POLICY_ASSIGNMENT", "false"))
+	if err != nil {
+		log.Printf("[ERROR] Unexpected value for AZURE_EPHEMERAL_POLICY_ASSIGNMENT: %v. Ephemeral policy assignment is disabled", err)
+		return false
+	}
+	return enabled
+}
+
+//PolicyPropagationWait returns how long to wait for ephemeral policy assignments to take effect, defaults to 300 seconds and may be set in seconds by the environment variable AZURE_POLICY_PROPAGATION_WAIT.
+func PolicyPropagationWait() time.Duration {
+	seconds, err := strconv.Atoi(envvar.GetOrDefault("AZURE_POLICY_PROPAGATION_WAIT", "300"))
+	if err != nil || seconds < 0 {
+		log.Printf("[ERROR] Unexpected value for AZURE_POLICY_PROPAGATION_WAIT: expected a number of seconds. Using 300")
+		seconds = 300
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+//ComplianceEvaluationTimeout returns how long to wait for Azure Policy Insights to flag a resource as non-compliant, defaults to 1800 seconds and may be set in seconds by the environment variable AZURE_COMPLIANCE_EVALUATION_TIMEOUT.
+func ComplianceEvaluationTimeout() time.Duration {
+	seconds, err := strconv.Atoi(envvar.GetOrDefault("AZURE_COMPLIANCE_EVALUATION_TIMEOUT", "1800"))
+	if err != nil || seconds <= 0 {
+		log.Printf("[ERROR] Unexpected value for AZURE_COMPLIANCE_EVALUATION_TIMEOUT: expected a number of seconds. Using 1800")
+		seconds = 1800
+	}
+	return time.Duration(seconds) * time.Second
+}
+
 func randomPrefix() string {
 	if prefix == "" {
 		prefix = "test" + utils.RandomString(6) + ""
@@ -0,0 +1,70 @@
+// Package compliance checks the policy compliance state reported by Azure Policy Insights for resources created by the probes.
+// This allows controls enforced with an Audit effect to be verified, where creation of a non-compliant resource succeeds.
+package compliance
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/to"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+)
+
+// NonCompliant is the compliance state reported by Policy Insights for a resource which does not comply with a policy
+const NonCompliant = "NonCompliant"
+
+// Result records the policy compliance states reported for a resource
+type Result struct {
+	ResourceID         string
+	PolicyDefinitionID string
+	ComplianceStates   map[string]string // Compliance state by policy assignment ID
+	Evaluations        int
+	Elapsed            string
+	NonCompliant       bool
+}
+
+// WaitForNonCompliance triggers policy evaluations of the Probr resource group until Policy Insights reports the
+// resource as NonCompliant against the policy definition, or the timeout expires. An error is returned only if an
+// evaluation cannot be triggered or queried; a resource which is not flagged in time is reported via NonCompliant.
+func WaitForNonCompliance(az connection.Azure, resourceID string, definition azureutil.PolicyDefinition, timeout time.Duration) (r Result, err error) {
+
+	r = Result{
+		ResourceID:         resourceID,
+		PolicyDefinitionID: definition.ID(),
+		ComplianceStates:   make(map[string]string),
+	}
+
+	start := time.Now()
+	for time.Since(start) < timeout {
+		r.Evaluations++
+		log.Printf("[INFO] Waiting for policy evaluation %d of '%s' against '%s'", r.Evaluations, resourceID, definition.DisplayName)
+
+		err = az.TriggerPolicyEvaluation(azureutil.ResourceGroup())
+		if err != nil {
+			return
+		}
+
+		states, listErr := az.ListResourcePolicyStates(resourceID, definition.ID())
+		if listErr != nil {
+			err = listErr
+			return
+		}
+
+		for _, state := range states {
+			r.ComplianceStates[to.String(state.PolicyAssignmentID)] = to.String(state.ComplianceState)
+			if strings.EqualFold(to.String(state.ComplianceState), NonCompliant) {
+				r.NonCompliant = true
+			}
+		}
+
+		r.Elapsed = time.Since(start).Round(time.Second).String()
+		if r.NonCompliant {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,136 @@
+// Package controls evaluates the properties of a storage account against the security controls encoded in the probes of this pack,
+// so that existing storage accounts can be checked without creating new ones.
+package controls
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// Names of the supported controls
+const (
+	HTTPSOnly    = "https only"
+	NetworkRules = "network rules"
+	PublicAccess = "public access"
+	Encryption   = "encryption"
+	MinimumTLS   = "minimum tls"
+)
+
+// Result is the outcome of evaluating a storage account against a single control
+type Result struct {
+	Control   string
+	Compliant bool
+	Reason    string
+}
+
+// Evaluator evaluates a storage account against a single control
+type Evaluator func(account storage.Account) Result
+
+var evaluators = map[string]Evaluator{
+	HTTPSOnly:    EvaluateHTTPSOnly,
+	NetworkRules: EvaluateNetworkRules,
+	PublicAccess: EvaluatePublicAccess,
+	Encryption:   EvaluateEncryption,
+	MinimumTLS:   EvaluateMinimumTLS,
+}
+
+// Get returns the evaluator for the named control
+func Get(name string) (Evaluator, error) {
+	evaluator, ok := evaluators[name]
+	if !ok {
+		return nil, utils.ReformatError("Unexpected control: '%s' Expected values: %v", name, Names())
+	}
+	return evaluator, nil
+}
+
+// Names returns the names of all supported controls
+func Names() []string {
+	var names []string
+	for name := range evaluators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// EvaluateHTTPSOnly checks that the storage account only accepts HTTPS traffic
+func EvaluateHTTPSOnly(account storage.Account) Result {
+	result := Result{Control: HTTPSOnly}
+	properties := account.AccountProperties
+	switch {
+	case properties == nil || properties.EnableHTTPSTrafficOnly == nil:
+		result.Reason = "EnableHTTPSTrafficOnly is not set"
+	case !*properties.EnableHTTPSTrafficOnly:
+		result.Reason = "EnableHTTPSTrafficOnly is false"
+	default:
+		result.Compliant = true
+	}
+	return result
+}
+
+// EvaluateNetworkRules checks that the storage account denies network access by default
+func EvaluateNetworkRules(account storage.Account) Result {
+	result := Result{Control: NetworkRules}
+	properties := account.AccountProperties
+	switch {
+	case properties == nil:
+		result.Reason = "Account properties are not available"
+	case properties.PublicNetworkAccess == storage.PublicNetworkAccessDisabled:
+		result.Compliant = true
+	case properties.NetworkRuleSet == nil:
+		result.Reason = "NetworkRuleSet is not set"
+	case properties.NetworkRuleSet.DefaultAction != storage.DefaultActionDeny:
+		result.Reason = fmt.Sprintf("NetworkRuleSet.DefaultAction is '%s'", properties.NetworkRuleSet.DefaultAction)
+	default:
+		result.Compliant = true
+	}
+	return result
+}
+
+// EvaluatePublicAccess checks that anonymous public read access to blobs is disallowed
+func EvaluatePublicAccess(account storage.Account) Result {
+	result := Result{Control: PublicAccess}
+	properties := account.AccountProperties
+	switch {
+	case properties == nil || properties.AllowBlobPublicAccess == nil:
+		result.Reason = "AllowBlobPublicAccess is not set"
+	case *properties.AllowBlobPublicAccess:
+		result.Reason = "AllowBlobPublicAccess is true"
+	default:
+		result.Compliant = true
+	}
+	return result
+}
+
+// EvaluateEncryption checks that the storage account is encrypted at rest with customer managed keys
+func EvaluateEncryption(account storage.Account) Result {
+	result := Result{Control: Encryption}
+	properties := account.AccountProperties
+	switch {
+	case properties == nil || properties.Encryption == nil:
+		result.Reason = "Encryption is not set"
+	case properties.Encryption.KeySource != storage.KeySourceMicrosoftKeyvault:
+		result.Reason = fmt.Sprintf("Encryption.KeySource is '%s'", properties.Encryption.KeySource)
+	default:
+		result.Compliant = true
+	}
+	return result
+}
+
+// EvaluateMinimumTLS checks that the storage account rejects requests using TLS versions older than 1.2
+func EvaluateMinimumTLS(account storage.Account) Result {
+	result := Result{Control: MinimumTLS}
+	properties := account.AccountProperties
+	switch {
+	case properties == nil || properties.MinimumTLSVersion == "":
+		result.Reason = "MinimumTLSVersion is not set"
+	case properties.MinimumTLSVersion != storage.MinimumTLSVersionTLS12:
+		result.Reason = fmt.Sprintf("MinimumTLSVersion is '%s'", properties.MinimumTLSVersion)
+	default:
+		result.Compliant = true
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package controls
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		control string
+		wantErr bool
+	}{
+		{"TestCase1_KnownControl_ShouldReturnEvaluator", HTTPSOnly, false},
+		{"TestCase2_UnknownControl_ShouldReturnError", "unknown", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluator, err := Get(tt.control)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && evaluator == nil {
+				t.Errorf("Get() returned nil evaluator for '%s'", tt.control)
+			}
+		})
+	}
+}
+
+func TestEvaluators(t *testing.T) {
+	tests := []struct {
+		name          string
+		evaluator     Evaluator
+		properties    *storage.AccountProperties
+		wantCompliant bool
+	}{
+		{"TestCase1_HTTPSOnlyEnabled_ShouldBeCompliant", EvaluateHTTPSOnly,
+			&storage.AccountProperties{EnableHTTPSTrafficOnly: to.BoolPtr(true)}, true},
+		{"TestCase2_HTTPSOnlyDisabled_ShouldNotBeCompliant", EvaluateHTTPSOnly,
+			&storage.AccountProperties{EnableHTTPSTrafficOnly: to.BoolPtr(false)}, false},
+		{"TestCase3_NoProperties_ShouldNotBeCompliant", EvaluateHTTPSOnly,
+			nil, false},
+		{"TestCase4_NetworkDefaultDeny_ShouldBeCompliant", EvaluateNetworkRules,
+			&storage.AccountProperties{NetworkRuleSet: &storage.NetworkRuleSet{DefaultAction: storage.DefaultActionDeny}}, true},
+		{"TestCase5_NetworkDefaultAllow_ShouldNotBeCompliant", EvaluateNetworkRules,
+			&storage.AccountProperties{NetworkRuleSet: &storage.NetworkRuleSet{DefaultAction: storage.DefaultActionAllow}}, false},
+		{"TestCase6_PublicNetworkAccessDisabled_ShouldBeCompliant", EvaluateNetworkRules,
+			&storage.AccountProperties{PublicNetworkAccess: storage.PublicNetworkAccessDisabled}, true},
+		{"TestCase7_PublicAccessDisallowed_ShouldBeCompliant", EvaluatePublicAccess,
+			&storage.AccountProperties{AllowBlobPublicAccess: to.BoolPtr(false)}, true},
+		{"TestCase8_PublicAccessNotSet_ShouldNotBeCompliant", EvaluatePublicAccess,
+			&storage.AccountProperties{}, false},
+		{"TestCase9_CustomerManagedKey_ShouldBeCompliant", EvaluateEncryption,
+			&storage.AccountProperties{Encryption: &storage.Encryption{KeySource: storage.KeySourceMicrosoftKeyvault}}, true},
+		{"TestCase10_MicrosoftManagedKey_ShouldNotBeCompliant", EvaluateEncryption,
+			&storage.AccountProperties{Encryption: &storage.Encryption{KeySource: storage.KeySourceMicrosoftStorage}}, false},
+		{"TestCase11_MinimumTLS12_ShouldBeCompliant", EvaluateMinimumTLS,
+			&storage.AccountProperties{MinimumTLSVersion: storage.MinimumTLSVersionTLS12}, true},
+		{"TestCase12_MinimumTLS10_ShouldNotBeCompliant", EvaluateMinimumTLS,
+			&storage.AccountProperties{MinimumTLSVersion: storage.MinimumTLSVersionTLS10}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.evaluator(storage.Account{AccountProperties: tt.properties})
+			if result.Compliant != tt.wantCompliant {
+				t.Errorf("%s evaluation = %v (%s), want %v", result.Control, result.Compliant, result.Reason, tt.wantCompliant)
+			}
+		})
+	}
+}
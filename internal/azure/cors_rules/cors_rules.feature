@@ -0,0 +1,23 @@
+@s-azcors
+Feature: Object Storage CORS Rules Are Restricted
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that my organisation's data cannot be read or modified by scripts running on arbitrary websites
+
+    Background:
+      Given an Azure subscription is available
+      And azure resource group specified in config exists
+
+    @s-azcors-001
+    Scenario: Prevent Object Storage from Allowing CORS Requests From Any Origin or With Any Method
+      Given the scenario requires a real storage account
+      And a list of allowed CORS origins is provided in config
+      And a storage account is created for CORS testing
+      When an attempt to set a CORS rule with "allowed origins and methods" on the storage account "succeeds"
+      Then an attempt to set a CORS rule with "wildcard origin" on the storage account "fails"
+      And an attempt to set a CORS rule with "wildcard methods" on the storage account "fails"
+
+    @s-azcors-002
+    Scenario: Detect Object Storage With Overly Permissive CORS Rules
+      Then no storage account in the resource group has overly permissive CORS rules
@@ -0,0 +1,542 @@
+package azurecors
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	azureStorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/cucumber/godog"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-pack-storage/internal/envvar"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// ProbeStruct allows this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+type scenarioState struct {
+	name            string
+	currentStep     string
+	audit           *audit.ScenarioAudit
+	probe           *audit.Probe
+	ctx             context.Context
+	tags            map[string]*string
+	bucketName      string
+	storageAccounts []string
+	corsOrigins     CorsOrigins
+}
+
+// Probe ...
+var Probe probeStruct             // Probe allows this probe to be added to the ProbeStore
+var scenario scenarioState        // Local container of scenario state
+var azConnection connection.Azure // Provides functionality to interact with Azure
+
+// allMethods lists every HTTP method supported by blob service CORS rules. Azure does not accept '*' for methods, so allowing all of them is the equivalent of a wildcard.
+var allMethods = []string{"DELETE", "GET", "HEAD", "MERGE", "POST", "OPTIONS", "PUT", "PATCH"}
+
+func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that Azure subscription specified in config file is available; "))
+
+	payload = struct {
+		SubscriptionID string
+		TenantID       string
+		CreationMode   string
+	}{
+		azureutil.SubscriptionID(),
+		azureutil.TenantID(),
+		azureutil.CreationMode(),
+	}
+
+	err = azConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) azureResourceGroupSpecifiedInConfigExists() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check if value for Azure resource group is set in config vars; ")
+	if azureutil.ResourceGroup() == "" {
+		err = utils.ReformatError("Azure resource group config var not set")
+		return err
+	}
+
+	stepTrace.WriteString("Check the resource group exists in the specified azure subscription; ")
+	_, getGrpErr := azConnection.GetResourceGroupByName(azureutil.ResourceGroup())
+	if getGrpErr != nil {
+		err = utils.ReformatError("Azure resource group '%s' does not exists. Error: %v", azureutil.ResourceGroup(), getGrpErr)
+		return err
+	}
+
+	//Audit log
+	payload = struct {
+		SubscriptionID string
+		ResourceGroup  string
+	}{
+		SubscriptionID: azureutil.SubscriptionID(),
+		ResourceGroup:  azureutil.ResourceGroup(),
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) aListOfAllowedCORSOriginsIsProvidedInConfig() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Validate that allowed CORS origins and methods are provided in config; ")
+
+	scenario.corsOrigins = getCorsOrigins()
+
+	//Audit log
+	payload = struct {
+		CorsOrigins CorsOrigins
+	}{
+		CorsOrigins: scenario.corsOrigins,
+	}
+
+	if !(len(scenario.corsOrigins.AllowedOrigins) > 0) || !(len(scenario.corsOrigins.AllowedMethods) > 0) {
+		err = utils.ReformatError("The list of allowed CORS origins and methods has not been defined in config")
+		return err
+	}
+	for _, origin := range scenario.corsOrigins.AllowedOrigins {
+		if origin == "*" {
+			err = utils.ReformatError("The list of allowed CORS origins provided in config must not contain a wildcard")
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) aStorageAccountIsCreatedForCORSTesting() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	scenario.bucketName = utils.RandomString(10)
+	stepTrace.WriteString(fmt.Sprintf("Generate a storage account name using a random string: '%s'; ", scenario.bucketName))
+
+	stepTrace.WriteString("Create Storage Account with HTTPS only; ")
+	storageAccount, creationErr := azConnection.CreateStorageAccountWithParameters(
+		scenario.bucketName,
+		azureutil.ResourceGroup(),
+		azureStorage.AccountCreateParameters{
+			AccountPropertiesCreateParameters: &azureStorage.AccountPropertiesCreateParameters{
+				EnableHTTPSTrafficOnly: to.BoolPtr(true),
+			},
+			Tags: scenario.tags,
+		})
+	if creationErr != nil {
+		err = utils.ReformatError("Creation of storage account did not succeed: %v", creationErr)
+		return err
+	}
+	scenario.storageAccounts = append(scenario.storageAccounts, scenario.bucketName) // Record for later cleanup
+
+	//Audit log
+	payload = struct {
+		StorageAccountName string
+		ResourceGroup      string
+		StorageAccount     azureStorage.Account
+	}{
+		StorageAccountName: scenario.bucketName,
+		ResourceGroup:      azureutil.ResourceGroup(),
+		StorageAccount:     storageAccount,
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) anAttemptToSetACORSRuleWithXOnTheStorageAccountY(ruleOption, expectedResult string) error {
+
+	// Supported values for 'ruleOption':
+	//	'allowed origins and methods'
+	//	'wildcard origin'
+	//	'wildcard methods'
+
+	// Supported values for 'expectedResult':
+	//	'succeeds'
+	//	'fails'
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values - ruleOption
+	corsRule := azureStorage.CorsRule{
+		AllowedOrigins:  to.StringSlicePtr(scenario.corsOrigins.AllowedOrigins),
+		AllowedMethods:  to.StringSlicePtr(scenario.corsOrigins.AllowedMethods),
+		AllowedHeaders:  to.StringSlicePtr([]string{"x-ms-meta-*"}),
+		ExposedHeaders:  to.StringSlicePtr([]string{"x-ms-meta-*"}),
+		MaxAgeInSeconds: to.Int32Ptr(200),
+	}
+	switch ruleOption {
+	case "allowed origins and methods":
+	case "wildcard origin":
+		corsRule.AllowedOrigins = to.StringSlicePtr([]string{"*"})
+	case "wildcard methods":
+		corsRule.AllowedMethods = to.StringSlicePtr(allMethods)
+	default:
+		err = utils.ReformatError("Unexpected value provided for ruleOption: '%s' Expected values: ['allowed origins and methods', 'wildcard origin', 'wildcard methods']", ruleOption)
+		return err
+	}
+
+	// Validate input values - expectedResult
+	var shouldSet bool
+	switch expectedResult {
+	case "succeeds":
+		shouldSet = true
+	case "fails":
+		shouldSet = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Attempt to set CORS rule with %s on Storage Account '%s'; ", ruleOption, scenario.bucketName))
+	_, setErr := azConnection.SetBlobServiceProperties(
+		azureutil.ResourceGroup(),
+		scenario.bucketName,
+		azureStorage.BlobServiceProperties{
+			BlobServicePropertiesProperties: &azureStorage.BlobServicePropertiesProperties{
+				Cors: &azureStorage.CorsRules{
+					CorsRules: &[]azureStorage.CorsRule{corsRule},
+				},
+			},
+		})
+
+	stepTrace.WriteString(fmt.Sprintf("Validate that setting the CORS rule %s; ", expectedResult))
+	var offendingRules []azureStorage.CorsRule
+	switch shouldSet {
+	case true:
+		if setErr != nil {
+			err = utils.ReformatError("Setting CORS rule with %s did not succeed: %v", ruleOption, setErr)
+		}
+	case false:
+		if setErr != nil {
+			if !isRefusal(setErr) {
+				// Ensure failure is due to a policy or the storage account refusing the rule, and not to an invalid request
+				err = utils.ReformatError("Setting CORS rule with %s failed with unexpected reason: %v - %v", ruleOption, azureutil.ServiceErrorCode(setErr), setErr)
+			}
+		} else {
+			// The rule was accepted, so read it back to confirm whether it is in effect and flag it
+			stepTrace.WriteString("CORS rule was accepted; read back blob service properties to flag offending rules; ")
+			blobServiceProperties, getErr := azConnection.GetBlobServiceProperties(azureutil.ResourceGroup(), scenario.bucketName)
+			if getErr != nil {
+				err = utils.ReformatError("Failed to read back blob service properties: %v", getErr)
+				break
+			}
+			offendingRules = getOffendingCorsRules(blobServiceProperties)
+			err = utils.ReformatError("Setting CORS rule with %s succeeded, but should have failed. %d overly permissive CORS rule(s) flagged", ruleOption, len(offendingRules))
+		}
+	}
+
+	setError := ""
+	if setErr != nil {
+		setError = setErr.Error()
+	}
+
+	//Audit log
+	payload = struct {
+		StorageAccountName string
+		ResourceGroup      string
+		CorsRule           azureStorage.CorsRule
+		SetError           string
+		OffendingRules     []azureStorage.CorsRule
+	}{
+		StorageAccountName: scenario.bucketName,
+		ResourceGroup:      azureutil.ResourceGroup(),
+		CorsRule:           corsRule,
+		SetError:           setError,
+		OffendingRules:     offendingRules,
+	}
+
+	return err
+}
+
+func (scenario *scenarioState) noStorageAccountInTheResourceGroupHasOverlyPermissiveCORSRules() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString(fmt.Sprintf("List Storage Accounts in resource group '%s'; ", azureutil.ResourceGroup()))
+	accounts, listErr := azConnection.ListStorageAccountsByResourceGroup(azureutil.ResourceGroup())
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list storage accounts: %v", listErr)
+		return err
+	}
+
+	stepTrace.WriteString("Check blob service CORS rules of each account for wildcard origins or methods; ")
+	var findings []corsFinding
+	for _, account := range accounts {
+		accountName := to.String(account.Name)
+		blobServiceProperties, getErr := azConnection.GetBlobServiceProperties(azureutil.ResourceGroup(), accountName)
+		if getErr != nil {
+			findings = append(findings, corsFinding{
+				StorageAccountName: accountName,
+				Error:              getErr.Error(),
+			})
+			continue
+		}
+		if offendingRules := getOffendingCorsRules(blobServiceProperties); len(offendingRules) > 0 {
+			findings = append(findings, corsFinding{
+				StorageAccountName: accountName,
+				OffendingRules:     offendingRules,
+			})
+		}
+	}
+
+	if len(findings) > 0 {
+		err = utils.ReformatError("%d of %d storage accounts have overly permissive CORS rules or could not be checked", len(findings), len(accounts))
+	}
+
+	//Audit log
+	payload = struct {
+		ResourceGroup   string
+		AccountsChecked int
+		Findings        []corsFinding
+	}{
+		ResourceGroup:   azureutil.ResourceGroup(),
+		AccountsChecked: len(accounts),
+		Findings:        findings,
+	}
+
+	return err
+}
+
+// corsFinding records a storage account with overly permissive CORS rules
+type corsFinding struct {
+	StorageAccountName string
+	OffendingRules     []azureStorage.CorsRule
+	Error              string
+}
+
+// getOffendingCorsRules returns the CORS rules which allow any origin or every method
+func getOffendingCorsRules(properties azureStorage.BlobServiceProperties) (offendingRules []azureStorage.CorsRule) {
+	if properties.BlobServicePropertiesProperties == nil ||
+		properties.BlobServicePropertiesProperties.Cors == nil ||
+		properties.BlobServicePropertiesProperties.Cors.CorsRules == nil {
+		return
+	}
+
+	for _, rule := range *properties.BlobServicePropertiesProperties.Cors.CorsRules {
+		if hasWildcardOrigin(rule) || hasWildcardMethods(rule) {
+			offendingRules = append(offendingRules, rule)
+		}
+	}
+	return
+}
+
+func hasWildcardOrigin(rule azureStorage.CorsRule) bool {
+	if rule.AllowedOrigins == nil {
+		return false
+	}
+	for _, origin := range *rule.AllowedOrigins {
+		if strings.TrimSpace(origin) == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// isRefusal returns whether setting a CORS rule was denied by an Azure Policy assignment or refused with a 403
+func isRefusal(err error) bool {
+	return azureutil.IsPolicyDenial(err) || azureutil.ServiceErrorStatusCode(err) == http.StatusForbidden
+}
+
+func hasWildcardMethods(rule azureStorage.CorsRule) bool {
+	if rule.AllowedMethods == nil {
+		return false
+	}
+	for _, method := range allMethods {
+		found := false
+		for _, allowed := range *rule.AllowedMethods {
+			if strings.EqualFold(allowed, method) || allowed == "*" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.storageAccounts = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "cors_rules"
+}
+
+// Path returns this probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "azure", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize azure connection
+		azConnection = connection.NewAzureConnection(
+			context.Background(),
+			azureutil.SubscriptionID(),
+			azureutil.TenantID(),
+			azureutil.ClientID(),
+			azureutil.ClientSecret(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an Azure subscription is available$`, scenario.anAzureSubscriptionIsAvailable)
+	ctx.Step(`^azure resource group specified in config exists$`, scenario.azureResourceGroupSpecifiedInConfigExists)
+
+	// Steps
+	ctx.Step(`^the scenario requires a real storage account$`, func() error {
+		return azureutil.TheScenarioRequiresARealStorageAccount(scenario.audit, scenario.currentStep)
+	})
+	ctx.Step(`^a list of allowed CORS origins is provided in config$`, scenario.aListOfAllowedCORSOriginsIsProvidedInConfig)
+	ctx.Step(`^a storage account is created for CORS testing$`, scenario.aStorageAccountIsCreatedForCORSTesting)
+	ctx.Step(`^an attempt to set a CORS rule with "([^"]*)" on the storage account "([^"]*)"$`, scenario.anAttemptToSetACORSRuleWithXOnTheStorageAccountY)
+	ctx.Step(`^no storage account in the resource group has overly permissive CORS rules$`, scenario.noStorageAccountInTheResourceGroupHasOverlyPermissiveCORSRules)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing storage accounts used during tests")
+
+	for _, account := range scenario.storageAccounts {
+		log.Printf("[DEBUG] need to delete the storageAccount: %s", account)
+		err := azConnection.DeleteStorageAccount(azureutil.ResourceGroup(), account)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the storageAccount: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
+
+// CorsOrigins represents the required config settings for this probe. This shall be removed and replaced with actual config vars once sdk refactor is complete.
+type CorsOrigins struct {
+	AllowedOrigins []string `yaml:"AllowedOrigins"` // A list of origins which may be allowed by blob service CORS rules
+	AllowedMethods []string `yaml:"AllowedMethods"` // A list of HTTP methods which may be allowed by blob service CORS rules
+}
+
+// getCorsOrigins returns the origins and methods CORS rules may allow. Either list may be overridden as a comma separated list by the
+// environment variables AZURE_CORS_ALLOWED_ORIGINS and AZURE_CORS_ALLOWED_METHODS.
+func getCorsOrigins() CorsOrigins {
+
+	corsOrigins := CorsOrigins{
+		AllowedOrigins: []string{
+			"https://www.example.com",
+		},
+		AllowedMethods: []string{
+			"GET",
+			"HEAD",
+		},
+	}
+	if allowedOrigins := envvar.SplitList(envvar.GetOrDefault("AZURE_CORS_ALLOWED_ORIGINS", "")); len(allowedOrigins) > 0 {
+		corsOrigins.AllowedOrigins = allowedOrigins
+	}
+	if allowedMethods := envvar.SplitList(envvar.GetOrDefault("AZURE_CORS_ALLOWED_METHODS", "")); len(allowedMethods) > 0 {
+		corsOrigins.AllowedMethods = allowedMethods
+	}
+	return corsOrigins
+}
@@ -0,0 +1,24 @@
+@s-azctr
+Feature: Object Storage Cannot Replicate Data to Other Tenants
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that my organisation's data cannot be exfiltrated to storage accounts outside of our Azure AD tenant
+
+  #Rule: CHC2-SVD030 - protect cloud service network access by limiting access from the appropriate source network only
+
+    Background:
+      Given an Azure subscription is available
+      And azure resource group specified in config exists
+
+    @s-azctr-001
+    Scenario: Prevent Object Storage from Being Created With Cross Tenant Replication Allowed
+      Then an attempt to create a storage account with cross tenant replication "disallowed" "succeeds"
+      But an attempt to create a storage account with cross tenant replication "allowed" "fails"
+
+    @s-azctr-002
+    Scenario: Prevent Object Replication to a Storage Account in Another Tenant
+      Given the scenario requires a real storage account
+      And a destination storage account in another tenant is provided in config
+      And a source storage account with blob versioning and change feed enabled is created
+      Then an attempt to create an object replication policy to the destination storage account "fails"
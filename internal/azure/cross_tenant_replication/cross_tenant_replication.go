@@ -0,0 +1,600 @@
+package azurectr
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	azureStorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/cucumber/godog"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/azure/policyassignment"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-pack-storage/internal/envvar"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// ProbeStruct allows this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+type scenarioState struct {
+	name                   string
+	currentStep            string
+	audit                  *audit.ScenarioAudit
+	probe                  *audit.Probe
+	ctx                    context.Context
+	tags                   map[string]*string
+	storageAccounts        []string
+	sourceAccountName      string
+	sourceAccountID        string
+	sourceContainerName    string
+	crossTenantReplication CrossTenantReplication
+	destinationPolicy      *replicationPolicy
+}
+
+// replicationPolicy records an object replication policy created on the destination storage account, removed after the scenario
+type replicationPolicy struct {
+	replication *connection.AzureObjectReplication
+	account     storageAccountID
+	policyID    string
+}
+
+// Probe ...
+var Probe probeStruct             // Probe allows this probe to be added to the ProbeStore
+var scenario scenarioState        // Local container of scenario state
+var azConnection connection.Azure // Provides functionality to interact with Azure
+
+var requiredPolicies = policyassignment.Require(Probe.Name(), azureutil.CrossTenantReplicationPolicy) // Assigned for the test run when ephemeral policy assignment is enabled
+
+func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that Azure subscription specified in config file is available; "))
+
+	payload = struct {
+		SubscriptionID string
+		TenantID       string
+		CreationMode   string
+	}{
+		azureutil.SubscriptionID(),
+		azureutil.TenantID(),
+		azureutil.CreationMode(),
+	}
+
+	err = azConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) azureResourceGroupSpecifiedInConfigExists() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check if value for Azure resource group is set in config vars; ")
+	if azureutil.ResourceGroup() == "" {
+		err = utils.ReformatError("Azure resource group config var not set")
+		return err
+	}
+
+	stepTrace.WriteString("Check the resource group exists in the specified azure subscription; ")
+	_, getGrpErr := azConnection.GetResourceGroupByName(azureutil.ResourceGroup())
+	if getGrpErr != nil {
+		err = utils.ReformatError("Azure resource group '%s' does not exists. Error: %v", azureutil.ResourceGroup(), getGrpErr)
+		return err
+	}
+
+	//Audit log
+	payload = struct {
+		SubscriptionID string
+		ResourceGroup  string
+	}{
+		SubscriptionID: azureutil.SubscriptionID(),
+		ResourceGroup:  azureutil.ResourceGroup(),
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) anAttemptToCreateAStorageAccountWithCrossTenantReplicationXY(replicationOption, expectedResult string) error {
+
+	// Supported values for 'replicationOption':
+	//	'allowed'
+	//  'disallowed'
+
+	// Supported values for 'expectedResult':
+	//	'succeeds'
+	//	'fails'
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values - replicationOption
+	var allowCrossTenantReplication bool
+	switch replicationOption {
+	case "allowed":
+		allowCrossTenantReplication = true
+	case "disallowed":
+		allowCrossTenantReplication = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for replicationOption: '%s' Expected values: ['allowed', 'disallowed']", replicationOption)
+		return err
+	}
+
+	// Validate input values - expectedResult
+	var shouldCreate bool
+	switch expectedResult {
+	case "succeeds":
+		shouldCreate = true
+	case "fails":
+		shouldCreate = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	bucketName := utils.RandomString(10)
+	stepTrace.WriteString(fmt.Sprintf("Generate a storage account name using a random string: '%s'; ", bucketName))
+
+	stepTrace.WriteString(fmt.Sprintf("Attempt to create Storage Account with AllowCrossTenantReplication: %v; ", allowCrossTenantReplication))
+	parameters := azureStorage.AccountCreateParameters{
+		AccountPropertiesCreateParameters: &azureStorage.AccountPropertiesCreateParameters{
+			EnableHTTPSTrafficOnly:      to.BoolPtr(true),
+			AllowCrossTenantReplication: to.BoolPtr(allowCrossTenantReplication),
+		},
+		Tags: scenario.tags,
+	}
+	storageAccount, creationErr := azConnection.CreateStorageAccountWithParameters(bucketName, azureutil.ResourceGroup(), parameters)
+	if creationErr == nil {
+		scenario.storageAccounts = append(scenario.storageAccounts, bucketName) // Record for later cleanup
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Validate that storage account creation %s; ", expectedResult))
+	switch shouldCreate {
+	case true:
+		if creationErr != nil {
+			err = utils.ReformatError("Creation of storage account did not succeed: %v", creationErr)
+		}
+	case false:
+		if creationErr == nil {
+			err = utils.ReformatError("Creation of storage account succeeded, but should have failed")
+		} else if !azureutil.IsPolicyDenial(creationErr) {
+			// Ensure failure is due to the cross tenant replication policy
+			err = utils.ReformatError("Creation of storage account failed with unexpected reason: %v - %v", azureutil.ServiceErrorCode(creationErr), creationErr)
+		}
+	}
+
+	//Audit log
+	payload = struct {
+		StorageAccountName          string
+		ResourceGroup               string
+		AllowCrossTenantReplication bool
+		StorageAccount              azureStorage.Account
+	}{
+		StorageAccountName:          bucketName,
+		ResourceGroup:               azureutil.ResourceGroup(),
+		AllowCrossTenantReplication: allowCrossTenantReplication,
+		StorageAccount:              storageAccount,
+	}
+
+	return err
+}
+
+func (scenario *scenarioState) aDestinationStorageAccountInAnotherTenantIsProvidedInConfig() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Validate that a replication destination in another tenant is provided in config; ")
+
+	scenario.crossTenantReplication = getCrossTenantReplication()
+
+	//Audit log
+	payload = struct {
+		CrossTenantReplication CrossTenantReplication
+	}{
+		CrossTenantReplication: scenario.crossTenantReplication,
+	}
+
+	if scenario.crossTenantReplication.DestinationTenantID == "" || scenario.crossTenantReplication.DestinationAccountID == "" || scenario.crossTenantReplication.DestinationContainer == "" {
+		err = utils.ReformatError("The replication destination tenant, storage account and container in another tenant have not been defined in config. Set AZURE_DESTINATION_TENANT_ID and AZURE_DESTINATION_ACCOUNT_ID")
+		return err
+	}
+
+	if scenario.crossTenantReplication.DestinationTenantID == azureutil.TenantID() {
+		err = utils.ReformatError("The replication destination tenant '%s' is the tenant under test", scenario.crossTenantReplication.DestinationTenantID)
+		return err
+	}
+
+	_, err = parseStorageAccountID(scenario.crossTenantReplication.DestinationAccountID)
+
+	return err
+}
+
+func (scenario *scenarioState) aSourceStorageAccountWithBlobVersioningAndChangeFeedEnabledIsCreated() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	scenario.sourceAccountName = utils.RandomString(10)
+	stepTrace.WriteString(fmt.Sprintf("Generate a source storage account name using a random string: '%s'; ", scenario.sourceAccountName))
+
+	// Cross tenant replication is left to the platform default so that any policy or guard in place is what is under test
+	stepTrace.WriteString("Create source Storage Account; ")
+	parameters := azureStorage.AccountCreateParameters{
+		AccountPropertiesCreateParameters: &azureStorage.AccountPropertiesCreateParameters{
+			EnableHTTPSTrafficOnly: to.BoolPtr(true),
+		},
+		Tags: scenario.tags,
+	}
+	storageAccount, creationErr := azConnection.CreateStorageAccountWithParameters(scenario.sourceAccountName, azureutil.ResourceGroup(), parameters)
+	if creationErr != nil {
+		err = utils.ReformatError("Creation of source storage account did not succeed: %v", creationErr)
+		return err
+	}
+	scenario.storageAccounts = append(scenario.storageAccounts, scenario.sourceAccountName) // Record for later cleanup
+	scenario.sourceAccountID = to.String(storageAccount.ID)
+
+	// Object replication requires versioning and change feed on the source, so a rejection cannot be attributed to missing prerequisites
+	stepTrace.WriteString("Enable blob versioning and change feed on source Storage Account; ")
+	blobServiceProperties, setErr := azConnection.SetBlobServiceProperties(
+		azureutil.ResourceGroup(),
+		scenario.sourceAccountName,
+		azureStorage.BlobServiceProperties{
+			BlobServicePropertiesProperties: &azureStorage.BlobServicePropertiesProperties{
+				IsVersioningEnabled: to.BoolPtr(true),
+				ChangeFeed: &azureStorage.ChangeFeed{
+					Enabled: to.BoolPtr(true),
+				},
+			},
+		})
+	if setErr != nil {
+		err = utils.ReformatError("Failed to enable blob versioning and change feed on source storage account: %v", setErr)
+		return err
+	}
+
+	scenario.sourceContainerName = "probr-replication"
+	stepTrace.WriteString(fmt.Sprintf("Create source container '%s'; ", scenario.sourceContainerName))
+	_, containerErr := azConnection.CreateBlobContainer(azureutil.ResourceGroup(), scenario.sourceAccountName, scenario.sourceContainerName)
+	if containerErr != nil {
+		err = utils.ReformatError("Failed to create container on source storage account: %v", containerErr)
+	}
+
+	//Audit log
+	payload = struct {
+		StorageAccountName    string
+		ResourceGroup         string
+		StorageAccount        azureStorage.Account
+		BlobServiceProperties azureStorage.BlobServiceProperties
+	}{
+		StorageAccountName:    scenario.sourceAccountName,
+		ResourceGroup:         azureutil.ResourceGroup(),
+		StorageAccount:        storageAccount,
+		BlobServiceProperties: blobServiceProperties,
+	}
+
+	return err
+}
+
+func (scenario *scenarioState) anAttemptToCreateAnObjectReplicationPolicyToTheDestinationStorageAccountX(expectedResult string) error {
+
+	// Supported values for 'expectedResult':
+	//	'succeeds'
+	//	'fails'
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values
+	var shouldCreate bool
+	switch expectedResult {
+	case "succeeds":
+		shouldCreate = true
+	case "fails":
+		shouldCreate = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	destination, parseErr := parseStorageAccountID(scenario.crossTenantReplication.DestinationAccountID)
+	if parseErr != nil {
+		err = parseErr
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Connect to the destination tenant '%s'; ", scenario.crossTenantReplication.DestinationTenantID))
+	destinationReplication, connErr := connection.NewObjectReplicationInTenant(
+		scenario.ctx,
+		destination.subscriptionID,
+		scenario.crossTenantReplication.DestinationTenantID,
+		azureutil.ClientID(),
+		azureutil.ClientSecret(),
+	)
+	if connErr != nil {
+		err = utils.ReformatError("Failed to connect to the destination tenant: %v", connErr)
+		return err
+	}
+
+	// Object replication policies are created on the destination account first, which generates the policy and rule IDs the source policy must use
+	stepTrace.WriteString(fmt.Sprintf("Create Object Replication Policy on destination '%s'; ", scenario.crossTenantReplication.DestinationAccountID))
+	policy := azureStorage.ObjectReplicationPolicy{
+		ObjectReplicationPolicyProperties: &azureStorage.ObjectReplicationPolicyProperties{
+			SourceAccount:      to.StringPtr(scenario.sourceAccountID),
+			DestinationAccount: to.StringPtr(scenario.crossTenantReplication.DestinationAccountID),
+			Rules: &[]azureStorage.ObjectReplicationPolicyRule{
+				{
+					SourceContainer:      to.StringPtr(scenario.sourceContainerName),
+					DestinationContainer: to.StringPtr(scenario.crossTenantReplication.DestinationContainer),
+				},
+			},
+		},
+	}
+	destinationPolicy, destinationErr := destinationReplication.CreatePolicy(destination.resourceGroup, destination.accountName, "default", policy)
+	if destinationErr != nil {
+		err = utils.ReformatError("Creation of object replication policy on the destination storage account did not succeed: %v", destinationErr)
+		return err
+	}
+	scenario.destinationPolicy = &replicationPolicy{
+		replication: destinationReplication,
+		account:     destination,
+		policyID:    to.String(destinationPolicy.PolicyID),
+	} // Record for later cleanup
+
+	stepTrace.WriteString(fmt.Sprintf("Attempt to create Object Replication Policy '%s' from '%s' to '%s'; ", to.String(destinationPolicy.PolicyID), scenario.sourceAccountName, scenario.crossTenantReplication.DestinationAccountID))
+	createdPolicy, creationErr := azConnection.CreateObjectReplicationPolicy(azureutil.ResourceGroup(), scenario.sourceAccountName, to.String(destinationPolicy.PolicyID), destinationPolicy)
+
+	stepTrace.WriteString(fmt.Sprintf("Validate that object replication policy creation %s; ", expectedResult))
+	switch shouldCreate {
+	case true:
+		if creationErr != nil {
+			err = utils.ReformatError("Creation of object replication policy did not succeed: %v", creationErr)
+		}
+	case false:
+		if creationErr == nil {
+			err = utils.ReformatError("Creation of object replication policy to a storage account in another tenant succeeded, but should have failed")
+		} else if !isCrossTenantDenial(creationErr) {
+			// Ensure failure is due to the cross tenant replication control
+			err = utils.ReformatError("Creation of object replication policy failed with unexpected reason: %v - %v", azureutil.ServiceErrorCode(creationErr), creationErr)
+		}
+	}
+
+	creationError := ""
+	if creationErr != nil {
+		creationError = creationErr.Error()
+	}
+
+	//Audit log
+	payload = struct {
+		SourceStorageAccountName string
+		ResourceGroup            string
+		DestinationAccountID     string
+		DestinationPolicy        azureStorage.ObjectReplicationPolicy
+		ObjectReplicationPolicy  azureStorage.ObjectReplicationPolicy
+		CreationErrorCode        string
+		CreationError            string
+	}{
+		SourceStorageAccountName: scenario.sourceAccountName,
+		ResourceGroup:            azureutil.ResourceGroup(),
+		DestinationAccountID:     scenario.crossTenantReplication.DestinationAccountID,
+		DestinationPolicy:        destinationPolicy,
+		ObjectReplicationPolicy:  createdPolicy,
+		CreationErrorCode:        azureutil.ServiceErrorCode(creationErr),
+		CreationError:            creationError,
+	}
+
+	return err
+}
+
+// isCrossTenantDenial returns whether an object replication policy was refused by an Azure Policy assignment or because
+// cross tenant replication is disallowed on the source storage account
+func isCrossTenantDenial(err error) bool {
+	if azureutil.IsPolicyDenial(err) {
+		return true
+	}
+	message := strings.ToLower(azureutil.ServiceErrorMessage(err))
+	return strings.Contains(message, "cross tenant") || strings.Contains(message, "cross-tenant") || strings.Contains(message, "crosstenant")
+}
+
+// storageAccountID holds the parts of a storage account resource ID
+type storageAccountID struct {
+	subscriptionID string
+	resourceGroup  string
+	accountName    string
+}
+
+// parseStorageAccountID splits a storage account resource ID of the form
+// /subscriptions/{id}/resourceGroups/{name}/providers/Microsoft.Storage/storageAccounts/{name}
+func parseStorageAccountID(resourceID string) (id storageAccountID, err error) {
+	parts := strings.Split(strings.Trim(resourceID, "/"), "/")
+	if len(parts) != 8 ||
+		!strings.EqualFold(parts[0], "subscriptions") ||
+		!strings.EqualFold(parts[2], "resourceGroups") ||
+		!strings.EqualFold(parts[4], "providers") ||
+		!strings.EqualFold(parts[5], "Microsoft.Storage") ||
+		!strings.EqualFold(parts[6], "storageAccounts") {
+		err = utils.ReformatError("Unexpected value provided for DestinationAccountID: '%s' Expected a storage account resource ID", resourceID)
+		return
+	}
+	id = storageAccountID{
+		subscriptionID: parts[1],
+		resourceGroup:  parts[3],
+		accountName:    parts[7],
+	}
+	return
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.storageAccounts = make([]string, 0)
+	s.tags = policyassignment.Tags(probeName)
+	s.destinationPolicy = nil
+	probeengine.LogScenarioStart(gs)
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "cross_tenant_replication"
+}
+
+// Path returns this probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "azure", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize azure connection
+		azConnection = connection.NewAzureConnection(
+			context.Background(),
+			azureutil.SubscriptionID(),
+			azureutil.TenantID(),
+			azureutil.ClientID(),
+			azureutil.ClientSecret(),
+		)
+
+		// Policies required by every probe are assigned once, by the first probe to run
+		if azureutil.EphemeralPolicyAssignment() {
+			if err := policyassignment.Ensure(azConnection); err != nil {
+				log.Printf("[ERROR] Ephemeral policy assignment failed: %v", err)
+			}
+		}
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an Azure subscription is available$`, scenario.anAzureSubscriptionIsAvailable)
+	ctx.Step(`^azure resource group specified in config exists$`, scenario.azureResourceGroupSpecifiedInConfigExists)
+
+	// Steps
+	ctx.Step(`^the scenario requires a real storage account$`, func() error {
+		return azureutil.TheScenarioRequiresARealStorageAccount(scenario.audit, scenario.currentStep)
+	})
+	ctx.Step(`^an attempt to create a storage account with cross tenant replication "([^"]*)" "([^"]*)"$`, scenario.anAttemptToCreateAStorageAccountWithCrossTenantReplicationXY)
+	ctx.Step(`^a destination storage account in another tenant is provided in config$`, scenario.aDestinationStorageAccountInAnotherTenantIsProvidedInConfig)
+	ctx.Step(`^a source storage account with blob versioning and change feed enabled is created$`, scenario.aSourceStorageAccountWithBlobVersioningAndChangeFeedEnabledIsCreated)
+	ctx.Step(`^an attempt to create an object replication policy to the destination storage account "([^"]*)"$`, scenario.anAttemptToCreateAnObjectReplicationPolicyToTheDestinationStorageAccountX)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func teardown() {
+
+	if scenario.destinationPolicy != nil {
+		log.Printf("[DEBUG] Cleanup - removing object replication policy '%s' from destination storage account", scenario.destinationPolicy.policyID)
+		err := scenario.destinationPolicy.replication.DeletePolicy(scenario.destinationPolicy.account.resourceGroup, scenario.destinationPolicy.account.accountName, scenario.destinationPolicy.policyID)
+		if err != nil {
+			log.Printf("[ERROR] error deleting the object replication policy: %v", err)
+		}
+	}
+
+	log.Printf("[DEBUG] Cleanup - removing storage accounts used during tests")
+
+	for _, account := range scenario.storageAccounts {
+		log.Printf("[DEBUG] need to delete the storageAccount: %s", account)
+		err := azConnection.DeleteStorageAccount(azureutil.ResourceGroup(), account)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the storageAccount: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
+
+// CrossTenantReplication represents the required config settings for this probe. This shall be removed and replaced with actual config vars once sdk refactor is complete.
+type CrossTenantReplication struct {
+	DestinationTenantID  string `yaml:"DestinationTenantID"`  // Tenant of the destination storage account, in which the client must also be registered
+	DestinationAccountID string `yaml:"DestinationAccountID"` // Resource ID of a storage account in another tenant to be used as replication destination
+	DestinationContainer string `yaml:"DestinationContainer"` // Name of an existing container in the destination storage account
+}
+
+// getCrossTenantReplication returns the replication destination set by the environment variables AZURE_DESTINATION_TENANT_ID,
+// AZURE_DESTINATION_ACCOUNT_ID and AZURE_DESTINATION_CONTAINER. The container defaults to probr-replication.
+func getCrossTenantReplication() CrossTenantReplication {
+
+	return CrossTenantReplication{
+		DestinationTenantID:  envvar.GetOrDefault("AZURE_DESTINATION_TENANT_ID", ""),
+		DestinationAccountID: envvar.GetOrDefault("AZURE_DESTINATION_ACCOUNT_ID", ""),
+		DestinationContainer: envvar.GetOrDefault("AZURE_DESTINATION_CONTAINER", "probr-replication"),
+	}
+}
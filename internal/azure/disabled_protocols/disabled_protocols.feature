@@ -0,0 +1,23 @@
+@s-azdp
+Feature: Object Storage Does Not Expose Protocols Which Bypass Azure AD Authentication
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that my organisation's data cannot be accessed through protocols which bypass Azure AD authentication and the storage firewall
+
+    Background:
+      Given an Azure subscription is available
+      And azure resource group specified in config exists
+
+    @s-azdp-001
+    Scenario Outline: Prevent Object Storage from Being Created With SFTP, NFSv3 or Local Users Enabled
+      Given an exception list for storage account protocols is provided in config
+      When an attempt to create a storage account with "<Protocol>" "disabled" "succeeds"
+      Then an attempt to create a storage account with "<Protocol>" "enabled" "fails"
+      But an attempt to create a storage account on the exception list with "<Protocol>" "enabled" "succeeds"
+
+      Examples:
+        | Protocol    |
+        | SFTP        |
+        | NFSv3       |
+        | local users |
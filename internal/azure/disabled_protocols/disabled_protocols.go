@@ -0,0 +1,447 @@
+package azuredp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	azureStorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/cucumber/godog"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-pack-storage/internal/envvar"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// ProbeStruct allows this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+// creationOutcome records the result of a storage account creation attempt through one creation backend
+type creationOutcome struct {
+	Backend            string
+	StorageAccountName string
+	StorageAccount     azureStorage.Account
+	Error              string
+}
+
+type scenarioState struct {
+	name               string
+	currentStep        string
+	audit              *audit.ScenarioAudit
+	probe              *audit.Probe
+	ctx                context.Context
+	tags               map[string]*string
+	storageAccounts    []string
+	protocolExceptions ProtocolExceptions
+}
+
+// Probe ...
+var Probe probeStruct             // Probe allows this probe to be added to the ProbeStore
+var scenario scenarioState        // Local container of scenario state
+var azConnection connection.Azure // Provides functionality to interact with Azure
+
+func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that Azure subscription specified in config file is available; "))
+
+	payload = struct {
+		SubscriptionID string
+		TenantID       string
+		CreationMode   string
+	}{
+		azureutil.SubscriptionID(),
+		azureutil.TenantID(),
+		azureutil.CreationMode(),
+	}
+
+	err = azConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) azureResourceGroupSpecifiedInConfigExists() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check if value for Azure resource group is set in config vars; ")
+	if azureutil.ResourceGroup() == "" {
+		err = utils.ReformatError("Azure resource group config var not set")
+		return err
+	}
+
+	stepTrace.WriteString("Check the resource group exists in the specified azure subscription; ")
+	_, getGrpErr := azConnection.GetResourceGroupByName(azureutil.ResourceGroup())
+	if getGrpErr != nil {
+		err = utils.ReformatError("Azure resource group '%s' does not exists. Error: %v", azureutil.ResourceGroup(), getGrpErr)
+		return err
+	}
+
+	//Audit log
+	payload = struct {
+		SubscriptionID string
+		ResourceGroup  string
+	}{
+		SubscriptionID: azureutil.SubscriptionID(),
+		ResourceGroup:  azureutil.ResourceGroup(),
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) anExceptionListForStorageAccountProtocolsIsProvidedInConfig() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Validate that the protocol exception list is provided in config; ")
+
+	scenario.protocolExceptions = getProtocolExceptions()
+
+	//Audit log
+	payload = struct {
+		ProtocolExceptions ProtocolExceptions
+	}{
+		ProtocolExceptions: scenario.protocolExceptions,
+	}
+
+	if !(len(scenario.protocolExceptions.ExceptionTags) > 0) {
+		err = utils.ReformatError("The tags identifying storage accounts on the protocol exception list have not been defined in config")
+	}
+
+	return err
+}
+
+func (scenario *scenarioState) anAttemptToCreateAStorageAccountWithXY(protocol, protocolOption, expectedResult string) error {
+	return scenario.attemptCreation(protocol, protocolOption, expectedResult, false)
+}
+
+func (scenario *scenarioState) anAttemptToCreateAStorageAccountOnTheExceptionListWithXY(protocol, protocolOption, expectedResult string) error {
+	return scenario.attemptCreation(protocol, protocolOption, expectedResult, true)
+}
+
+func (scenario *scenarioState) attemptCreation(protocol, protocolOption, expectedResult string, onExceptionList bool) error {
+
+	// Supported values for 'protocol':
+	//	'SFTP'
+	//	'NFSv3'
+	//	'local users'
+
+	// Supported values for 'protocolOption':
+	//	'enabled'
+	//  'disabled'
+
+	// Supported values for 'expectedResult':
+	//	'succeeds'
+	//	'fails'
+
+	// NFSv3 accounts cannot be created without a subnet to accept traffic from, so the NFSv3 rows are skipped rather than audited as failures
+	if protocol == "NFSv3" && scenario.protocolExceptions.NFSv3SubnetID == "" {
+		log.Printf("[WARN] Skipping NFSv3: the subnet from which NFSv3 storage accounts accept traffic has not been set in AZURE_NFSV3_SUBNET_ID")
+		return godog.ErrPending
+	}
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values - protocolOption
+	var protocolEnabled bool
+	switch protocolOption {
+	case "enabled":
+		protocolEnabled = true
+	case "disabled":
+		protocolEnabled = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for protocolOption: '%s' Expected values: ['enabled', 'disabled']", protocolOption)
+		return err
+	}
+
+	// Validate input values - expectedResult
+	var shouldCreate bool
+	switch expectedResult {
+	case "succeeds":
+		shouldCreate = true
+	case "fails":
+		shouldCreate = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	properties := &azureStorage.AccountPropertiesCreateParameters{
+		EnableHTTPSTrafficOnly: to.BoolPtr(true),
+	}
+
+	// Validate input values - protocol
+	// Each protocol is set together with the account settings Azure requires for it, so that a failure can only be attributed to the protocol itself
+	switch protocol {
+	case "SFTP":
+		properties.IsSftpEnabled = to.BoolPtr(protocolEnabled)
+		properties.IsHnsEnabled = to.BoolPtr(true)
+	case "NFSv3":
+		properties.EnableNfsV3 = to.BoolPtr(protocolEnabled)
+		properties.IsHnsEnabled = to.BoolPtr(true)
+		if protocolEnabled {
+			// Azure only accepts NFSv3 on accounts which do not require secure transfer and which accept traffic from a virtual network only
+			properties.EnableHTTPSTrafficOnly = to.BoolPtr(false)
+			properties.NetworkRuleSet = &azureStorage.NetworkRuleSet{
+				DefaultAction: azureStorage.DefaultActionDeny,
+				VirtualNetworkRules: &[]azureStorage.VirtualNetworkRule{
+					{
+						VirtualNetworkResourceID: to.StringPtr(scenario.protocolExceptions.NFSv3SubnetID),
+						Action:                   azureStorage.ActionAllow,
+					},
+				},
+			}
+		}
+	case "local users":
+		properties.IsLocalUserEnabled = to.BoolPtr(protocolEnabled)
+	default:
+		err = utils.ReformatError("Unexpected value provided for protocol: '%s' Expected values: ['SFTP', 'NFSv3', 'local users']", protocol)
+		return err
+	}
+
+	tags := make(map[string]*string)
+	for key, value := range scenario.tags {
+		tags[key] = value
+	}
+	if onExceptionList {
+		stepTrace.WriteString(fmt.Sprintf("Place storage account on exception list using tags %v; ", scenario.protocolExceptions.ExceptionTags))
+		for key, value := range scenario.protocolExceptions.ExceptionTags {
+			tags[key] = to.StringPtr(value)
+		}
+	}
+
+	var outcomes []creationOutcome
+	for _, backend := range azureutil.CreationBackends() {
+
+		bucketName := utils.RandomString(10)
+		stepTrace.WriteString(fmt.Sprintf("Generate a storage account name using a random string: '%s'; ", bucketName))
+
+		stepTrace.WriteString(fmt.Sprintf("Attempt to create Storage Account with %s %s using the '%s' creation backend; ", protocol, protocolOption, backend))
+		storageAccount, creationErr := azConnection.CreateStorageAccountWithBackend(
+			azureutil.CreationMode(),
+			backend,
+			bucketName,
+			azureutil.ResourceGroup(),
+			azureStorage.AccountCreateParameters{
+				AccountPropertiesCreateParameters: properties,
+				Tags:                              tags,
+			})
+		if creationErr == nil {
+			scenario.storageAccounts = append(scenario.storageAccounts, bucketName) // Record for later cleanup
+		}
+
+		outcome := creationOutcome{
+			Backend:            backend,
+			StorageAccountName: bucketName,
+			StorageAccount:     storageAccount,
+		}
+		if creationErr != nil {
+			outcome.Error = creationErr.Error()
+		}
+		outcomes = append(outcomes, outcome)
+
+		var attemptErr error
+		stepTrace.WriteString(fmt.Sprintf("Validate that storage account creation %s; ", expectedResult))
+		switch shouldCreate {
+		case true:
+			if creationErr != nil {
+				attemptErr = utils.ReformatError("Creation of storage account with %s %s did not succeed: %v", protocol, protocolOption, creationErr)
+			}
+		case false:
+			if creationErr == nil {
+				attemptErr = utils.ReformatError("Creation of storage account with %s %s succeeded, but should have failed", protocol, protocolOption)
+			} else if !isProtocolDenial(creationErr) {
+				// Ensure failure is due to a policy denying the protocol, and not to the settings the protocol requires
+				attemptErr = utils.ReformatError("Creation of storage account with %s %s failed with unexpected reason: %v - %v", protocol, protocolOption, azureutil.ServiceErrorCode(creationErr), creationErr)
+			}
+		}
+		if attemptErr != nil && err == nil {
+			err = utils.ReformatError("Creation backend '%s': %v", backend, attemptErr)
+		}
+	}
+
+	//Audit log
+	payload = struct {
+		Protocol        string
+		ProtocolEnabled bool
+		OnExceptionList bool
+		ResourceGroup   string
+		Tags            map[string]*string
+		Outcomes        []creationOutcome
+	}{
+		Protocol:        protocol,
+		ProtocolEnabled: protocolEnabled,
+		OnExceptionList: onExceptionList,
+		ResourceGroup:   azureutil.ResourceGroup(),
+		Tags:            tags,
+		Outcomes:        outcomes,
+	}
+
+	return err
+}
+
+// isProtocolDenial returns whether a storage account creation was denied by an Azure Policy assignment other than the secure transfer
+// policy, which would also deny the insecure transfer setting NFSv3 requires. A combined denial by the secure transfer policy and
+// another policy is accepted, as the protocol is still denied when secure transfer is enforced in the same subscription.
+func isProtocolDenial(creationErr error) bool {
+	if !azureutil.IsPolicyDenial(creationErr) {
+		return false
+	}
+	definitions := azureutil.DeniedPolicyDefinitions(creationErr)
+	if len(definitions) == 0 {
+		// Without policy violation details only a denial which does not name the secure transfer policy can be attributed to the protocol
+		return !strings.Contains(strings.ToLower(azureutil.ServiceErrorMessage(creationErr)), strings.ToLower(azureutil.SecureTransferPolicy.Name))
+	}
+	for _, definition := range definitions {
+		if !strings.EqualFold(definition, azureutil.SecureTransferPolicy.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.storageAccounts = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "disabled_protocols"
+}
+
+// Path returns this probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "azure", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize azure connection
+		azConnection = connection.NewAzureConnection(
+			context.Background(),
+			azureutil.SubscriptionID(),
+			azureutil.TenantID(),
+			azureutil.ClientID(),
+			azureutil.ClientSecret(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an Azure subscription is available$`, scenario.anAzureSubscriptionIsAvailable)
+	ctx.Step(`^azure resource group specified in config exists$`, scenario.azureResourceGroupSpecifiedInConfigExists)
+
+	// Steps
+	ctx.Step(`^an exception list for storage account protocols is provided in config$`, scenario.anExceptionListForStorageAccountProtocolsIsProvidedInConfig)
+	ctx.Step(`^an attempt to create a storage account with "([^"]*)" "([^"]*)" "([^"]*)"$`, scenario.anAttemptToCreateAStorageAccountWithXY)
+	ctx.Step(`^an attempt to create a storage account on the exception list with "([^"]*)" "([^"]*)" "([^"]*)"$`, scenario.anAttemptToCreateAStorageAccountOnTheExceptionListWithXY)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing storage accounts used during tests")
+
+	for _, account := range scenario.storageAccounts {
+		log.Printf("[DEBUG] need to delete the storageAccount: %s", account)
+		err := azConnection.DeleteStorageAccount(azureutil.ResourceGroup(), account)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the storageAccount: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
+
+// ProtocolExceptions represents the required config settings for this probe. This shall be removed and replaced with actual config vars once sdk refactor is complete.
+type ProtocolExceptions struct {
+	ExceptionTags map[string]string `yaml:"ExceptionTags"` // Tags which place a storage account on the approved exception list for SFTP, NFSv3 and local users
+	NFSv3SubnetID string            `yaml:"NFSv3SubnetID"` // Resource ID of a subnet with the Microsoft.Storage service endpoint, the only network NFSv3 storage accounts accept traffic from
+}
+
+// getProtocolExceptions returns the protocol exception list. The NFSv3 subnet is set by the environment variable AZURE_NFSV3_SUBNET_ID,
+// and the NFSv3 scenarios are skipped when it is not set.
+func getProtocolExceptions() ProtocolExceptions {
+
+	return ProtocolExceptions{
+		ExceptionTags: map[string]string{
+			"probr-protocol-exception": "approved",
+		},
+		NFSv3SubnetID: envvar.GetOrDefault("AZURE_NFSV3_SUBNET_ID", ""),
+	}
+}
@@ -51,9 +51,11 @@ func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
 	payload = struct {
 		SubscriptionID string
 		TenantID       string
+		CreationMode   string
 	}{
 		azureutil.SubscriptionID(),
 		azureutil.TenantID(),
+		azureutil.CreationMode(),
 	}
 
 	err = azConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
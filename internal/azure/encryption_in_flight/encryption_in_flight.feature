@@ -15,11 +15,22 @@ Feature: Object Storage Encryption in Flight
       Security Standard References:
         - CHC2-AGP140 : Ensure cryptographic controls are in place to protect the confidentiality and integrity of data in-transit, stored, generated and processed in the cloud
 
+      Given the control is enforced by "deny"
+      And the "Secure transfer to storage accounts should be enabled" policy is assigned with effect "Deny"
       Then creation of an Object Storage bucket with https "enabled" "succeeds"
       But creation of an Object Storage bucket with https "disabled" "fails" with error code "RequestDisallowedByPolicy"
-      
+
       # TODO: Verify HTTP and HTTPs cannot be enabled at the same time
       # Try setting up storage account with custom subdomain and enabled http and https
       # https://docs.microsoft.com/en-us/azure/storage/blobs/storage-custom-domain-name?tabs=azure-portal#enable-https
 
-  
\ No newline at end of file
+    @s-azeif-002
+    Scenario: Remediate Object Storage Created Without Encryption in Flight
+      Given the control is enforced by "remediate"
+      Then creation of an Object Storage bucket with https "disabled" succeeds and the effective "https only" is remediated to "true" within 600 seconds
+
+    @s-azeif-003
+    Scenario: Flag Object Storage Created Without Encryption in Flight
+      Given the control is enforced by "audit"
+      And the "Secure transfer to storage accounts should be enabled" policy is assigned with effect "Audit"
+      Then creation of an Object Storage bucket with https "disabled" "is flagged non-compliant"
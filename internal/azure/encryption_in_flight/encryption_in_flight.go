@@ -5,13 +5,18 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
-	azureStorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-04-01/storage"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-09-01/policy"
+	azureStorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/cucumber/godog"
 
 	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/azure/compliance"
+	"github.com/citihub/probr-pack-storage/internal/azure/policyassignment"
 	"github.com/citihub/probr-pack-storage/internal/connection"
 	"github.com/citihub/probr-sdk/audit"
 	"github.com/citihub/probr-sdk/probeengine"
@@ -35,11 +40,24 @@ type scenarioState struct {
 type probeStruct struct {
 }
 
+// creationOutcome records the result of a storage account creation attempt through one creation backend
+type creationOutcome struct {
+	Backend            string
+	StorageAccountName string
+	StorageAccount     azureStorage.Account
+	Error              string
+	Compliance         compliance.Result
+}
+
 // Probe allows this probe to be added to the ProbeStore
 var Probe probeStruct
 var scenario scenarioState        // Local container of scenario state
 var azConnection connection.Azure // Provides functionality to interact with Azure
 
+const remediationPollInterval = 15 * time.Second // How often a storage account is re-read while waiting for policy remediation
+
+var requiredPolicies = policyassignment.Require(Probe.Name(), azureutil.SecureTransferPolicy) // Assigned for the test run when ephemeral policy assignment is enabled
+
 func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
 
 	// Standard auditing logic to ensures panics are also audited
@@ -56,9 +74,11 @@ func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
 	payload = struct {
 		SubscriptionID string
 		TenantID       string
+		CreationMode   string
 	}{
 		azureutil.SubscriptionID(),
 		azureutil.TenantID(),
+		azureutil.CreationMode(),
 	}
 
 	err = azConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
@@ -102,6 +122,104 @@ func (scenario *scenarioState) azureResourceGroupSpecifiedInConfigExists() error
 	return nil
 }
 
+func (scenario *scenarioState) thePolicyIsAssignedWithEffect(policyName, expectedEffect string) error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	definition, err := azureutil.BuiltInPolicyDefinition(policyName)
+	if err != nil {
+		return err
+	}
+
+	scenario.policyAssignmentMgmtGroup = azureutil.ManagementGroup()
+
+	stepTrace.WriteString(fmt.Sprintf("Get the default effect of built-in policy definition '%s'; ", definition.Name))
+	defaultEffect := ""
+	builtIn, getErr := azConnection.GetBuiltInPolicyDefinition(definition.Name)
+	if getErr != nil {
+		err = utils.ReformatError("Failed to get built-in policy definition '%s': %v", policyName, getErr)
+		return err
+	}
+	if builtIn.DefinitionProperties != nil {
+		if effect, ok := builtIn.DefinitionProperties.Parameters["effect"]; ok && effect != nil {
+			defaultEffect, _ = effect.DefaultValue.(string)
+		}
+	}
+
+	stepTrace.WriteString(fmt.Sprintf(
+		"List policy assignments at subscription '%s', resource group '%s' and management group '%s' scope; ",
+		azureutil.SubscriptionID(), azureutil.ResourceGroup(), scenario.policyAssignmentMgmtGroup))
+	assignments, listErr := azConnection.ListPolicyAssignments(azureutil.ResourceGroup(), scenario.policyAssignmentMgmtGroup)
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list policy assignments: %v", listErr)
+		return err
+	}
+
+	stepTrace.WriteString("Get the policy set definitions assigned, to resolve policies assigned through an initiative; ")
+	setDefinitions := make(map[string]policy.SetDefinition)
+	for _, setDefinitionID := range azureutil.PolicySetDefinitionIDs(assignments) {
+		setDefinition, getSetErr := azConnection.GetPolicySetDefinition(setDefinitionID)
+		if getSetErr != nil {
+			err = utils.ReformatError("Failed to get policy set definition '%s': %v", setDefinitionID, getSetErr)
+			return err
+		}
+		setDefinitions[setDefinitionID] = setDefinition
+	}
+
+	// Ephemeral assignments are of a copy of the definition scoped to the storage accounts created by this probe
+	assigned := policyassignment.Definition(Probe.Name(), definition)
+
+	stepTrace.WriteString(fmt.Sprintf("Find an enforced assignment of '%s' with effect '%s'; ", assigned.DisplayName, expectedEffect))
+	match, candidates := azureutil.FindPolicyAssignment(assignments, setDefinitions, assigned, defaultEffect, expectedEffect, azureutil.SubscriptionID(), azureutil.ResourceGroup())
+
+	// Audit log
+	payload = struct {
+		PolicyName      string
+		PolicyID        string
+		ExpectedEffect  string
+		ManagementGroup string
+		Match           *azureutil.PolicyAssignmentMatch
+		Candidates      []azureutil.PolicyAssignmentMatch
+	}{
+		PolicyName:      policyName,
+		PolicyID:        assigned.ID(),
+		ExpectedEffect:  expectedEffect,
+		ManagementGroup: scenario.policyAssignmentMgmtGroup,
+		Match:           match,
+		Candidates:      candidates,
+	}
+
+	switch {
+	case match != nil:
+		return nil
+	case len(candidates) == 0:
+		err = utils.ReformatError("Prerequisite not met: policy '%s' is not assigned to resource group '%s' at any scope. The probe cannot verify the control until the policy is assigned", policyName, azureutil.ResourceGroup())
+	default:
+		err = utils.ReformatError("Prerequisite not met: policy '%s' is assigned but not enforced with effect '%s' (found: %v). The probe cannot verify the control until the assignment is corrected", policyName, expectedEffect, describeAssignments(candidates))
+	}
+	return err
+}
+
+func describeAssignments(candidates []azureutil.PolicyAssignmentMatch) string {
+	var descriptions []string
+	for _, candidate := range candidates {
+		description := fmt.Sprintf("%s scope '%s' effect '%s' enforcement '%s'", candidate.ScopeType, candidate.Scope, candidate.Effect, candidate.EnforcementMode)
+		if candidate.PolicySetDefinitionID != "" {
+			description += fmt.Sprintf(" via initiative '%s'", candidate.PolicySetDefinitionID)
+		}
+		descriptions = append(descriptions, description)
+	}
+	return strings.Join(descriptions, "; ")
+}
+
 func (scenario *scenarioState) creationOfAnObjectStorageBucketWithHTTPSXY(httpsOption, expectedResult string) error {
 	return scenario.creationOfAnObjectStorageBucketWithHTTPSXYWithErrorCodeZ(httpsOption, expectedResult, "")
 }
@@ -115,10 +233,18 @@ func (scenario *scenarioState) creationOfAnObjectStorageBucketWithHTTPSXYWithErr
 	// Supported values for 'expectedResult':
 	//	'succeeds'
 	//	'fails'
+	//	'is flagged non-compliant'
 
 	// Supported values for 'expectedErrorCode':
 	//	free text
 
+	// Compliance is only evaluated for provisioned resources, so skip before auditing the step
+	if expectedResult == "is flagged non-compliant" {
+		if err := azureutil.RequireRealStorageAccount(); err != nil {
+			return err
+		}
+	}
+
 	// Standard auditing logic to ensures panics are also audited
 	stepTrace, payload, err := utils.AuditPlaceholders()
 	defer func() {
@@ -142,34 +268,91 @@ func (scenario *scenarioState) creationOfAnObjectStorageBucketWithHTTPSXYWithErr
 	}
 
 	// Validate input values - expectedResult
-	var shouldCreate bool
+	var shouldCreate, shouldBeFlagged bool
 	switch expectedResult {
 	case "succeeds":
 		shouldCreate = true
 	case "fails":
 		shouldCreate = false
+	case "is flagged non-compliant":
+		shouldCreate = true
+		shouldBeFlagged = true
 	default:
-		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails', 'is flagged non-compliant']", expectedResult)
 		return err
 	}
 
 	resourceGroup := azureutil.ResourceGroup()
-	bucketName := utils.RandomString(10)
-	stepTrace.WriteString(fmt.Sprintf("Generate a storage account name using a random string: '%s'; ", bucketName))
 
 	stepTrace.WriteString("Use DefaultActionAllow for NetworkRuleSet; ")
 	networkRuleSet := azureStorage.NetworkRuleSet{
 		DefaultAction: azureStorage.DefaultActionAllow,
 	}
+	parameters := azureStorage.AccountCreateParameters{
+		AccountPropertiesCreateParameters: &azureStorage.AccountPropertiesCreateParameters{
+			EnableHTTPSTrafficOnly: to.BoolPtr(httpsEnabled),
+			NetworkRuleSet:         &networkRuleSet,
+		},
+		Tags: scenario.tags,
+	}
 
-	stepTrace.WriteString(fmt.Sprintf(
-		"Attempt to create Storage Account with HTTPS: %v; ", httpsEnabled))
-	storageAccount, creationErr := azConnection.CreateStorageAccount(bucketName, resourceGroup, scenario.tags, httpsEnabled, &networkRuleSet)
-	if creationErr == nil {
-		scenario.storageAccounts = append(scenario.storageAccounts, bucketName) // Record for later cleanup
+	var outcomes []creationOutcome
+	for _, backend := range azureutil.CreationBackends() {
+
+		bucketName := utils.RandomString(10)
+		stepTrace.WriteString(fmt.Sprintf("Generate a storage account name using a random string: '%s'; ", bucketName))
+
+		stepTrace.WriteString(fmt.Sprintf(
+			"Attempt to create Storage Account with HTTPS: %v using the '%s' creation backend; ", httpsEnabled, backend))
+		storageAccount, creationErr := azConnection.CreateStorageAccountWithBackend(azureutil.CreationMode(), backend, bucketName, resourceGroup, parameters)
+		if creationErr == nil {
+			scenario.storageAccounts = append(scenario.storageAccounts, bucketName) // Record for later cleanup
+		}
+
+		outcome := creationOutcome{
+			Backend:            backend,
+			StorageAccountName: bucketName,
+			StorageAccount:     storageAccount,
+		}
+		if creationErr != nil {
+			outcome.Error = creationErr.Error()
+		}
+
+		stepTrace.WriteString(fmt.Sprintf("Validate that storage account creation %s; ", expectedResult))
+		attemptErr := checkCreationResult(creationErr, shouldCreate, expectedErrorCode)
+
+		if shouldBeFlagged && attemptErr == nil {
+			stepTrace.WriteString(fmt.Sprintf("Validate that Policy Insights flags the storage account as non-compliant with '%s'; ", azureutil.SecureTransferPolicy.DisplayName))
+			outcome.Compliance, attemptErr = compliance.WaitForNonCompliance(azConnection, to.String(storageAccount.ID), policyassignment.Definition(Probe.Name(), azureutil.SecureTransferPolicy), azureutil.ComplianceEvaluationTimeout())
+			if attemptErr == nil && !outcome.Compliance.NonCompliant {
+				attemptErr = utils.ReformatError("Storage account was not flagged non-compliant with '%s' after %s", azureutil.SecureTransferPolicy.DisplayName, outcome.Compliance.Elapsed)
+			}
+		}
+
+		outcomes = append(outcomes, outcome)
+		if attemptErr != nil && err == nil {
+			err = utils.ReformatError("Creation backend '%s': %v", backend, attemptErr)
+		}
+	}
+
+	//Audit log
+	payload = struct {
+		ResourceGroup  string
+		NetworkRuleSet azureStorage.NetworkRuleSet
+		Tags           map[string]*string
+		Outcomes       []creationOutcome
+	}{
+		ResourceGroup:  resourceGroup,
+		NetworkRuleSet: networkRuleSet,
+		Tags:           scenario.tags,
+		Outcomes:       outcomes,
 	}
 
-	stepTrace.WriteString(fmt.Sprintf("Validate that storage account creation %s; ", expectedResult))
+	return err
+}
+
+// checkCreationResult compares the result of a storage account creation attempt with the expected result
+func checkCreationResult(creationErr error, shouldCreate bool, expectedErrorCode string) (err error) {
 	switch shouldCreate {
 	case true:
 		if creationErr != nil {
@@ -203,21 +386,85 @@ func (scenario *scenarioState) creationOfAnObjectStorageBucketWithHTTPSXYWithErr
 			}
 		}
 	}
+	return
+}
+
+func (scenario *scenarioState) creationOfAnObjectStorageBucketWithHTTPSXSucceedsAndTheEffectiveYIsRemediatedToZWithinNSeconds(httpsOption, property, desiredValue string, timeoutSeconds int) error {
+
+	// Supported values for 'httpsOption':
+	//	'enabled'
+	//  'disabled'
+
+	// Supported values for 'property':
+	//	see azureutil.AccountProperty
+
+	// Remediation requires a real storage account, so skip before auditing the step
+	if err := azureutil.RequireRealStorageAccount(); err != nil {
+		return err
+	}
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values - httpsOption
+	var httpsEnabled bool
+	switch httpsOption {
+	case "enabled":
+		httpsEnabled = true
+	case "disabled":
+		httpsEnabled = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for httpsOption: '%s' Expected values: ['enabled', 'disabled']", httpsOption)
+		return err
+	}
+
+	resourceGroup := azureutil.ResourceGroup()
+	bucketName := utils.RandomString(10)
+	stepTrace.WriteString(fmt.Sprintf("Generate a storage account name using a random string: '%s'; ", bucketName))
+
+	stepTrace.WriteString("Use DefaultActionAllow for NetworkRuleSet; ")
+	networkRuleSet := azureStorage.NetworkRuleSet{
+		DefaultAction: azureStorage.DefaultActionAllow,
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Create Storage Account with HTTPS: %v; ", httpsEnabled))
+	_, creationErr := azConnection.CreateStorageAccount(bucketName, resourceGroup, scenario.tags, httpsEnabled, &networkRuleSet)
+	if creationErr != nil {
+		err = utils.ReformatError("Creation of storage account did not succeed: %v", creationErr)
+		return err
+	}
+	scenario.storageAccounts = append(scenario.storageAccounts, bucketName) // Record for later cleanup
+
+	stepTrace.WriteString(fmt.Sprintf("Re-read the storage account until '%s' is '%s' or %d seconds have elapsed; ", property, desiredValue, timeoutSeconds))
+	remediation, err := azureutil.WaitForRemediation(
+		func() (azureStorage.Account, error) {
+			return azConnection.GetStorageAccountProperties(resourceGroup, bucketName)
+		},
+		property, desiredValue, time.Duration(timeoutSeconds)*time.Second, remediationPollInterval)
 
 	//Audit log
 	payload = struct {
 		StorageAccountName string
 		ResourceGroup      string
-		StorageAccount     azureStorage.Account
-		NetworkRuleSet     azureStorage.NetworkRuleSet
-		Tags               map[string]*string
+		HTTPSEnabled       bool
+		Remediation        azureutil.Remediation
 	}{
 		StorageAccountName: bucketName,
 		ResourceGroup:      resourceGroup,
-		StorageAccount:     storageAccount,
-		NetworkRuleSet:     networkRuleSet,
+		HTTPSEnabled:       httpsEnabled,
+		Remediation:        remediation,
 	}
 
+	if err == nil && !remediation.Remediated {
+		err = utils.ReformatError("Effective '%s' of storage account was '%s' after %s, expected it to be remediated to '%s'", property, remediation.Effective, remediation.Elapsed, desiredValue)
+	}
 	return err
 }
 
@@ -227,6 +474,7 @@ func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
 	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
 	s.ctx = context.Background()
 	s.storageAccounts = make([]string, 0)
+	s.tags = policyassignment.Tags(probeName)
 	probeengine.LogScenarioStart(gs)
 }
 
@@ -254,9 +502,13 @@ func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
 			azureutil.ClientID(),
 			azureutil.ClientSecret(),
 		)
-	})
 
-	ctx.AfterSuite(func() {
+		// Policies required by every probe are assigned once, by the first probe to run
+		if azureutil.EphemeralPolicyAssignment() {
+			if err := policyassignment.Ensure(azConnection); err != nil {
+				log.Printf("[ERROR] Ephemeral policy assignment failed: %v", err)
+			}
+		}
 	})
 }
 
@@ -270,10 +522,15 @@ func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
 	// Background
 	ctx.Step(`^an Azure subscription is available$`, scenario.anAzureSubscriptionIsAvailable)
 	ctx.Step(`^azure resource group specified in config exists$`, scenario.azureResourceGroupSpecifiedInConfigExists)
+	ctx.Step(`^the "([^"]*)" policy is assigned with effect "([^"]*)"$`, scenario.thePolicyIsAssignedWithEffect)
 
 	// Steps
+	ctx.Step(`^the control is enforced by "([^"]*)"$`, func(enforcement string) error {
+		return azureutil.TheControlIsEnforcedByX(scenario.audit, scenario.currentStep, enforcement)
+	})
 	ctx.Step(`^creation of an Object Storage bucket with https "([^"]*)" "([^"]*)"$`, scenario.creationOfAnObjectStorageBucketWithHTTPSXY)
 	ctx.Step(`^creation of an Object Storage bucket with https "([^"]*)" "([^"]*)" with error code "([^"]*)"$`, scenario.creationOfAnObjectStorageBucketWithHTTPSXYWithErrorCodeZ)
+	ctx.Step(`^creation of an Object Storage bucket with https "([^"]*)" succeeds and the effective "([^"]*)" is remediated to "([^"]*)" within (\d+) seconds$`, scenario.creationOfAnObjectStorageBucketWithHTTPSXSucceedsAndTheEffectiveYIsRemediatedToZWithinNSeconds)
 
 	ctx.AfterScenario(func(s *godog.Scenario, err error) {
 		afterScenario(scenario, probe, s, err)
@@ -0,0 +1,109 @@
+package azure
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// PolicyDenialCode is the error code returned when a request is denied by an Azure Policy assignment
+const PolicyDenialCode = "RequestDisallowedByPolicy"
+
+// ServiceErrorCode returns the error code of an Azure service error, including a blob service data plane error, or an empty string
+// if the error did not come from the Azure service
+func ServiceErrorCode(err error) string {
+	switch e := err.(type) {
+	case autorest.DetailedError:
+		return ServiceErrorCode(e.Original)
+	case *autorest.DetailedError:
+		return ServiceErrorCode(e.Original)
+	case *azure.ServiceError:
+		return e.Code
+	case *azure.RequestError:
+		if e.ServiceError != nil {
+			return e.ServiceError.Code
+		}
+	case azblob.StorageError:
+		return string(e.ServiceCode())
+	}
+	return ""
+}
+
+// ServiceErrorStatusCode returns the HTTP status code of an Azure service error, including a blob service data plane error, or 0
+// if the error did not come from the Azure service
+func ServiceErrorStatusCode(err error) int {
+	switch e := err.(type) {
+	case autorest.DetailedError:
+		if statusCode, ok := e.StatusCode.(int); ok {
+			return statusCode
+		}
+	case *autorest.DetailedError:
+		if statusCode, ok := e.StatusCode.(int); ok {
+			return statusCode
+		}
+	case azblob.StorageError:
+		if e.Response() != nil {
+			return e.Response().StatusCode
+		}
+	}
+	return 0
+}
+
+// ServiceErrorMessage returns the message of an Azure service error, or an empty string if the error did not come from the Azure service
+func ServiceErrorMessage(err error) string {
+	switch e := err.(type) {
+	case autorest.DetailedError:
+		return ServiceErrorMessage(e.Original)
+	case *autorest.DetailedError:
+		return ServiceErrorMessage(e.Original)
+	case *azure.ServiceError:
+		return e.Message
+	case *azure.RequestError:
+		if e.ServiceError != nil {
+			return e.ServiceError.Message
+		}
+	}
+	return ""
+}
+
+// IsPolicyDenial returns whether the error is a denial by an Azure Policy assignment
+func IsPolicyDenial(err error) bool {
+	return strings.EqualFold(ServiceErrorCode(err), PolicyDenialCode)
+}
+
+// DeniedPolicyDefinitions returns the names of the policy definitions which denied a request, as listed in the policy violation
+// details of a policy denial, or nil if the error carries no policy violation details
+func DeniedPolicyDefinitions(err error) []string {
+	var definitions []string
+	for _, additionalInfo := range serviceErrorAdditionalInfo(err) {
+		if additionalInfo["type"] != "PolicyViolation" {
+			continue
+		}
+		info, ok := additionalInfo["info"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, ok := info["policyDefinitionName"].(string); ok && name != "" {
+			definitions = append(definitions, name)
+		}
+	}
+	return definitions
+}
+
+func serviceErrorAdditionalInfo(err error) []map[string]interface{} {
+	switch e := err.(type) {
+	case autorest.DetailedError:
+		return serviceErrorAdditionalInfo(e.Original)
+	case *autorest.DetailedError:
+		return serviceErrorAdditionalInfo(e.Original)
+	case *azure.ServiceError:
+		return e.AdditionalInfo
+	case *azure.RequestError:
+		if e.ServiceError != nil {
+			return e.ServiceError.AdditionalInfo
+		}
+	}
+	return nil
+}
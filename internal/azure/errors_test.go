@@ -0,0 +1,135 @@
+package azure
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+)
+
+// storageError is a blob service data plane error, as returned by azblob
+type storageError struct {
+	serviceCode azblob.ServiceCodeType
+	statusCode  int
+}
+
+func (e storageError) Error() string                       { return string(e.serviceCode) }
+func (e storageError) Timeout() bool                       { return false }
+func (e storageError) Temporary() bool                     { return false }
+func (e storageError) Response() *http.Response            { return &http.Response{StatusCode: e.statusCode} }
+func (e storageError) ServiceCode() azblob.ServiceCodeType { return e.serviceCode }
+
+func TestServiceErrorCode(t *testing.T) {
+	tests := []struct {
+		testName     string
+		err          error
+		expectedCode string
+		policyDenial bool
+	}{
+		{
+			testName:     "TestCase1_DetailedPolicyDenial_ShouldReturnCode",
+			err:          autorest.DetailedError{Original: &azure.ServiceError{Code: "RequestDisallowedByPolicy", Message: "denied"}},
+			expectedCode: "RequestDisallowedByPolicy",
+			policyDenial: true,
+		},
+		{
+			testName:     "TestCase2_RequestError_ShouldReturnServiceErrorCode",
+			err:          &azure.RequestError{ServiceError: &azure.ServiceError{Code: "InvalidRequestPropertyValue"}},
+			expectedCode: "InvalidRequestPropertyValue",
+		},
+		{
+			testName:     "TestCase3_BlobServiceError_ShouldReturnServiceCode",
+			err:          storageError{serviceCode: "AuthorizationFailure", statusCode: http.StatusForbidden},
+			expectedCode: "AuthorizationFailure",
+		},
+		{
+			testName:     "TestCase4_GenericError_ShouldReturnEmptyCode",
+			err:          errors.New("connection reset by peer"),
+			expectedCode: "",
+		},
+		{
+			testName:     "TestCase5_NilError_ShouldReturnEmptyCode",
+			err:          nil,
+			expectedCode: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			if code := ServiceErrorCode(tc.err); code != tc.expectedCode {
+				t.Errorf("ServiceErrorCode() = '%s', expected '%s'", code, tc.expectedCode)
+			}
+			if denial := IsPolicyDenial(tc.err); denial != tc.policyDenial {
+				t.Errorf("IsPolicyDenial() = %v, expected %v", denial, tc.policyDenial)
+			}
+		})
+	}
+}
+
+func TestServiceErrorStatusCode(t *testing.T) {
+	tests := []struct {
+		testName           string
+		err                error
+		expectedStatusCode int
+	}{
+		{"TestCase1_DetailedError_ShouldReturnStatusCode", autorest.DetailedError{StatusCode: http.StatusForbidden}, http.StatusForbidden},
+		{"TestCase2_BlobServiceError_ShouldReturnResponseStatusCode", storageError{serviceCode: "AuthorizationFailure", statusCode: http.StatusForbidden}, http.StatusForbidden},
+		{"TestCase3_GenericError_ShouldReturnZero", errors.New("connection reset by peer"), 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			if statusCode := ServiceErrorStatusCode(tc.err); statusCode != tc.expectedStatusCode {
+				t.Errorf("ServiceErrorStatusCode() = %d, expected %d", statusCode, tc.expectedStatusCode)
+			}
+		})
+	}
+}
+
+func TestDeniedPolicyDefinitions(t *testing.T) {
+	violation := func(definitionName string) map[string]interface{} {
+		return map[string]interface{}{
+			"type": "PolicyViolation",
+			"info": map[string]interface{}{"policyDefinitionName": definitionName},
+		}
+	}
+	tests := []struct {
+		testName            string
+		err                 error
+		expectedDefinitions []string
+	}{
+		{
+			testName: "TestCase1_CombinedDenial_ShouldReturnEveryDefinition",
+			err: autorest.DetailedError{Original: &azure.ServiceError{
+				Code:           "RequestDisallowedByPolicy",
+				AdditionalInfo: []map[string]interface{}{violation("404c3081-a854-4457-ae30-26a93ef643f9"), violation("probr-nfsv3")},
+			}},
+			expectedDefinitions: []string{"404c3081-a854-4457-ae30-26a93ef643f9", "probr-nfsv3"},
+		},
+		{
+			testName: "TestCase2_OtherAdditionalInfo_ShouldBeIgnored",
+			err: &azure.RequestError{ServiceError: &azure.ServiceError{
+				Code:           "RequestDisallowedByPolicy",
+				AdditionalInfo: []map[string]interface{}{{"type": "PolicyEvaluation"}},
+			}},
+			expectedDefinitions: nil,
+		},
+		{
+			testName:            "TestCase3_GenericError_ShouldReturnNoDefinitions",
+			err:                 errors.New("connection reset by peer"),
+			expectedDefinitions: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			if definitions := DeniedPolicyDefinitions(tc.err); !reflect.DeepEqual(definitions, tc.expectedDefinitions) {
+				t.Errorf("DeniedPolicyDefinitions() = %v, expected %v", definitions, tc.expectedDefinitions)
+			}
+		})
+	}
+}
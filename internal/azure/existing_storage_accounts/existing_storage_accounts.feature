@@ -0,0 +1,26 @@
+@s-azesa
+Feature: Existing Object Storage Complies With Security Controls
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to the Object Storage already in use
+  So that my organisation's data is protected even where storage was created before policies were in place
+
+    Background:
+      Given an Azure subscription is available
+      And the storage accounts in the configured scope are listed
+
+    @s-azesa-001
+    Scenario: Detect Existing Object Storage Without Encryption in Flight
+      Then every existing storage account complies with the "https only" control
+
+    @s-azesa-002
+    Scenario: Detect Existing Object Storage Without Allowed Network Access Measures
+      Then every existing storage account complies with the "network rules" control
+
+    @s-azesa-003
+    Scenario: Detect Existing Object Storage With Anonymous Access
+      Then every existing storage account complies with the "public access" control
+
+    @s-azesa-004
+    Scenario: Detect Existing Object Storage Without Encryption at Rest Using Customer Managed Keys
+      Then every existing storage account complies with the "encryption" control
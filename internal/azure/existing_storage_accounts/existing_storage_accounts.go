@@ -0,0 +1,280 @@
+package azureesa
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	azureStorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/cucumber/godog"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/azure/controls"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// ProbeStruct allows this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+type scenarioState struct {
+	name            string
+	currentStep     string
+	audit           *audit.ScenarioAudit
+	probe           *audit.Probe
+	ctx             context.Context
+	storageAccounts []azureStorage.Account
+}
+
+// Probe ...
+var Probe probeStruct             // Probe allows this probe to be added to the ProbeStore
+var scenario scenarioState        // Local container of scenario state
+var azConnection connection.Azure // Provides functionality to interact with Azure
+
+func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that Azure subscription specified in config file is available; "))
+
+	payload = struct {
+		SubscriptionID string
+		TenantID       string
+	}{
+		azureutil.SubscriptionID(),
+		azureutil.TenantID(),
+	}
+
+	err = azConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) theStorageAccountsInTheConfiguredScopeAreListed() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	subscriptions := azureutil.InventorySubscriptions()
+	resourceGroups := azureutil.DetectiveResourceGroups()
+
+	switch {
+	case len(subscriptions) > 0:
+		stepTrace.WriteString(fmt.Sprintf("Query Azure Resource Graph for Storage Accounts in %d subscriptions; ", len(subscriptions)))
+		items, listErr := azConnection.ListStorageAccountsInSubscriptions(subscriptions)
+		if listErr != nil {
+			err = utils.ReformatError("Failed to list storage accounts from inventory: %v", listErr)
+			return err
+		}
+		for _, item := range items {
+			if len(resourceGroups) > 0 && !contains(resourceGroups, item.ResourceGroup) {
+				continue
+			}
+			scenario.storageAccounts = append(scenario.storageAccounts, item.Account)
+		}
+	case len(resourceGroups) > 0:
+		for _, resourceGroup := range resourceGroups {
+			stepTrace.WriteString(fmt.Sprintf("List Storage Accounts in resource group '%s'; ", resourceGroup))
+			accounts, listErr := azConnection.ListStorageAccountsByResourceGroup(resourceGroup)
+			if listErr != nil {
+				err = utils.ReformatError("Failed to list storage accounts in resource group '%s': %v", resourceGroup, listErr)
+				return err
+			}
+			scenario.storageAccounts = append(scenario.storageAccounts, accounts...)
+		}
+	default:
+		stepTrace.WriteString(fmt.Sprintf("List all Storage Accounts in subscription '%s'; ", azureutil.SubscriptionID()))
+		accounts, listErr := azConnection.ListStorageAccounts()
+		if listErr != nil {
+			err = utils.ReformatError("Failed to list storage accounts in subscription: %v", listErr)
+			return err
+		}
+		scenario.storageAccounts = accounts
+	}
+
+	var accountIDs []string
+	for _, account := range scenario.storageAccounts {
+		accountIDs = append(accountIDs, to.String(account.ID))
+	}
+
+	//Audit log
+	payload = struct {
+		SubscriptionID         string
+		InventorySubscriptions []string
+		ResourceGroups         []string
+		StorageAccounts        []string
+	}{
+		SubscriptionID:         azureutil.SubscriptionID(),
+		InventorySubscriptions: subscriptions,
+		ResourceGroups:         resourceGroups,
+		StorageAccounts:        accountIDs,
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) everyExistingStorageAccountCompliesWithTheXControl(control string) error {
+
+	// Supported values for 'control':
+	//	'https only'
+	//	'network rules'
+	//	'public access'
+	//	'encryption'
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	evaluate, getErr := controls.Get(control)
+	if getErr != nil {
+		err = getErr
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Evaluate %d storage accounts against the '%s' control, auditing each account individually; ", len(scenario.storageAccounts), control))
+	var nonCompliant []string
+	for _, account := range scenario.storageAccounts {
+		result := evaluate(account)
+
+		var accountErr error
+		if !result.Compliant {
+			accountErr = utils.ReformatError("Storage account '%s' does not comply with the '%s' control: %s", to.String(account.Name), control, result.Reason)
+			nonCompliant = append(nonCompliant, to.String(account.ID))
+		}
+
+		// One audit entry per account
+		scenario.audit.AuditScenarioStep(
+			fmt.Sprintf("%s - %s", scenario.currentStep, to.String(account.ID)),
+			fmt.Sprintf("Evaluate storage account '%s' against the '%s' control; ", to.String(account.ID), control),
+			struct {
+				StorageAccountID string
+				Result           controls.Result
+			}{
+				StorageAccountID: to.String(account.ID),
+				Result:           result,
+			},
+			accountErr)
+	}
+
+	if len(nonCompliant) > 0 {
+		err = utils.ReformatError("%d of %d storage accounts do not comply with the '%s' control: %s", len(nonCompliant), len(scenario.storageAccounts), control, strings.Join(nonCompliant, ", "))
+	}
+
+	//Audit log
+	payload = struct {
+		Control         string
+		AccountsChecked int
+		NonCompliant    []string
+	}{
+		Control:         control,
+		AccountsChecked: len(scenario.storageAccounts),
+		NonCompliant:    nonCompliant,
+	}
+
+	return err
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.storageAccounts = make([]azureStorage.Account, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	// No resources are created in detective mode, so there is nothing to tear down
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "existing_storage_accounts"
+}
+
+// Path returns this probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "azure", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize azure connection
+		azConnection = connection.NewAzureConnection(
+			context.Background(),
+			azureutil.SubscriptionID(),
+			azureutil.TenantID(),
+			azureutil.ClientID(),
+			azureutil.ClientSecret(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an Azure subscription is available$`, scenario.anAzureSubscriptionIsAvailable)
+	ctx.Step(`^the storage accounts in the configured scope are listed$`, scenario.theStorageAccountsInTheConfiguredScopeAreListed)
+
+	// Steps
+	ctx.Step(`^every existing storage account complies with the "([^"]*)" control$`, scenario.everyExistingStorageAccountCompliesWithTheXControl)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
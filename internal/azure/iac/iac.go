@@ -0,0 +1,456 @@
+// Package iac extracts storage account definitions from infrastructure as code, so that the controls encoded in this pack
+// can be evaluated offline before anything is deployed. ARM templates (including Bicep files compiled with 'az bicep build')
+// and Terraform plans exported with 'terraform show -json' are supported.
+package iac
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// Supported definition formats
+const (
+	FormatARM       = "arm"
+	FormatTerraform = "terraform"
+)
+
+const (
+	armStorageAccountType       = "Microsoft.Storage/storageAccounts"
+	armDeploymentType           = "Microsoft.Resources/deployments"
+	terraformStorageAccountType = "azurerm_storage_account"
+	terraformCustomerKeyType    = "azurerm_storage_account_customer_managed_key"
+)
+
+// Definition is a storage account defined in an infrastructure as code file
+type Definition struct {
+	Source  string // Path of the file the definition was found in
+	Address string // Resource name in an ARM template, or resource address in a Terraform plan
+	Format  string
+	Account storage.Account
+}
+
+// ID identifies the definition in audit output
+func (d Definition) ID() string {
+	return fmt.Sprintf("%s:%s", d.Source, d.Address)
+}
+
+// LoadDirectory extracts the storage account definitions from every ARM template and Terraform plan JSON file in a directory tree.
+// JSON files in other formats are ignored.
+func LoadDirectory(dir string) (definitions []Definition, err error) {
+
+	info, statErr := os.Stat(dir)
+	if statErr != nil || !info.IsDir() {
+		err = utils.ReformatError("Infrastructure as code directory '%s' does not exist", dir)
+		return
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".json") {
+			return nil
+		}
+
+		data, readErr := ioutil.ReadFile(path)
+		if readErr != nil {
+			return utils.ReformatError("Failed to read '%s': %v", path, readErr)
+		}
+
+		found, parseErr := Parse(path, data)
+		if parseErr != nil {
+			return parseErr
+		}
+		definitions = append(definitions, found...)
+		return nil
+	})
+	return
+}
+
+// Parse detects the format of a JSON document and extracts its storage account definitions
+func Parse(source string, data []byte) ([]Definition, error) {
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, utils.ReformatError("Failed to parse '%s': %v", source, err)
+	}
+
+	switch {
+	case document["planned_values"] != nil:
+		return parseTerraformPlan(source, document)
+	case document["resources"] != nil && strings.Contains(strings.ToLower(fmt.Sprint(document["$schema"])), "deploymenttemplate"):
+		return parseARMTemplate(source, document, nil)
+	default:
+		return nil, nil
+	}
+}
+
+// parseARMTemplate extracts the storage accounts of a template. Values bound to the template's parameters by an enclosing
+// deployment take precedence over the parameters' default values.
+func parseARMTemplate(source string, template map[string]interface{}, bound map[string]interface{}) ([]Definition, error) {
+
+	parameters := make(map[string]interface{})
+	if declared, ok := template["parameters"].(map[string]interface{}); ok {
+		for name, declaration := range declared {
+			if d, ok := declaration.(map[string]interface{}); ok && d["defaultValue"] != nil {
+				parameters[strings.ToLower(name)] = d["defaultValue"]
+			}
+		}
+	}
+	for name, value := range bound {
+		parameters[name] = value
+	}
+
+	var definitions []Definition
+	for _, r := range armResources(template["resources"]) {
+		resourceType := fmt.Sprint(r["type"])
+
+		switch {
+		case strings.EqualFold(resourceType, armDeploymentType):
+			// Nested deployments, which is how Bicep modules are compiled
+			if properties, ok := r["properties"].(map[string]interface{}); ok {
+				if nested, ok := properties["template"].(map[string]interface{}); ok {
+					found, err := parseARMTemplate(source, nested, nestedParameters(properties, parameters))
+					if err != nil {
+						return nil, err
+					}
+					definitions = append(definitions, found...)
+				}
+			}
+
+		case strings.EqualFold(resourceType, armStorageAccountType):
+			resolved, _ := resolveExpressions(r, parameters, false)
+
+			data, err := json.Marshal(resolved)
+			if err != nil {
+				return nil, utils.ReformatError("Failed to read storage account in '%s': %v", source, err)
+			}
+			var account storage.Account
+			if err := json.Unmarshal(data, &account); err != nil {
+				return nil, utils.ReformatError("Failed to read storage account '%v' in '%s': %v", r["name"], source, err)
+			}
+			applyARMDefaults(&account, r)
+
+			definitions = append(definitions, Definition{
+				Source:  source,
+				Address: fmt.Sprint(r["name"]),
+				Format:  FormatARM,
+				Account: account,
+			})
+		}
+	}
+	return definitions, nil
+}
+
+// nestedParameters returns the parameter values a nested deployment binds into its template. With the default 'outer'
+// expression evaluation scope, the nested template is evaluated with the parameters of the enclosing template. With the
+// 'inner' scope, used by Bicep modules, values are passed through the deployment's own parameters, which may be expressions
+// over the parameters of the enclosing template.
+func nestedParameters(deployment map[string]interface{}, outer map[string]interface{}) map[string]interface{} {
+
+	scope := ""
+	if options, ok := deployment["expressionEvaluationOptions"].(map[string]interface{}); ok {
+		scope = strings.ToLower(fmt.Sprint(options["scope"]))
+	}
+	if scope != "inner" {
+		return outer
+	}
+
+	bound := make(map[string]interface{})
+	if values, ok := deployment["parameters"].(map[string]interface{}); ok {
+		for name, v := range values {
+			value, ok := v.(map[string]interface{})
+			if !ok || value["value"] == nil {
+				continue
+			}
+			// Expressions which cannot be resolved are bound as they are, so that the nested template treats them as unresolved too
+			resolved, _ := resolveExpressions(value["value"], outer, false)
+			bound[strings.ToLower(name)] = resolved
+		}
+	}
+	return bound
+}
+
+// applyARMDefaults sets the values Azure applies to properties a storage account resource omits. Properties which are
+// set to an expression that cannot be evaluated offline are left unset.
+func applyARMDefaults(account *storage.Account, resource map[string]interface{}) {
+	declared, _ := resource["properties"].(map[string]interface{})
+	if account.AccountProperties == nil {
+		account.AccountProperties = &storage.AccountProperties{}
+	}
+	if !hasKey(declared, "supportsHttpsTrafficOnly") {
+		// Secure transfer is required by default since API version 2019-04-01
+		account.AccountProperties.EnableHTTPSTrafficOnly = to.BoolPtr(true)
+	}
+}
+
+// hasKey returns whether an object declares a property, ignoring case as template property names are case insensitive
+func hasKey(object map[string]interface{}, name string) bool {
+	for key := range object {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// armResources returns the resources of a template, declared either as an array or, in symbolic name templates, as an object
+func armResources(value interface{}) []map[string]interface{} {
+	var resources []map[string]interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		for _, r := range v {
+			if resource, ok := r.(map[string]interface{}); ok {
+				resources = append(resources, resource)
+			}
+		}
+	case map[string]interface{}:
+		for _, r := range v {
+			if resource, ok := r.(map[string]interface{}); ok {
+				resources = append(resources, resource)
+			}
+		}
+	}
+	return resources
+}
+
+var parametersExpression = regexp.MustCompile(`^\[parameters\('([^']+)'\)\]$`)
+
+// resolveExpressions substitutes parameters() expressions with the parameter's default value. Other template expressions
+// cannot be evaluated offline; they are kept for the resource name and location and otherwise treated as unset.
+func resolveExpressions(value interface{}, parameters map[string]interface{}, inProperties bool) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		if !isExpression(v) {
+			return v, true
+		}
+		if match := parametersExpression.FindStringSubmatch(v); match != nil {
+			if resolved, ok := parameters[strings.ToLower(match[1])]; ok {
+				// A parameter whose value is itself an expression is no more resolvable than the expression
+				if s, isString := resolved.(string); !isString || !isExpression(s) {
+					return resolved, true
+				}
+			}
+		}
+		return v, !inProperties
+	case map[string]interface{}:
+		resolved := make(map[string]interface{})
+		for key, item := range v {
+			if r, keep := resolveExpressions(item, parameters, inProperties || key == "properties"); keep {
+				resolved[key] = r
+			}
+		}
+		return resolved, true
+	case []interface{}:
+		var resolved []interface{}
+		for _, item := range v {
+			if r, keep := resolveExpressions(item, parameters, inProperties); keep {
+				resolved = append(resolved, r)
+			}
+		}
+		return resolved, true
+	default:
+		return v, true
+	}
+}
+
+// isExpression returns whether a template string is an expression rather than a literal. Literals starting with a bracket are escaped as '[['.
+func isExpression(s string) bool {
+	return strings.HasPrefix(s, "[") && !strings.HasPrefix(s, "[[")
+}
+
+func parseTerraformPlan(source string, plan map[string]interface{}) ([]Definition, error) {
+	var definitions []Definition
+	if planned, ok := plan["planned_values"].(map[string]interface{}); ok {
+		if root, ok := planned["root_module"].(map[string]interface{}); ok {
+			definitions = terraformModule(source, root)
+		}
+	}
+
+	// Customer managed keys may be configured with a separate resource instead of the customer_managed_key block
+	customerKeys := make(map[string]bool)
+	if configuration, ok := plan["configuration"].(map[string]interface{}); ok {
+		if root, ok := configuration["root_module"].(map[string]interface{}); ok {
+			terraformCustomerKeyReferences(root, "", customerKeys)
+		}
+	}
+	if planned, ok := plan["planned_values"].(map[string]interface{}); ok {
+		if root, ok := planned["root_module"].(map[string]interface{}); ok {
+			terraformCustomerKeyAccountIDs(root, customerKeys)
+		}
+	}
+	for i, d := range definitions {
+		if customerKeys[d.Address] || customerKeys[stripIndex(d.Address)] || customerKeys[strings.ToLower(to.String(d.Account.Name))] {
+			definitions[i].Account.Encryption.KeySource = storage.KeySourceMicrosoftKeyvault
+		}
+	}
+	return definitions, nil
+}
+
+// terraformCustomerKeyReferences records the addresses of the storage accounts referenced by the storage_account_id of each
+// azurerm_storage_account_customer_managed_key resource in a module configuration and its module calls. The account IDs are
+// unknown until apply, so the references in the configuration are used instead of planned values.
+func terraformCustomerKeyReferences(module map[string]interface{}, prefix string, addresses map[string]bool) {
+	resources, _ := module["resources"].([]interface{})
+	for _, r := range resources {
+		resource, ok := r.(map[string]interface{})
+		if !ok || resource["type"] != terraformCustomerKeyType {
+			continue
+		}
+		expressions, _ := resource["expressions"].(map[string]interface{})
+		accountID, _ := expressions["storage_account_id"].(map[string]interface{})
+		references, _ := accountID["references"].([]interface{})
+		for _, reference := range references {
+			address := strings.TrimSuffix(fmt.Sprint(reference), ".id")
+			if strings.HasPrefix(address, terraformStorageAccountType+".") {
+				addresses[prefix+address] = true
+			}
+		}
+	}
+
+	calls, _ := module["module_calls"].(map[string]interface{})
+	for name, c := range calls {
+		call, _ := c.(map[string]interface{})
+		if child, ok := call["module"].(map[string]interface{}); ok {
+			terraformCustomerKeyReferences(child, fmt.Sprintf("%smodule.%s.", prefix, name), addresses)
+		}
+	}
+}
+
+// terraformCustomerKeyAccountIDs records the names of storage accounts whose ID is already known to an
+// azurerm_storage_account_customer_managed_key resource, as is the case for accounts which are not managed by the plan
+func terraformCustomerKeyAccountIDs(module map[string]interface{}, names map[string]bool) {
+	resources, _ := module["resources"].([]interface{})
+	for _, r := range resources {
+		resource, ok := r.(map[string]interface{})
+		if !ok || resource["type"] != terraformCustomerKeyType {
+			continue
+		}
+		values, _ := resource["values"].(map[string]interface{})
+		if id := stringValue(values, "storage_account_id"); id != "" {
+			names[strings.ToLower(id[strings.LastIndex(id, "/")+1:])] = true
+		}
+	}
+
+	children, _ := module["child_modules"].([]interface{})
+	for _, c := range children {
+		if child, ok := c.(map[string]interface{}); ok {
+			terraformCustomerKeyAccountIDs(child, names)
+		}
+	}
+}
+
+// stripIndex removes the count or for_each index from a resource address, as references in the configuration do not include it
+func stripIndex(address string) string {
+	if i := strings.LastIndex(address, "["); i > 0 && strings.HasSuffix(address, "]") {
+		return address[:i]
+	}
+	return address
+}
+
+func terraformModule(source string, module map[string]interface{}) []Definition {
+	var definitions []Definition
+
+	resources, _ := module["resources"].([]interface{})
+	for _, r := range resources {
+		resource, ok := r.(map[string]interface{})
+		if !ok || resource["type"] != terraformStorageAccountType || resource["mode"] == "data" {
+			continue
+		}
+		values, _ := resource["values"].(map[string]interface{})
+		definitions = append(definitions, Definition{
+			Source:  source,
+			Address: fmt.Sprint(resource["address"]),
+			Format:  FormatTerraform,
+			Account: terraformAccount(values),
+		})
+	}
+
+	children, _ := module["child_modules"].([]interface{})
+	for _, c := range children {
+		if child, ok := c.(map[string]interface{}); ok {
+			definitions = append(definitions, terraformModule(source, child)...)
+		}
+	}
+	return definitions
+}
+
+// terraformAccount maps the planned values of an azurerm_storage_account resource to the storage account they would create.
+// Argument names from both the 3.x and 4.x azurerm providers are recognised.
+func terraformAccount(values map[string]interface{}) storage.Account {
+
+	properties := &storage.AccountProperties{
+		EnableHTTPSTrafficOnly:      boolValue(values, "https_traffic_only_enabled", "enable_https_traffic_only"),
+		AllowBlobPublicAccess:       boolValue(values, "allow_nested_items_to_be_public", "allow_blob_public_access"),
+		AllowCrossTenantReplication: boolValue(values, "cross_tenant_replication_enabled"),
+		MinimumTLSVersion:           storage.MinimumTLSVersion(stringValue(values, "min_tls_version")),
+	}
+
+	if enabled := boolValue(values, "public_network_access_enabled"); enabled != nil {
+		properties.PublicNetworkAccess = storage.PublicNetworkAccessEnabled
+		if !*enabled {
+			properties.PublicNetworkAccess = storage.PublicNetworkAccessDisabled
+		}
+	}
+
+	if rules := firstBlock(values, "network_rules"); rules != nil {
+		ruleSet := &storage.NetworkRuleSet{DefaultAction: storage.DefaultAction(stringValue(rules, "default_action"))}
+		var ipRules []storage.IPRule
+		ranges, _ := rules["ip_rules"].([]interface{})
+		for _, ipRange := range ranges {
+			ipRules = append(ipRules, storage.IPRule{IPAddressOrRange: to.StringPtr(fmt.Sprint(ipRange)), Action: storage.ActionAllow})
+		}
+		ruleSet.IPRules = &ipRules
+		properties.NetworkRuleSet = ruleSet
+	}
+
+	properties.Encryption = &storage.Encryption{KeySource: storage.KeySourceMicrosoftStorage}
+	if firstBlock(values, "customer_managed_key") != nil {
+		properties.Encryption.KeySource = storage.KeySourceMicrosoftKeyvault
+	}
+
+	account := storage.Account{
+		Name:              to.StringPtr(stringValue(values, "name")),
+		Location:          to.StringPtr(stringValue(values, "location")),
+		Kind:              storage.Kind(stringValue(values, "account_kind")),
+		AccountProperties: properties,
+	}
+	if tier, replication := stringValue(values, "account_tier"), stringValue(values, "account_replication_type"); tier != "" && replication != "" {
+		account.Sku = &storage.Sku{Name: storage.SkuName(tier + "_" + replication)}
+	}
+	return account
+}
+
+func boolValue(values map[string]interface{}, names ...string) *bool {
+	for _, name := range names {
+		if b, ok := values[name].(bool); ok {
+			return to.BoolPtr(b)
+		}
+	}
+	return nil
+}
+
+func stringValue(values map[string]interface{}, name string) string {
+	if s, ok := values[name].(string); ok {
+		return s
+	}
+	return ""
+}
+
+func firstBlock(values map[string]interface{}, name string) map[string]interface{} {
+	blocks, _ := values[name].([]interface{})
+	if len(blocks) == 0 {
+		return nil
+	}
+	block, _ := blocks[0].(map[string]interface{})
+	return block
+}
@@ -0,0 +1,72 @@
+package iac
+
+import (
+	"testing"
+
+	"github.com/citihub/probr-pack-storage/internal/azure/controls"
+)
+
+func TestLoadDirectory(t *testing.T) {
+	definitions, err := LoadDirectory("testdata")
+	if err != nil {
+		t.Fatalf("LoadDirectory() error = %v", err)
+	}
+
+	byAddress := make(map[string]Definition)
+	for _, d := range definitions {
+		byAddress[d.Address] = d
+	}
+	if len(byAddress) != 8 {
+		t.Fatalf("LoadDirectory() found %d storage account definitions, want 8: %v", len(byAddress), definitions)
+	}
+
+	tests := []struct {
+		name          string
+		address       string
+		format        string
+		wantCompliant map[string]bool
+	}{
+		{"TestCase1_InsecureARMTemplate_ShouldNotComply", "probrinsecure", FormatARM, map[string]bool{
+			controls.HTTPSOnly: false, controls.MinimumTLS: false, controls.PublicAccess: false, controls.NetworkRules: false, controls.Encryption: false}},
+		{"TestCase2_SecureNestedARMTemplate_ShouldComply", "probrsecure", FormatARM, map[string]bool{
+			controls.HTTPSOnly: true, controls.MinimumTLS: true, controls.PublicAccess: true, controls.NetworkRules: true, controls.Encryption: true}},
+		{"TestCase3_InsecureTerraformPlan_ShouldNotComply", "azurerm_storage_account.insecure", FormatTerraform, map[string]bool{
+			controls.HTTPSOnly: false, controls.MinimumTLS: false, controls.PublicAccess: false, controls.NetworkRules: false, controls.Encryption: false}},
+		{"TestCase4_SecureTerraformModule_ShouldComply", "module.storage.azurerm_storage_account.secure", FormatTerraform, map[string]bool{
+			controls.HTTPSOnly: true, controls.MinimumTLS: true, controls.PublicAccess: true, controls.NetworkRules: true, controls.Encryption: true}},
+		{"TestCase5_InnerScopeModuleBoundFromOuterParameters_ShouldUseBoundValues", "probrinnermodule", FormatARM, map[string]bool{
+			controls.HTTPSOnly: false, controls.MinimumTLS: true}},
+		{"TestCase6_OuterScopeModule_ShouldUseOuterParameters", "probroutermodule", FormatARM, map[string]bool{
+			controls.HTTPSOnly: false}},
+		{"TestCase7_HTTPSOnlyOmitted_ShouldApplyPlatformDefault", "probrdefaults", FormatARM, map[string]bool{
+			controls.HTTPSOnly: true, controls.MinimumTLS: true}},
+		{"TestCase8_SeparateCustomerManagedKeyResource_ShouldComplyWithEncryption", "azurerm_storage_account.cmk", FormatTerraform, map[string]bool{
+			controls.HTTPSOnly: true, controls.Encryption: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, ok := byAddress[tt.address]
+			if !ok {
+				t.Fatalf("LoadDirectory() did not find '%s'", tt.address)
+			}
+			if d.Format != tt.format {
+				t.Errorf("Definition format = %s, want %s", d.Format, tt.format)
+			}
+			for control, wantCompliant := range tt.wantCompliant {
+				evaluate, err := controls.Get(control)
+				if err != nil {
+					t.Fatalf("controls.Get() error = %v", err)
+				}
+				if result := evaluate(d.Account); result.Compliant != wantCompliant {
+					t.Errorf("%s evaluation = %v (%s), want %v", control, result.Compliant, result.Reason, wantCompliant)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadDirectoryMissing(t *testing.T) {
+	if _, err := LoadDirectory("testdata/missing"); err == nil {
+		t.Errorf("LoadDirectory() error = nil, want error for missing directory")
+	}
+}
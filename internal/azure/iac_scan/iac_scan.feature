@@ -0,0 +1,29 @@
+@s-aziac
+Feature: Object Storage Defined in Infrastructure as Code Complies With Security Controls
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage before it is deployed
+  So that non-compliant storage definitions are caught in pull requests rather than in production
+
+    Background:
+      Given the storage account definitions in the configured IaC directory are extracted
+
+    @s-aziac-001
+    Scenario: Detect Object Storage Definitions Without Encryption in Flight
+      Then every storage account definition complies with the "https only" control
+
+    @s-aziac-002
+    Scenario: Detect Object Storage Definitions Without Allowed Network Access Measures
+      Then every storage account definition complies with the "network rules" control
+
+    @s-aziac-003
+    Scenario: Detect Object Storage Definitions With Anonymous Access
+      Then every storage account definition complies with the "public access" control
+
+    @s-aziac-004
+    Scenario: Detect Object Storage Definitions Allowing Legacy TLS Versions
+      Then every storage account definition complies with the "minimum tls" control
+
+    @s-aziac-005
+    Scenario: Detect Object Storage Definitions Without Encryption at Rest Using Customer Managed Keys
+      Then every storage account definition complies with the "encryption" control
@@ -0,0 +1,212 @@
+package azureiac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cucumber/godog"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/azure/controls"
+	"github.com/citihub/probr-pack-storage/internal/azure/iac"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// ProbeStruct allows this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+type scenarioState struct {
+	name        string
+	currentStep string
+	audit       *audit.ScenarioAudit
+	probe       *audit.Probe
+	ctx         context.Context
+	definitions []iac.Definition
+}
+
+// Probe ...
+var Probe probeStruct      // Probe allows this probe to be added to the ProbeStore
+var scenario scenarioState // Local container of scenario state
+
+func (scenario *scenarioState) theStorageAccountDefinitionsInTheConfiguredIaCDirectoryAreExtracted() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check if the IaC directory is set in config vars; ")
+	directory := azureutil.IaCDirectory()
+	if directory == "" {
+		err = utils.ReformatError("IaC directory config var not set")
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Extract storage account definitions from ARM templates and Terraform plans in '%s'; ", directory))
+	scenario.definitions, err = iac.LoadDirectory(directory)
+	if err != nil {
+		return err
+	}
+
+	var definitionIDs []string
+	for _, definition := range scenario.definitions {
+		definitionIDs = append(definitionIDs, definition.ID())
+	}
+
+	//Audit log
+	payload = struct {
+		Directory   string
+		Definitions []string
+	}{
+		Directory:   directory,
+		Definitions: definitionIDs,
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) everyStorageAccountDefinitionCompliesWithTheXControl(control string) error {
+
+	// Supported values for 'control':
+	//	'https only'
+	//	'network rules'
+	//	'public access'
+	//	'minimum tls'
+	//	'encryption'
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	evaluate, getErr := controls.Get(control)
+	if getErr != nil {
+		err = getErr
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Evaluate %d storage account definitions against the '%s' control, auditing each definition individually; ", len(scenario.definitions), control))
+	var nonCompliant []string
+	for _, definition := range scenario.definitions {
+		result := evaluate(definition.Account)
+
+		var definitionErr error
+		if !result.Compliant {
+			definitionErr = utils.ReformatError("Storage account definition '%s' does not comply with the '%s' control: %s", definition.ID(), control, result.Reason)
+			nonCompliant = append(nonCompliant, definition.ID())
+		}
+
+		// One audit entry per definition
+		scenario.audit.AuditScenarioStep(
+			fmt.Sprintf("%s - %s", scenario.currentStep, definition.ID()),
+			fmt.Sprintf("Evaluate %s storage account definition '%s' against the '%s' control; ", definition.Format, definition.ID(), control),
+			struct {
+				Source  string
+				Address string
+				Format  string
+				Result  controls.Result
+			}{
+				Source:  definition.Source,
+				Address: definition.Address,
+				Format:  definition.Format,
+				Result:  result,
+			},
+			definitionErr)
+	}
+
+	if len(nonCompliant) > 0 {
+		err = utils.ReformatError("%d of %d storage account definitions do not comply with the '%s' control: %s", len(nonCompliant), len(scenario.definitions), control, strings.Join(nonCompliant, ", "))
+	}
+
+	//Audit log
+	payload = struct {
+		Control            string
+		DefinitionsChecked int
+		NonCompliant       []string
+	}{
+		Control:            control,
+		DefinitionsChecked: len(scenario.definitions),
+		NonCompliant:       nonCompliant,
+	}
+
+	return err
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.definitions = make([]iac.Definition, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	// No resources are created in IaC mode, so there is nothing to tear down
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "iac_scan"
+}
+
+// Path returns this probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "azure", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	// No Azure connection is required, definitions are evaluated offline
+
+	ctx.BeforeSuite(func() {
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^the storage account definitions in the configured IaC directory are extracted$`, scenario.theStorageAccountDefinitionsInTheConfiguredIaCDirectoryAreExtracted)
+
+	// Steps
+	ctx.Step(`^every storage account definition complies with the "([^"]*)" control$`, scenario.everyStorageAccountDefinitionCompliesWithTheXControl)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
@@ -0,0 +1,27 @@
+@s-azkse
+Feature: Object Storage Access Keys and Shared Access Signatures Expire
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that leaked access keys and shared access signatures cannot be used indefinitely
+
+    Background:
+      Given an Azure subscription is available
+      And azure resource group specified in config exists
+
+    @s-azkse-001
+    Scenario: Prevent Object Storage from Being Created Without a SAS Expiration Policy
+      Given SAS and key expiration settings are provided in config
+      When an attempt to create a storage account "with" a SAS expiration policy "succeeds"
+      Then an attempt to create a storage account "without" a SAS expiration policy "fails"
+
+    @s-azkse-002
+    Scenario: Prevent Object Storage from Being Created Without a Key Expiration Policy
+      Given SAS and key expiration settings are provided in config
+      When an attempt to create a storage account "with" a key expiration policy "succeeds"
+      Then an attempt to create a storage account "without" a key expiration policy "fails"
+
+    @s-azkse-003
+    Scenario: Detect Object Storage Access Keys Which Have Not Been Rotated
+      Given SAS and key expiration settings are provided in config
+      Then the keys of every storage account in the resource group have been rotated within the configured window
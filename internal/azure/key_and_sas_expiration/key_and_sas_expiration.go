@@ -0,0 +1,477 @@
+package azurekse
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	azureStorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/cucumber/godog"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-pack-storage/internal/envvar"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// ProbeStruct allows this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+// creationOutcome records the result of a storage account creation attempt through one creation backend
+type creationOutcome struct {
+	Backend            string
+	StorageAccountName string
+	StorageAccount     azureStorage.Account
+	Error              string
+}
+
+type scenarioState struct {
+	name             string
+	currentStep      string
+	audit            *audit.ScenarioAudit
+	probe            *audit.Probe
+	ctx              context.Context
+	tags             map[string]*string
+	storageAccounts  []string
+	expirationPolicy ExpirationPolicy
+}
+
+// Probe ...
+var Probe probeStruct             // Probe allows this probe to be added to the ProbeStore
+var scenario scenarioState        // Local container of scenario state
+var azConnection connection.Azure // Provides functionality to interact with Azure
+
+func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that Azure subscription specified in config file is available; "))
+
+	payload = struct {
+		SubscriptionID string
+		TenantID       string
+		CreationMode   string
+	}{
+		azureutil.SubscriptionID(),
+		azureutil.TenantID(),
+		azureutil.CreationMode(),
+	}
+
+	err = azConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) azureResourceGroupSpecifiedInConfigExists() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check if value for Azure resource group is set in config vars; ")
+	if azureutil.ResourceGroup() == "" {
+		err = utils.ReformatError("Azure resource group config var not set")
+		return err
+	}
+
+	stepTrace.WriteString("Check the resource group exists in the specified azure subscription; ")
+	_, getGrpErr := azConnection.GetResourceGroupByName(azureutil.ResourceGroup())
+	if getGrpErr != nil {
+		err = utils.ReformatError("Azure resource group '%s' does not exists. Error: %v", azureutil.ResourceGroup(), getGrpErr)
+		return err
+	}
+
+	//Audit log
+	payload = struct {
+		SubscriptionID string
+		ResourceGroup  string
+	}{
+		SubscriptionID: azureutil.SubscriptionID(),
+		ResourceGroup:  azureutil.ResourceGroup(),
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) sasAndKeyExpirationSettingsAreProvidedInConfig() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Validate that SAS and key expiration settings are provided in config; ")
+
+	scenario.expirationPolicy = getExpirationPolicy()
+
+	//Audit log
+	payload = struct {
+		ExpirationPolicy ExpirationPolicy
+	}{
+		ExpirationPolicy: scenario.expirationPolicy,
+	}
+
+	if scenario.expirationPolicy.SasExpirationPeriod == "" ||
+		!(scenario.expirationPolicy.KeyExpirationPeriodInDays > 0) ||
+		!(scenario.expirationPolicy.KeyRotationWindowInDays > 0) {
+		err = utils.ReformatError("SAS expiration period, key expiration period and key rotation window have not been defined in config")
+	}
+
+	return err
+}
+
+func (scenario *scenarioState) anAttemptToCreateAStorageAccountXASASExpirationPolicyY(policyOption, expectedResult string) error {
+	return scenario.attemptCreation("SAS expiration policy", policyOption, expectedResult)
+}
+
+func (scenario *scenarioState) anAttemptToCreateAStorageAccountXAKeyExpirationPolicyY(policyOption, expectedResult string) error {
+	return scenario.attemptCreation("key expiration policy", policyOption, expectedResult)
+}
+
+func (scenario *scenarioState) attemptCreation(policyUnderTest, policyOption, expectedResult string) error {
+
+	// Supported values for 'policyOption':
+	//	'with'
+	//  'without'
+
+	// Supported values for 'expectedResult':
+	//	'succeeds'
+	//	'fails'
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values - policyOption
+	var withPolicy bool
+	switch policyOption {
+	case "with":
+		withPolicy = true
+	case "without":
+		withPolicy = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for policyOption: '%s' Expected values: ['with', 'without']", policyOption)
+		return err
+	}
+
+	// Validate input values - expectedResult
+	var shouldCreate bool
+	switch expectedResult {
+	case "succeeds":
+		shouldCreate = true
+	case "fails":
+		shouldCreate = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	// Both policies are set by default so that only the policy under test is omitted
+	sasPolicy := &azureStorage.SasPolicy{
+		SasExpirationPeriod: to.StringPtr(scenario.expirationPolicy.SasExpirationPeriod),
+		ExpirationAction:    to.StringPtr("Log"),
+	}
+	keyPolicy := &azureStorage.KeyPolicy{
+		KeyExpirationPeriodInDays: to.Int32Ptr(scenario.expirationPolicy.KeyExpirationPeriodInDays),
+	}
+	if !withPolicy {
+		switch policyUnderTest {
+		case "SAS expiration policy":
+			sasPolicy = nil
+		case "key expiration policy":
+			keyPolicy = nil
+		}
+	}
+
+	parameters := azureStorage.AccountCreateParameters{
+		AccountPropertiesCreateParameters: &azureStorage.AccountPropertiesCreateParameters{
+			EnableHTTPSTrafficOnly: to.BoolPtr(true),
+			SasPolicy:              sasPolicy,
+			KeyPolicy:              keyPolicy,
+		},
+		Tags: scenario.tags,
+	}
+
+	var outcomes []creationOutcome
+	for _, backend := range azureutil.CreationBackends() {
+
+		bucketName := utils.RandomString(10)
+		stepTrace.WriteString(fmt.Sprintf("Generate a storage account name using a random string: '%s'; ", bucketName))
+
+		stepTrace.WriteString(fmt.Sprintf("Attempt to create Storage Account %s a %s using the '%s' creation backend; ", policyOption, policyUnderTest, backend))
+		storageAccount, creationErr := azConnection.CreateStorageAccountWithBackend(azureutil.CreationMode(), backend, bucketName, azureutil.ResourceGroup(), parameters)
+		if creationErr == nil {
+			scenario.storageAccounts = append(scenario.storageAccounts, bucketName) // Record for later cleanup
+		}
+
+		outcome := creationOutcome{
+			Backend:            backend,
+			StorageAccountName: bucketName,
+			StorageAccount:     storageAccount,
+		}
+		if creationErr != nil {
+			outcome.Error = creationErr.Error()
+		}
+		outcomes = append(outcomes, outcome)
+
+		var attemptErr error
+		stepTrace.WriteString(fmt.Sprintf("Validate that storage account creation %s; ", expectedResult))
+		switch shouldCreate {
+		case true:
+			if creationErr != nil {
+				attemptErr = utils.ReformatError("Creation of storage account did not succeed: %v", creationErr)
+			}
+		case false:
+			if creationErr == nil {
+				attemptErr = utils.ReformatError("Creation of storage account %s a %s succeeded, but should have failed", policyOption, policyUnderTest)
+			} else if !azureutil.IsPolicyDenial(creationErr) {
+				// Ensure failure is due to the expiration policy
+				attemptErr = utils.ReformatError("Creation of storage account %s a %s failed with unexpected reason: %v - %v", policyOption, policyUnderTest, azureutil.ServiceErrorCode(creationErr), creationErr)
+			}
+		}
+		if attemptErr != nil && err == nil {
+			err = utils.ReformatError("Creation backend '%s': %v", backend, attemptErr)
+		}
+	}
+
+	//Audit log
+	payload = struct {
+		ResourceGroup string
+		SasPolicy     *azureStorage.SasPolicy
+		KeyPolicy     *azureStorage.KeyPolicy
+		Outcomes      []creationOutcome
+	}{
+		ResourceGroup: azureutil.ResourceGroup(),
+		SasPolicy:     sasPolicy,
+		KeyPolicy:     keyPolicy,
+		Outcomes:      outcomes,
+	}
+
+	return err
+}
+
+func (scenario *scenarioState) theKeysOfEveryStorageAccountInTheResourceGroupHaveBeenRotatedWithinTheConfiguredWindow() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	window := time.Duration(scenario.expirationPolicy.KeyRotationWindowInDays) * 24 * time.Hour
+
+	stepTrace.WriteString(fmt.Sprintf("List Storage Accounts in resource group '%s'; ", azureutil.ResourceGroup()))
+	accounts, listErr := azConnection.ListStorageAccountsByResourceGroup(azureutil.ResourceGroup())
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list storage accounts: %v", listErr)
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Check that key creation time of each account is within the last %d days; ", scenario.expirationPolicy.KeyRotationWindowInDays))
+	var findings []keyRotationFinding
+	for _, account := range accounts {
+		finding := keyRotationFinding{
+			StorageAccountName: to.String(account.Name),
+		}
+		if account.AccountProperties != nil && account.AccountProperties.KeyCreationTime != nil {
+			finding.Key1CreationTime = account.AccountProperties.KeyCreationTime.Key1
+			finding.Key2CreationTime = account.AccountProperties.KeyCreationTime.Key2
+		}
+		finding.Key1Rotated = isWithinWindow(finding.Key1CreationTime, window)
+		finding.Key2Rotated = isWithinWindow(finding.Key2CreationTime, window)
+		if !finding.Key1Rotated || !finding.Key2Rotated {
+			findings = append(findings, finding)
+		}
+	}
+
+	if len(findings) > 0 {
+		err = utils.ReformatError("%d of %d storage accounts have keys which have not been rotated within %d days", len(findings), len(accounts), scenario.expirationPolicy.KeyRotationWindowInDays)
+	}
+
+	//Audit log
+	payload = struct {
+		ResourceGroup           string
+		KeyRotationWindowInDays int32
+		AccountsChecked         int
+		Findings                []keyRotationFinding
+	}{
+		ResourceGroup:           azureutil.ResourceGroup(),
+		KeyRotationWindowInDays: scenario.expirationPolicy.KeyRotationWindowInDays,
+		AccountsChecked:         len(accounts),
+		Findings:                findings,
+	}
+
+	return err
+}
+
+// keyRotationFinding records a storage account whose keys have not been rotated within the configured window
+type keyRotationFinding struct {
+	StorageAccountName string
+	Key1CreationTime   *date.Time
+	Key1Rotated        bool
+	Key2CreationTime   *date.Time
+	Key2Rotated        bool
+}
+
+// isWithinWindow reports whether a key was created within the given window.
+// A missing creation time is reported as not rotated, since Azure only records it for keys created or rotated since the feature was introduced.
+func isWithinWindow(keyCreationTime *date.Time, window time.Duration) bool {
+	if keyCreationTime == nil {
+		return false
+	}
+	return time.Since(keyCreationTime.ToTime()) <= window
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.storageAccounts = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "key_and_sas_expiration"
+}
+
+// Path returns this probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "azure", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize azure connection
+		azConnection = connection.NewAzureConnection(
+			context.Background(),
+			azureutil.SubscriptionID(),
+			azureutil.TenantID(),
+			azureutil.ClientID(),
+			azureutil.ClientSecret(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an Azure subscription is available$`, scenario.anAzureSubscriptionIsAvailable)
+	ctx.Step(`^azure resource group specified in config exists$`, scenario.azureResourceGroupSpecifiedInConfigExists)
+
+	// Steps
+	ctx.Step(`^SAS and key expiration settings are provided in config$`, scenario.sasAndKeyExpirationSettingsAreProvidedInConfig)
+	ctx.Step(`^an attempt to create a storage account "([^"]*)" a SAS expiration policy "([^"]*)"$`, scenario.anAttemptToCreateAStorageAccountXASASExpirationPolicyY)
+	ctx.Step(`^an attempt to create a storage account "([^"]*)" a key expiration policy "([^"]*)"$`, scenario.anAttemptToCreateAStorageAccountXAKeyExpirationPolicyY)
+	ctx.Step(`^the keys of every storage account in the resource group have been rotated within the configured window$`, scenario.theKeysOfEveryStorageAccountInTheResourceGroupHaveBeenRotatedWithinTheConfiguredWindow)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing storage accounts used during tests")
+
+	for _, account := range scenario.storageAccounts {
+		log.Printf("[DEBUG] need to delete the storageAccount: %s", account)
+		err := azConnection.DeleteStorageAccount(azureutil.ResourceGroup(), account)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the storageAccount: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
+
+// ExpirationPolicy represents the required config settings for this probe. This shall be removed and replaced with actual config vars once sdk refactor is complete.
+type ExpirationPolicy struct {
+	SasExpirationPeriod       string `yaml:"SasExpirationPeriod"`       // Maximum SAS expiration period in the format DD.HH:MM:SS to be set when creating storage accounts
+	KeyExpirationPeriodInDays int32  `yaml:"KeyExpirationPeriodInDays"` // Key expiration period in days to be set when creating storage accounts
+	KeyRotationWindowInDays   int32  `yaml:"KeyRotationWindowInDays"`   // Maximum age in days of storage account keys on existing accounts
+}
+
+// getExpirationPolicy returns the expiration settings, which may be set by the environment variables AZURE_SAS_EXPIRATION_PERIOD,
+// AZURE_KEY_EXPIRATION_PERIOD_DAYS and AZURE_KEY_ROTATION_WINDOW_DAYS. They default to 1 day, 90 days and 90 days.
+func getExpirationPolicy() ExpirationPolicy {
+
+	return ExpirationPolicy{
+		SasExpirationPeriod:       envvar.GetOrDefault("AZURE_SAS_EXPIRATION_PERIOD", "1.00:00:00"),
+		KeyExpirationPeriodInDays: getDays("AZURE_KEY_EXPIRATION_PERIOD_DAYS", 90),
+		KeyRotationWindowInDays:   getDays("AZURE_KEY_ROTATION_WINDOW_DAYS", 90),
+	}
+}
+
+// getDays returns a positive number of days set by an environment variable, or the default value if it is not set or invalid
+func getDays(varName string, defaultValue int32) int32 {
+	days, err := strconv.ParseInt(envvar.GetOrDefault(varName, strconv.Itoa(int(defaultValue))), 10, 32)
+	if err != nil || days < 1 {
+		log.Printf("[ERROR] Unexpected value for %s: expected a positive number of days. Using %d", varName, defaultValue)
+		return defaultValue
+	}
+	return int32(days)
+}
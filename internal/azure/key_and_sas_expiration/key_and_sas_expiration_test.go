@@ -0,0 +1,73 @@
+package azurekse
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Azure/go-autorest/autorest/date"
+)
+
+func TestGetExpirationPolicy(t *testing.T) {
+	tests := []struct {
+		testName       string
+		env            map[string]string
+		expectedPolicy ExpirationPolicy
+	}{
+		{
+			testName:       "TestCase1_NoEnvironmentVariables_ShouldReturnDefaults",
+			env:            map[string]string{},
+			expectedPolicy: ExpirationPolicy{SasExpirationPeriod: "1.00:00:00", KeyExpirationPeriodInDays: 90, KeyRotationWindowInDays: 90},
+		},
+		{
+			testName: "TestCase2_EnvironmentVariables_ShouldOverrideDefaults",
+			env: map[string]string{
+				"AZURE_SAS_EXPIRATION_PERIOD":      "0.08:00:00",
+				"AZURE_KEY_EXPIRATION_PERIOD_DAYS": "30",
+				"AZURE_KEY_ROTATION_WINDOW_DAYS":   "60",
+			},
+			expectedPolicy: ExpirationPolicy{SasExpirationPeriod: "0.08:00:00", KeyExpirationPeriodInDays: 30, KeyRotationWindowInDays: 60},
+		},
+		{
+			testName: "TestCase3_InvalidDays_ShouldReturnDefaultDays",
+			env: map[string]string{
+				"AZURE_KEY_EXPIRATION_PERIOD_DAYS": "thirty",
+				"AZURE_KEY_ROTATION_WINDOW_DAYS":   "0",
+			},
+			expectedPolicy: ExpirationPolicy{SasExpirationPeriod: "1.00:00:00", KeyExpirationPeriodInDays: 90, KeyRotationWindowInDays: 90},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			for key, value := range tc.env {
+				os.Setenv(key, value)
+				defer os.Unsetenv(key)
+			}
+			if policy := getExpirationPolicy(); policy != tc.expectedPolicy {
+				t.Errorf("getExpirationPolicy() = %+v, expected %+v", policy, tc.expectedPolicy)
+			}
+		})
+	}
+}
+
+func TestIsWithinWindow(t *testing.T) {
+	window := 90 * 24 * time.Hour
+	tests := []struct {
+		testName        string
+		keyCreationTime *date.Time
+		expected        bool
+	}{
+		{"TestCase1_RecentKey_ShouldBeWithinWindow", &date.Time{Time: time.Now().Add(-24 * time.Hour)}, true},
+		{"TestCase2_OldKey_ShouldNotBeWithinWindow", &date.Time{Time: time.Now().Add(-100 * 24 * time.Hour)}, false},
+		{"TestCase3_MissingCreationTime_ShouldNotBeWithinWindow", nil, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			if withinWindow := isWithinWindow(tc.keyCreationTime, window); withinWindow != tc.expected {
+				t.Errorf("isWithinWindow() = %v, expected %v", withinWindow, tc.expected)
+			}
+		})
+	}
+}
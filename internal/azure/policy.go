@@ -0,0 +1,300 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-09-01/policy"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// PolicyDefinition identifies an Azure Policy definition required by the probes in this pack
+type PolicyDefinition struct {
+	DisplayName string
+	Name        string // The GUID name of a built-in definition, or the name of a custom definition
+	Custom      bool   // Custom definitions are saved in the subscription rather than provided by Azure
+}
+
+// ID returns the resource ID of the policy definition
+func (d PolicyDefinition) ID() string {
+	if d.Custom {
+		return "/subscriptions/" + SubscriptionID() + "/providers/Microsoft.Authorization/policyDefinitions/" + d.Name
+	}
+	return "/providers/Microsoft.Authorization/policyDefinitions/" + d.Name
+}
+
+// Built-in policy definitions required by the probes in this pack
+var (
+	SecureTransferPolicy = PolicyDefinition{
+		DisplayName: "Secure transfer to storage accounts should be enabled",
+		Name:        "404c3081-a854-4457-ae30-26a93ef643f9",
+	}
+	NetworkRulesPolicy = PolicyDefinition{
+		DisplayName: "Storage accounts should restrict network access",
+		Name:        "34c877ad-507e-4c82-993e-3452a6e0ad3c",
+	}
+	CrossTenantReplicationPolicy = PolicyDefinition{
+		DisplayName: "Storage accounts should prevent cross tenant object replication",
+		Name:        "92a89a79-6c52-4a7e-a03f-61306fc49312",
+	}
+	PublicAccessPolicy = PolicyDefinition{
+		DisplayName: "Storage account public access should be disallowed",
+		Name:        "4fa4b6c0-31ca-4c0d-b10d-24b96f62a751",
+	}
+)
+
+// Custom policy definitions provided by this pack, for controls which no built-in definition enforces.
+// NetworkRulesPolicy only requires the network default action to be Deny, so it cannot tell allowed and disallowed network segments apart.
+var (
+	AllowedNetworkSegmentsPolicy = PolicyDefinition{
+		DisplayName: "Storage accounts should only allow access from allowed network segments",
+		Name:        "probr-allowed-network-segments",
+		Custom:      true,
+	}
+)
+
+// AllowedNetworkSegmentsPolicyJSON is the AllowedNetworkSegmentsPolicy definition. It flags storage accounts which allow access by
+// default, or which have an IP rule for an address or range which is not in the allowedAddressRanges parameter.
+const AllowedNetworkSegmentsPolicyJSON = `{
+  "displayName": "Storage accounts should only allow access from allowed network segments",
+  "mode": "Indexed",
+  "parameters": {
+    "effect": {
+      "type": "String",
+      "allowedValues": ["Audit", "Deny", "Disabled"],
+      "defaultValue": "Audit"
+    },
+    "allowedAddressRanges": {
+      "type": "Array"
+    }
+  },
+  "policyRule": {
+    "if": {
+      "allOf": [
+        {
+          "field": "type",
+          "equals": "Microsoft.Storage/storageAccounts"
+        },
+        {
+          "anyOf": [
+            {
+              "field": "Microsoft.Storage/storageAccounts/networkAcls.defaultAction",
+              "notEquals": "Deny"
+            },
+            {
+              "not": {
+                "field": "Microsoft.Storage/storageAccounts/networkAcls.ipRules[*].value",
+                "in": "[parameters('allowedAddressRanges')]"
+              }
+            }
+          ]
+        }
+      ]
+    },
+    "then": {
+      "effect": "[parameters('effect')]"
+    }
+  }
+}`
+
+// CustomPolicyDefinition returns the properties of a custom policy definition provided by this pack, to be saved in the subscription
+func CustomPolicyDefinition(definition PolicyDefinition) (properties policy.DefinitionProperties, err error) {
+	var data string
+	switch definition.Name {
+	case AllowedNetworkSegmentsPolicy.Name:
+		data = AllowedNetworkSegmentsPolicyJSON
+	default:
+		err = utils.ReformatError("Unknown custom policy definition: '%s'", definition.Name)
+		return
+	}
+	if unmarshalErr := json.Unmarshal([]byte(data), &properties); unmarshalErr != nil {
+		err = utils.ReformatError("Failed to parse custom policy definition '%s': %v", definition.Name, unmarshalErr)
+		return
+	}
+	properties.PolicyType = policy.Custom
+	return
+}
+
+// BuiltInPolicyDefinition returns a policy definition required by the probes in this pack by display name
+func BuiltInPolicyDefinition(displayName string) (PolicyDefinition, error) {
+	for _, definition := range []PolicyDefinition{
+		SecureTransferPolicy,
+		NetworkRulesPolicy,
+		CrossTenantReplicationPolicy,
+		PublicAccessPolicy,
+		AllowedNetworkSegmentsPolicy,
+	} {
+		if strings.EqualFold(definition.DisplayName, displayName) {
+			return definition, nil
+		}
+	}
+	return PolicyDefinition{}, utils.ReformatError("Unknown policy definition: '%s'", displayName)
+}
+
+// PolicyAssignmentMatch describes a policy assignment which applies a policy definition to the Probr resource group
+type PolicyAssignmentMatch struct {
+	AssignmentID          string
+	DisplayName           string
+	Scope                 string
+	ScopeType             string // One of 'ManagementGroup', 'Subscription' or 'ResourceGroup'
+	PolicySetDefinitionID string // Set when the definition is assigned as part of a policy set (initiative)
+	Effect                string
+	EnforcementMode       string
+}
+
+// PolicySetDefinitionIDs returns the IDs of the policy set (initiative) definitions assigned by the given assignments,
+// which must be retrieved so that the policy definitions they contain can be resolved by FindPolicyAssignment.
+func PolicySetDefinitionIDs(assignments []policy.Assignment) (ids []string) {
+	seen := make(map[string]bool)
+	for _, assignment := range assignments {
+		if assignment.AssignmentProperties == nil {
+			continue
+		}
+		id := to.String(assignment.AssignmentProperties.PolicyDefinitionID)
+		if isPolicySetDefinitionID(id) && !seen[strings.ToLower(id)] {
+			seen[strings.ToLower(id)] = true
+			ids = append(ids, id)
+		}
+	}
+	return
+}
+
+// FindPolicyAssignment looks for an enforced assignment of the given policy definition which applies to the resource group with the expected effect.
+// Assignments made at management group, subscription or resource group scope are considered. Assignments of policy set (initiative) definitions
+// are considered when the set definition is provided, keyed by its resource ID, and the effect of the definition within the set is resolved
+// from the assignment and set parameters. Every assignment of the definition is returned so that mismatches can be audited.
+func FindPolicyAssignment(assignments []policy.Assignment, setDefinitions map[string]policy.SetDefinition, definition PolicyDefinition, defaultEffect, expectedEffect, subscriptionID, resourceGroup string) (match *PolicyAssignmentMatch, candidates []PolicyAssignmentMatch) {
+
+	resourceGroupScope := strings.ToLower(fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", subscriptionID, resourceGroup))
+
+	sets := make(map[string]policy.SetDefinition)
+	for id, setDefinition := range setDefinitions {
+		sets[strings.ToLower(id)] = setDefinition
+	}
+
+	for _, assignment := range assignments {
+		properties := assignment.AssignmentProperties
+		if properties == nil || isExcluded(properties.NotScopes, resourceGroupScope) {
+			continue
+		}
+
+		var effects []string
+		setDefinitionID := ""
+		assignedID := to.String(properties.PolicyDefinitionID)
+		switch {
+		case strings.EqualFold(assignedID, definition.ID()):
+			effects = []string{assignedEffect(properties.Parameters, defaultEffect)}
+		case isPolicySetDefinitionID(assignedID):
+			setDefinition, ok := sets[strings.ToLower(assignedID)]
+			if !ok {
+				continue
+			}
+			setDefinitionID = assignedID
+			effects = setEffects(setDefinition, definition, properties.Parameters, defaultEffect)
+		}
+
+		for _, effect := range effects {
+			candidate := PolicyAssignmentMatch{
+				AssignmentID:          to.String(assignment.ID),
+				DisplayName:           to.String(properties.DisplayName),
+				Scope:                 to.String(properties.Scope),
+				ScopeType:             scopeType(to.String(properties.Scope)),
+				PolicySetDefinitionID: setDefinitionID,
+				Effect:                effect,
+				EnforcementMode:       string(properties.EnforcementMode),
+			}
+			candidates = append(candidates, candidate)
+
+			if match == nil && strings.EqualFold(candidate.Effect, expectedEffect) && properties.EnforcementMode != policy.DoNotEnforce {
+				matched := candidate
+				match = &matched
+			}
+		}
+	}
+	return
+}
+
+// setEffects returns the effect of every reference to the policy definition within a policy set definition
+func setEffects(setDefinition policy.SetDefinition, definition PolicyDefinition, assignmentParameters map[string]*policy.ParameterValuesValue, defaultEffect string) (effects []string) {
+	if setDefinition.SetDefinitionProperties == nil || setDefinition.SetDefinitionProperties.PolicyDefinitions == nil {
+		return
+	}
+	for _, reference := range *setDefinition.SetDefinitionProperties.PolicyDefinitions {
+		if !strings.EqualFold(to.String(reference.PolicyDefinitionID), definition.ID()) {
+			continue
+		}
+		effect := defaultEffect
+		if value, ok := reference.Parameters["effect"]; ok && value != nil {
+			effect = resolveSetParameter(value.Value, setDefinition.SetDefinitionProperties.Parameters, assignmentParameters, defaultEffect)
+		}
+		effects = append(effects, effect)
+	}
+	return
+}
+
+var setParameterExpression = regexp.MustCompile(`^\[parameters\('([^']+)'\)\]$`)
+
+// resolveSetParameter resolves a parameter value passed to a definition within a policy set, which is either a literal or a
+// reference to a set parameter whose value is taken from the assignment, or otherwise from the set parameter default
+func resolveSetParameter(value interface{}, setParameters map[string]*policy.ParameterDefinitionsValue, assignmentParameters map[string]*policy.ParameterValuesValue, defaultValue string) string {
+	literal, ok := value.(string)
+	if !ok {
+		return defaultValue
+	}
+	parameter := setParameterExpression.FindStringSubmatch(strings.TrimSpace(literal))
+	if parameter == nil {
+		return literal
+	}
+	if assigned, ok := assignmentParameters[parameter[1]]; ok && assigned != nil {
+		if effect, ok := assigned.Value.(string); ok {
+			return effect
+		}
+	}
+	if definition, ok := setParameters[parameter[1]]; ok && definition != nil {
+		if effect, ok := definition.DefaultValue.(string); ok {
+			return effect
+		}
+	}
+	return defaultValue
+}
+
+func isPolicySetDefinitionID(id string) bool {
+	return strings.Contains(strings.ToLower(id), "/providers/microsoft.authorization/policysetdefinitions/")
+}
+
+func isExcluded(notScopes *[]string, resourceGroupScope string) bool {
+	if notScopes == nil {
+		return false
+	}
+	for _, notScope := range *notScopes {
+		notScope = strings.ToLower(strings.TrimSuffix(notScope, "/"))
+		if resourceGroupScope == notScope || strings.HasPrefix(resourceGroupScope, notScope+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func scopeType(scope string) string {
+	scope = strings.ToLower(scope)
+	switch {
+	case strings.HasPrefix(scope, "/providers/microsoft.management/managementgroups/"):
+		return "ManagementGroup"
+	case strings.Contains(scope, "/resourcegroups/"):
+		return "ResourceGroup"
+	default:
+		return "Subscription"
+	}
+}
+
+func assignedEffect(parameters map[string]*policy.ParameterValuesValue, defaultEffect string) string {
+	if value, ok := parameters["effect"]; ok && value != nil {
+		if effect, ok := value.Value.(string); ok {
+			return effect
+		}
+	}
+	return defaultEffect
+}
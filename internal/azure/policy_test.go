@@ -0,0 +1,194 @@
+package azure
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-09-01/policy"
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	"github.com/citihub/probr-pack-storage/internal/azure/policysim"
+)
+
+func testAssignment(id, scope string, effect interface{}, enforcement policy.EnforcementMode, notScopes ...string) policy.Assignment {
+	parameters := map[string]*policy.ParameterValuesValue{}
+	if effect != nil {
+		parameters["effect"] = &policy.ParameterValuesValue{Value: effect}
+	}
+	return policy.Assignment{
+		ID: to.StringPtr(id),
+		AssignmentProperties: &policy.AssignmentProperties{
+			PolicyDefinitionID: to.StringPtr(SecureTransferPolicy.ID()),
+			Scope:              to.StringPtr(scope),
+			NotScopes:          &notScopes,
+			Parameters:         parameters,
+			EnforcementMode:    enforcement,
+		},
+	}
+}
+
+func TestFindPolicyAssignment(t *testing.T) {
+	const subscriptionScope = "/subscriptions/sub"
+	const resourceGroupScope = "/subscriptions/sub/resourceGroups/probr"
+	const managementGroupScope = "/providers/Microsoft.Management/managementGroups/mg"
+
+	tests := []struct {
+		name           string
+		assignments    []policy.Assignment
+		wantMatch      bool
+		wantCandidates int
+		wantScopeType  string
+	}{
+		{"TestCase1_NoAssignments_ShouldNotMatch", nil, false, 0, ""},
+		{"TestCase2_DenyAtSubscription_ShouldMatch",
+			[]policy.Assignment{testAssignment("a1", subscriptionScope, "Deny", policy.Default)}, true, 1, "Subscription"},
+		{"TestCase3_DefaultAuditEffect_ShouldNotMatch",
+			[]policy.Assignment{testAssignment("a1", resourceGroupScope, nil, policy.Default)}, false, 1, ""},
+		{"TestCase4_DoNotEnforce_ShouldNotMatch",
+			[]policy.Assignment{testAssignment("a1", resourceGroupScope, "Deny", policy.DoNotEnforce)}, false, 1, ""},
+		{"TestCase5_ResourceGroupExcluded_ShouldNotBeCandidate",
+			[]policy.Assignment{testAssignment("a1", subscriptionScope, "Deny", policy.Default, resourceGroupScope)}, false, 0, ""},
+		{"TestCase6_DenyAtManagementGroup_ShouldMatch",
+			[]policy.Assignment{
+				testAssignment("a1", subscriptionScope, "Audit", policy.Default),
+				testAssignment("a2", managementGroupScope, "deny", policy.Default),
+			}, true, 2, "ManagementGroup"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, candidates := FindPolicyAssignment(tt.assignments, nil, SecureTransferPolicy, "Audit", "Deny", "sub", "probr")
+			if (match != nil) != tt.wantMatch {
+				t.Errorf("FindPolicyAssignment() match = %v, wantMatch %v", match, tt.wantMatch)
+			}
+			if len(candidates) != tt.wantCandidates {
+				t.Errorf("FindPolicyAssignment() candidates = %d, want %d", len(candidates), tt.wantCandidates)
+			}
+			if match != nil && match.ScopeType != tt.wantScopeType {
+				t.Errorf("FindPolicyAssignment() scope type = %s, want %s", match.ScopeType, tt.wantScopeType)
+			}
+		})
+	}
+}
+
+func testSetDefinition(effect interface{}, setParameterDefault interface{}) policy.SetDefinition {
+	return policy.SetDefinition{
+		SetDefinitionProperties: &policy.SetDefinitionProperties{
+			Parameters: map[string]*policy.ParameterDefinitionsValue{
+				"secureTransferEffect": {DefaultValue: setParameterDefault},
+			},
+			PolicyDefinitions: &[]policy.DefinitionReference{
+				{PolicyDefinitionID: to.StringPtr(NetworkRulesPolicy.ID())},
+				{
+					PolicyDefinitionID: to.StringPtr(SecureTransferPolicy.ID()),
+					Parameters: map[string]*policy.ParameterValuesValue{
+						"effect": {Value: effect},
+					},
+				},
+			},
+		},
+	}
+}
+
+func testSetAssignment(setDefinitionID string, parameters map[string]*policy.ParameterValuesValue) policy.Assignment {
+	return policy.Assignment{
+		ID: to.StringPtr("initiative"),
+		AssignmentProperties: &policy.AssignmentProperties{
+			PolicyDefinitionID: to.StringPtr(setDefinitionID),
+			Scope:              to.StringPtr("/subscriptions/sub"),
+			Parameters:         parameters,
+			EnforcementMode:    policy.Default,
+		},
+	}
+}
+
+func TestFindPolicyAssignmentViaPolicySet(t *testing.T) {
+	const setDefinitionID = "/providers/Microsoft.Management/managementGroups/mg/providers/Microsoft.Authorization/policySetDefinitions/storage-baseline"
+
+	tests := []struct {
+		name           string
+		assignment     policy.Assignment
+		setDefinition  policy.SetDefinition
+		provideSet     bool
+		wantMatch      bool
+		wantCandidates int
+	}{
+		{"TestCase1_LiteralDenyInSet_ShouldMatch",
+			testSetAssignment(setDefinitionID, nil), testSetDefinition("Deny", nil), true, true, 1},
+		{"TestCase2_SetParameterFromAssignment_ShouldMatch",
+			testSetAssignment(setDefinitionID, map[string]*policy.ParameterValuesValue{"secureTransferEffect": {Value: "Deny"}}),
+			testSetDefinition("[parameters('secureTransferEffect')]", "Audit"), true, true, 1},
+		{"TestCase3_SetParameterDefaultAudit_ShouldNotMatch",
+			testSetAssignment(setDefinitionID, nil), testSetDefinition("[parameters('secureTransferEffect')]", "Audit"), true, false, 1},
+		{"TestCase4_SetDefinitionNotProvided_ShouldNotBeCandidate",
+			testSetAssignment(setDefinitionID, nil), testSetDefinition("Deny", nil), false, false, 0},
+		{"TestCase5_NoEffectInSet_ShouldUseDefinitionDefault",
+			testSetAssignment(setDefinitionID, nil), testSetDefinition(nil, nil), true, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignments := []policy.Assignment{tt.assignment}
+			if ids := PolicySetDefinitionIDs(assignments); len(ids) != 1 || ids[0] != setDefinitionID {
+				t.Errorf("PolicySetDefinitionIDs() = %v, want [%s]", ids, setDefinitionID)
+			}
+			setDefinitions := map[string]policy.SetDefinition{}
+			if tt.provideSet {
+				setDefinitions[setDefinitionID] = tt.setDefinition
+			}
+			match, candidates := FindPolicyAssignment(assignments, setDefinitions, SecureTransferPolicy, "Audit", "Deny", "sub", "probr")
+			if (match != nil) != tt.wantMatch {
+				t.Errorf("FindPolicyAssignment() match = %v, wantMatch %v", match, tt.wantMatch)
+			}
+			if len(candidates) != tt.wantCandidates {
+				t.Errorf("FindPolicyAssignment() candidates = %d, want %d", len(candidates), tt.wantCandidates)
+			}
+			if match != nil && match.PolicySetDefinitionID != setDefinitionID {
+				t.Errorf("FindPolicyAssignment() policy set = %s, want %s", match.PolicySetDefinitionID, setDefinitionID)
+			}
+		})
+	}
+}
+
+func TestAllowedNetworkSegmentsPolicy(t *testing.T) {
+	definition, err := policysim.LoadDefinition([]byte(AllowedNetworkSegmentsPolicyJSON))
+	if err != nil {
+		t.Fatalf("LoadDefinition() error = %v", err)
+	}
+	assignmentParameters := map[string]interface{}{
+		"effect":               "Deny",
+		"allowedAddressRanges": []interface{}{"219.79.19.0/24", "170.74.231.168"},
+	}
+	networkRuleSet := func(defaultAction storage.DefaultAction, ranges ...string) *storage.NetworkRuleSet {
+		var rules []storage.IPRule
+		for _, r := range ranges {
+			rules = append(rules, storage.IPRule{IPAddressOrRange: to.StringPtr(r), Action: storage.ActionAllow})
+		}
+		return &storage.NetworkRuleSet{DefaultAction: defaultAction, IPRules: &rules}
+	}
+
+	tests := []struct {
+		testName       string
+		networkRuleSet *storage.NetworkRuleSet
+		expectedEffect string
+	}{
+		{"TestCase1_AllowedSegments_ShouldNotApply", networkRuleSet(storage.DefaultActionDeny, "219.79.19.0/24", "170.74.231.168"), policysim.EffectNone},
+		{"TestCase2_DisallowedSegment_ShouldBeDenied", networkRuleSet(storage.DefaultActionDeny, "219.79.19.0/24", "219.108.32.1"), policysim.EffectDeny},
+		{"TestCase3_DefaultActionAllow_ShouldBeDenied", networkRuleSet(storage.DefaultActionAllow, "219.79.19.0/24"), policysim.EffectDeny},
+		{"TestCase4_NoNetworkRules_ShouldBeDenied", nil, policysim.EffectDeny},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			parameters := storage.AccountCreateParameters{
+				Kind:                              storage.KindStorageV2,
+				AccountPropertiesCreateParameters: &storage.AccountPropertiesCreateParameters{NetworkRuleSet: tc.networkRuleSet},
+			}
+			outcome, err := definition.Evaluate("probrtest", parameters, assignmentParameters)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if outcome.Effect != tc.expectedEffect {
+				t.Errorf("Evaluate() effect = %s, expected %s", outcome.Effect, tc.expectedEffect)
+			}
+		})
+	}
+}
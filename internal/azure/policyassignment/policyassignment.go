@@ -0,0 +1,266 @@
+// Package policyassignment assigns the policies required by the probes to the Probr resource group
+// for the duration of a test run, so that probes can be proven in sandbox subscriptions before policies
+// are rolled out to real subscriptions.
+//
+// Every probe registers the policies it requires with Require. The policies of all probes are assigned
+// together by the first probe to run, with a single wait for the assignments to propagate, and removed by
+// Remove at the end of the test run. Each assigned definition is a copy of the required definition scoped
+// to the storage accounts tagged by one probe, so that the effects required by one probe do not apply to
+// the storage accounts created by another.
+package policyassignment
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-09-01/policy"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/citihub/probr-sdk/utils"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-pack-storage/internal/network"
+)
+
+// effectsByEnforcement lists the effects which enforce a control in each Enforcement, in order of preference
+var effectsByEnforcement = map[string][]string{
+	azureutil.EnforcementDeny:      {"Deny"},
+	azureutil.EnforcementRemediate: {"Modify", "Append", "DeployIfNotExists"},
+	azureutil.EnforcementAudit:     {"Audit"},
+}
+
+// TagName is the tag which brings a storage account into scope of the ephemeral policy assignments of the probe that created it
+const TagName = "probr-control"
+
+// maxDefinitionName is the maximum length of a policy definition name
+const maxDefinitionName = 64
+
+// Assignments records the policy assignments and custom policy definitions created for a test run so that they can be removed afterwards
+type Assignments struct {
+	az          connection.Azure
+	scope       string
+	names       []string
+	definitions []string
+}
+
+// requirement is a policy definition required by a probe
+type requirement struct {
+	control    string
+	definition azureutil.PolicyDefinition
+}
+
+var (
+	requirements []requirement // Registered by each probe when its package is initialised
+	shared       *Assignments  // Created by the first call to Ensure
+	assignErr    error         // Returned by every call to Ensure
+	once         sync.Once
+	mutex        sync.Mutex
+)
+
+// Require registers the policy definitions required by a probe, identified by its name, and returns them
+func Require(control string, definitions ...azureutil.PolicyDefinition) []azureutil.PolicyDefinition {
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, definition := range definitions {
+		requirements = append(requirements, requirement{control: control, definition: definition})
+	}
+	return definitions
+}
+
+// Ensure assigns the policy definitions required by every probe on its first call, and waits once for the
+// assignments to propagate. Later calls return the result of the first call without assigning anything.
+func Ensure(az connection.Azure) error {
+	once.Do(func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		shared, assignErr = assign(az, requirements)
+	})
+	return assignErr
+}
+
+// Remove deletes the policy assignments and custom policy definitions created by Ensure. It is called once at the end of the test run.
+func Remove() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	shared.remove()
+}
+
+// Definition returns the policy definition that enforces a required definition for a probe. When ephemeral
+// policy assignment is enabled this is the copy scoped to the probe's storage accounts, otherwise the definition itself.
+func Definition(control string, definition azureutil.PolicyDefinition) azureutil.PolicyDefinition {
+	if !azureutil.EphemeralPolicyAssignment() {
+		return definition
+	}
+	name := fmt.Sprintf("probr-%s-%s", control, definition.Name)
+	if len(name) > maxDefinitionName {
+		name = name[:maxDefinitionName]
+	}
+	return azureutil.PolicyDefinition{
+		DisplayName: fmt.Sprintf("%s (Probr %s)", definition.DisplayName, control),
+		Name:        name,
+		Custom:      true,
+	}
+}
+
+// Tags returns the tags for the storage accounts created by a probe, which bring them into scope of the probe's ephemeral policy assignments
+func Tags(control string) map[string]*string {
+	return map[string]*string{
+		TagName: to.StringPtr(control),
+	}
+}
+
+// assign saves a scoped copy of each required definition to the subscription, assigns it to the Probr resource group
+// with the effect matching the configured Enforcement and waits for the assignments to propagate. Definitions which
+// allow no such effect are not assigned. Assignments created before an error is returned are still recorded, so that
+// Remove deletes them.
+func assign(az connection.Azure, requirements []requirement) (a *Assignments, err error) {
+
+	a = &Assignments{
+		az:    az,
+		scope: fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", azureutil.SubscriptionID(), azureutil.ResourceGroup()),
+	}
+
+	for _, r := range requirements {
+		properties, definitionErr := definitionProperties(az, r.definition)
+		if definitionErr != nil {
+			err = definitionErr
+			return
+		}
+		effect, effectErr := assignmentEffect(properties, azureutil.Enforcement())
+		if effectErr != nil {
+			log.Printf("[WARN] Not assigning policy '%s': %v", r.definition.DisplayName, effectErr)
+			continue
+		}
+		scoped := Definition(r.control, r.definition)
+		properties, err = scopeToControl(properties, scoped.DisplayName, r.control)
+		if err != nil {
+			return
+		}
+
+		log.Printf("[INFO] Saving policy definition '%s' to subscription '%s'", scoped.DisplayName, azureutil.SubscriptionID())
+		_, createErr := az.CreatePolicyDefinition(scoped.Name, properties)
+		if createErr != nil {
+			err = utils.ReformatError("Failed to save policy definition '%s': %v", scoped.DisplayName, createErr)
+			return
+		}
+		a.definitions = append(a.definitions, scoped.Name)
+
+		log.Printf("[INFO] Assigning policy '%s' with effect '%s' to scope '%s'", scoped.DisplayName, effect, a.scope)
+		_, createErr = az.CreatePolicyAssignment(a.scope, scoped.Name, scoped.ID(), assignmentParameters(r.definition, effect))
+		if createErr != nil {
+			err = utils.ReformatError("Failed to assign policy '%s' to scope '%s': %v", scoped.DisplayName, a.scope, createErr)
+			return
+		}
+		a.names = append(a.names, scoped.Name)
+	}
+
+	if len(a.names) > 0 {
+		wait := azureutil.PolicyPropagationWait()
+		log.Printf("[INFO] Waiting %v for %d policy assignment(s) to propagate", wait, len(a.names))
+		time.Sleep(wait)
+	}
+
+	return
+}
+
+// remove deletes the recorded policy assignments, then the custom policy definitions. Failures are logged so that every removal is attempted.
+func (a *Assignments) remove() {
+	if a == nil {
+		return
+	}
+	for _, name := range a.names {
+		log.Printf("[INFO] Removing policy assignment '%s' from scope '%s'", name, a.scope)
+		if err := a.az.DeletePolicyAssignment(a.scope, name); err != nil {
+			log.Printf("[ERROR] Failed to remove policy assignment '%s': %v", name, err)
+		}
+	}
+	a.names = nil
+
+	// Definitions can only be removed once they are no longer assigned
+	for _, name := range a.definitions {
+		log.Printf("[INFO] Removing policy definition '%s'", name)
+		if err := a.az.DeletePolicyDefinition(name); err != nil {
+			log.Printf("[ERROR] Failed to remove policy definition '%s': %v", name, err)
+		}
+	}
+	a.definitions = nil
+}
+
+// definitionProperties returns the rule and parameters of a built-in definition, or of a custom definition provided by this pack
+func definitionProperties(az connection.Azure, definition azureutil.PolicyDefinition) (properties policy.DefinitionProperties, err error) {
+	if definition.Custom {
+		return azureutil.CustomPolicyDefinition(definition)
+	}
+	builtIn, getErr := az.GetBuiltInPolicyDefinition(definition.Name)
+	if getErr != nil || builtIn.DefinitionProperties == nil {
+		err = utils.ReformatError("Failed to get built-in policy definition '%s': %v", definition.DisplayName, getErr)
+		return
+	}
+	properties = *builtIn.DefinitionProperties
+	return
+}
+
+// scopeToControl returns a custom copy of a policy definition which only applies to resources tagged by the given probe
+func scopeToControl(properties policy.DefinitionProperties, displayName, control string) (scoped policy.DefinitionProperties, err error) {
+	rule, ok := properties.PolicyRule.(map[string]interface{})
+	if !ok || rule["if"] == nil || rule["then"] == nil {
+		err = utils.ReformatError("Unexpected policy rule in definition '%s': %v", displayName, properties.PolicyRule)
+		return
+	}
+	scoped = policy.DefinitionProperties{
+		PolicyType:  policy.Custom,
+		Mode:        properties.Mode,
+		DisplayName: to.StringPtr(displayName),
+		Parameters:  properties.Parameters,
+		PolicyRule: map[string]interface{}{
+			"if": map[string]interface{}{
+				"allOf": []interface{}{
+					map[string]interface{}{
+						"field":  fmt.Sprintf("tags['%s']", TagName),
+						"equals": control,
+					},
+					rule["if"],
+				},
+			},
+			"then": rule["then"],
+		},
+	}
+	return
+}
+
+// assignmentEffect returns the effect an assignment of a definition must set for the given enforcement, as spelled in the
+// effect values the definition allows. An empty effect is returned if the definition has no effect parameter, in which
+// case the effect in its rule applies.
+func assignmentEffect(properties policy.DefinitionProperties, enforcement string) (string, error) {
+	parameter, ok := properties.Parameters["effect"]
+	if !ok || parameter == nil {
+		return "", nil
+	}
+	effects := effectsByEnforcement[enforcement]
+	if parameter.AllowedValues == nil {
+		return effects[0], nil
+	}
+	for _, effect := range effects {
+		for _, allowed := range *parameter.AllowedValues {
+			if allowedEffect, ok := allowed.(string); ok && strings.EqualFold(allowedEffect, effect) {
+				return allowedEffect, nil
+			}
+		}
+	}
+	return "", utils.ReformatError("Policy definition allows none of the effects %v required by '%s' enforcement. Allowed effects: %v", effects, enforcement, *parameter.AllowedValues)
+}
+
+// assignmentParameters sets the effect of the assignment, and the values of any other parameter a definition requires from config
+func assignmentParameters(definition azureutil.PolicyDefinition, effect string) map[string]*policy.ParameterValuesValue {
+	parameters := map[string]*policy.ParameterValuesValue{}
+	if effect != "" {
+		parameters["effect"] = &policy.ParameterValuesValue{Value: effect}
+	}
+	if definition.Name == azureutil.AllowedNetworkSegmentsPolicy.Name {
+		parameters["allowedAddressRanges"] = &policy.ParameterValuesValue{Value: network.ConfiguredSegments().Allowed}
+	}
+	return parameters
+}
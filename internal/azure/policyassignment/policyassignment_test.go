@@ -0,0 +1,143 @@
+package policyassignment
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-09-01/policy"
+	"github.com/Azure/go-autorest/autorest/to"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+)
+
+func TestScopeToControl(t *testing.T) {
+	condition := map[string]interface{}{
+		"field":  "Microsoft.Storage/storageAccounts/supportsHttpsTrafficOnly",
+		"equals": "false",
+	}
+	effect := map[string]interface{}{"effect": "[parameters('effect')]"}
+	parameters := map[string]*policy.ParameterDefinitionsValue{"effect": {DefaultValue: "Audit"}}
+
+	tests := []struct {
+		testName    string
+		properties  policy.DefinitionProperties
+		expectError bool
+	}{
+		{
+			testName: "TestCase1_BuiltInRule_ShouldBeScopedToControlTag",
+			properties: policy.DefinitionProperties{
+				PolicyType: policy.BuiltIn,
+				Mode:       to.StringPtr("Indexed"),
+				PolicyRule: map[string]interface{}{"if": condition, "then": effect},
+				Parameters: parameters,
+			},
+		},
+		{
+			testName:    "TestCase2_MissingRule_ShouldReturnError",
+			properties:  policy.DefinitionProperties{PolicyType: policy.BuiltIn},
+			expectError: true,
+		},
+		{
+			testName: "TestCase3_MissingThen_ShouldReturnError",
+			properties: policy.DefinitionProperties{
+				PolicyRule: map[string]interface{}{"if": condition},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			scoped, err := scopeToControl(tc.properties, "Secure transfer (Probr encryption_in_flight)", "encryption_in_flight")
+			if (err != nil) != tc.expectError {
+				t.Fatalf("scopeToControl() error = %v, expectError %v", err, tc.expectError)
+			}
+			if tc.expectError {
+				return
+			}
+			if scoped.PolicyType != policy.Custom {
+				t.Errorf("scopeToControl() policy type = %s, expected %s", scoped.PolicyType, policy.Custom)
+			}
+			if !reflect.DeepEqual(scoped.Parameters, parameters) {
+				t.Errorf("scopeToControl() parameters = %v, expected %v", scoped.Parameters, parameters)
+			}
+			expectedRule := map[string]interface{}{
+				"if": map[string]interface{}{
+					"allOf": []interface{}{
+						map[string]interface{}{"field": "tags['probr-control']", "equals": "encryption_in_flight"},
+						condition,
+					},
+				},
+				"then": effect,
+			}
+			if !reflect.DeepEqual(scoped.PolicyRule, expectedRule) {
+				t.Errorf("scopeToControl() rule = %v, expected %v", scoped.PolicyRule, expectedRule)
+			}
+		})
+	}
+}
+
+func TestAssignmentEffect(t *testing.T) {
+	withAllowed := func(allowed ...interface{}) policy.DefinitionProperties {
+		return policy.DefinitionProperties{
+			Parameters: map[string]*policy.ParameterDefinitionsValue{"effect": {AllowedValues: &allowed}},
+		}
+	}
+
+	tests := []struct {
+		testName       string
+		properties     policy.DefinitionProperties
+		enforcement    string
+		expectedEffect string
+		expectError    bool
+	}{
+		{
+			testName:       "TestCase1_DenyEnforcement_ShouldReturnDeny",
+			properties:     withAllowed("Audit", "Deny", "Disabled"),
+			enforcement:    azureutil.EnforcementDeny,
+			expectedEffect: "Deny",
+		},
+		{
+			testName:       "TestCase2_AuditEnforcement_ShouldReturnAuditAsSpelledByDefinition",
+			properties:     withAllowed("audit", "deny", "disabled"),
+			enforcement:    azureutil.EnforcementAudit,
+			expectedEffect: "audit",
+		},
+		{
+			testName:       "TestCase3_RemediateEnforcement_ShouldReturnFirstAllowedRemediation",
+			properties:     withAllowed("Audit", "DeployIfNotExists", "Modify", "Disabled"),
+			enforcement:    azureutil.EnforcementRemediate,
+			expectedEffect: "Modify",
+		},
+		{
+			testName:    "TestCase4_NoAllowedEffect_ShouldReturnError",
+			properties:  withAllowed("Audit", "Deny", "Disabled"),
+			enforcement: azureutil.EnforcementRemediate,
+			expectError: true,
+		},
+		{
+			testName:       "TestCase5_NoAllowedValues_ShouldReturnPreferredEffect",
+			properties:     policy.DefinitionProperties{Parameters: map[string]*policy.ParameterDefinitionsValue{"effect": {}}},
+			enforcement:    azureutil.EnforcementAudit,
+			expectedEffect: "Audit",
+		},
+		{
+			testName:       "TestCase6_NoEffectParameter_ShouldReturnEmptyEffect",
+			properties:     policy.DefinitionProperties{},
+			enforcement:    azureutil.EnforcementDeny,
+			expectedEffect: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			effect, err := assignmentEffect(tc.properties, tc.enforcement)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("assignmentEffect() error = %v, expectError %v", err, tc.expectError)
+			}
+			if effect != tc.expectedEffect {
+				t.Errorf("assignmentEffect() = '%s', expected '%s'", effect, tc.expectedEffect)
+			}
+		})
+	}
+}
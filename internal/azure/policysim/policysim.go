@@ -0,0 +1,424 @@
+// Package policysim evaluates Azure Policy definitions offline against the storage account create requests sent by the probes.
+// It predicts whether a request would be denied, audited or modified, so that the policy library and the probe expectations
+// can be checked without an Azure subscription.
+//
+// Only the subset of the policy language used by storage account policies is supported: the allOf, anyOf and not logical
+// operators, field and value conditions, and parameters() and requestContext().apiVersion expressions.
+package policysim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// ResourceType is the resource type of a storage account, as matched by the 'type' field
+const ResourceType = "Microsoft.Storage/storageAccounts"
+
+// APIVersion is the storage API version used by the probes, returned by requestContext().apiVersion
+const APIVersion = "2021-09-01"
+
+// Policy effects which may be predicted by Evaluate. EffectNone is returned when the policy does not apply to the request.
+const (
+	EffectNone              = "None"
+	EffectDeny              = "Deny"
+	EffectAudit             = "Audit"
+	EffectModify            = "Modify"
+	EffectAppend            = "Append"
+	EffectDeployIfNotExists = "DeployIfNotExists"
+	EffectAuditIfNotExists  = "AuditIfNotExists"
+	EffectDisabled          = "Disabled"
+)
+
+// Definition is an Azure Policy definition loaded from JSON
+type Definition struct {
+	Name        string
+	DisplayName string
+	Parameters  map[string]ParameterDefinition
+	PolicyRule  PolicyRule
+}
+
+// ParameterDefinition describes a parameter of a policy definition
+type ParameterDefinition struct {
+	Type          string        `json:"type"`
+	DefaultValue  interface{}   `json:"defaultValue"`
+	AllowedValues []interface{} `json:"allowedValues"`
+}
+
+// PolicyRule is the if/then rule of a policy definition
+type PolicyRule struct {
+	If   map[string]interface{} `json:"if"`
+	Then struct {
+		Effect  string      `json:"effect"`
+		Details interface{} `json:"details"`
+	} `json:"then"`
+}
+
+// Outcome is the predicted result of evaluating a policy definition against a request
+type Outcome struct {
+	Policy  string
+	Effect  string // One of the Effect constants
+	Details interface{}
+}
+
+// LoadDefinition parses a policy definition, either as exported from Azure (with a 'properties' object) or as the bare properties
+func LoadDefinition(data []byte) (*Definition, error) {
+
+	var raw struct {
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+		Properties  *struct {
+			DisplayName string                         `json:"displayName"`
+			Parameters  map[string]ParameterDefinition `json:"parameters"`
+			PolicyRule  *PolicyRule                    `json:"policyRule"`
+		} `json:"properties"`
+		Parameters map[string]ParameterDefinition `json:"parameters"`
+		PolicyRule *PolicyRule                    `json:"policyRule"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, utils.ReformatError("Failed to parse policy definition: %v", err)
+	}
+
+	d := &Definition{
+		Name:        raw.Name,
+		DisplayName: raw.DisplayName,
+		Parameters:  raw.Parameters,
+	}
+	rule := raw.PolicyRule
+	if raw.Properties != nil {
+		d.DisplayName = raw.Properties.DisplayName
+		d.Parameters = raw.Properties.Parameters
+		rule = raw.Properties.PolicyRule
+	}
+	if rule == nil || rule.If == nil {
+		return nil, utils.ReformatError("Policy definition '%s' has no policy rule", d.DisplayName)
+	}
+	d.PolicyRule = *rule
+
+	return d, nil
+}
+
+// LoadDefinitionFile reads and parses a policy definition JSON file
+func LoadDefinitionFile(path string) (*Definition, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, utils.ReformatError("Failed to read policy definition '%s': %v", path, err)
+	}
+	return LoadDefinition(data)
+}
+
+// LoadDefinitions reads and parses every policy definition JSON file in a directory
+func LoadDefinitions(dir string) ([]*Definition, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var definitions []*Definition
+	for _, path := range paths {
+		d, err := LoadDefinitionFile(path)
+		if err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, d)
+	}
+	return definitions, nil
+}
+
+// Evaluate predicts the effect of the policy definition on a request to create the named storage account.
+// Assignment parameters override the default values of the definition's parameters.
+func (d *Definition) Evaluate(accountName string, parameters storage.AccountCreateParameters, assignmentParameters map[string]interface{}) (outcome Outcome, err error) {
+
+	outcome = Outcome{Policy: d.DisplayName, Effect: EffectNone}
+
+	resource, err := requestFields(accountName, parameters)
+	if err != nil {
+		return
+	}
+
+	e := evaluator{definition: d, assignmentParameters: assignmentParameters, resource: resource}
+
+	effect, err := e.resolve(d.PolicyRule.Then.Effect)
+	if err != nil {
+		return
+	}
+	effectName := normalizeEffect(fmt.Sprint(effect))
+	if effectName == EffectDisabled {
+		outcome.Effect = EffectDisabled
+		return
+	}
+
+	applies, err := e.condition(d.PolicyRule.If)
+	if err != nil || !applies {
+		return
+	}
+
+	outcome.Effect = effectName
+	outcome.Details = d.PolicyRule.Then.Details
+	return
+}
+
+// Evaluate predicts the outcome of every policy definition for a request. The request is denied if any outcome is EffectDeny.
+func Evaluate(definitions []*Definition, accountName string, parameters storage.AccountCreateParameters) (outcomes []Outcome, denied bool, err error) {
+	for _, d := range definitions {
+		outcome, evalErr := d.Evaluate(accountName, parameters, nil)
+		if evalErr != nil {
+			err = evalErr
+			return
+		}
+		outcomes = append(outcomes, outcome)
+		denied = denied || outcome.Effect == EffectDeny
+	}
+	return
+}
+
+func normalizeEffect(effect string) string {
+	for _, known := range []string{EffectDeny, EffectAudit, EffectModify, EffectAppend, EffectDeployIfNotExists, EffectAuditIfNotExists, EffectDisabled} {
+		if strings.EqualFold(effect, known) {
+			return known
+		}
+	}
+	return effect
+}
+
+// requestFields converts the create request into the resource document that policy field aliases are resolved against
+func requestFields(accountName string, parameters storage.AccountCreateParameters) (map[string]interface{}, error) {
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return nil, utils.ReformatError("Failed to serialise storage account create parameters: %v", err)
+	}
+	resource := make(map[string]interface{})
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, utils.ReformatError("Failed to serialise storage account create parameters: %v", err)
+	}
+	resource["name"] = accountName
+	resource["type"] = ResourceType
+	return resource, nil
+}
+
+type evaluator struct {
+	definition           *Definition
+	assignmentParameters map[string]interface{}
+	resource             map[string]interface{}
+}
+
+var parametersExpression = regexp.MustCompile(`^\[parameters\('([^']+)'\)\]$`)
+
+// resolve evaluates template expressions in a policy value. Values which are not expressions are returned unchanged.
+func (e evaluator) resolve(value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok || !strings.HasPrefix(s, "[") || strings.HasPrefix(s, "[[") {
+		return value, nil
+	}
+
+	if match := parametersExpression.FindStringSubmatch(s); match != nil {
+		if v, ok := e.assignmentParameters[match[1]]; ok {
+			return v, nil
+		}
+		if p, ok := e.definition.Parameters[match[1]]; ok && p.DefaultValue != nil {
+			return p.DefaultValue, nil
+		}
+		return nil, utils.ReformatError("Policy '%s' parameter '%s' has no value", e.definition.DisplayName, match[1])
+	}
+	if strings.EqualFold(s, "[requestContext().apiVersion]") {
+		return APIVersion, nil
+	}
+	return nil, utils.ReformatError("Policy '%s' uses an unsupported expression: %s", e.definition.DisplayName, s)
+}
+
+func (e evaluator) condition(c map[string]interface{}) (bool, error) {
+
+	if operands, ok := c["allOf"]; ok {
+		for _, operand := range toConditions(operands) {
+			result, err := e.condition(operand)
+			if err != nil || !result {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	if operands, ok := c["anyOf"]; ok {
+		for _, operand := range toConditions(operands) {
+			result, err := e.condition(operand)
+			if err != nil || result {
+				return result, err
+			}
+		}
+		return false, nil
+	}
+
+	if operand, ok := c["not"].(map[string]interface{}); ok {
+		result, err := e.condition(operand)
+		return !result, err
+	}
+
+	var values []interface{}
+	if field, ok := c["field"].(string); ok {
+		values = e.field(field)
+	} else if value, ok := c["value"]; ok {
+		resolved, err := e.resolve(value)
+		if err != nil {
+			return false, err
+		}
+		values = []interface{}{resolved}
+	} else {
+		return false, utils.ReformatError("Policy '%s' has an unsupported condition: %v", e.definition.DisplayName, c)
+	}
+
+	for operator, operand := range c {
+		if operator == "field" || operator == "value" {
+			continue
+		}
+		expected, err := e.resolve(operand)
+		if err != nil {
+			return false, err
+		}
+
+		// A condition on an array alias ([*]) is true only if every element meets it
+		for _, value := range values {
+			result, err := compare(operator, value, expected)
+			if err != nil {
+				return false, utils.ReformatError("Policy '%s': %v", e.definition.DisplayName, err)
+			}
+			if !result {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	return false, utils.ReformatError("Policy '%s' has a condition without an operator: %v", e.definition.DisplayName, c)
+}
+
+// field resolves a field name or storage account alias to its values in the request. Missing fields resolve to a single nil value.
+func (e evaluator) field(name string) []interface{} {
+	path := name
+	switch lower := strings.ToLower(name); {
+	case lower == "type", lower == "name", lower == "location", lower == "kind":
+		path = lower
+	case strings.HasPrefix(lower, strings.ToLower(ResourceType)+"/"):
+		path = name[len(ResourceType)+1:]
+		if !strings.HasPrefix(strings.ToLower(path), "sku.") && !strings.EqualFold(path, "kind") {
+			path = "properties." + path
+		}
+	}
+	return lookup(e.resource, strings.Split(path, "."))
+}
+
+func lookup(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		return []interface{}{value}
+	}
+
+	segment := path[0]
+	isArray := strings.HasSuffix(segment, "[*]")
+	segment = strings.TrimSuffix(segment, "[*]")
+
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return []interface{}{nil}
+	}
+	var child interface{}
+	for key, v := range object {
+		if strings.EqualFold(key, segment) {
+			child = v
+		}
+	}
+
+	if !isArray {
+		return lookup(child, path[1:])
+	}
+	elements, _ := child.([]interface{})
+	values := []interface{}{}
+	for _, element := range elements {
+		values = append(values, lookup(element, path[1:])...)
+	}
+	return values
+}
+
+func toConditions(value interface{}) []map[string]interface{} {
+	var conditions []map[string]interface{}
+	operands, _ := value.([]interface{})
+	for _, operand := range operands {
+		if c, ok := operand.(map[string]interface{}); ok {
+			conditions = append(conditions, c)
+		}
+	}
+	return conditions
+}
+
+func compare(operator string, value, expected interface{}) (bool, error) {
+	switch strings.ToLower(operator) {
+	case "equals":
+		return value != nil && equal(value, expected), nil
+	case "notequals":
+		return value == nil || !equal(value, expected), nil
+	case "in":
+		return value != nil && contains(expected, value), nil
+	case "notin":
+		return value == nil || !contains(expected, value), nil
+	case "exists":
+		exists, err := strconv.ParseBool(fmt.Sprint(expected))
+		if err != nil {
+			return false, fmt.Errorf("unexpected value for exists: %v", expected)
+		}
+		return (value != nil) == exists, nil
+	case "contains":
+		return value != nil && strings.Contains(strings.ToLower(fmt.Sprint(value)), strings.ToLower(fmt.Sprint(expected))), nil
+	case "notcontains":
+		return value == nil || !strings.Contains(strings.ToLower(fmt.Sprint(value)), strings.ToLower(fmt.Sprint(expected))), nil
+	case "less", "lessorequals", "greater", "greaterorequals":
+		if value == nil {
+			return false, nil
+		}
+		order := strings.Compare(fmt.Sprint(value), fmt.Sprint(expected))
+		if a, aErr := strconv.ParseFloat(fmt.Sprint(value), 64); aErr == nil {
+			if b, bErr := strconv.ParseFloat(fmt.Sprint(expected), 64); bErr == nil {
+				order = compareFloats(a, b)
+			}
+		}
+		switch strings.ToLower(operator) {
+		case "less":
+			return order < 0, nil
+		case "lessorequals":
+			return order <= 0, nil
+		case "greater":
+			return order > 0, nil
+		default:
+			return order >= 0, nil
+		}
+	default:
+		return false, fmt.Errorf("unsupported operator '%s'", operator)
+	}
+}
+
+func equal(value, expected interface{}) bool {
+	return strings.EqualFold(fmt.Sprint(value), fmt.Sprint(expected))
+}
+
+func contains(list, value interface{}) bool {
+	items, _ := list.([]interface{})
+	for _, item := range items {
+		if equal(value, item) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
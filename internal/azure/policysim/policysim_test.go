@@ -0,0 +1,134 @@
+package policysim
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+func loadTestDefinition(t *testing.T, name string) *Definition {
+	d, err := LoadDefinitionFile(filepath.Join("testdata", name+".json"))
+	if err != nil {
+		t.Fatalf("LoadDefinitionFile() error = %v", err)
+	}
+	return d
+}
+
+func createParameters(properties storage.AccountPropertiesCreateParameters) storage.AccountCreateParameters {
+	return storage.AccountCreateParameters{
+		Sku:                               &storage.Sku{Name: storage.SkuNameStandardLRS},
+		Kind:                              storage.KindStorageV2,
+		Location:                          to.StringPtr("uksouth"),
+		AccountPropertiesCreateParameters: &properties,
+	}
+}
+
+func TestDefinitionEvaluate(t *testing.T) {
+	ipRules := func(ranges ...string) *storage.NetworkRuleSet {
+		var rules []storage.IPRule
+		for _, r := range ranges {
+			rules = append(rules, storage.IPRule{IPAddressOrRange: to.StringPtr(r), Action: storage.ActionAllow})
+		}
+		return &storage.NetworkRuleSet{DefaultAction: storage.DefaultActionDeny, IPRules: &rules}
+	}
+
+	tests := []struct {
+		name                 string
+		definition           string
+		properties           storage.AccountPropertiesCreateParameters
+		assignmentParameters map[string]interface{}
+		wantEffect           string
+	}{
+		{"TestCase1_HTTPSDisabledWithDeny_ShouldBeDenied", "404c3081-a854-4457-ae30-26a93ef643f9",
+			storage.AccountPropertiesCreateParameters{EnableHTTPSTrafficOnly: to.BoolPtr(false)},
+			map[string]interface{}{"effect": "Deny"}, EffectDeny},
+		{"TestCase2_HTTPSEnabledWithDeny_ShouldNotApply", "404c3081-a854-4457-ae30-26a93ef643f9",
+			storage.AccountPropertiesCreateParameters{EnableHTTPSTrafficOnly: to.BoolPtr(true)},
+			map[string]interface{}{"effect": "Deny"}, EffectNone},
+		{"TestCase3_HTTPSDisabledWithDefaultEffect_ShouldBeAudited", "404c3081-a854-4457-ae30-26a93ef643f9",
+			storage.AccountPropertiesCreateParameters{EnableHTTPSTrafficOnly: to.BoolPtr(false)},
+			nil, EffectAudit},
+		{"TestCase4_HTTPSDisabledWithDisabledEffect_ShouldBeDisabled", "404c3081-a854-4457-ae30-26a93ef643f9",
+			storage.AccountPropertiesCreateParameters{EnableHTTPSTrafficOnly: to.BoolPtr(false)},
+			map[string]interface{}{"effect": "Disabled"}, EffectDisabled},
+		{"TestCase5_NetworkDefaultActionAllow_ShouldBeDenied", "34c877ad-507e-4c82-993e-3452a6e0ad3c",
+			storage.AccountPropertiesCreateParameters{NetworkRuleSet: &storage.NetworkRuleSet{DefaultAction: storage.DefaultActionAllow}},
+			map[string]interface{}{"effect": "Deny"}, EffectDeny},
+		{"TestCase6_NetworkDefaultActionMissing_ShouldBeDenied", "34c877ad-507e-4c82-993e-3452a6e0ad3c",
+			storage.AccountPropertiesCreateParameters{},
+			map[string]interface{}{"effect": "Deny"}, EffectDeny},
+		{"TestCase7_NetworkDefaultActionDeny_ShouldNotApply", "34c877ad-507e-4c82-993e-3452a6e0ad3c",
+			storage.AccountPropertiesCreateParameters{NetworkRuleSet: ipRules("10.0.0.0/24")},
+			map[string]interface{}{"effect": "Deny"}, EffectNone},
+		{"TestCase8_CrossTenantReplicationUnset_ShouldBeDenied", "92a89a79-6c52-4a7e-a03f-61306fc49312",
+			storage.AccountPropertiesCreateParameters{},
+			map[string]interface{}{"effect": "Deny"}, EffectDeny},
+		{"TestCase9_CrossTenantReplicationDisallowed_ShouldNotApply", "92a89a79-6c52-4a7e-a03f-61306fc49312",
+			storage.AccountPropertiesCreateParameters{AllowCrossTenantReplication: to.BoolPtr(false)},
+			map[string]interface{}{"effect": "Deny"}, EffectNone},
+		{"TestCase10_AllIPRulesApproved_ShouldNotApply", "allowed_ip_ranges",
+			storage.AccountPropertiesCreateParameters{NetworkRuleSet: ipRules("10.0.0.0/24")},
+			nil, EffectNone},
+		{"TestCase11_UnapprovedIPRule_ShouldBeDenied", "allowed_ip_ranges",
+			storage.AccountPropertiesCreateParameters{NetworkRuleSet: ipRules("10.0.0.0/24", "8.8.8.8")},
+			nil, EffectDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := loadTestDefinition(t, tt.definition)
+			got, err := d.Evaluate("probrtest", createParameters(tt.properties), tt.assignmentParameters)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got.Effect != tt.wantEffect {
+				t.Errorf("Evaluate() effect = %v, want %v", got.Effect, tt.wantEffect)
+			}
+		})
+	}
+}
+
+func TestLoadDefinition(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"TestCase1_BareDefinition_ShouldLoad", `{"displayName": "bare", "policyRule": {"if": {"field": "type", "equals": "x"}, "then": {"effect": "Deny"}}}`, false},
+		{"TestCase2_MissingRule_ShouldReturnError", `{"properties": {"displayName": "empty"}}`, true},
+		{"TestCase3_InvalidJSON_ShouldReturnError", `{`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadDefinition([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadDefinition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluateLibrary(t *testing.T) {
+	definitions, err := LoadDefinitions("testdata")
+	if err != nil {
+		t.Fatalf("LoadDefinitions() error = %v", err)
+	}
+
+	// The request sent by the encryption in flight probe when https is disabled is audited, but not denied, by default effects
+	parameters := createParameters(storage.AccountPropertiesCreateParameters{
+		EnableHTTPSTrafficOnly:      to.BoolPtr(false),
+		AllowCrossTenantReplication: to.BoolPtr(false),
+		NetworkRuleSet:              &storage.NetworkRuleSet{DefaultAction: storage.DefaultActionDeny},
+	})
+	outcomes, denied, err := Evaluate(definitions, "probrtest", parameters)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if len(outcomes) != len(definitions) {
+		t.Errorf("Evaluate() returned %d outcomes, want %d", len(outcomes), len(definitions))
+	}
+	if denied {
+		t.Errorf("Evaluate() denied = true, want false: %v", outcomes)
+	}
+}
@@ -0,0 +1,109 @@
+package azure
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// Names of the storage account properties whose effective value can be compared against a desired value
+const (
+	HTTPSOnlyProperty              = "https only"
+	NetworkDefaultActionProperty   = "network default action"
+	PublicNetworkAccessProperty    = "public network access"
+	PublicBlobAccessProperty       = "public blob access"
+	MinimumTLSVersionProperty      = "minimum tls version"
+	CrossTenantReplicationProperty = "cross tenant replication"
+)
+
+// AccountProperty returns the effective value of a named storage account property as a string.
+// Properties which are not set on the account are returned as an empty string.
+func AccountProperty(account storage.Account, name string) (string, error) {
+	properties := account.AccountProperties
+	if properties == nil {
+		properties = &storage.AccountProperties{}
+	}
+
+	switch strings.ToLower(name) {
+	case HTTPSOnlyProperty:
+		return boolProperty(properties.EnableHTTPSTrafficOnly), nil
+	case NetworkDefaultActionProperty:
+		if properties.NetworkRuleSet == nil {
+			return "", nil
+		}
+		return string(properties.NetworkRuleSet.DefaultAction), nil
+	case PublicNetworkAccessProperty:
+		return string(properties.PublicNetworkAccess), nil
+	case PublicBlobAccessProperty:
+		return boolProperty(properties.AllowBlobPublicAccess), nil
+	case MinimumTLSVersionProperty:
+		return string(properties.MinimumTLSVersion), nil
+	case CrossTenantReplicationProperty:
+		return boolProperty(properties.AllowCrossTenantReplication), nil
+	default:
+		return "", utils.ReformatError("Unexpected value provided for property: '%s' Expected values: ['%s']", name,
+			strings.Join([]string{
+				HTTPSOnlyProperty,
+				NetworkDefaultActionProperty,
+				PublicNetworkAccessProperty,
+				PublicBlobAccessProperty,
+				MinimumTLSVersionProperty,
+				CrossTenantReplicationProperty,
+			}, "', '"))
+	}
+}
+
+// Remediation records the desired and effective values of a property while waiting for a policy to remediate it
+type Remediation struct {
+	Property   string
+	Desired    string
+	Initial    string // Effective value when the account was first read after creation
+	Effective  string // Effective value when the wait ended
+	Elapsed    string
+	Remediated bool
+}
+
+// WaitForRemediation re-reads a storage account until the effective value of a property matches the desired value,
+// which is how Modify, Append and DeployIfNotExists policy effects are observed. An error is returned only if the
+// account cannot be read or the property is unknown; a value which is not remediated in time is reported via Remediated.
+func WaitForRemediation(read func() (storage.Account, error), property, desired string, timeout, interval time.Duration) (r Remediation, err error) {
+
+	r = Remediation{
+		Property: property,
+		Desired:  desired,
+	}
+
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		account, readErr := read()
+		if readErr != nil {
+			err = utils.ReformatError("Failed to read storage account while waiting for remediation: %v", readErr)
+			return
+		}
+
+		r.Effective, err = AccountProperty(account, property)
+		if err != nil {
+			return
+		}
+		if attempt == 0 {
+			r.Initial = r.Effective
+		}
+
+		r.Remediated = strings.EqualFold(r.Effective, desired)
+		r.Elapsed = time.Since(start).Round(time.Second).String()
+		if r.Remediated || time.Since(start)+interval > timeout {
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
+func boolProperty(value *bool) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*value)
+}
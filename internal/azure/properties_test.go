@@ -0,0 +1,91 @@
+package azure
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+func TestAccountProperty(t *testing.T) {
+	account := storage.Account{
+		AccountProperties: &storage.AccountProperties{
+			EnableHTTPSTrafficOnly: to.BoolPtr(true),
+			NetworkRuleSet:         &storage.NetworkRuleSet{DefaultAction: storage.DefaultActionDeny},
+			MinimumTLSVersion:      storage.MinimumTLSVersionTLS12,
+		},
+	}
+
+	tests := []struct {
+		name     string
+		property string
+		want     string
+		wantErr  bool
+	}{
+		{"TestCase1_HTTPSOnly_ShouldReturnTrue", HTTPSOnlyProperty, "true", false},
+		{"TestCase2_NetworkDefaultAction_ShouldReturnDeny", NetworkDefaultActionProperty, "Deny", false},
+		{"TestCase3_MinimumTLSVersion_ShouldReturnTLS12", MinimumTLSVersionProperty, "TLS1_2", false},
+		{"TestCase4_UnsetProperty_ShouldReturnEmpty", PublicBlobAccessProperty, "", false},
+		{"TestCase5_UnknownProperty_ShouldReturnError", "unknown", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AccountProperty(account, tt.property)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AccountProperty() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("AccountProperty() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitForRemediation(t *testing.T) {
+	accountWithHTTPS := func(enabled bool) storage.Account {
+		return storage.Account{AccountProperties: &storage.AccountProperties{EnableHTTPSTrafficOnly: to.BoolPtr(enabled)}}
+	}
+
+	tests := []struct {
+		name           string
+		reads          []storage.Account
+		readErr        error
+		wantRemediated bool
+		wantInitial    string
+		wantErr        bool
+	}{
+		{"TestCase1_RemediatedOnThirdRead_ShouldBeRemediated",
+			[]storage.Account{accountWithHTTPS(false), accountWithHTTPS(false), accountWithHTTPS(true)}, nil, true, "false", false},
+		{"TestCase2_NeverRemediated_ShouldNotBeRemediated",
+			[]storage.Account{accountWithHTTPS(false)}, nil, false, "false", false},
+		{"TestCase3_ReadFails_ShouldReturnError", nil, errors.New("not found"), false, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			read := func() (storage.Account, error) {
+				if tt.readErr != nil {
+					return storage.Account{}, tt.readErr
+				}
+				account := tt.reads[calls%len(tt.reads)]
+				if calls < len(tt.reads)-1 {
+					calls++
+				}
+				return account, nil
+			}
+
+			got, err := WaitForRemediation(read, HTTPSOnlyProperty, "true", 50*time.Millisecond, 5*time.Millisecond)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WaitForRemediation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Remediated != tt.wantRemediated {
+				t.Errorf("WaitForRemediation() remediated = %v, want %v", got.Remediated, tt.wantRemediated)
+			}
+			if got.Initial != tt.wantInitial {
+				t.Errorf("WaitForRemediation() initial = %v, want %v", got.Initial, tt.wantInitial)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+@s-azsw
+Feature: Object Storage Does Not Publicly Serve Static Websites
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that my organisation's data is not accidentally exposed to the internet through static website hosting
+
+    Background:
+      Given an Azure subscription is available
+      And azure resource group specified in config exists
+
+    @s-azsw-001
+    Scenario: Prevent Object Storage from Serving Static Website Content Anonymously
+      Given the scenario requires a real storage account
+      And a storage account is created for static website testing
+      When an attempt is made to enable static website hosting on the storage account
+      Then static website hosting is rejected or its content cannot be served anonymously
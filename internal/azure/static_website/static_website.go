@@ -0,0 +1,403 @@
+package azuresw
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	azureStorage "github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/cucumber/godog"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// ProbeStruct allows this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+type scenarioState struct {
+	name               string
+	currentStep        string
+	audit              *audit.ScenarioAudit
+	probe              *audit.Probe
+	ctx                context.Context
+	tags               map[string]*string
+	bucketName         string
+	storageAccounts    []string
+	staticWebsiteError error
+}
+
+// Probe ...
+var Probe probeStruct             // Probe allows this probe to be added to the ProbeStore
+var scenario scenarioState        // Local container of scenario state
+var azConnection connection.Azure // Provides functionality to interact with Azure
+
+const (
+	indexDocument = "index.html"
+	webContainer  = "$web"
+)
+
+func (scenario *scenarioState) anAzureSubscriptionIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that Azure subscription specified in config file is available; "))
+
+	payload = struct {
+		SubscriptionID string
+		TenantID       string
+		CreationMode   string
+	}{
+		azureutil.SubscriptionID(),
+		azureutil.TenantID(),
+		azureutil.CreationMode(),
+	}
+
+	err = azConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) azureResourceGroupSpecifiedInConfigExists() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check if value for Azure resource group is set in config vars; ")
+	if azureutil.ResourceGroup() == "" {
+		err = utils.ReformatError("Azure resource group config var not set")
+		return err
+	}
+
+	stepTrace.WriteString("Check the resource group exists in the specified azure subscription; ")
+	_, getGrpErr := azConnection.GetResourceGroupByName(azureutil.ResourceGroup())
+	if getGrpErr != nil {
+		err = utils.ReformatError("Azure resource group '%s' does not exists. Error: %v", azureutil.ResourceGroup(), getGrpErr)
+		return err
+	}
+
+	//Audit log
+	payload = struct {
+		SubscriptionID string
+		ResourceGroup  string
+	}{
+		SubscriptionID: azureutil.SubscriptionID(),
+		ResourceGroup:  azureutil.ResourceGroup(),
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) aStorageAccountIsCreatedForStaticWebsiteTesting() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	scenario.bucketName = utils.RandomString(10)
+	stepTrace.WriteString(fmt.Sprintf("Generate a storage account name using a random string: '%s'; ", scenario.bucketName))
+
+	stepTrace.WriteString("Create Storage Account with HTTPS only; ")
+	storageAccount, creationErr := azConnection.CreateStorageAccountWithParameters(
+		scenario.bucketName,
+		azureutil.ResourceGroup(),
+		azureStorage.AccountCreateParameters{
+			AccountPropertiesCreateParameters: &azureStorage.AccountPropertiesCreateParameters{
+				EnableHTTPSTrafficOnly: to.BoolPtr(true),
+			},
+			Tags: scenario.tags,
+		})
+	if creationErr != nil {
+		err = utils.ReformatError("Creation of storage account did not succeed: %v", creationErr)
+		return err
+	}
+	scenario.storageAccounts = append(scenario.storageAccounts, scenario.bucketName) // Record for later cleanup
+
+	//Audit log
+	payload = struct {
+		StorageAccountName string
+		ResourceGroup      string
+		StorageAccount     azureStorage.Account
+	}{
+		StorageAccountName: scenario.bucketName,
+		ResourceGroup:      azureutil.ResourceGroup(),
+		StorageAccount:     storageAccount,
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) anAttemptIsMadeToEnableStaticWebsiteHostingOnTheStorageAccount() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString(fmt.Sprintf("Attempt to enable static website hosting with index document '%s'; ", indexDocument))
+	scenario.staticWebsiteError = azConnection.EnableStaticWebsite(azureutil.ResourceGroup(), scenario.bucketName, indexDocument)
+
+	staticWebsiteError := ""
+	if scenario.staticWebsiteError != nil {
+		staticWebsiteError = scenario.staticWebsiteError.Error()
+	}
+
+	//Audit log
+	payload = struct {
+		StorageAccountName string
+		StaticWebsiteError string
+	}{
+		StorageAccountName: scenario.bucketName,
+		StaticWebsiteError: staticWebsiteError,
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) staticWebsiteHostingIsRejectedOrItsContentCannotBeServedAnonymously() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check whether enabling static website hosting was rejected; ")
+	if scenario.staticWebsiteError != nil {
+		payload = struct {
+			StorageAccountName string
+			Rejected           bool
+			RejectionError     string
+		}{
+			StorageAccountName: scenario.bucketName,
+			Rejected:           true,
+			RejectionError:     scenario.staticWebsiteError.Error(),
+		}
+		if !isRefusal(scenario.staticWebsiteError) {
+			// Ensure the request failed because static website hosting was refused, and not for an unrelated reason
+			err = utils.ReformatError("Enabling static website hosting failed with unexpected reason: %v - %v", azureutil.ServiceErrorCode(scenario.staticWebsiteError), scenario.staticWebsiteError)
+		}
+		return err
+	}
+
+	stepTrace.WriteString("Get web endpoint of Storage Account; ")
+	storageAccount, getErr := azConnection.GetStorageAccountProperties(azureutil.ResourceGroup(), scenario.bucketName)
+	if getErr != nil {
+		err = utils.ReformatError("Failed to get storage account properties: %v", getErr)
+		return err
+	}
+	if storageAccount.AccountProperties == nil || storageAccount.AccountProperties.PrimaryEndpoints == nil || storageAccount.AccountProperties.PrimaryEndpoints.Web == nil {
+		// Static website hosting was accepted, so the anonymous request must be made before the control can be considered met
+		err = utils.ReformatError("Static website hosting was accepted on storage account '%s', but it exposes no web endpoint to request anonymously", scenario.bucketName)
+		return err
+	}
+	webEndpoint := *storageAccount.AccountProperties.PrimaryEndpoints.Web
+
+	marker := utils.RandomString(16)
+	stepTrace.WriteString(fmt.Sprintf("Upload '%s' containing marker '%s' to '%s' container; ", indexDocument, marker, webContainer))
+	uploadErr := azConnection.UploadBlob(azureutil.ResourceGroup(), scenario.bucketName, webContainer, indexDocument, "text/html", []byte(marker))
+	if uploadErr != nil {
+		err = utils.ReformatError("Static website hosting was accepted on storage account '%s', but the content to request anonymously could not be uploaded: %v", scenario.bucketName, uploadErr)
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Request '%s' anonymously; ", webEndpoint))
+	statusCode, served, requestErr := isServedAnonymously(webEndpoint, marker)
+	switch {
+	case requestErr != nil:
+		err = utils.ReformatError("Anonymous request to static website of storage account '%s' could not be made: %v", scenario.bucketName, requestErr)
+	case served:
+		err = utils.ReformatError("Static website content of storage account '%s' was served anonymously from '%s'", scenario.bucketName, webEndpoint)
+	}
+
+	requestError := ""
+	if requestErr != nil {
+		requestError = requestErr.Error()
+	}
+
+	//Audit log
+	payload = struct {
+		StorageAccountName string
+		Rejected           bool
+		WebEndpoint        string
+		StatusCode         int
+		RequestError       string
+		ServedAnonymously  bool
+	}{
+		StorageAccountName: scenario.bucketName,
+		Rejected:           false,
+		WebEndpoint:        webEndpoint,
+		StatusCode:         statusCode,
+		RequestError:       requestError,
+		ServedAnonymously:  served,
+	}
+
+	return err
+}
+
+// isRefusal returns whether enabling static website hosting was refused by the storage account or by an Azure Policy assignment
+func isRefusal(err error) bool {
+	if azureutil.ServiceErrorStatusCode(err) == http.StatusForbidden {
+		return true
+	}
+	switch azureutil.ServiceErrorCode(err) {
+	case "AuthorizationFailure", azureutil.PolicyDenialCode:
+		return true
+	}
+	return false
+}
+
+// isServedAnonymously requests the web endpoint without credentials and reports whether the uploaded marker was returned
+func isServedAnonymously(webEndpoint, marker string) (statusCode int, served bool, err error) {
+
+	client := http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	resp, err := client.Get(webEndpoint)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	statusCode = resp.StatusCode
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	served = statusCode == http.StatusOK && strings.Contains(string(body), marker)
+	return
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.storageAccounts = make([]string, 0)
+	s.staticWebsiteError = nil
+	probeengine.LogScenarioStart(gs)
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "static_website"
+}
+
+// Path returns this probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "azure", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize azure connection
+		azConnection = connection.NewAzureConnection(
+			context.Background(),
+			azureutil.SubscriptionID(),
+			azureutil.TenantID(),
+			azureutil.ClientID(),
+			azureutil.ClientSecret(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an Azure subscription is available$`, scenario.anAzureSubscriptionIsAvailable)
+	ctx.Step(`^azure resource group specified in config exists$`, scenario.azureResourceGroupSpecifiedInConfigExists)
+
+	// Steps
+	ctx.Step(`^the scenario requires a real storage account$`, func() error {
+		return azureutil.TheScenarioRequiresARealStorageAccount(scenario.audit, scenario.currentStep)
+	})
+	ctx.Step(`^a storage account is created for static website testing$`, scenario.aStorageAccountIsCreatedForStaticWebsiteTesting)
+	ctx.Step(`^an attempt is made to enable static website hosting on the storage account$`, scenario.anAttemptIsMadeToEnableStaticWebsiteHostingOnTheStorageAccount)
+	ctx.Step(`^static website hosting is rejected or its content cannot be served anonymously$`, scenario.staticWebsiteHostingIsRejectedOrItsContentCannotBeServedAnonymously)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing storage accounts used during tests")
+
+	for _, account := range scenario.storageAccounts {
+		log.Printf("[DEBUG] need to delete the storageAccount: %s", account)
+		err := azConnection.DeleteStorageAccount(azureutil.ResourceGroup(), account)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the storageAccount: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
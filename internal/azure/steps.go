@@ -0,0 +1,83 @@
+package azure
+
+import (
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/utils"
+	"github.com/cucumber/godog"
+)
+
+// Step functions shared by the Azure probes. Probes register them with a closure over their scenario state,
+// so that the step is recorded in the audit log of the running scenario, e.g.
+//
+//	ctx.Step(`^the control is enforced by "([^"]*)"$`, func(enforcement string) error {
+//		return azureutil.TheControlIsEnforcedByX(scenario.audit, scenario.currentStep, enforcement)
+//	})
+
+// TheControlIsEnforcedByX skips the scenario unless the configured Enforcement matches the one the scenario tests
+func TheControlIsEnforcedByX(scenarioAudit *audit.ScenarioAudit, currentStep, enforcement string) error {
+
+	// Supported values for 'enforcement':
+	//	'deny'
+	//	'remediate'
+	//	'audit'
+
+	// Skip before auditing, so that scenarios for other enforcement mechanisms are not recorded as failures
+	if RequireEnforcement(enforcement) == godog.ErrPending {
+		return godog.ErrPending
+	}
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenarioAudit.AuditScenarioStep(currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check that controls are enforced as configured by AZURE_ENFORCEMENT, otherwise skip the scenario; ")
+
+	// Audit log
+	payload = struct {
+		ExpectedEnforcement   string
+		ConfiguredEnforcement string
+	}{
+		ExpectedEnforcement:   enforcement,
+		ConfiguredEnforcement: Enforcement(),
+	}
+
+	err = RequireEnforcement(enforcement)
+	return err
+}
+
+// TheScenarioRequiresARealStorageAccount skips the scenario unless storage accounts are provisioned in the configured CreationMode
+func TheScenarioRequiresARealStorageAccount(scenarioAudit *audit.ScenarioAudit, currentStep string) error {
+
+	// Skip before auditing, so that skipped scenarios are not recorded as failures
+	err := RequireRealStorageAccount()
+	if err != nil {
+		return err
+	}
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenarioAudit.AuditScenarioStep(currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check that storage accounts are provisioned in the configured creation mode; ")
+
+	// Audit log
+	payload = struct {
+		CreationMode string
+	}{
+		CreationMode: CreationMode(),
+	}
+
+	return err
+}
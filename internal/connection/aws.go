@@ -0,0 +1,276 @@
+package connection
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3control"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// AWSConnection simplifies the connection with cloud provider
+type AWSConnection struct {
+	isCloudAvailable error
+	ctx              context.Context
+	session          *session.Session
+	S3               *AWSS3        // Client obj to interact with Amazon S3 buckets and objects
+	S3Control        *AWSS3Control // Client obj to interact with account level Amazon S3 settings
+	KMS              *AWSKMS       // Client obj to resolve the KMS keys used for bucket encryption
+}
+
+// AWS interface defining all aws methods
+type AWS interface {
+	IsCloudAvailable() error
+	Region() string
+	AccountID() (string, error)
+	GetAccountPublicAccessBlock() (*s3control.PublicAccessBlockConfiguration, error)
+	ListBuckets() ([]string, error)
+	CreateBucket(bucketName string) error
+	CreateBucketWithObjectLock(bucketName string) error
+	DeleteBucket(bucketName string) error
+	GetBucketPolicy(bucketName string) (string, error)
+	PutBucketPolicy(bucketName, policy string) error
+	GetPublicAccessBlock(bucketName string) (*s3.PublicAccessBlockConfiguration, error)
+	PutPublicAccessBlock(bucketName string, configuration *s3.PublicAccessBlockConfiguration) error
+	GetBucketObjectOwnership(bucketName string) (string, error)
+	PutBucketACL(bucketName, acl string) error
+	GetBucketVersioning(bucketName string) (*s3.GetBucketVersioningOutput, error)
+	GetObjectLockConfiguration(bucketName string) (*s3.ObjectLockConfiguration, error)
+	GetBucketEncryption(bucketName string) (*s3.ServerSideEncryptionConfiguration, error)
+	PutBucketEncryption(bucketName, algorithm, kmsKeyID string) error
+	PutObject(bucketName, key string, content []byte) error
+	PutObjectWithEncryption(bucketName, key string, content []byte, algorithm, kmsKeyID string) error
+	PutObjectWithRetention(bucketName, key string, content []byte, mode string, retainUntil time.Time) (string, error)
+	GetObject(bucketName, key string) ([]byte, error)
+	DeleteObjectVersion(bucketName, key, versionID string) error
+	GetObjectOverHTTP(bucketName, key string) error
+	KMSKeyARN(keyID string) (string, error)
+}
+
+// defaultCompatibleRegion is used when a custom endpoint is set and no region is configured
+const defaultCompatibleRegion = "us-east-1"
+
+var awsInstance *AWSConnection
+var awsOnce sync.Once
+
+// NewAWSConnection provides a singleton instance of AWSConnection. Credentials and region are read from the AWS SDK
+// shared configuration: environment variables, the shared config and credentials files, or an instance role.
+// An endpoint may be given to connect to an S3 compatible server such as LocalStack or MinIO instead of AWS.
+func NewAWSConnection(c context.Context, endpoint string, forcePathStyle bool) *AWSConnection {
+	awsOnce.Do(func() {
+		awsInstance = &AWSConnection{
+			ctx: c,
+		}
+
+		// Guard clause
+		if c == nil {
+			awsInstance.isCloudAvailable = utils.ReformatError("Context instance cannot be nil")
+			return
+		}
+
+		// Create a session from the SDK shared configuration
+		sess, sessErr := session.NewSessionWithOptions(session.Options{
+			SharedConfigState: session.SharedConfigEnable,
+		})
+		if sessErr != nil {
+			awsInstance.isCloudAvailable = utils.ReformatError("Failed to initialize AWS Session: %v", sessErr)
+			return
+		}
+		if aws.StringValue(sess.Config.Region) == "" {
+			if endpoint == "" {
+				awsInstance.isCloudAvailable = utils.ReformatError("AWS region is not set in the SDK configuration. Set AWS_REGION or a region in the shared config file")
+				return
+			}
+			// Requests are still signed with a region, which S3 compatible servers such as MinIO and Ceph RGW default to us-east-1
+			sess.Config.Region = aws.String(defaultCompatibleRegion)
+		}
+		awsInstance.session = sess
+
+		// Create an S3 client object via the session
+		var s3Err error
+		awsInstance.S3, s3Err = NewS3(c, sess, endpoint, forcePathStyle)
+		if s3Err != nil {
+			awsInstance.isCloudAvailable = utils.ReformatError("Failed to initialize AWS S3: %v", s3Err)
+			return
+		}
+
+		// Create an S3 Control client object via the session
+		var scErr error
+		awsInstance.S3Control, scErr = NewS3Control(c, sess, endpoint)
+		if scErr != nil {
+			awsInstance.isCloudAvailable = utils.ReformatError("Failed to initialize AWS S3 Control: %v", scErr)
+			return
+		}
+
+		// Create a KMS client object via the session
+		var kmsErr error
+		awsInstance.KMS, kmsErr = NewKMS(c, sess, endpoint)
+		if kmsErr != nil {
+			awsInstance.isCloudAvailable = utils.ReformatError("Failed to initialize AWS KMS: %v", kmsErr)
+			return
+		}
+
+		// Verify the credentials are accepted by the endpoint
+		if _, listErr := awsInstance.S3.ListBuckets(); listErr != nil {
+			awsInstance.isCloudAvailable = utils.ReformatError("Failed to connect to AWS S3: %v", listErr)
+			return
+		}
+	})
+	return awsInstance
+}
+
+// IsCloudAvailable verifies that the connection instantiation did not report a failure
+func (a *AWSConnection) IsCloudAvailable() error {
+	return a.isCloudAvailable
+}
+
+// Region returns the region read from the SDK configuration
+func (a *AWSConnection) Region() string {
+	if a.session == nil {
+		return ""
+	}
+	return aws.StringValue(a.session.Config.Region)
+}
+
+// AccountID returns the ID of the account the caller belongs to
+func (a *AWSConnection) AccountID() (string, error) {
+	log.Printf("[DEBUG] getting AWS caller identity")
+	return a.S3Control.AccountID()
+}
+
+// GetAccountPublicAccessBlock returns the account level S3 Block Public Access settings, or nil if none are set
+func (a *AWSConnection) GetAccountPublicAccessBlock() (*s3control.PublicAccessBlockConfiguration, error) {
+	log.Printf("[DEBUG] getting account level S3 Block Public Access settings")
+	return a.S3Control.GetPublicAccessBlock()
+}
+
+// ListBuckets returns the names of all buckets owned by the caller
+func (a *AWSConnection) ListBuckets() ([]string, error) {
+	log.Printf("[DEBUG] listing S3 Buckets")
+	return a.S3.ListBuckets()
+}
+
+// CreateBucket creates a bucket in the configured region
+func (a *AWSConnection) CreateBucket(bucketName string) error {
+	log.Printf("[DEBUG] creating S3 Bucket '%s'", bucketName)
+	return a.S3.CreateBucket(bucketName)
+}
+
+// CreateBucketWithObjectLock creates a bucket in the configured region with Object Lock, and therefore versioning, enabled
+func (a *AWSConnection) CreateBucketWithObjectLock(bucketName string) error {
+	log.Printf("[DEBUG] creating S3 Bucket '%s' with Object Lock enabled", bucketName)
+	return a.S3.CreateBucketWithObjectLock(bucketName)
+}
+
+// DeleteBucket deletes a bucket and all object versions within it
+func (a *AWSConnection) DeleteBucket(bucketName string) error {
+	log.Printf("[DEBUG] deleting S3 Bucket '%s'", bucketName)
+	return a.S3.DeleteBucket(bucketName)
+}
+
+// GetBucketPolicy returns the policy document of a bucket, or an empty string if the bucket has no policy
+func (a *AWSConnection) GetBucketPolicy(bucketName string) (string, error) {
+	log.Printf("[DEBUG] getting policy of S3 Bucket '%s'", bucketName)
+	return a.S3.GetBucketPolicy(bucketName)
+}
+
+// PutBucketPolicy sets the policy document of a bucket
+func (a *AWSConnection) PutBucketPolicy(bucketName, policy string) error {
+	log.Printf("[DEBUG] setting policy of S3 Bucket '%s'", bucketName)
+	return a.S3.PutBucketPolicy(bucketName, policy)
+}
+
+// GetPublicAccessBlock returns the Block Public Access settings of a bucket, or nil if none are set
+func (a *AWSConnection) GetPublicAccessBlock(bucketName string) (*s3.PublicAccessBlockConfiguration, error) {
+	log.Printf("[DEBUG] getting Block Public Access settings of S3 Bucket '%s'", bucketName)
+	return a.S3.GetPublicAccessBlock(bucketName)
+}
+
+// PutPublicAccessBlock sets the Block Public Access settings of a bucket
+func (a *AWSConnection) PutPublicAccessBlock(bucketName string, configuration *s3.PublicAccessBlockConfiguration) error {
+	log.Printf("[DEBUG] setting Block Public Access settings of S3 Bucket '%s'", bucketName)
+	return a.S3.PutPublicAccessBlock(bucketName, configuration)
+}
+
+// GetBucketObjectOwnership returns the object ownership setting of a bucket, or an empty string if none is set
+func (a *AWSConnection) GetBucketObjectOwnership(bucketName string) (string, error) {
+	log.Printf("[DEBUG] getting object ownership of S3 Bucket '%s'", bucketName)
+	return a.S3.GetBucketObjectOwnership(bucketName)
+}
+
+// PutBucketACL applies a canned ACL, such as public-read, to a bucket
+func (a *AWSConnection) PutBucketACL(bucketName, acl string) error {
+	log.Printf("[DEBUG] applying ACL '%s' to S3 Bucket '%s'", acl, bucketName)
+	return a.S3.PutBucketACL(bucketName, acl)
+}
+
+// GetBucketVersioning returns the versioning and MFA delete status of a bucket
+func (a *AWSConnection) GetBucketVersioning(bucketName string) (*s3.GetBucketVersioningOutput, error) {
+	log.Printf("[DEBUG] getting versioning of S3 Bucket '%s'", bucketName)
+	return a.S3.GetBucketVersioning(bucketName)
+}
+
+// GetObjectLockConfiguration returns the Object Lock configuration of a bucket, or nil if Object Lock is not enabled
+func (a *AWSConnection) GetObjectLockConfiguration(bucketName string) (*s3.ObjectLockConfiguration, error) {
+	log.Printf("[DEBUG] getting Object Lock configuration of S3 Bucket '%s'", bucketName)
+	return a.S3.GetObjectLockConfiguration(bucketName)
+}
+
+// GetBucketEncryption returns the default encryption configuration of a bucket, or nil if the bucket has none
+func (a *AWSConnection) GetBucketEncryption(bucketName string) (*s3.ServerSideEncryptionConfiguration, error) {
+	log.Printf("[DEBUG] getting default encryption of S3 Bucket '%s'", bucketName)
+	return a.S3.GetBucketEncryption(bucketName)
+}
+
+// PutBucketEncryption sets the default encryption of a bucket. The KMS key is only used with the aws:kms algorithm, and may be empty to use the AWS managed key.
+func (a *AWSConnection) PutBucketEncryption(bucketName, algorithm, kmsKeyID string) error {
+	log.Printf("[DEBUG] setting default encryption of S3 Bucket '%s' to '%s'", bucketName, algorithm)
+	return a.S3.PutBucketEncryption(bucketName, algorithm, kmsKeyID)
+}
+
+// PutObject uploads an object to a bucket
+func (a *AWSConnection) PutObject(bucketName, key string, content []byte) error {
+	log.Printf("[DEBUG] uploading object '%s' to S3 Bucket '%s'", key, bucketName)
+	return a.S3.PutObject(bucketName, key, content)
+}
+
+// PutObjectWithEncryption uploads an object to a bucket with the given server side encryption headers. An empty algorithm sends no encryption headers.
+func (a *AWSConnection) PutObjectWithEncryption(bucketName, key string, content []byte, algorithm, kmsKeyID string) error {
+	log.Printf("[DEBUG] uploading object '%s' to S3 Bucket '%s' with server side encryption '%s'", key, bucketName, algorithm)
+	return a.S3.PutObjectWithEncryption(bucketName, key, content, algorithm, kmsKeyID)
+}
+
+// PutObjectWithRetention uploads an object to a bucket with Object Lock enabled, locked in the given mode until the given time, and returns its version ID
+func (a *AWSConnection) PutObjectWithRetention(bucketName, key string, content []byte, mode string, retainUntil time.Time) (string, error) {
+	log.Printf("[DEBUG] uploading object '%s' to S3 Bucket '%s' with '%s' retention until %s", key, bucketName, mode, retainUntil)
+	return a.S3.PutObjectWithRetention(bucketName, key, content, mode, retainUntil)
+}
+
+// GetObject downloads the content of an object
+func (a *AWSConnection) GetObject(bucketName, key string) ([]byte, error) {
+	log.Printf("[DEBUG] downloading object '%s' from S3 Bucket '%s'", key, bucketName)
+	return a.S3.GetObject(bucketName, key)
+}
+
+// DeleteObjectVersion permanently deletes a version of an object
+func (a *AWSConnection) DeleteObjectVersion(bucketName, key, versionID string) error {
+	log.Printf("[DEBUG] deleting version '%s' of object '%s' from S3 Bucket '%s'", versionID, key, bucketName)
+	return a.S3.DeleteObjectVersion(bucketName, key, versionID)
+}
+
+// GetObjectOverHTTP requests an object from a bucket over plain HTTP rather than TLS
+func (a *AWSConnection) GetObjectOverHTTP(bucketName, key string) error {
+	log.Printf("[DEBUG] requesting object '%s' from S3 Bucket '%s' over HTTP", key, bucketName)
+	return a.S3.GetObjectOverHTTP(bucketName, key)
+}
+
+// KMSKeyARN resolves a KMS key ID, key ARN or alias to the ARN of the key
+func (a *AWSConnection) KMSKeyARN(keyID string) (string, error) {
+	log.Printf("[DEBUG] resolving KMS key '%s'", keyID)
+	return a.KMS.KeyARN(keyID)
+}
@@ -2,11 +2,15 @@ package connection
 
 import (
 	"context"
+	"io/ioutil"
 	"log"
 	"sync"
 
+	"github.com/Azure/azure-sdk-for-go/services/preview/policyinsights/mgmt/2019-10-01-preview/policyinsights"
 	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2018-02-01/resources"
-	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-04-01/storage"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-09-01/policy"
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/azure/auth"
 	"github.com/citihub/probr-sdk/utils"
@@ -20,11 +24,16 @@ type AzureCredentials struct {
 
 // AzureConnection simplifies the connection with cloud provider
 type AzureConnection struct {
-	isCloudAvailable error
-	ctx              context.Context
-	credentials      AzureCredentials
-	ResourceGroup    *AzureResourceGroup  // Client obj to interact with Azure Resource Groups
-	StorageAccount   *AzureStorageAccount // Client obj to interact with Azure Storage Accounts
+	isCloudAvailable  error
+	ctx               context.Context
+	credentials       AzureCredentials
+	ResourceGroup     *AzureResourceGroup     // Client obj to interact with Azure Resource Groups
+	StorageAccount    *AzureStorageAccount    // Client obj to interact with Azure Storage Accounts
+	BlobService       *AzureBlobService       // Client obj to interact with Azure Blob Services and Containers
+	ObjectReplication *AzureObjectReplication // Client obj to interact with Azure Object Replication Policies
+	Inventory         *AzureInventory         // Client obj to query Azure Resource Graph for storage accounts across subscriptions
+	Policy            *AzurePolicy            // Client obj to interact with Azure Policy assignments and definitions
+	PolicyInsights    *AzurePolicyInsights    // Client obj to interact with Azure Policy compliance states
 }
 
 // Azure interface defining all azure methods
@@ -32,7 +41,30 @@ type Azure interface {
 	IsCloudAvailable() error
 	GetResourceGroupByName(name string) (resources.Group, error)
 	CreateStorageAccount(accountName, accountGroupName string, tags map[string]*string, httpsOnly bool, networkRuleSet *storage.NetworkRuleSet) (storage.Account, error)
+	CreateStorageAccountWithParameters(accountName, accountGroupName string, parameters storage.AccountCreateParameters) (storage.Account, error)
+	CreateStorageAccountWithBackend(creationMode, backend, accountName, accountGroupName string, parameters storage.AccountCreateParameters) (storage.Account, error)
 	DeleteStorageAccount(resourceGroupName, accountName string) error
+	GetStorageAccountProperties(resourceGroupName, accountName string) (storage.Account, error)
+	ListStorageAccounts() ([]storage.Account, error)
+	ListStorageAccountsByResourceGroup(resourceGroupName string) ([]storage.Account, error)
+	ListStorageAccountsInSubscriptions(subscriptionIDs []string) ([]InventoryItem, error)
+	GetBlobServiceProperties(resourceGroupName, accountName string) (storage.BlobServiceProperties, error)
+	SetBlobServiceProperties(resourceGroupName, accountName string, properties storage.BlobServiceProperties) (storage.BlobServiceProperties, error)
+	CreateBlobContainer(resourceGroupName, accountName, containerName string) (storage.BlobContainer, error)
+	EnableStaticWebsite(resourceGroupName, accountName, indexDocument string) error
+	UploadBlob(resourceGroupName, accountName, containerName, blobName, contentType string, content []byte) error
+	DownloadBlob(resourceGroupName, accountName, containerName, blobName string) ([]byte, error)
+	DownloadBlobOverHTTP(resourceGroupName, accountName, containerName, blobName string) error
+	ListPolicyAssignments(resourceGroupName, managementGroupID string) ([]policy.Assignment, error)
+	GetBuiltInPolicyDefinition(definitionName string) (policy.Definition, error)
+	GetPolicySetDefinition(setDefinitionID string) (policy.SetDefinition, error)
+	CreatePolicyDefinition(definitionName string, properties policy.DefinitionProperties) (policy.Definition, error)
+	DeletePolicyDefinition(definitionName string) error
+	CreatePolicyAssignment(scope, assignmentName, definitionID string, parameters map[string]*policy.ParameterValuesValue) (policy.Assignment, error)
+	DeletePolicyAssignment(scope, assignmentName string) error
+	TriggerPolicyEvaluation(resourceGroupName string) error
+	ListResourcePolicyStates(resourceID, policyDefinitionID string) ([]policyinsights.PolicyState, error)
+	CreateObjectReplicationPolicy(resourceGroupName, accountName, policyID string, policy storage.ObjectReplicationPolicy) (storage.ObjectReplicationPolicy, error)
 }
 
 var instance *AzureConnection
@@ -75,11 +107,51 @@ func NewAzureConnection(c context.Context, subscriptionID, tenantID, clientID, c
 			return
 		}
 
-		// Create an azure resource group client object via the connection config vars
+		// Create an azure storage account client object via the connection config vars
 		var saErr error
-		instance.StorageAccount, grpErr = NewStorageAccount(c, instance.credentials)
+		instance.StorageAccount, saErr = NewStorageAccount(c, instance.credentials)
 		if saErr != nil {
-			instance.isCloudAvailable = utils.ReformatError("Failed to initialize Azure Storage Account: %v", grpErr)
+			instance.isCloudAvailable = utils.ReformatError("Failed to initialize Azure Storage Account: %v", saErr)
+			return
+		}
+
+		// Create an azure blob service client object via the connection config vars
+		var bsErr error
+		instance.BlobService, bsErr = NewBlobService(c, instance.credentials)
+		if bsErr != nil {
+			instance.isCloudAvailable = utils.ReformatError("Failed to initialize Azure Blob Service: %v", bsErr)
+			return
+		}
+
+		// Create an azure object replication client object via the connection config vars
+		var orErr error
+		instance.ObjectReplication, orErr = NewObjectReplication(c, instance.credentials)
+		if orErr != nil {
+			instance.isCloudAvailable = utils.ReformatError("Failed to initialize Azure Object Replication: %v", orErr)
+			return
+		}
+
+		// Create an azure resource graph inventory object via the connection config vars
+		var invErr error
+		instance.Inventory, invErr = NewInventory(c, instance.credentials)
+		if invErr != nil {
+			instance.isCloudAvailable = utils.ReformatError("Failed to initialize Azure Resource Graph Inventory: %v", invErr)
+			return
+		}
+
+		// Create an azure policy client object via the connection config vars
+		var polErr error
+		instance.Policy, polErr = NewPolicy(c, instance.credentials)
+		if polErr != nil {
+			instance.isCloudAvailable = utils.ReformatError("Failed to initialize Azure Policy: %v", polErr)
+			return
+		}
+
+		// Create an azure policy insights client object via the connection config vars
+		var piErr error
+		instance.PolicyInsights, piErr = NewPolicyInsights(c, instance.credentials)
+		if piErr != nil {
+			instance.isCloudAvailable = utils.ReformatError("Failed to initialize Azure Policy Insights: %v", piErr)
 			return
 		}
 	})
@@ -103,8 +175,197 @@ func (az *AzureConnection) CreateStorageAccount(accountName, accountGroupName st
 	return az.StorageAccount.Create(accountName, accountGroupName, tags, httpsOnly, networkRuleSet)
 }
 
+// CreateStorageAccountWithParameters creates a storage account using the given create parameters
+func (az *AzureConnection) CreateStorageAccountWithParameters(accountName, accountGroupName string, parameters storage.AccountCreateParameters) (storage.Account, error) {
+	log.Printf("[DEBUG] creating Storage Account '%s'", accountName)
+	return az.StorageAccount.CreateWithParameters(accountName, accountGroupName, parameters)
+}
+
+// CreateStorageAccountWithBackend creates a storage account in the given creation mode, through the given creation backend: the storage SDK or an ARM template deployment
+func (az *AzureConnection) CreateStorageAccountWithBackend(creationMode, backend, accountName, accountGroupName string, parameters storage.AccountCreateParameters) (storage.Account, error) {
+	log.Printf("[DEBUG] creating Storage Account '%s' in '%s' creation mode using the '%s' creation backend", accountName, creationMode, backend)
+	return az.StorageAccount.CreateWithBackend(creationMode, backend, accountName, accountGroupName, parameters)
+}
+
 // DeleteStorageAccount deletes a storage account
 func (az *AzureConnection) DeleteStorageAccount(resourceGroupName, accountName string) error {
 	log.Printf("[DEBUG] deleting Storage Account '%s'", accountName)
 	return az.StorageAccount.Delete(resourceGroupName, accountName)
 }
+
+// GetStorageAccountProperties returns the full properties of an existing storage account
+func (az *AzureConnection) GetStorageAccountProperties(resourceGroupName, accountName string) (storage.Account, error) {
+	log.Printf("[DEBUG] getting Storage Account '%s'", accountName)
+	return az.StorageAccount.GetProperties(resourceGroupName, accountName)
+}
+
+// ListStorageAccounts returns all storage accounts within the subscription
+func (az *AzureConnection) ListStorageAccounts() ([]storage.Account, error) {
+	log.Printf("[DEBUG] listing Storage Accounts")
+	return az.StorageAccount.List()
+}
+
+// ListStorageAccountsByResourceGroup returns all storage accounts within a resource group
+func (az *AzureConnection) ListStorageAccountsByResourceGroup(resourceGroupName string) ([]storage.Account, error) {
+	log.Printf("[DEBUG] listing Storage Accounts in Resource Group '%s'", resourceGroupName)
+	return az.StorageAccount.ListByResourceGroup(resourceGroupName)
+}
+
+// ListStorageAccountsInSubscriptions returns all storage accounts within the given subscriptions using a single Azure Resource Graph inventory query
+func (az *AzureConnection) ListStorageAccountsInSubscriptions(subscriptionIDs []string) ([]InventoryItem, error) {
+	log.Printf("[DEBUG] listing Storage Accounts in %d Subscriptions", len(subscriptionIDs))
+	return az.Inventory.ListStorageAccounts(subscriptionIDs)
+}
+
+// GetBlobServiceProperties returns the blob service properties of a storage account
+func (az *AzureConnection) GetBlobServiceProperties(resourceGroupName, accountName string) (storage.BlobServiceProperties, error) {
+	log.Printf("[DEBUG] getting Blob Service properties for Storage Account '%s'", accountName)
+	return az.BlobService.GetProperties(resourceGroupName, accountName)
+}
+
+// SetBlobServiceProperties sets the blob service properties of a storage account
+func (az *AzureConnection) SetBlobServiceProperties(resourceGroupName, accountName string, properties storage.BlobServiceProperties) (storage.BlobServiceProperties, error) {
+	log.Printf("[DEBUG] setting Blob Service properties for Storage Account '%s'", accountName)
+	return az.BlobService.SetProperties(resourceGroupName, accountName, properties)
+}
+
+// CreateBlobContainer creates a private blob container in a storage account
+func (az *AzureConnection) CreateBlobContainer(resourceGroupName, accountName, containerName string) (storage.BlobContainer, error) {
+	log.Printf("[DEBUG] creating Blob Container '%s'", containerName)
+	return az.BlobService.CreateContainer(resourceGroupName, accountName, containerName)
+}
+
+// EnableStaticWebsite enables static website hosting on a storage account through the blob service data plane
+func (az *AzureConnection) EnableStaticWebsite(resourceGroupName, accountName, indexDocument string) error {
+	log.Printf("[DEBUG] enabling static website on Storage Account '%s'", accountName)
+
+	serviceURL, err := az.StorageAccount.GetBlobServiceURL(resourceGroupName, accountName)
+	if err != nil {
+		return err
+	}
+
+	_, err = serviceURL.SetProperties(az.ctx, azblob.StorageServiceProperties{
+		StaticWebsite: &azblob.StaticWebsite{
+			Enabled:       true,
+			IndexDocument: &indexDocument,
+		},
+	})
+	return err
+}
+
+// UploadBlob uploads content to a blob through the blob service data plane
+func (az *AzureConnection) UploadBlob(resourceGroupName, accountName, containerName, blobName, contentType string, content []byte) error {
+	log.Printf("[DEBUG] uploading Blob '%s' to Container '%s'", blobName, containerName)
+
+	serviceURL, err := az.StorageAccount.GetBlobServiceURL(resourceGroupName, accountName)
+	if err != nil {
+		return err
+	}
+
+	blockBlobURL := serviceURL.NewContainerURL(containerName).NewBlockBlobURL(blobName)
+	_, err = azblob.UploadBufferToBlockBlob(az.ctx, content, blockBlobURL, azblob.UploadToBlockBlobOptions{
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{
+			ContentType: contentType,
+		},
+	})
+	return err
+}
+
+// DownloadBlob downloads the content of a blob through the blob service data plane
+func (az *AzureConnection) DownloadBlob(resourceGroupName, accountName, containerName, blobName string) ([]byte, error) {
+	log.Printf("[DEBUG] downloading Blob '%s' from Container '%s'", blobName, containerName)
+
+	serviceURL, err := az.StorageAccount.GetBlobServiceURL(resourceGroupName, accountName)
+	if err != nil {
+		return nil, err
+	}
+
+	blobURL := serviceURL.NewContainerURL(containerName).NewBlobURL(blobName)
+	response, err := blobURL.Download(az.ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return nil, err
+	}
+	body := response.Body(azblob.RetryReaderOptions{})
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// DownloadBlobOverHTTP requests a blob through the blob service data plane over plain HTTP rather than TLS
+func (az *AzureConnection) DownloadBlobOverHTTP(resourceGroupName, accountName, containerName, blobName string) error {
+	log.Printf("[DEBUG] downloading Blob '%s' from Container '%s' over plain HTTP", blobName, containerName)
+
+	serviceURL, err := az.StorageAccount.GetBlobServiceURLOverHTTP(resourceGroupName, accountName)
+	if err != nil {
+		return err
+	}
+
+	blobURL := serviceURL.NewContainerURL(containerName).NewBlobURL(blobName)
+	response, err := blobURL.Download(az.ctx, 0, azblob.CountToEnd, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	if err != nil {
+		return err
+	}
+	if closeErr := response.Body(azblob.RetryReaderOptions{}).Close(); closeErr != nil {
+		log.Printf("[ERROR] error closing blob body: %v", closeErr)
+	}
+	return nil
+}
+
+// CreateObjectReplicationPolicy creates an object replication policy on a storage account
+func (az *AzureConnection) CreateObjectReplicationPolicy(resourceGroupName, accountName, policyID string, policy storage.ObjectReplicationPolicy) (storage.ObjectReplicationPolicy, error) {
+	log.Printf("[DEBUG] creating Object Replication Policy on Storage Account '%s'", accountName)
+	return az.ObjectReplication.CreatePolicy(resourceGroupName, accountName, policyID, policy)
+}
+
+// ListPolicyAssignments returns the policy assignments applying to a resource group, including those inherited from its subscription and management groups
+func (az *AzureConnection) ListPolicyAssignments(resourceGroupName, managementGroupID string) ([]policy.Assignment, error) {
+	log.Printf("[DEBUG] listing Policy Assignments for Resource Group '%s'", resourceGroupName)
+	return az.Policy.ListAssignments(resourceGroupName, managementGroupID)
+}
+
+// GetBuiltInPolicyDefinition returns a built-in policy definition by name
+func (az *AzureConnection) GetBuiltInPolicyDefinition(definitionName string) (policy.Definition, error) {
+	log.Printf("[DEBUG] getting built-in Policy Definition '%s'", definitionName)
+	return az.Policy.GetBuiltInDefinition(definitionName)
+}
+
+// GetPolicySetDefinition returns a policy set (initiative) definition by resource ID
+func (az *AzureConnection) GetPolicySetDefinition(setDefinitionID string) (policy.SetDefinition, error) {
+	log.Printf("[DEBUG] getting Policy Set Definition '%s'", setDefinitionID)
+	return az.Policy.GetSetDefinition(setDefinitionID)
+}
+
+// CreatePolicyDefinition creates or updates a custom policy definition in the subscription
+func (az *AzureConnection) CreatePolicyDefinition(definitionName string, properties policy.DefinitionProperties) (policy.Definition, error) {
+	log.Printf("[DEBUG] creating Policy Definition '%s'", definitionName)
+	return az.Policy.CreateDefinition(definitionName, properties)
+}
+
+// DeletePolicyDefinition removes a custom policy definition from the subscription
+func (az *AzureConnection) DeletePolicyDefinition(definitionName string) error {
+	log.Printf("[DEBUG] deleting Policy Definition '%s'", definitionName)
+	return az.Policy.DeleteDefinition(definitionName)
+}
+
+// CreatePolicyAssignment assigns a policy definition at the given scope
+func (az *AzureConnection) CreatePolicyAssignment(scope, assignmentName, definitionID string, parameters map[string]*policy.ParameterValuesValue) (policy.Assignment, error) {
+	log.Printf("[DEBUG] creating Policy Assignment '%s' at scope '%s'", assignmentName, scope)
+	return az.Policy.CreateAssignment(scope, assignmentName, definitionID, parameters)
+}
+
+// DeletePolicyAssignment removes a policy assignment from the given scope
+func (az *AzureConnection) DeletePolicyAssignment(scope, assignmentName string) error {
+	log.Printf("[DEBUG] deleting Policy Assignment '%s' at scope '%s'", assignmentName, scope)
+	return az.Policy.DeleteAssignment(scope, assignmentName)
+}
+
+// TriggerPolicyEvaluation starts a policy compliance scan of a resource group and waits for it to complete
+func (az *AzureConnection) TriggerPolicyEvaluation(resourceGroupName string) error {
+	log.Printf("[DEBUG] triggering Policy evaluation for Resource Group '%s'", resourceGroupName)
+	return az.PolicyInsights.TriggerResourceGroupEvaluation(resourceGroupName)
+}
+
+// ListResourcePolicyStates returns the latest policy states of a resource for a policy definition
+func (az *AzureConnection) ListResourcePolicyStates(resourceID, policyDefinitionID string) ([]policyinsights.PolicyState, error) {
+	log.Printf("[DEBUG] listing Policy States for '%s'", resourceID)
+	return az.PolicyInsights.ListResourcePolicyStates(resourceID, policyDefinitionID)
+}
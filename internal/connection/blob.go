@@ -0,0 +1,65 @@
+package connection
+
+import (
+	"context"
+	"log"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// AzureBlobService ...
+type AzureBlobService struct {
+	ctx                    context.Context
+	credentials            AzureCredentials
+	azBlobServicesClient   storage.BlobServicesClient
+	azBlobContainersClient storage.BlobContainersClient
+}
+
+// NewBlobService provides a new instance of AzureBlobService
+func NewBlobService(c context.Context, creds AzureCredentials) (bs *AzureBlobService, err error) {
+
+	// Guard clause - context
+	if c == nil {
+		err = utils.ReformatError("Context instance cannot be nil")
+		return
+	}
+
+	// Guard clause - authorizer
+	if creds.Authorizer == nil {
+		err = utils.ReformatError("Authorizer instance cannot be nil")
+		return
+	}
+
+	bs = &AzureBlobService{
+		ctx:         c,
+		credentials: creds,
+	}
+
+	// Create azure blob service and container client objects via the connection config vars
+	bs.azBlobServicesClient = storage.NewBlobServicesClient(creds.SubscriptionID)
+	bs.azBlobServicesClient.Authorizer = creds.Authorizer
+
+	bs.azBlobContainersClient = storage.NewBlobContainersClient(creds.SubscriptionID)
+	bs.azBlobContainersClient.Authorizer = creds.Authorizer
+
+	return
+}
+
+// GetProperties returns the blob service properties of a storage account
+func (bs *AzureBlobService) GetProperties(resourceGroupName, accountName string) (storage.BlobServiceProperties, error) {
+	log.Printf("[DEBUG] getting Blob Service properties for Storage Account '%s'", accountName)
+	return bs.azBlobServicesClient.GetServiceProperties(bs.ctx, resourceGroupName, accountName)
+}
+
+// SetProperties sets the blob service properties of a storage account
+func (bs *AzureBlobService) SetProperties(resourceGroupName, accountName string, properties storage.BlobServiceProperties) (storage.BlobServiceProperties, error) {
+	log.Printf("[DEBUG] setting Blob Service properties for Storage Account '%s'", accountName)
+	return bs.azBlobServicesClient.SetServiceProperties(bs.ctx, resourceGroupName, accountName, properties)
+}
+
+// CreateContainer creates a private blob container in a storage account
+func (bs *AzureBlobService) CreateContainer(resourceGroupName, accountName, containerName string) (storage.BlobContainer, error) {
+	log.Printf("[DEBUG] creating Blob Container '%s' in Storage Account '%s'", containerName, accountName)
+	return bs.azBlobContainersClient.Create(bs.ctx, resourceGroupName, accountName, containerName, storage.BlobContainer{})
+}
@@ -0,0 +1,81 @@
+package connection
+
+import (
+	"log"
+	"net/url"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// ListKeys returns the access keys of a storage account
+func (sa *AzureStorageAccount) ListKeys(resourceGroupName, accountName string) (keys []string, err error) {
+
+	log.Printf("[DEBUG] listing keys of Storage Account '%s' from Resource Group '%s'", accountName, resourceGroupName)
+
+	result, listErr := sa.azStorageAccountClient.ListKeys(sa.ctx, resourceGroupName, accountName, "")
+	if listErr != nil {
+		err = listErr
+		return
+	}
+	if result.Keys == nil {
+		return
+	}
+	for _, key := range *result.Keys {
+		keys = append(keys, to.String(key.Value))
+	}
+	return
+}
+
+// GetBlobServiceURL returns a data plane client for the blob service of a storage account, authorized with the first account key
+func (sa *AzureStorageAccount) GetBlobServiceURL(resourceGroupName, accountName string) (serviceURL azblob.ServiceURL, err error) {
+	return sa.getBlobServiceURL(resourceGroupName, accountName, false)
+}
+
+// GetBlobServiceURLOverHTTP returns a data plane client for the blob service of a storage account, authorized with the first
+// account key, which sends requests over plain HTTP rather than TLS
+func (sa *AzureStorageAccount) GetBlobServiceURLOverHTTP(resourceGroupName, accountName string) (serviceURL azblob.ServiceURL, err error) {
+	return sa.getBlobServiceURL(resourceGroupName, accountName, true)
+}
+
+func (sa *AzureStorageAccount) getBlobServiceURL(resourceGroupName, accountName string, plainHTTP bool) (serviceURL azblob.ServiceURL, err error) {
+
+	account, getErr := sa.GetProperties(resourceGroupName, accountName)
+	if getErr != nil {
+		err = utils.ReformatError("Failed to get properties of storage account '%s': %v", accountName, getErr)
+		return
+	}
+	if account.AccountProperties == nil || account.AccountProperties.PrimaryEndpoints == nil || account.AccountProperties.PrimaryEndpoints.Blob == nil {
+		err = utils.ReformatError("Storage account '%s' does not expose a blob endpoint", accountName)
+		return
+	}
+
+	keys, keysErr := sa.ListKeys(resourceGroupName, accountName)
+	if keysErr != nil {
+		err = utils.ReformatError("Failed to list keys of storage account '%s': %v", accountName, keysErr)
+		return
+	}
+	if len(keys) == 0 {
+		err = utils.ReformatError("No keys available for storage account '%s'", accountName)
+		return
+	}
+
+	credential, credErr := azblob.NewSharedKeyCredential(accountName, keys[0])
+	if credErr != nil {
+		err = utils.ReformatError("Failed to create shared key credential for storage account '%s': %v", accountName, credErr)
+		return
+	}
+
+	blobURL, urlErr := url.Parse(*account.AccountProperties.PrimaryEndpoints.Blob)
+	if urlErr != nil {
+		err = utils.ReformatError("Invalid blob endpoint for storage account '%s': %v", accountName, urlErr)
+		return
+	}
+	if plainHTTP {
+		blobURL.Scheme = "http"
+	}
+
+	serviceURL = azblob.NewServiceURL(*blobURL, azblob.NewPipeline(credential, azblob.PipelineOptions{}))
+	return
+}
@@ -0,0 +1,211 @@
+package connection
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2020-06-01/resources"
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/citihub/probr-sdk/utils"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+)
+
+// AzureDeployment ...
+type AzureDeployment struct {
+	ctx                 context.Context
+	credentials         AzureCredentials
+	azDeploymentsClient resources.DeploymentsClient
+}
+
+// NewDeployment provides a new instance of AzureDeployment
+func NewDeployment(c context.Context, creds AzureCredentials) (d *AzureDeployment, err error) {
+
+	// Guard clause - context
+	if c == nil {
+		err = utils.ReformatError("Context instance cannot be nil")
+		return
+	}
+
+	// Guard clause - authorizer
+	if creds.Authorizer == nil {
+		err = utils.ReformatError("Authorizer instance cannot be nil")
+		return
+	}
+
+	d = &AzureDeployment{
+		ctx:         c,
+		credentials: creds,
+	}
+
+	// Create an azure deployments client object via the connection config vars
+	d.azDeploymentsClient = resources.NewDeploymentsClient(creds.SubscriptionID)
+	d.azDeploymentsClient.Authorizer = creds.Authorizer
+
+	return
+}
+
+// ValidateStorageAccount submits the storage account as an ARM deployment validation request. Azure Policy evaluates the
+// request for Deny effects, but nothing is provisioned. A denial is returned in the same form as a failed creation.
+func (d *AzureDeployment) ValidateStorageAccount(resourceGroupName, accountName string, parameters storage.AccountCreateParameters) (storage.Account, error) {
+
+	log.Printf("[DEBUG] validating deployment of Storage Account '%s'", accountName)
+
+	template, err := StorageAccountTemplate(accountName, parameters)
+	if err != nil {
+		return storage.Account{}, err
+	}
+
+	future, err := d.azDeploymentsClient.Validate(d.ctx, resourceGroupName, deploymentName(accountName), resources.Deployment{
+		Properties: &resources.DeploymentProperties{
+			Template: template,
+			Mode:     resources.DeploymentModeIncremental,
+		},
+	})
+	if err != nil {
+		return storage.Account{}, deploymentCallError(err)
+	}
+	if err = future.WaitForCompletionRef(d.ctx, d.azDeploymentsClient.Client); err != nil {
+		return storage.Account{}, deploymentCallError(err)
+	}
+	result, err := future.Result(d.azDeploymentsClient)
+	if err != nil {
+		return storage.Account{}, deploymentCallError(err)
+	}
+	if result.Error != nil {
+		return storage.Account{}, deploymentResponseError(result.Error)
+	}
+
+	return plannedAccount(accountName, parameters), nil
+}
+
+// WhatIfStorageAccount submits the storage account as an ARM what-if request. Azure Policy evaluates the request for
+// Deny effects, but nothing is provisioned. A denial is returned in the same form as a failed creation.
+func (d *AzureDeployment) WhatIfStorageAccount(resourceGroupName, accountName string, parameters storage.AccountCreateParameters) (storage.Account, error) {
+
+	log.Printf("[DEBUG] running what-if deployment of Storage Account '%s'", accountName)
+
+	template, err := StorageAccountTemplate(accountName, parameters)
+	if err != nil {
+		return storage.Account{}, err
+	}
+
+	future, err := d.azDeploymentsClient.WhatIf(d.ctx, resourceGroupName, deploymentName(accountName), resources.DeploymentWhatIf{
+		Properties: &resources.DeploymentWhatIfProperties{
+			Template: template,
+			Mode:     resources.DeploymentModeIncremental,
+		},
+	})
+	if err != nil {
+		return storage.Account{}, deploymentCallError(err)
+	}
+	if err = future.WaitForCompletionRef(d.ctx, d.azDeploymentsClient.Client); err != nil {
+		return storage.Account{}, deploymentCallError(err)
+	}
+	result, err := future.Result(d.azDeploymentsClient)
+	if err != nil {
+		return storage.Account{}, deploymentCallError(err)
+	}
+	if result.Error != nil {
+		return storage.Account{}, deploymentResponseError(result.Error)
+	}
+
+	return plannedAccount(accountName, parameters), nil
+}
+
+// DeployStorageAccount creates the storage account through an ARM template deployment to the resource group and waits for the
+// deployment to complete. A denial is returned in the same form as a failed creation via the storage SDK.
+func (d *AzureDeployment) DeployStorageAccount(resourceGroupName, accountName string, parameters storage.AccountCreateParameters) error {
+
+	log.Printf("[DEBUG] deploying Storage Account '%s' from template", accountName)
+
+	template, err := StorageAccountTemplate(accountName, parameters)
+	if err != nil {
+		return err
+	}
+
+	future, err := d.azDeploymentsClient.CreateOrUpdate(d.ctx, resourceGroupName, deploymentName(accountName), resources.Deployment{
+		Properties: &resources.DeploymentProperties{
+			Template: template,
+			Mode:     resources.DeploymentModeIncremental,
+		},
+	})
+	if err != nil {
+		return deploymentCallError(err)
+	}
+	if err = future.WaitForCompletionRef(d.ctx, d.azDeploymentsClient.Client); err != nil {
+		return deploymentCallError(err)
+	}
+
+	return nil
+}
+
+func deploymentName(accountName string) string {
+	return "probr-" + accountName
+}
+
+// plannedAccount describes the storage account that a successful validation would have created
+func plannedAccount(accountName string, parameters storage.AccountCreateParameters) storage.Account {
+	return storage.Account{
+		Name:     to.StringPtr(accountName),
+		Type:     to.StringPtr("Microsoft.Storage/storageAccounts"),
+		Location: parameters.Location,
+		Sku:      parameters.Sku,
+		Kind:     parameters.Kind,
+		Tags:     parameters.Tags,
+	}
+}
+
+// deploymentResponseError converts a deployment error response into the error a failed creation returns, surfacing a
+// policy denial nested in the error details as the top level error code
+func deploymentResponseError(response *resources.ErrorResponse) error {
+	code, message := to.String(response.Code), to.String(response.Message)
+	if denial := findPolicyDenial(response); denial != nil {
+		code, message = to.String(denial.Code), to.String(denial.Message)
+	}
+	return autorest.DetailedError{
+		Original:   &azure.ServiceError{Code: code, Message: message},
+		StatusCode: http.StatusBadRequest,
+		Message:    message,
+	}
+}
+
+func findPolicyDenial(response *resources.ErrorResponse) *resources.ErrorResponse {
+	if strings.EqualFold(to.String(response.Code), azureutil.PolicyDenialCode) {
+		return response
+	}
+	if response.Details == nil {
+		return nil
+	}
+	for i := range *response.Details {
+		if denial := findPolicyDenial(&(*response.Details)[i]); denial != nil {
+			return denial
+		}
+	}
+	return nil
+}
+
+// deploymentCallError surfaces a policy denial nested in the details of a failed deployment request as the top level error code
+func deploymentCallError(err error) error {
+	detailed, ok := err.(autorest.DetailedError)
+	if !ok {
+		return err
+	}
+	serviceErr, ok := detailed.Original.(*azure.ServiceError)
+	if !ok {
+		return err
+	}
+	for _, detail := range serviceErr.Details {
+		if code, _ := detail["code"].(string); strings.EqualFold(code, azureutil.PolicyDenialCode) {
+			message, _ := detail["message"].(string)
+			detailed.Original = &azure.ServiceError{Code: code, Message: message}
+			return detailed
+		}
+	}
+	return err
+}
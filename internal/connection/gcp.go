@@ -0,0 +1,149 @@
+package connection
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"cloud.google.com/go/iam"
+	"cloud.google.com/go/storage"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// GCPConnection simplifies the connection with cloud provider
+type GCPConnection struct {
+	isCloudAvailable error
+	ctx              context.Context
+	projectID        string
+	Storage          *GCPStorage // Client obj to interact with Cloud Storage buckets and objects
+}
+
+// GCP interface defining all gcp methods
+type GCP interface {
+	IsCloudAvailable() error
+	ProjectID() string
+	ProjectExists() error
+	ListBuckets() ([]string, error)
+	CreateBucket(bucketName string, attrs *storage.BucketAttrs) error
+	DeleteBucket(bucketName string) error
+	GetBucketAttrs(bucketName string) (*storage.BucketAttrs, error)
+	LockRetentionPolicy(bucketName string) error
+	GetBucketIAMPolicy(bucketName string) (*iam.Policy, error)
+	AddBucketIAMMember(bucketName, member, role string) error
+	PutObject(bucketName, key string, content []byte) error
+	GetObject(bucketName, key string) ([]byte, error)
+	GetObjectAttrs(bucketName, key string) (*storage.ObjectAttrs, error)
+	DeleteObject(bucketName, key string) error
+}
+
+var gcpInstance *GCPConnection
+var gcpOnce sync.Once
+
+// NewGCPConnection provides a singleton instance of GCPConnection. Credentials are read from Application Default
+// Credentials. If the STORAGE_EMULATOR_HOST environment variable is set, unauthenticated requests are sent to a
+// Cloud Storage emulator such as fake-gcs-server instead of GCP.
+func NewGCPConnection(c context.Context, projectID string) *GCPConnection {
+	gcpOnce.Do(func() {
+		gcpInstance = &GCPConnection{
+			ctx:       c,
+			projectID: projectID,
+		}
+
+		// Guard clause
+		if c == nil {
+			gcpInstance.isCloudAvailable = utils.ReformatError("Context instance cannot be nil")
+			return
+		}
+
+		// Create a Cloud Storage client object
+		var storageErr error
+		gcpInstance.Storage, storageErr = NewGCPStorage(c, projectID)
+		if storageErr != nil {
+			gcpInstance.isCloudAvailable = utils.ReformatError("Failed to initialize GCP Cloud Storage: %v", storageErr)
+			return
+		}
+	})
+	return gcpInstance
+}
+
+// IsCloudAvailable verifies that the connection instantiation did not report a failure
+func (g *GCPConnection) IsCloudAvailable() error {
+	return g.isCloudAvailable
+}
+
+// ProjectID returns the project in which buckets are listed and created
+func (g *GCPConnection) ProjectID() string {
+	return g.projectID
+}
+
+// ProjectExists verifies that the project exists and its buckets can be listed by the caller
+func (g *GCPConnection) ProjectExists() error {
+	log.Printf("[DEBUG] checking GCP project '%s'", g.projectID)
+	return g.Storage.ProjectExists()
+}
+
+// ListBuckets returns the names of all buckets in the project
+func (g *GCPConnection) ListBuckets() ([]string, error) {
+	log.Printf("[DEBUG] listing Cloud Storage Buckets")
+	return g.Storage.ListBuckets()
+}
+
+// CreateBucket creates a bucket in the project with the given attributes
+func (g *GCPConnection) CreateBucket(bucketName string, attrs *storage.BucketAttrs) error {
+	log.Printf("[DEBUG] creating Cloud Storage Bucket '%s'", bucketName)
+	return g.Storage.CreateBucket(bucketName, attrs)
+}
+
+// DeleteBucket deletes a bucket and all object versions within it
+func (g *GCPConnection) DeleteBucket(bucketName string) error {
+	log.Printf("[DEBUG] deleting Cloud Storage Bucket '%s'", bucketName)
+	return g.Storage.DeleteBucket(bucketName)
+}
+
+// GetBucketAttrs returns the attributes of a bucket
+func (g *GCPConnection) GetBucketAttrs(bucketName string) (*storage.BucketAttrs, error) {
+	log.Printf("[DEBUG] getting attributes of Cloud Storage Bucket '%s'", bucketName)
+	return g.Storage.GetBucketAttrs(bucketName)
+}
+
+// LockRetentionPolicy permanently locks the retention policy of a bucket, so that it cannot be removed or shortened
+func (g *GCPConnection) LockRetentionPolicy(bucketName string) error {
+	log.Printf("[DEBUG] locking retention policy of Cloud Storage Bucket '%s'", bucketName)
+	return g.Storage.LockRetentionPolicy(bucketName)
+}
+
+// GetBucketIAMPolicy returns the IAM policy of a bucket
+func (g *GCPConnection) GetBucketIAMPolicy(bucketName string) (*iam.Policy, error) {
+	log.Printf("[DEBUG] getting IAM policy of Cloud Storage Bucket '%s'", bucketName)
+	return g.Storage.GetBucketIAMPolicy(bucketName)
+}
+
+// AddBucketIAMMember grants a role to a member in the IAM policy of a bucket
+func (g *GCPConnection) AddBucketIAMMember(bucketName, member, role string) error {
+	log.Printf("[DEBUG] granting '%s' to '%s' on Cloud Storage Bucket '%s'", role, member, bucketName)
+	return g.Storage.AddBucketIAMMember(bucketName, member, role)
+}
+
+// PutObject uploads an object to a bucket
+func (g *GCPConnection) PutObject(bucketName, key string, content []byte) error {
+	log.Printf("[DEBUG] uploading object '%s' to Cloud Storage Bucket '%s'", key, bucketName)
+	return g.Storage.PutObject(bucketName, key, content)
+}
+
+// GetObject downloads the content of an object
+func (g *GCPConnection) GetObject(bucketName, key string) ([]byte, error) {
+	log.Printf("[DEBUG] downloading object '%s' from Cloud Storage Bucket '%s'", key, bucketName)
+	return g.Storage.GetObject(bucketName, key)
+}
+
+// GetObjectAttrs returns the attributes of an object, including the key it is encrypted with
+func (g *GCPConnection) GetObjectAttrs(bucketName, key string) (*storage.ObjectAttrs, error) {
+	log.Printf("[DEBUG] getting attributes of object '%s' in Cloud Storage Bucket '%s'", key, bucketName)
+	return g.Storage.GetObjectAttrs(bucketName, key)
+}
+
+// DeleteObject deletes the live version of an object
+func (g *GCPConnection) DeleteObject(bucketName, key string) error {
+	log.Printf("[DEBUG] deleting object '%s' from Cloud Storage Bucket '%s'", key, bucketName)
+	return g.Storage.DeleteObject(bucketName, key)
+}
@@ -0,0 +1,156 @@
+package connection
+
+import (
+	"context"
+	"io/ioutil"
+
+	"cloud.google.com/go/iam"
+	"cloud.google.com/go/storage"
+	"github.com/citihub/probr-sdk/utils"
+	"google.golang.org/api/iterator"
+)
+
+// GCPStorage ...
+type GCPStorage struct {
+	ctx       context.Context
+	projectID string
+	client    *storage.Client
+}
+
+// NewGCPStorage provides a new instance of GCPStorage
+func NewGCPStorage(c context.Context, projectID string) (s *GCPStorage, err error) {
+
+	// Guard clause - context
+	if c == nil {
+		err = utils.ReformatError("Context instance cannot be nil")
+		return
+	}
+
+	// Guard clause - project
+	if projectID == "" {
+		err = utils.ReformatError("GCP project ID cannot be empty")
+		return
+	}
+
+	s = &GCPStorage{
+		ctx:       c,
+		projectID: projectID,
+	}
+
+	// The client honours STORAGE_EMULATOR_HOST and skips authentication when it is set
+	s.client, err = storage.NewClient(c)
+
+	return
+}
+
+// ProjectExists verifies that the project exists by listing the first of its buckets
+func (s *GCPStorage) ProjectExists() error {
+	_, err := s.client.Buckets(s.ctx, s.projectID).Next()
+	if err == iterator.Done {
+		return nil
+	}
+	return err
+}
+
+// ListBuckets returns the names of all buckets in the project
+func (s *GCPStorage) ListBuckets() ([]string, error) {
+	var names []string
+	it := s.client.Buckets(s.ctx, s.projectID)
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, attrs.Name)
+	}
+	return names, nil
+}
+
+// CreateBucket creates a bucket in the project with the given attributes, which may be nil to use the defaults
+func (s *GCPStorage) CreateBucket(bucketName string, attrs *storage.BucketAttrs) error {
+	return s.client.Bucket(bucketName).Create(s.ctx, s.projectID, attrs)
+}
+
+// DeleteBucket deletes all object versions within a bucket, then the bucket
+func (s *GCPStorage) DeleteBucket(bucketName string) error {
+	bucket := s.client.Bucket(bucketName)
+	it := bucket.Objects(s.ctx, &storage.Query{Versions: true})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := bucket.Object(attrs.Name).Generation(attrs.Generation).Delete(s.ctx); err != nil {
+			return err
+		}
+	}
+	return bucket.Delete(s.ctx)
+}
+
+// GetBucketAttrs returns the attributes of a bucket
+func (s *GCPStorage) GetBucketAttrs(bucketName string) (*storage.BucketAttrs, error) {
+	return s.client.Bucket(bucketName).Attrs(s.ctx)
+}
+
+// LockRetentionPolicy permanently locks the retention policy of a bucket. The lock request must match the current
+// metageneration of the bucket, so that a policy changed since it was read is not locked by mistake.
+func (s *GCPStorage) LockRetentionPolicy(bucketName string) error {
+	bucket := s.client.Bucket(bucketName)
+	attrs, err := bucket.Attrs(s.ctx)
+	if err != nil {
+		return err
+	}
+	return bucket.If(storage.BucketConditions{MetagenerationMatch: attrs.MetaGeneration}).LockRetentionPolicy(s.ctx)
+}
+
+// GetBucketIAMPolicy returns the IAM policy of a bucket
+func (s *GCPStorage) GetBucketIAMPolicy(bucketName string) (*iam.Policy, error) {
+	return s.client.Bucket(bucketName).IAM().Policy(s.ctx)
+}
+
+// AddBucketIAMMember reads the IAM policy of a bucket, grants a role to a member and writes the policy back
+func (s *GCPStorage) AddBucketIAMMember(bucketName, member, role string) error {
+	handle := s.client.Bucket(bucketName).IAM()
+	policy, err := handle.Policy(s.ctx)
+	if err != nil {
+		return err
+	}
+	policy.Add(member, iam.RoleName(role))
+	return handle.SetPolicy(s.ctx, policy)
+}
+
+// PutObject uploads an object to a bucket
+func (s *GCPStorage) PutObject(bucketName, key string, content []byte) error {
+	writer := s.client.Bucket(bucketName).Object(key).NewWriter(s.ctx)
+	if _, err := writer.Write(content); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// GetObject downloads the content of an object
+func (s *GCPStorage) GetObject(bucketName, key string) ([]byte, error) {
+	reader, err := s.client.Bucket(bucketName).Object(key).NewReader(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+// GetObjectAttrs returns the attributes of an object
+func (s *GCPStorage) GetObjectAttrs(bucketName, key string) (*storage.ObjectAttrs, error) {
+	return s.client.Bucket(bucketName).Object(key).Attrs(s.ctx)
+}
+
+// DeleteObject deletes the live version of an object
+func (s *GCPStorage) DeleteObject(bucketName, key string) error {
+	return s.client.Bucket(bucketName).Object(key).Delete(s.ctx)
+}
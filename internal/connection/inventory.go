@@ -0,0 +1,160 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/Azure/azure-sdk-for-go/services/resourcegraph/mgmt/2019-04-01/resourcegraph"
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// storageAccountsQuery selects every storage account together with the properties evaluated by the detective probes
+const storageAccountsQuery = `Resources
+| where type =~ 'microsoft.storage/storageaccounts'
+| project id, name, type, location, kind, sku, tags, properties, resourceGroup, subscriptionId
+| order by id asc`
+
+const (
+	maxSubscriptionsPerQuery = 1000 // Limit imposed by Azure Resource Graph on the subscriptions of a single query
+	inventoryPageSize        = 1000 // Maximum number of rows returned by Azure Resource Graph per page
+)
+
+// ResourceGraphQuerier is the subset of the Azure Resource Graph client used by AzureInventory.
+// It allows the query layer to be replaced, e.g. by a fixture backed fake in tests.
+type ResourceGraphQuerier interface {
+	Resources(ctx context.Context, query resourcegraph.QueryRequest) (resourcegraph.QueryResponse, error)
+}
+
+// InventoryItem is a storage account returned by an inventory query
+type InventoryItem struct {
+	SubscriptionID string
+	ResourceGroup  string
+	Account        storage.Account
+}
+
+// AzureInventory queries Azure Resource Graph once for the storage accounts of many subscriptions
+type AzureInventory struct {
+	ctx     context.Context
+	querier ResourceGraphQuerier
+}
+
+// NewInventory provides a new instance of AzureInventory backed by Azure Resource Graph
+func NewInventory(c context.Context, creds AzureCredentials) (inv *AzureInventory, err error) {
+
+	// Guard clause - context
+	if c == nil {
+		err = utils.ReformatError("Context instance cannot be nil")
+		return
+	}
+
+	// Guard clause - authorizer
+	if creds.Authorizer == nil {
+		err = utils.ReformatError("Authorizer instance cannot be nil")
+		return
+	}
+
+	// Create an azure resource graph client object via the connection config vars
+	client := resourcegraph.New()
+	client.Authorizer = creds.Authorizer
+
+	inv = NewInventoryWithQuerier(c, client)
+	return
+}
+
+// NewInventoryWithQuerier provides a new instance of AzureInventory backed by the given querier
+func NewInventoryWithQuerier(c context.Context, querier ResourceGraphQuerier) *AzureInventory {
+	return &AzureInventory{
+		ctx:     c,
+		querier: querier,
+	}
+}
+
+// ListStorageAccounts returns all storage accounts within the given subscriptions, following result pages until exhausted
+func (inv *AzureInventory) ListStorageAccounts(subscriptionIDs []string) (items []InventoryItem, err error) {
+
+	log.Printf("[DEBUG] querying Azure Resource Graph for Storage Accounts in %d subscriptions", len(subscriptionIDs))
+
+	for start := 0; start < len(subscriptionIDs); start += maxSubscriptionsPerQuery {
+		end := start + maxSubscriptionsPerQuery
+		if end > len(subscriptionIDs) {
+			end = len(subscriptionIDs)
+		}
+		batch := subscriptionIDs[start:end]
+
+		var skipToken *string
+		for {
+			response, queryErr := inv.querier.Resources(inv.ctx, resourcegraph.QueryRequest{
+				Subscriptions: &batch,
+				Query:         to.StringPtr(storageAccountsQuery),
+				Options: &resourcegraph.QueryRequestOptions{
+					SkipToken:    skipToken,
+					Top:          to.Int32Ptr(inventoryPageSize),
+					ResultFormat: resourcegraph.ResultFormatObjectArray,
+				},
+			})
+			if queryErr != nil {
+				err = utils.ReformatError("Azure Resource Graph query failed: %v", queryErr)
+				return
+			}
+
+			pageItems, parseErr := parseInventoryRows(response.Data)
+			if parseErr != nil {
+				err = parseErr
+				return
+			}
+			items = append(items, pageItems...)
+
+			if response.SkipToken == nil || *response.SkipToken == "" {
+				break
+			}
+			skipToken = response.SkipToken
+		}
+	}
+
+	log.Printf("[DEBUG] Azure Resource Graph returned %d Storage Accounts", len(items))
+	return
+}
+
+// parseInventoryRows converts the object array returned by Azure Resource Graph into inventory items.
+// Each row uses the same property names as the storage account resource, so it can be unmarshalled directly into storage.Account.
+func parseInventoryRows(data interface{}) (items []InventoryItem, err error) {
+
+	raw, marshalErr := json.Marshal(data)
+	if marshalErr != nil {
+		err = utils.ReformatError("Failed to read Azure Resource Graph result: %v", marshalErr)
+		return
+	}
+
+	var rows []json.RawMessage
+	if unmarshalErr := json.Unmarshal(raw, &rows); unmarshalErr != nil {
+		err = utils.ReformatError("Unexpected Azure Resource Graph result format, expected an object array: %v", unmarshalErr)
+		return
+	}
+
+	for _, row := range rows {
+		var scope struct {
+			SubscriptionID string `json:"subscriptionId"`
+			ResourceGroup  string `json:"resourceGroup"`
+		}
+		if unmarshalErr := json.Unmarshal(row, &scope); unmarshalErr != nil {
+			err = utils.ReformatError("Failed to read Azure Resource Graph row: %v", unmarshalErr)
+			return
+		}
+
+		var account storage.Account
+		if unmarshalErr := json.Unmarshal(row, &account); unmarshalErr != nil {
+			err = utils.ReformatError("Failed to read storage account from Azure Resource Graph row: %v", unmarshalErr)
+			return
+		}
+
+		items = append(items, InventoryItem{
+			SubscriptionID: scope.SubscriptionID,
+			ResourceGroup:  scope.ResourceGroup,
+			Account:        account,
+		})
+	}
+	return
+}
@@ -0,0 +1,126 @@
+package connection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/resourcegraph/mgmt/2019-04-01/resourcegraph"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+// fakeResourceGraph serves Azure Resource Graph responses from fixture files, selected by skip token
+type fakeResourceGraph struct {
+	pages    map[string]string // Fixture file per skip token; the empty token is the first page
+	requests []resourcegraph.QueryRequest
+}
+
+func (f *fakeResourceGraph) Resources(ctx context.Context, query resourcegraph.QueryRequest) (response resourcegraph.QueryResponse, err error) {
+	f.requests = append(f.requests, query)
+
+	skipToken := ""
+	if query.Options != nil && query.Options.SkipToken != nil {
+		skipToken = *query.Options.SkipToken
+	}
+	fixture, ok := f.pages[skipToken]
+	if !ok {
+		err = fmt.Errorf("unexpected skip token '%s'", skipToken)
+		return
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(content, &response)
+	return
+}
+
+func TestAzureInventory_ListStorageAccounts(t *testing.T) {
+	tests := []struct {
+		name               string
+		subscriptionIDs    []string
+		pages              map[string]string
+		wantAccounts       []string
+		wantRequests       int
+		wantErr            bool
+		wantFirstHTTPSOnly bool
+	}{
+		{
+			name:            "TestCase1_MultiplePages_ShouldReturnAllAccounts",
+			subscriptionIDs: []string{"00000000-0000-0000-0000-000000000001", "00000000-0000-0000-0000-000000000002"},
+			pages: map[string]string{
+				"":      "resourcegraph_page1.json",
+				"page2": "resourcegraph_page2.json",
+			},
+			wantAccounts:       []string{"accountone", "accounttwo", "accountthree"},
+			wantRequests:       2,
+			wantFirstHTTPSOnly: true,
+		},
+		{
+			name:            "TestCase2_QueryError_ShouldReturnError",
+			subscriptionIDs: []string{"00000000-0000-0000-0000-000000000001"},
+			pages: map[string]string{
+				"": "missing.json",
+			},
+			wantRequests: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeResourceGraph{pages: tt.pages}
+			inv := NewInventoryWithQuerier(context.Background(), fake)
+
+			items, err := inv.ListStorageAccounts(tt.subscriptionIDs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListStorageAccounts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(fake.requests) != tt.wantRequests {
+				t.Errorf("ListStorageAccounts() made %d requests, want %d", len(fake.requests), tt.wantRequests)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var names []string
+			for _, item := range items {
+				names = append(names, to.String(item.Account.Name))
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.wantAccounts) {
+				t.Errorf("ListStorageAccounts() = %v, want %v", names, tt.wantAccounts)
+			}
+			if items[0].ResourceGroup != "rg-one" || items[0].SubscriptionID != tt.subscriptionIDs[0] {
+				t.Errorf("ListStorageAccounts() scope = %s/%s, want %s/rg-one", items[0].SubscriptionID, items[0].ResourceGroup, tt.subscriptionIDs[0])
+			}
+			properties := items[0].Account.AccountProperties
+			if properties == nil || to.Bool(properties.EnableHTTPSTrafficOnly) != tt.wantFirstHTTPSOnly {
+				t.Errorf("ListStorageAccounts() did not read account properties from result rows")
+			}
+		})
+	}
+}
+
+func TestAzureInventory_ListStorageAccountsBatchesSubscriptions(t *testing.T) {
+	var subscriptionIDs []string
+	for i := 0; i < maxSubscriptionsPerQuery+1; i++ {
+		subscriptionIDs = append(subscriptionIDs, fmt.Sprintf("%08d-0000-0000-0000-000000000000", i))
+	}
+
+	fake := &fakeResourceGraph{pages: map[string]string{"": "resourcegraph_page2.json"}}
+	inv := NewInventoryWithQuerier(context.Background(), fake)
+
+	_, err := inv.ListStorageAccounts(subscriptionIDs)
+	if err != nil {
+		t.Fatalf("ListStorageAccounts() error = %v", err)
+	}
+	if len(fake.requests) != 2 {
+		t.Fatalf("ListStorageAccounts() made %d requests, want 2", len(fake.requests))
+	}
+	if len(*fake.requests[0].Subscriptions) != maxSubscriptionsPerQuery || len(*fake.requests[1].Subscriptions) != 1 {
+		t.Errorf("ListStorageAccounts() did not split subscriptions into batches of %d", maxSubscriptionsPerQuery)
+	}
+}
@@ -0,0 +1,73 @@
+package connection
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// AWSKMS ...
+type AWSKMS struct {
+	ctx    context.Context
+	client kmsiface.KMSAPI
+	arns   map[string]string // Key ARNs already resolved, by the key ID, ARN or alias they were resolved from
+	mutex  sync.Mutex
+}
+
+// NewKMS provides a new instance of AWSKMS. If endpoint is empty the regional AWS endpoint is used.
+func NewKMS(c context.Context, sess *session.Session, endpoint string) (k *AWSKMS, err error) {
+
+	// Guard clause - context
+	if c == nil {
+		err = utils.ReformatError("Context instance cannot be nil")
+		return
+	}
+
+	// Guard clause - session
+	if sess == nil {
+		err = utils.ReformatError("Session instance cannot be nil")
+		return
+	}
+
+	k = &AWSKMS{
+		ctx:  c,
+		arns: make(map[string]string),
+	}
+
+	config := aws.NewConfig()
+	if endpoint != "" {
+		// S3 stand-ins which provide KMS, such as LocalStack, serve it on the same host
+		config = config.WithEndpoint(endpoint)
+	}
+
+	k.client = kms.New(sess, config)
+
+	return
+}
+
+// KeyARN returns the ARN of a KMS key given as a key ID, key ARN, alias name such as alias/aws/s3, or alias ARN
+func (k *AWSKMS) KeyARN(keyID string) (string, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if arn, ok := k.arns[keyID]; ok {
+		return arn, nil
+	}
+	output, err := k.client.DescribeKeyWithContext(k.ctx, &kms.DescribeKeyInput{
+		KeyId: aws.String(keyID),
+	})
+	if err != nil {
+		return "", err
+	}
+	if output.KeyMetadata == nil || aws.StringValue(output.KeyMetadata.Arn) == "" {
+		return "", utils.ReformatError("KMS key '%s' has no ARN", keyID)
+	}
+	arn := aws.StringValue(output.KeyMetadata.Arn)
+	k.arns[keyID] = arn
+	return arn, nil
+}
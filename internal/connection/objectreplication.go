@@ -0,0 +1,79 @@
+package connection
+
+import (
+	"context"
+	"log"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// AzureObjectReplication ...
+type AzureObjectReplication struct {
+	ctx                             context.Context
+	credentials                     AzureCredentials
+	azObjectReplicationPolicyClient storage.ObjectReplicationPoliciesClient
+}
+
+// NewObjectReplication provides a new instance of AzureObjectReplication
+func NewObjectReplication(c context.Context, creds AzureCredentials) (or *AzureObjectReplication, err error) {
+
+	// Guard clause - context
+	if c == nil {
+		err = utils.ReformatError("Context instance cannot be nil")
+		return
+	}
+
+	// Guard clause - authorizer
+	if creds.Authorizer == nil {
+		err = utils.ReformatError("Authorizer instance cannot be nil")
+		return
+	}
+
+	or = &AzureObjectReplication{
+		ctx:         c,
+		credentials: creds,
+	}
+
+	// Create an azure object replication policy client object via the connection config vars
+	or.azObjectReplicationPolicyClient = storage.NewObjectReplicationPoliciesClient(creds.SubscriptionID)
+	or.azObjectReplicationPolicyClient.Authorizer = creds.Authorizer
+
+	return
+}
+
+// NewObjectReplicationInTenant provides a new instance of AzureObjectReplication authorized against the given tenant and subscription,
+// used to manage the destination side of a replication policy to a storage account in another tenant. The client must be registered
+// as a multi-tenant application in both tenants.
+func NewObjectReplicationInTenant(c context.Context, subscriptionID, tenantID, clientID, clientSecret string) (or *AzureObjectReplication, err error) {
+
+	// Create an authorization object for the given tenant
+	clientCredentialsConfig := auth.NewClientCredentialsConfig(clientID, clientSecret, tenantID)
+	authorizer, authErr := clientCredentialsConfig.Authorizer()
+	if authErr != nil {
+		err = utils.ReformatError("Failed to initialize Azure Authorizer for tenant '%s': %v", tenantID, authErr)
+		return
+	}
+
+	return NewObjectReplication(c, AzureCredentials{
+		SubscriptionID: subscriptionID,
+		TenantID:       tenantID,
+		ClientID:       clientID,
+		ClientSecret:   clientSecret,
+		Authorizer:     authorizer,
+	})
+}
+
+// CreatePolicy creates or updates an object replication policy on the given (source or destination) storage account
+func (or *AzureObjectReplication) CreatePolicy(resourceGroupName, accountName, policyID string, policy storage.ObjectReplicationPolicy) (storage.ObjectReplicationPolicy, error) {
+	log.Printf("[DEBUG] creating Object Replication Policy '%s' on Storage Account '%s'", policyID, accountName)
+	return or.azObjectReplicationPolicyClient.CreateOrUpdate(or.ctx, resourceGroupName, accountName, policyID, policy)
+}
+
+// DeletePolicy deletes an object replication policy from the given (source or destination) storage account
+func (or *AzureObjectReplication) DeletePolicy(resourceGroupName, accountName, policyID string) error {
+	log.Printf("[DEBUG] deleting Object Replication Policy '%s' from Storage Account '%s'", policyID, accountName)
+	_, err := or.azObjectReplicationPolicyClient.Delete(or.ctx, resourceGroupName, accountName, policyID)
+	return err
+}
@@ -0,0 +1,172 @@
+package connection
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-09-01/policy"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// AzurePolicy ...
+type AzurePolicy struct {
+	ctx                      context.Context
+	credentials              AzureCredentials
+	azPolicyAssignmentClient policy.AssignmentsClient
+	azPolicyDefinitionClient policy.DefinitionsClient
+	azPolicySetClient        policy.SetDefinitionsClient
+}
+
+// NewPolicy provides a new instance of AzurePolicy
+func NewPolicy(c context.Context, creds AzureCredentials) (p *AzurePolicy, err error) {
+
+	// Guard clause - context
+	if c == nil {
+		err = utils.ReformatError("Context instance cannot be nil")
+		return
+	}
+
+	// Guard clause - authorizer
+	if creds.Authorizer == nil {
+		err = utils.ReformatError("Authorizer instance cannot be nil")
+		return
+	}
+
+	p = &AzurePolicy{
+		ctx:         c,
+		credentials: creds,
+	}
+
+	// Create azure policy assignment and definition client objects via the connection config vars
+	p.azPolicyAssignmentClient = policy.NewAssignmentsClient(creds.SubscriptionID)
+	p.azPolicyAssignmentClient.Authorizer = creds.Authorizer
+
+	p.azPolicyDefinitionClient = policy.NewDefinitionsClient(creds.SubscriptionID)
+	p.azPolicyDefinitionClient.Authorizer = creds.Authorizer
+
+	p.azPolicySetClient = policy.NewSetDefinitionsClient(creds.SubscriptionID)
+	p.azPolicySetClient.Authorizer = creds.Authorizer
+
+	return
+}
+
+// ListAssignments returns the policy assignments which apply to the given resource group.
+// Assignments are listed with the atScope() filter at subscription and resource group level,
+// which also returns assignments inherited from the management groups above the subscription.
+// If a management group is provided, assignments at that scope are listed explicitly as well.
+func (p *AzurePolicy) ListAssignments(resourceGroupName, managementGroupID string) (assignments []policy.Assignment, err error) {
+
+	log.Printf("[DEBUG] listing Policy Assignments applying to Resource Group '%s'", resourceGroupName)
+
+	seen := make(map[string]bool)
+	add := func(iterator policy.AssignmentListResultIterator) error {
+		for iterator.NotDone() {
+			assignment := iterator.Value()
+			if assignment.ID != nil && !seen[strings.ToLower(*assignment.ID)] {
+				seen[strings.ToLower(*assignment.ID)] = true
+				assignments = append(assignments, assignment)
+			}
+			if err := iterator.NextWithContext(p.ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	subscriptionIterator, listErr := p.azPolicyAssignmentClient.ListComplete(p.ctx, "atScope()")
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list policy assignments for subscription: %v", listErr)
+		return
+	}
+	if addErr := add(subscriptionIterator); addErr != nil {
+		err = utils.ReformatError("Failed to list policy assignments for subscription: %v", addErr)
+		return
+	}
+
+	resourceGroupIterator, listErr := p.azPolicyAssignmentClient.ListForResourceGroupComplete(p.ctx, resourceGroupName, "atScope()")
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list policy assignments for resource group '%s': %v", resourceGroupName, listErr)
+		return
+	}
+	if addErr := add(resourceGroupIterator); addErr != nil {
+		err = utils.ReformatError("Failed to list policy assignments for resource group '%s': %v", resourceGroupName, addErr)
+		return
+	}
+
+	if managementGroupID == "" {
+		return
+	}
+
+	managementGroupIterator, listErr := p.azPolicyAssignmentClient.ListForManagementGroupComplete(p.ctx, managementGroupID, "atScope()")
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list policy assignments for management group '%s': %v", managementGroupID, listErr)
+		return
+	}
+	if addErr := add(managementGroupIterator); addErr != nil {
+		err = utils.ReformatError("Failed to list policy assignments for management group '%s': %v", managementGroupID, addErr)
+		return
+	}
+
+	return
+}
+
+// GetBuiltInDefinition returns a built-in policy definition by name
+func (p *AzurePolicy) GetBuiltInDefinition(definitionName string) (policy.Definition, error) {
+	log.Printf("[DEBUG] getting built-in Policy Definition '%s'", definitionName)
+	return p.azPolicyDefinitionClient.GetBuiltIn(p.ctx, definitionName)
+}
+
+// GetSetDefinition returns a policy set (initiative) definition by resource ID. Built-in definitions and custom definitions
+// saved in a management group or in the subscription are supported.
+func (p *AzurePolicy) GetSetDefinition(setDefinitionID string) (policy.SetDefinition, error) {
+	log.Printf("[DEBUG] getting Policy Set Definition '%s'", setDefinitionID)
+
+	parts := strings.Split(strings.Trim(setDefinitionID, "/"), "/")
+	name := parts[len(parts)-1]
+	switch {
+	case len(parts) == 4 && strings.EqualFold(parts[0], "providers"):
+		return p.azPolicySetClient.GetBuiltIn(p.ctx, name)
+	case len(parts) == 8 && strings.EqualFold(parts[2], "managementGroups"):
+		return p.azPolicySetClient.GetAtManagementGroup(p.ctx, name, parts[3])
+	case len(parts) == 6 && strings.EqualFold(parts[0], "subscriptions"):
+		return p.azPolicySetClient.Get(p.ctx, name)
+	}
+	return policy.SetDefinition{}, utils.ReformatError("Unexpected policy set definition ID: '%s'", setDefinitionID)
+}
+
+// CreateDefinition creates or updates a custom policy definition in the subscription
+func (p *AzurePolicy) CreateDefinition(definitionName string, properties policy.DefinitionProperties) (policy.Definition, error) {
+	log.Printf("[DEBUG] creating Policy Definition '%s' in Subscription '%s'", definitionName, p.credentials.SubscriptionID)
+	return p.azPolicyDefinitionClient.CreateOrUpdate(p.ctx, definitionName, policy.Definition{
+		DefinitionProperties: &properties,
+	})
+}
+
+// DeleteDefinition removes a custom policy definition from the subscription
+func (p *AzurePolicy) DeleteDefinition(definitionName string) error {
+	log.Printf("[DEBUG] deleting Policy Definition '%s' from Subscription '%s'", definitionName, p.credentials.SubscriptionID)
+	_, err := p.azPolicyDefinitionClient.Delete(p.ctx, definitionName)
+	return err
+}
+
+// CreateAssignment assigns a policy definition at the given scope
+func (p *AzurePolicy) CreateAssignment(scope, assignmentName, definitionID string, parameters map[string]*policy.ParameterValuesValue) (policy.Assignment, error) {
+	log.Printf("[DEBUG] creating Policy Assignment '%s' of '%s' at scope '%s'", assignmentName, definitionID, scope)
+	return p.azPolicyAssignmentClient.Create(p.ctx, scope, assignmentName, policy.Assignment{
+		AssignmentProperties: &policy.AssignmentProperties{
+			DisplayName:        to.StringPtr(assignmentName),
+			PolicyDefinitionID: to.StringPtr(definitionID),
+			Parameters:         parameters,
+			EnforcementMode:    policy.Default,
+		},
+	})
+}
+
+// DeleteAssignment removes a policy assignment from the given scope
+func (p *AzurePolicy) DeleteAssignment(scope, assignmentName string) error {
+	log.Printf("[DEBUG] deleting Policy Assignment '%s' at scope '%s'", assignmentName, scope)
+	_, err := p.azPolicyAssignmentClient.Delete(p.ctx, scope, assignmentName)
+	return err
+}
@@ -0,0 +1,85 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/policyinsights/mgmt/2019-10-01-preview/policyinsights"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// AzurePolicyInsights ...
+type AzurePolicyInsights struct {
+	ctx                  context.Context
+	credentials          AzureCredentials
+	azPolicyStatesClient policyinsights.PolicyStatesClient
+}
+
+// NewPolicyInsights provides a new instance of AzurePolicyInsights
+func NewPolicyInsights(c context.Context, creds AzureCredentials) (pi *AzurePolicyInsights, err error) {
+
+	// Guard clause - context
+	if c == nil {
+		err = utils.ReformatError("Context instance cannot be nil")
+		return
+	}
+
+	// Guard clause - authorizer
+	if creds.Authorizer == nil {
+		err = utils.ReformatError("Authorizer instance cannot be nil")
+		return
+	}
+
+	pi = &AzurePolicyInsights{
+		ctx:         c,
+		credentials: creds,
+	}
+
+	// Create an azure policy states client object via the connection config vars
+	pi.azPolicyStatesClient = policyinsights.NewPolicyStatesClient()
+	pi.azPolicyStatesClient.Authorizer = creds.Authorizer
+
+	return
+}
+
+// TriggerResourceGroupEvaluation starts a policy compliance scan of the given resource group and waits for it to complete
+func (pi *AzurePolicyInsights) TriggerResourceGroupEvaluation(resourceGroupName string) error {
+
+	log.Printf("[DEBUG] triggering Policy compliance evaluation for Resource Group '%s'", resourceGroupName)
+
+	future, err := pi.azPolicyStatesClient.TriggerResourceGroupEvaluation(pi.ctx, pi.credentials.SubscriptionID, resourceGroupName)
+	if err != nil {
+		return utils.ReformatError("Failed to trigger policy evaluation for resource group '%s': %v", resourceGroupName, err)
+	}
+
+	err = future.WaitForCompletionRef(pi.ctx, pi.azPolicyStatesClient.Client)
+	if err != nil {
+		return utils.ReformatError("Failed to complete policy evaluation for resource group '%s': %v", resourceGroupName, err)
+	}
+
+	return nil
+}
+
+// ListResourcePolicyStates returns the latest policy states of a resource for the given policy definition
+func (pi *AzurePolicyInsights) ListResourcePolicyStates(resourceID, policyDefinitionID string) ([]policyinsights.PolicyState, error) {
+
+	log.Printf("[DEBUG] listing Policy States of '%s' for Policy Definition '%s'", resourceID, policyDefinitionID)
+
+	var states []policyinsights.PolicyState
+
+	filter := fmt.Sprintf("policyDefinitionId eq '%s'", policyDefinitionID)
+	iterator, err := pi.azPolicyStatesClient.ListQueryResultsForResourceComplete(pi.ctx, policyinsights.Latest, resourceID, nil, "", "", nil, nil, filter, "", "", "")
+	if err != nil {
+		return states, utils.ReformatError("Failed to list policy states for resource '%s': %v", resourceID, err)
+	}
+
+	for iterator.NotDone() {
+		states = append(states, iterator.Value())
+		if err := iterator.NextWithContext(pi.ctx); err != nil {
+			return states, utils.ReformatError("Failed to list policy states for resource '%s': %v", resourceID, err)
+		}
+	}
+
+	return states, nil
+}
@@ -0,0 +1,360 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// AWSS3 ...
+type AWSS3 struct {
+	ctx            context.Context
+	region         string
+	client         s3iface.S3API
+	insecureClient s3iface.S3API // Sends requests over plain HTTP, to verify they are refused
+}
+
+// NewS3 provides a new instance of AWSS3. If endpoint is empty the regional AWS endpoint is used.
+func NewS3(c context.Context, sess *session.Session, endpoint string, forcePathStyle bool) (s *AWSS3, err error) {
+
+	// Guard clause - context
+	if c == nil {
+		err = utils.ReformatError("Context instance cannot be nil")
+		return
+	}
+
+	// Guard clause - session
+	if sess == nil {
+		err = utils.ReformatError("Session instance cannot be nil")
+		return
+	}
+
+	s = &AWSS3{
+		ctx:    c,
+		region: aws.StringValue(sess.Config.Region),
+	}
+
+	config := aws.NewConfig().WithS3ForcePathStyle(forcePathStyle)
+	insecureConfig := aws.NewConfig().WithS3ForcePathStyle(forcePathStyle).WithDisableSSL(true)
+	if endpoint != "" {
+		config = config.WithEndpoint(endpoint)
+		insecureConfig = insecureConfig.WithEndpoint(insecureEndpoint(endpoint))
+	}
+
+	s.client = s3.New(sess, config)
+	if endpoint == "" || isHTTPSEndpoint(endpoint) {
+		// A plain HTTP request is only distinguishable from a request over the configured endpoint if that endpoint uses TLS
+		s.insecureClient = s3.New(sess, insecureConfig)
+	}
+
+	return
+}
+
+// isHTTPSEndpoint returns whether an endpoint URL uses TLS
+func isHTTPSEndpoint(endpoint string) bool {
+	return strings.HasPrefix(strings.ToLower(endpoint), "https://")
+}
+
+// insecureEndpoint returns the plain HTTP form of an https endpoint URL
+func insecureEndpoint(endpoint string) string {
+	if isHTTPSEndpoint(endpoint) {
+		return "http://" + endpoint[len("https://"):]
+	}
+	return endpoint
+}
+
+// ListBuckets returns the names of all buckets owned by the caller
+func (s *AWSS3) ListBuckets() ([]string, error) {
+	output, err := s.client.ListBucketsWithContext(s.ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, bucket := range output.Buckets {
+		names = append(names, aws.StringValue(bucket.Name))
+	}
+	return names, nil
+}
+
+// CreateBucket creates a bucket in the region of the session
+func (s *AWSS3) CreateBucket(bucketName string) error {
+	return s.createBucket(bucketName, false)
+}
+
+// CreateBucketWithObjectLock creates a bucket in the region of the session with Object Lock enabled
+func (s *AWSS3) CreateBucketWithObjectLock(bucketName string) error {
+	return s.createBucket(bucketName, true)
+}
+
+func (s *AWSS3) createBucket(bucketName string, objectLock bool) error {
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(bucketName),
+	}
+	if objectLock {
+		input.ObjectLockEnabledForBucket = aws.Bool(true)
+	}
+	// us-east-1 is the default location and must not be given as a location constraint
+	if s.region != "" && s.region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+			LocationConstraint: aws.String(s.region),
+		}
+	}
+	_, err := s.client.CreateBucketWithContext(s.ctx, input)
+	return err
+}
+
+// DeleteBucket deletes all object versions and delete markers within a bucket, then the bucket itself.
+// Governance mode retention is bypassed, so the caller must be allowed s3:BypassGovernanceRetention to delete locked objects.
+func (s *AWSS3) DeleteBucket(bucketName string) error {
+	var deleteErr error
+	listErr := s.client.ListObjectVersionsPagesWithContext(s.ctx, &s3.ListObjectVersionsInput{Bucket: aws.String(bucketName)},
+		func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+			var objects []*s3.ObjectIdentifier
+			for _, version := range page.Versions {
+				objects = append(objects, &s3.ObjectIdentifier{Key: version.Key, VersionId: version.VersionId})
+			}
+			for _, marker := range page.DeleteMarkers {
+				objects = append(objects, &s3.ObjectIdentifier{Key: marker.Key, VersionId: marker.VersionId})
+			}
+			if len(objects) == 0 {
+				return true
+			}
+			var output *s3.DeleteObjectsOutput
+			output, deleteErr = s.client.DeleteObjectsWithContext(s.ctx, &s3.DeleteObjectsInput{
+				Bucket:                    aws.String(bucketName),
+				Delete:                    &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+				BypassGovernanceRetention: aws.Bool(true),
+			})
+			if deleteErr == nil {
+				deleteErr = deleteObjectsError(output)
+			}
+			return deleteErr == nil
+		})
+	if listErr != nil {
+		return listErr
+	}
+	if deleteErr != nil {
+		return deleteErr
+	}
+	_, err := s.client.DeleteBucketWithContext(s.ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucketName)})
+	return err
+}
+
+// deleteObjectsError returns an error listing the objects a DeleteObjects request failed to delete, which are reported
+// in the response rather than as a request error, or nil if every object was deleted
+func deleteObjectsError(output *s3.DeleteObjectsOutput) error {
+	if output == nil || len(output.Errors) == 0 {
+		return nil
+	}
+	failures := make([]string, 0, len(output.Errors))
+	for _, e := range output.Errors {
+		failures = append(failures, fmt.Sprintf("'%s' version '%s': %s %s", aws.StringValue(e.Key), aws.StringValue(e.VersionId), aws.StringValue(e.Code), aws.StringValue(e.Message)))
+	}
+	return utils.ReformatError("Failed to delete %d object version(s): %s", len(output.Errors), strings.Join(failures, "; "))
+}
+
+// GetBucketPolicy returns the policy document of a bucket, or an empty string if the bucket has no policy
+func (s *AWSS3) GetBucketPolicy(bucketName string) (string, error) {
+	output, err := s.client.GetBucketPolicyWithContext(s.ctx, &s3.GetBucketPolicyInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NoSuchBucketPolicy" {
+			return "", nil
+		}
+		return "", err
+	}
+	return aws.StringValue(output.Policy), nil
+}
+
+// PutBucketPolicy sets the policy document of a bucket
+func (s *AWSS3) PutBucketPolicy(bucketName, policy string) error {
+	_, err := s.client.PutBucketPolicyWithContext(s.ctx, &s3.PutBucketPolicyInput{
+		Bucket: aws.String(bucketName),
+		Policy: aws.String(policy),
+	})
+	return err
+}
+
+// GetPublicAccessBlock returns the Block Public Access settings of a bucket, or nil if none are set
+func (s *AWSS3) GetPublicAccessBlock(bucketName string) (*s3.PublicAccessBlockConfiguration, error) {
+	output, err := s.client.GetPublicAccessBlockWithContext(s.ctx, &s3.GetPublicAccessBlockInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NoSuchPublicAccessBlockConfiguration" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return output.PublicAccessBlockConfiguration, nil
+}
+
+// PutPublicAccessBlock sets the Block Public Access settings of a bucket
+func (s *AWSS3) PutPublicAccessBlock(bucketName string, configuration *s3.PublicAccessBlockConfiguration) error {
+	_, err := s.client.PutPublicAccessBlockWithContext(s.ctx, &s3.PutPublicAccessBlockInput{
+		Bucket:                         aws.String(bucketName),
+		PublicAccessBlockConfiguration: configuration,
+	})
+	return err
+}
+
+// GetBucketObjectOwnership returns the object ownership setting of a bucket, or an empty string if none is set
+func (s *AWSS3) GetBucketObjectOwnership(bucketName string) (string, error) {
+	output, err := s.client.GetBucketOwnershipControlsWithContext(s.ctx, &s3.GetBucketOwnershipControlsInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "OwnershipControlsNotFoundError" {
+			return "", nil
+		}
+		return "", err
+	}
+	if output.OwnershipControls == nil || len(output.OwnershipControls.Rules) == 0 {
+		return "", nil
+	}
+	return aws.StringValue(output.OwnershipControls.Rules[0].ObjectOwnership), nil
+}
+
+// PutBucketACL applies a canned ACL to a bucket
+func (s *AWSS3) PutBucketACL(bucketName, acl string) error {
+	_, err := s.client.PutBucketAclWithContext(s.ctx, &s3.PutBucketAclInput{
+		Bucket: aws.String(bucketName),
+		ACL:    aws.String(acl),
+	})
+	return err
+}
+
+// GetBucketVersioning returns the versioning and MFA delete status of a bucket
+func (s *AWSS3) GetBucketVersioning(bucketName string) (*s3.GetBucketVersioningOutput, error) {
+	return s.client.GetBucketVersioningWithContext(s.ctx, &s3.GetBucketVersioningInput{Bucket: aws.String(bucketName)})
+}
+
+// GetObjectLockConfiguration returns the Object Lock configuration of a bucket, or nil if Object Lock is not enabled
+func (s *AWSS3) GetObjectLockConfiguration(bucketName string) (*s3.ObjectLockConfiguration, error) {
+	output, err := s.client.GetObjectLockConfigurationWithContext(s.ctx, &s3.GetObjectLockConfigurationInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "ObjectLockConfigurationNotFoundError" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return output.ObjectLockConfiguration, nil
+}
+
+// GetBucketEncryption returns the default encryption configuration of a bucket, or nil if the bucket has none
+func (s *AWSS3) GetBucketEncryption(bucketName string) (*s3.ServerSideEncryptionConfiguration, error) {
+	output, err := s.client.GetBucketEncryptionWithContext(s.ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "ServerSideEncryptionConfigurationNotFoundError" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return output.ServerSideEncryptionConfiguration, nil
+}
+
+// PutBucketEncryption sets the default encryption of a bucket
+func (s *AWSS3) PutBucketEncryption(bucketName, algorithm, kmsKeyID string) error {
+	rule := &s3.ServerSideEncryptionByDefault{
+		SSEAlgorithm: aws.String(algorithm),
+	}
+	if algorithm == s3.ServerSideEncryptionAwsKms && kmsKeyID != "" {
+		rule.KMSMasterKeyID = aws.String(kmsKeyID)
+	}
+	_, err := s.client.PutBucketEncryptionWithContext(s.ctx, &s3.PutBucketEncryptionInput{
+		Bucket: aws.String(bucketName),
+		ServerSideEncryptionConfiguration: &s3.ServerSideEncryptionConfiguration{
+			Rules: []*s3.ServerSideEncryptionRule{{ApplyServerSideEncryptionByDefault: rule}},
+		},
+	})
+	return err
+}
+
+// PutObject uploads an object to a bucket
+func (s *AWSS3) PutObject(bucketName, key string, content []byte) error {
+	return s.PutObjectWithEncryption(bucketName, key, content, "", "")
+}
+
+// PutObjectWithEncryption uploads an object to a bucket with the given server side encryption headers
+func (s *AWSS3) PutObjectWithEncryption(bucketName, key string, content []byte, algorithm, kmsKeyID string) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(content),
+	}
+	if algorithm != "" {
+		input.ServerSideEncryption = aws.String(algorithm)
+	}
+	if algorithm == s3.ServerSideEncryptionAwsKms && kmsKeyID != "" {
+		input.SSEKMSKeyId = aws.String(kmsKeyID)
+	}
+	_, err := s.client.PutObjectWithContext(s.ctx, input)
+	return err
+}
+
+// PutObjectWithRetention uploads an object locked in the given mode until the given time, and returns its version ID
+func (s *AWSS3) PutObjectWithRetention(bucketName, key string, content []byte, mode string, retainUntil time.Time) (string, error) {
+	// Requests which set a retention period must include a Content-MD5 header
+	checksum := md5.Sum(content)
+	output, err := s.client.PutObjectWithContext(s.ctx, &s3.PutObjectInput{
+		Bucket:                    aws.String(bucketName),
+		Key:                       aws.String(key),
+		Body:                      bytes.NewReader(content),
+		ContentMD5:                aws.String(base64.StdEncoding.EncodeToString(checksum[:])),
+		ObjectLockMode:            aws.String(mode),
+		ObjectLockRetainUntilDate: aws.Time(retainUntil),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.VersionId), nil
+}
+
+// GetObject downloads the content of an object
+func (s *AWSS3) GetObject(bucketName, key string) ([]byte, error) {
+	output, err := s.client.GetObjectWithContext(s.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+	return ioutil.ReadAll(output.Body)
+}
+
+// DeleteObjectVersion permanently deletes a version of an object, without bypassing governance mode retention
+func (s *AWSS3) DeleteObjectVersion(bucketName, key, versionID string) error {
+	_, err := s.client.DeleteObjectWithContext(s.ctx, &s3.DeleteObjectInput{
+		Bucket:    aws.String(bucketName),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionID),
+	})
+	return err
+}
+
+// GetObjectOverHTTP requests an object from a bucket over plain HTTP rather than TLS. It fails if the configured endpoint does not use TLS.
+func (s *AWSS3) GetObjectOverHTTP(bucketName, key string) error {
+	if s.insecureClient == nil {
+		return utils.ReformatError("Plain HTTP requests cannot be verified, as the configured S3 endpoint does not use https")
+	}
+	output, err := s.insecureClient.GetObjectWithContext(s.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+	if closeErr := output.Body.Close(); closeErr != nil {
+		log.Printf("[ERROR] error closing object body: %v", closeErr)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package connection
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3control"
+	"github.com/aws/aws-sdk-go/service/s3control/s3controliface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// AWSS3Control ...
+type AWSS3Control struct {
+	ctx       context.Context
+	accountID string
+	stsClient stsiface.STSAPI
+	client    s3controliface.S3ControlAPI
+}
+
+// NewS3Control provides a new instance of AWSS3Control, for account level S3 settings. If endpoint is empty the regional AWS endpoints are used.
+func NewS3Control(c context.Context, sess *session.Session, endpoint string) (sc *AWSS3Control, err error) {
+
+	// Guard clause - context
+	if c == nil {
+		err = utils.ReformatError("Context instance cannot be nil")
+		return
+	}
+
+	// Guard clause - session
+	if sess == nil {
+		err = utils.ReformatError("Session instance cannot be nil")
+		return
+	}
+
+	sc = &AWSS3Control{
+		ctx: c,
+	}
+
+	config := aws.NewConfig()
+	if endpoint != "" {
+		// S3 stand-ins serve account level requests on the same host, without the account ID host prefix
+		config = config.WithEndpoint(endpoint).WithDisableEndpointHostPrefix(true)
+	}
+
+	sc.stsClient = sts.New(sess, config)
+	sc.client = s3control.New(sess, config)
+
+	return
+}
+
+// AccountID returns the ID of the account the caller belongs to
+func (sc *AWSS3Control) AccountID() (string, error) {
+	if sc.accountID != "" {
+		return sc.accountID, nil
+	}
+	output, err := sc.stsClient.GetCallerIdentityWithContext(sc.ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	sc.accountID = aws.StringValue(output.Account)
+	return sc.accountID, nil
+}
+
+// GetPublicAccessBlock returns the account level Block Public Access settings, or nil if none are set
+func (sc *AWSS3Control) GetPublicAccessBlock() (*s3control.PublicAccessBlockConfiguration, error) {
+	accountID, err := sc.AccountID()
+	if err != nil {
+		return nil, err
+	}
+	output, err := sc.client.GetPublicAccessBlockWithContext(sc.ctx, &s3control.GetPublicAccessBlockInput{AccountId: aws.String(accountID)})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3control.ErrCodeNoSuchPublicAccessBlockConfiguration {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return output.PublicAccessBlockConfiguration, nil
+}
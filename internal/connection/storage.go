@@ -4,7 +4,7 @@ import (
 	"context"
 	"log"
 
-	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-04-01/storage"
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/citihub/probr-pack-storage/internal/azure"
 	"github.com/citihub/probr-sdk/utils"
@@ -15,6 +15,7 @@ type AzureStorageAccount struct {
 	ctx                    context.Context
 	credentials            AzureCredentials
 	azStorageAccountClient storage.AccountsClient
+	deployment             *AzureDeployment
 }
 
 // NewStorageAccount provides a new instance of AzureStorageAccount
@@ -45,6 +46,14 @@ func NewStorageAccount(c context.Context, creds AzureCredentials) (sa *AzureStor
 		return
 	}
 
+	// Create an azure deployment object, used instead of the storage account client when accounts are not provisioned
+	var dErr error
+	sa.deployment, dErr = NewDeployment(c, creds)
+	if dErr != nil {
+		err = utils.ReformatError("Failed to initialize Azure Deployment client: %v", dErr)
+		return
+	}
+
 	return
 }
 
@@ -61,10 +70,44 @@ func (sa *AzureStorageAccount) getStorageAccountClient(creds AzureCredentials) (
 // Create starts creation of a new Storage Account and waits for the account to be created.
 func (sa *AzureStorageAccount) Create(accountName, accountGroupName string, tags map[string]*string, httpsOnly bool, networkRuleSet *storage.NetworkRuleSet) (storage.Account, error) {
 
-	log.Printf("[DEBUG] creating Storage Account '%s'", accountName)
+	return sa.CreateWithParameters(
+		accountName,
+		accountGroupName,
+		storage.AccountCreateParameters{
+			AccountPropertiesCreateParameters: &storage.AccountPropertiesCreateParameters{
+				EnableHTTPSTrafficOnly: to.BoolPtr(httpsOnly),
+				NetworkRuleSet:         networkRuleSet,
+			},
+			Tags: tags,
+		})
+}
+
+// CreateWithParameters starts creation of a new Storage Account using the given parameters and waits for the account to be created.
+// Sku, Kind and Location are defaulted when not provided.
+func (sa *AzureStorageAccount) CreateWithParameters(accountName, accountGroupName string, parameters storage.AccountCreateParameters) (storage.Account, error) {
+	return sa.CreateWithBackend(azure.CreationModeCreate, azure.CreationBackendSDK, accountName, accountGroupName, parameters)
+}
+
+// CreateWithBackend creates a new Storage Account through the given creation backend, either directly via the storage SDK
+// or via an ARM template deployment to the resource group, and waits for the account to be created.
+// Sku, Kind and Location are defaulted when not provided. If the given creation mode is validate or whatif, the account
+// is submitted as an ARM deployment request instead and is not provisioned. Deployment requests are always templates, so
+// azure.CreationBackends only returns the template backend in these modes.
+func (sa *AzureStorageAccount) CreateWithBackend(creationMode, backend, accountName, accountGroupName string, parameters storage.AccountCreateParameters) (storage.Account, error) {
+
+	log.Printf("[DEBUG] creating Storage Account '%s' in '%s' creation mode using the '%s' creation backend", accountName, creationMode, backend)
 
 	var storageAccount storage.Account
 
+	if backend != azure.CreationBackendSDK && backend != azure.CreationBackendTemplate {
+		err := utils.ReformatError("Unexpected value provided for creation backend: '%s' Expected values: ['%s', '%s']", backend, azure.CreationBackendSDK, azure.CreationBackendTemplate)
+		return storageAccount, err
+	}
+	if creationMode != azure.CreationModeCreate && creationMode != azure.CreationModeValidate && creationMode != azure.CreationModeWhatIf {
+		err := utils.ReformatError("Unexpected value provided for creation mode: '%s' Expected values: ['%s', '%s', '%s']", creationMode, azure.CreationModeCreate, azure.CreationModeValidate, azure.CreationModeWhatIf)
+		return storageAccount, err
+	}
+
 	checkNameResult, checkNameErr := sa.azStorageAccountClient.CheckNameAvailability(
 		sa.ctx,
 		storage.AccountCheckNameAvailabilityParameters{
@@ -80,23 +123,37 @@ func (sa *AzureStorageAccount) Create(accountName, accountGroupName string, tags
 		return storageAccount, err
 	}
 
-	networkRuleSetParam := &storage.AccountPropertiesCreateParameters{
-		EnableHTTPSTrafficOnly: to.BoolPtr(httpsOnly),
-		NetworkRuleSet:         networkRuleSet,
+	if parameters.Sku == nil {
+		parameters.Sku = &storage.Sku{
+			Name: storage.SkuNameStandardLRS}
+	}
+	if parameters.Kind == "" {
+		parameters.Kind = storage.KindStorageV2
+	}
+	if parameters.Location == nil {
+		parameters.Location = to.StringPtr(azure.ResourceLocation())
+	}
+
+	switch creationMode {
+	case azure.CreationModeValidate:
+		return sa.deployment.ValidateStorageAccount(accountGroupName, accountName, parameters)
+	case azure.CreationModeWhatIf:
+		return sa.deployment.WhatIfStorageAccount(accountGroupName, accountName, parameters)
+	}
+
+	if backend == azure.CreationBackendTemplate {
+		deployErr := sa.deployment.DeployStorageAccount(accountGroupName, accountName, parameters)
+		if deployErr != nil {
+			return storageAccount, deployErr
+		}
+		return sa.GetProperties(accountGroupName, accountName)
 	}
 
 	future, createErr := sa.azStorageAccountClient.Create(
 		sa.ctx,
 		accountGroupName,
 		accountName,
-		storage.AccountCreateParameters{
-			Sku: &storage.Sku{
-				Name: storage.StandardLRS},
-			Kind:                              storage.Storage,
-			Location:                          to.StringPtr(azure.ResourceLocation()),
-			AccountPropertiesCreateParameters: networkRuleSetParam,
-			Tags:                              tags,
-		})
+		parameters)
 	if createErr != nil {
 		return storageAccount, createErr
 	}
@@ -110,6 +167,58 @@ func (sa *AzureStorageAccount) Create(accountName, accountGroupName string, tags
 
 }
 
+// GetProperties returns the full properties of an existing storage account
+func (sa *AzureStorageAccount) GetProperties(resourceGroupName, accountName string) (storage.Account, error) {
+
+	log.Printf("[DEBUG] getting properties of Storage Account '%s' from Resource Group '%s'", accountName, resourceGroupName)
+
+	return sa.azStorageAccountClient.GetProperties(sa.ctx, resourceGroupName, accountName, "")
+}
+
+// List returns all storage accounts within the subscription
+func (sa *AzureStorageAccount) List() ([]storage.Account, error) {
+
+	log.Printf("[DEBUG] listing Storage Accounts in Subscription '%s'", sa.credentials.SubscriptionID)
+
+	var accounts []storage.Account
+
+	iterator, err := sa.azStorageAccountClient.ListComplete(sa.ctx)
+	if err != nil {
+		return accounts, err
+	}
+
+	for iterator.NotDone() {
+		accounts = append(accounts, iterator.Value())
+		if err := iterator.NextWithContext(sa.ctx); err != nil {
+			return accounts, err
+		}
+	}
+
+	return accounts, nil
+}
+
+// ListByResourceGroup returns all storage accounts within the given resource group
+func (sa *AzureStorageAccount) ListByResourceGroup(resourceGroupName string) ([]storage.Account, error) {
+
+	log.Printf("[DEBUG] listing Storage Accounts in Resource Group '%s'", resourceGroupName)
+
+	var accounts []storage.Account
+
+	iterator, err := sa.azStorageAccountClient.ListByResourceGroupComplete(sa.ctx, resourceGroupName)
+	if err != nil {
+		return accounts, err
+	}
+
+	for iterator.NotDone() {
+		accounts = append(accounts, iterator.Value())
+		if err := iterator.NextWithContext(sa.ctx); err != nil {
+			return accounts, err
+		}
+	}
+
+	return accounts, nil
+}
+
 // Delete deletes a storage account given the resource group and account name
 func (sa *AzureStorageAccount) Delete(resourceGroupName, accountName string) error {
 
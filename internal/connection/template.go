@@ -0,0 +1,37 @@
+package connection
+
+import (
+	"encoding/json"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+const (
+	deploymentTemplateSchema = "https://schema.management.azure.com/schemas/2019-04-01/deploymentTemplate.json#"
+	storageAccountAPIVersion = "2021-09-01"
+)
+
+// StorageAccountTemplate returns an ARM deployment template containing the storage account that CreateStorageAccount would create,
+// so that the same resource can be validated or deployed through Azure Resource Manager.
+func StorageAccountTemplate(accountName string, parameters storage.AccountCreateParameters) (map[string]interface{}, error) {
+
+	// The create parameters serialise to the same sku, kind, location, tags and properties as the resource body
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return nil, utils.ReformatError("Failed to serialise storage account create parameters: %v", err)
+	}
+	resource := make(map[string]interface{})
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, utils.ReformatError("Failed to serialise storage account create parameters: %v", err)
+	}
+	resource["type"] = "Microsoft.Storage/storageAccounts"
+	resource["apiVersion"] = storageAccountAPIVersion
+	resource["name"] = accountName
+
+	return map[string]interface{}{
+		"$schema":        deploymentTemplateSchema,
+		"contentVersion": "1.0.0.0",
+		"resources":      []interface{}{resource},
+	}, nil
+}
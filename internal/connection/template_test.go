@@ -0,0 +1,49 @@
+package connection
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+)
+
+func TestStorageAccountTemplate(t *testing.T) {
+	parameters := storage.AccountCreateParameters{
+		Sku:      &storage.Sku{Name: storage.SkuNameStandardLRS},
+		Kind:     storage.KindStorageV2,
+		Location: to.StringPtr("uksouth"),
+		AccountPropertiesCreateParameters: &storage.AccountPropertiesCreateParameters{
+			EnableHTTPSTrafficOnly: to.BoolPtr(false),
+		},
+	}
+
+	template, err := StorageAccountTemplate("probrtest", parameters)
+	if err != nil {
+		t.Fatalf("StorageAccountTemplate() error = %v", err)
+	}
+
+	resources, ok := template["resources"].([]interface{})
+	if !ok || len(resources) != 1 {
+		t.Fatalf("StorageAccountTemplate() resources = %v, want a single resource", template["resources"])
+	}
+	resource := resources[0].(map[string]interface{})
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"TestCase1_Type_ShouldBeStorageAccount", resource["type"], "Microsoft.Storage/storageAccounts"},
+		{"TestCase2_Name_ShouldBeAccountName", resource["name"], "probrtest"},
+		{"TestCase3_Location_ShouldBeCopied", resource["location"], "uksouth"},
+		{"TestCase4_Kind_ShouldBeCopied", resource["kind"], "StorageV2"},
+		{"TestCase5_HTTPSOnly_ShouldBeInProperties", resource["properties"].(map[string]interface{})["supportsHttpsTrafficOnly"], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("StorageAccountTemplate() = %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+// Package envvar reads the settings of the storage pack which are not yet available as config vars in the SDK.
+package envvar
+
+import (
+	"os"
+	"strings"
+)
+
+// GetOrDefault returns the value of an environment variable, or the default value if it is not set or empty
+func GetOrDefault(varName, defaultValue string) string {
+	v, b := os.LookupEnv(varName)
+	if !b || v == "" {
+		return defaultValue
+	}
+	return v
+}
+
+// SplitList returns the trimmed, non-empty items of a comma separated list
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
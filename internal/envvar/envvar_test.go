@@ -0,0 +1,50 @@
+package envvar
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestGetOrDefault(t *testing.T) {
+	os.Setenv("PROBR_ENVVAR_TEST", "value")
+	defer os.Unsetenv("PROBR_ENVVAR_TEST")
+	os.Setenv("PROBR_ENVVAR_TEST_EMPTY", "")
+	defer os.Unsetenv("PROBR_ENVVAR_TEST_EMPTY")
+
+	tests := []struct {
+		name    string
+		varName string
+		want    string
+	}{
+		{"TestCase1_SetVariable_ShouldReturnValue", "PROBR_ENVVAR_TEST", "value"},
+		{"TestCase2_EmptyVariable_ShouldReturnDefault", "PROBR_ENVVAR_TEST_EMPTY", "default"},
+		{"TestCase3_UnsetVariable_ShouldReturnDefault", "PROBR_ENVVAR_TEST_UNSET", "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetOrDefault(tt.varName, "default"); got != tt.want {
+				t.Errorf("GetOrDefault() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{"TestCase1_EmptyValue_ShouldReturnNoItems", "", nil},
+		{"TestCase2_SingleItem_ShouldReturnItem", "sdk", []string{"sdk"}},
+		{"TestCase3_SpacesAndEmptyItems_ShouldBeTrimmedAndSkipped", " sdk, ,template ,", []string{"sdk", "template"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SplitList(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+@s-gcpac
+Feature: Object Storage Can Only Be Accessed By Authorized Users
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that my organisation's data can only be accessed by authorized users
+
+    Background:
+      Given a GCP project is available
+      And the GCP project specified in config exists
+
+    @s-gcpac-001
+    Scenario: Detect Cloud Storage Buckets Which May Be Made Public
+      Then every Cloud Storage bucket enforces uniform bucket-level access and public access prevention and grants no roles to the public
+
+    @s-gcpac-002
+    Scenario: Enforce Public Access Prevention on New Cloud Storage Buckets
+      Given a Cloud Storage bucket is created with default settings
+      Then the bucket enforces uniform bucket-level access and public access prevention
+
+    @s-gcpac-003
+    Scenario Outline: Prevent Object Storage from Being Made Public
+      Given a Cloud Storage bucket is created with default settings
+      Then an attempt to grant "<Member>" the "roles/storage.objectViewer" role on the bucket "fails"
+
+      Examples:
+        | Member                |
+        | allUsers              |
+        | allAuthenticatedUsers |
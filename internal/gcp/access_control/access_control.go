@@ -0,0 +1,401 @@
+package gcpac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/cucumber/godog"
+	"google.golang.org/api/googleapi"
+
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	gcputil "github.com/citihub/probr-pack-storage/internal/gcp"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+type scenarioState struct {
+	name        string
+	currentStep string
+	audit       *audit.ScenarioAudit
+	probe       *audit.Probe
+	ctx         context.Context
+	bucketName  string
+	buckets     []string
+}
+
+// ProbeStruct meets the interface allowing this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+// bucketResult records the evaluation of the public access settings of a bucket
+type bucketResult struct {
+	BucketName   string
+	PublicAccess gcputil.PublicAccess
+	Error        string
+}
+
+// Probe meets the interface allowing this probe to be added to the ProbeStore
+var Probe probeStruct
+var scenario scenarioState       // Local container of scenario state
+var gcpConnection connection.GCP // Provides functionality to interact with GCP
+
+func (scenario *scenarioState) aGCPProjectIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that a Cloud Storage client can be created for GCP; "))
+
+	payload = struct {
+		ProjectID           string
+		StorageEmulatorHost string
+	}{
+		gcputil.ProjectID(),
+		gcputil.StorageEmulatorHost(),
+	}
+
+	err = gcpConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) theGCPProjectSpecifiedInConfigExists() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check if value for GCP project is set in config vars; ")
+	if gcputil.ProjectID() == "" {
+		err = utils.ReformatError("GCP project config var not set")
+		return err
+	}
+
+	stepTrace.WriteString("Check the project exists and its buckets can be listed; ")
+	existsErr := gcpConnection.ProjectExists()
+	if existsErr != nil {
+		err = utils.ReformatError("GCP project '%s' does not exist or is not accessible. Error: %v", gcputil.ProjectID(), existsErr)
+		return err
+	}
+
+	// Audit log
+	payload = struct {
+		ProjectID string
+	}{
+		ProjectID: gcputil.ProjectID(),
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) everyCloudStorageBucketEnforcesUniformBucketLevelAccessAndPublicAccessPreventionAndGrantsNoRolesToThePublic() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check that the probes do not run against a Cloud Storage emulator, which does not implement IAM policies, otherwise skip the scenario; ")
+	if err = gcputil.RequireCloudStorage(); err != nil {
+		return err
+	}
+
+	stepTrace.WriteString("List the Cloud Storage buckets in the project; ")
+	bucketNames, listErr := gcpConnection.ListBuckets()
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list Cloud Storage buckets: %v", listErr)
+		return err
+	}
+
+	stepTrace.WriteString("Evaluate the uniform bucket-level access, public access prevention and IAM policy of each bucket; ")
+	results := make([]bucketResult, 0, len(bucketNames))
+	var flagged []string
+	for _, bucketName := range bucketNames {
+		result := evaluateBucket(bucketName)
+		if !result.PublicAccess.Compliant {
+			flagged = append(flagged, bucketName)
+		}
+		results = append(results, result)
+	}
+
+	// Audit log
+	payload = struct {
+		Buckets []bucketResult
+	}{
+		Buckets: results,
+	}
+
+	if len(flagged) > 0 {
+		err = utils.ReformatError("%d of %d Cloud Storage buckets may be made public: %s", len(flagged), len(bucketNames), strings.Join(flagged, ", "))
+	}
+	return err
+}
+
+// evaluateBucket reads the attributes and IAM policy of a bucket and evaluates its public access settings
+func evaluateBucket(bucketName string) (result bucketResult) {
+	result.BucketName = bucketName
+
+	attrs, attrsErr := gcpConnection.GetBucketAttrs(bucketName)
+	if attrsErr != nil {
+		result.Error = attrsErr.Error()
+		return
+	}
+
+	policy, policyErr := gcpConnection.GetBucketIAMPolicy(bucketName)
+	if policyErr != nil {
+		result.Error = policyErr.Error()
+		return
+	}
+
+	result.PublicAccess = gcputil.EvaluatePublicAccess(attrs, policy)
+	return
+}
+
+func (scenario *scenarioState) aCloudStorageBucketIsCreatedWithDefaultSettings() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	bucketName := gcputil.BucketName()
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		Location   string
+	}{
+		BucketName: bucketName,
+		Location:   gcputil.Location(),
+	}
+
+	stepTrace.WriteString("Check that the probes do not run against a Cloud Storage emulator, which does not implement public access prevention or IAM policies, otherwise skip the scenario; ")
+	if err = gcputil.RequireCloudStorage(); err != nil {
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Create Cloud Storage bucket '%s' without public access settings, so that organization policy constraints and defaults apply; ", bucketName))
+	createErr := gcpConnection.CreateBucket(bucketName, gcputil.DefaultBucketAttrs())
+	if createErr != nil {
+		err = utils.ReformatError("Failed to create Cloud Storage bucket '%s': %v", bucketName, createErr)
+		return err
+	}
+	scenario.buckets = append(scenario.buckets, bucketName)
+	scenario.bucketName = bucketName
+
+	return nil
+}
+
+func (scenario *scenarioState) theBucketEnforcesUniformBucketLevelAccessAndPublicAccessPrevention() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString(fmt.Sprintf("Read the effective public access settings of Cloud Storage bucket '%s'; ", scenario.bucketName))
+	result := evaluateBucket(scenario.bucketName)
+
+	// Audit log
+	payload = struct {
+		Bucket bucketResult
+	}{
+		Bucket: result,
+	}
+
+	if result.Error != "" {
+		err = utils.ReformatError("Failed to read the public access settings of Cloud Storage bucket '%s': %s", scenario.bucketName, result.Error)
+	} else if !result.PublicAccess.Compliant {
+		err = utils.ReformatError("Cloud Storage bucket '%s' was created without uniform bucket-level access and public access prevention enforced", scenario.bucketName)
+	}
+	return err
+}
+
+func (scenario *scenarioState) anAttemptToGrantXTheYRoleOnTheBucketZ(member, role, expectedResult string) error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values
+	var shouldSucceed bool
+	switch expectedResult {
+	case "succeeds":
+		shouldSucceed = true
+	case "fails":
+		shouldSucceed = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	if !gcputil.IsPublicMember(member) {
+		err = utils.ReformatError("Unexpected value provided for member: '%s' Expected values: ['%s', '%s']", member, gcputil.AllUsers, gcputil.AllAuthenticatedUsers)
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Grant '%s' the '%s' role in the IAM policy of Cloud Storage bucket '%s'; ", member, role, scenario.bucketName))
+	grantErr := gcpConnection.AddBucketIAMMember(scenario.bucketName, member, role)
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		Member     string
+		Role       string
+		StatusCode int
+		Error      string
+	}{
+		BucketName: scenario.bucketName,
+		Member:     member,
+		Role:       role,
+		StatusCode: statusCode(grantErr),
+		Error:      fmt.Sprintf("%v", grantErr),
+	}
+
+	// Public access prevention refuses the policy with 412 Precondition Failed. Any other error, such as an
+	// emulator which does not implement IAM, does not show that the control is in place.
+	if shouldSucceed && grantErr != nil {
+		err = utils.ReformatError("Attempt to grant '%s' the '%s' role failed: %v", member, role, grantErr)
+	} else if !shouldSucceed && grantErr == nil {
+		err = utils.ReformatError("Attempt to grant '%s' the '%s' role succeeded, expected it to be refused", member, role)
+	} else if !shouldSucceed && statusCode(grantErr) != http.StatusPreconditionFailed {
+		err = utils.ReformatError("Attempt to grant '%s' the '%s' role failed with an unexpected error, expected it to be refused by public access prevention: %v", member, role, grantErr)
+	}
+	return err
+}
+
+// statusCode returns the HTTP status code of a failed Cloud Storage request, such as 412 Precondition Failed
+func statusCode(err error) int {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		return gerr.Code
+	}
+	return 0
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.bucketName = ""
+	s.buckets = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "access_control"
+}
+
+// Path returns the probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "gcp", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize gcp connection
+		gcpConnection = connection.NewGCPConnection(
+			context.Background(),
+			gcputil.ProjectID(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^a GCP project is available$`, scenario.aGCPProjectIsAvailable)
+	ctx.Step(`^the GCP project specified in config exists$`, scenario.theGCPProjectSpecifiedInConfigExists)
+
+	// Steps
+	ctx.Step(`^every Cloud Storage bucket enforces uniform bucket-level access and public access prevention and grants no roles to the public$`, scenario.everyCloudStorageBucketEnforcesUniformBucketLevelAccessAndPublicAccessPreventionAndGrantsNoRolesToThePublic)
+	ctx.Step(`^a Cloud Storage bucket is created with default settings$`, scenario.aCloudStorageBucketIsCreatedWithDefaultSettings)
+	ctx.Step(`^the bucket enforces uniform bucket-level access and public access prevention$`, scenario.theBucketEnforcesUniformBucketLevelAccessAndPublicAccessPrevention)
+	ctx.Step(`^an attempt to grant "([^"]*)" the "([^"]*)" role on the bucket "([^"]*)"$`, scenario.anAttemptToGrantXTheYRoleOnTheBucketZ)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing Cloud Storage buckets used during tests")
+
+	for _, bucketName := range scenario.buckets {
+		log.Printf("[DEBUG] need to delete the Cloud Storage bucket: %s", bucketName)
+		err := gcpConnection.DeleteBucket(bucketName)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the Cloud Storage bucket: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
@@ -0,0 +1,44 @@
+package gcp
+
+import (
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+// Encryption records the default encryption key of a bucket
+type Encryption struct {
+	DefaultKMSKeyName string // Empty when objects are encrypted with a Google-managed key
+	CustomerManaged   bool
+	ApprovedKey       bool
+	Compliant         bool
+}
+
+// EvaluateEncryption checks that a bucket has a customer-managed default key, and that the key is approved. Any
+// customer-managed key is approved when the approved list is empty.
+func EvaluateEncryption(attrs *storage.BucketAttrs, approvedKeys []string) Encryption {
+	var e Encryption
+	if attrs != nil && attrs.Encryption != nil {
+		e.DefaultKMSKeyName = attrs.Encryption.DefaultKMSKeyName
+	}
+	e.CustomerManaged = e.DefaultKMSKeyName != ""
+	e.ApprovedKey = e.CustomerManaged && IsApprovedKey(e.DefaultKMSKeyName, approvedKeys)
+	e.Compliant = e.CustomerManaged && e.ApprovedKey
+	return e
+}
+
+// IsApprovedKey returns whether a Cloud KMS key, or a version of it, is one of the approved keys or belongs to one of
+// the approved key rings. Any key is approved when the list is empty.
+func IsApprovedKey(keyName string, approvedKeys []string) bool {
+	if len(approvedKeys) == 0 {
+		return true
+	}
+	keyName = strings.SplitN(keyName, "/cryptoKeyVersions/", 2)[0]
+	for _, approved := range approvedKeys {
+		approved = strings.TrimSuffix(approved, "/")
+		if keyName == approved || strings.HasPrefix(keyName, approved+"/cryptoKeys/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+@s-gcpear
+Feature: Object Storage Encryption at Rest
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that my organisation is protected against data leakage due to misconfiguration
+
+    Background:
+      Given a GCP project is available
+      And the GCP project specified in config exists
+
+    @s-gcpear-001
+    Scenario: Detect Cloud Storage Buckets Without an Approved Customer-Managed Default Key
+
+      Security Standard References:
+        - CHC2-AGP140 : Ensure cryptographic controls are in place to protect the confidentiality and integrity of data in-transit, stored, generated and processed in the cloud
+
+      Then every Cloud Storage bucket has an approved customer-managed default key
+
+    @s-gcpear-002
+    Scenario Outline: Prevent Creation of Object Storage Without Encryption at Rest using Customer Managed Keys
+
+      Security Standard References:
+        - CHC2-AGP140 : Ensure cryptographic controls are in place to protect the confidentiality and integrity of data in-transit, stored, generated and processed in the cloud
+
+      Then an attempt to create a Cloud Storage bucket "<Key>" a customer-managed default key "<Result>"
+
+      Examples:
+        | Key     | Result   |
+        | with    | succeeds |
+        | without | fails    |
+
+    @s-gcpear-003
+    Scenario: Encrypt New Objects With the Customer-Managed Default Key
+
+      Security Standard References:
+        - CHC2-AGP140 : Ensure cryptographic controls are in place to protect the confidentiality and integrity of data in-transit, stored, generated and processed in the cloud
+
+      Given a Cloud Storage bucket is created with an approved customer-managed default key
+      When an object is uploaded to the bucket
+      Then the object is encrypted with the customer-managed default key
@@ -0,0 +1,450 @@
+package gcpear
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/cucumber/godog"
+	"google.golang.org/api/googleapi"
+
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	gcputil "github.com/citihub/probr-pack-storage/internal/gcp"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+type scenarioState struct {
+	name        string
+	currentStep string
+	audit       *audit.ScenarioAudit
+	probe       *audit.Probe
+	ctx         context.Context
+	bucketName  string
+	objectKey   string
+	buckets     []string
+}
+
+// ProbeStruct meets the interface allowing this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+// bucketResult records the evaluation of the default encryption key of a bucket
+type bucketResult struct {
+	BucketName string
+	Encryption gcputil.Encryption
+	Error      string
+}
+
+// Probe meets the interface allowing this probe to be added to the ProbeStore
+var Probe probeStruct
+var scenario scenarioState       // Local container of scenario state
+var gcpConnection connection.GCP // Provides functionality to interact with GCP
+
+func (scenario *scenarioState) aGCPProjectIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that a Cloud Storage client can be created for GCP; "))
+
+	payload = struct {
+		ProjectID           string
+		StorageEmulatorHost string
+	}{
+		gcputil.ProjectID(),
+		gcputil.StorageEmulatorHost(),
+	}
+
+	err = gcpConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) theGCPProjectSpecifiedInConfigExists() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check if value for GCP project is set in config vars; ")
+	if gcputil.ProjectID() == "" {
+		err = utils.ReformatError("GCP project config var not set")
+		return err
+	}
+
+	stepTrace.WriteString("Check the project exists and its buckets can be listed; ")
+	existsErr := gcpConnection.ProjectExists()
+	if existsErr != nil {
+		err = utils.ReformatError("GCP project '%s' does not exist or is not accessible. Error: %v", gcputil.ProjectID(), existsErr)
+		return err
+	}
+
+	// Audit log
+	payload = struct {
+		ProjectID string
+	}{
+		ProjectID: gcputil.ProjectID(),
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) everyCloudStorageBucketHasAnApprovedCustomerManagedDefaultKey() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("List the Cloud Storage buckets in the project; ")
+	bucketNames, listErr := gcpConnection.ListBuckets()
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list Cloud Storage buckets: %v", listErr)
+		return err
+	}
+
+	approvedKeys := gcputil.ApprovedKMSKeys()
+	stepTrace.WriteString("Check that the default key of each bucket is an approved customer-managed key; ")
+	results := make([]bucketResult, 0, len(bucketNames))
+	var flagged []string
+	for _, bucketName := range bucketNames {
+		result := bucketResult{BucketName: bucketName}
+		attrs, attrsErr := gcpConnection.GetBucketAttrs(bucketName)
+		if attrsErr != nil {
+			result.Error = attrsErr.Error()
+		} else {
+			result.Encryption = gcputil.EvaluateEncryption(attrs, approvedKeys)
+		}
+		if !result.Encryption.Compliant {
+			flagged = append(flagged, bucketName)
+		}
+		results = append(results, result)
+	}
+
+	// Audit log
+	payload = struct {
+		ApprovedKMSKeys []string
+		Buckets         []bucketResult
+	}{
+		ApprovedKMSKeys: approvedKeys,
+		Buckets:         results,
+	}
+
+	if len(flagged) > 0 {
+		err = utils.ReformatError("%d of %d Cloud Storage buckets do not have an approved customer-managed default key: %s", len(flagged), len(bucketNames), strings.Join(flagged, ", "))
+	}
+	return err
+}
+
+func (scenario *scenarioState) anAttemptToCreateACloudStorageBucketXACustomerManagedDefaultKeyY(withKey, expectedResult string) error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values
+	var shouldSucceed bool
+	switch expectedResult {
+	case "succeeds":
+		shouldSucceed = true
+	case "fails":
+		shouldSucceed = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	bucketName := gcputil.BucketName()
+	attrs := gcputil.DefaultBucketAttrs()
+	switch withKey {
+	case "with":
+		if gcputil.KMSKeyName() == "" {
+			err = utils.ReformatError("GCP KMS key config var not set")
+			return err
+		}
+		attrs.Encryption = &storage.BucketEncryption{DefaultKMSKeyName: gcputil.KMSKeyName()}
+		stepTrace.WriteString(fmt.Sprintf("Create Cloud Storage bucket '%s' with default key '%s'; ", bucketName, gcputil.KMSKeyName()))
+	case "without":
+		stepTrace.WriteString(fmt.Sprintf("Create Cloud Storage bucket '%s' without a default key, so that objects are encrypted with a Google-managed key; ", bucketName))
+	default:
+		err = utils.ReformatError("Unexpected value provided for withKey: '%s' Expected values: ['with', 'without']", withKey)
+		return err
+	}
+
+	createErr := gcpConnection.CreateBucket(bucketName, attrs)
+	if createErr == nil {
+		scenario.buckets = append(scenario.buckets, bucketName)
+	}
+
+	// Audit log
+	payload = struct {
+		BucketName        string
+		DefaultKMSKeyName string
+		StatusCode        int
+		Error             string
+	}{
+		BucketName:        bucketName,
+		DefaultKMSKeyName: gcputil.KMSKeyName(),
+		StatusCode:        statusCode(createErr),
+		Error:             fmt.Sprintf("%v", createErr),
+	}
+
+	// The gcp.restrictNonCmekServices organization policy constraint refuses the bucket with 412 Precondition Failed.
+	// Any other error, such as a missing permission on the key, does not show that the control is in place.
+	if shouldSucceed && createErr != nil {
+		err = utils.ReformatError("Creation of Cloud Storage bucket %s a customer-managed default key failed: %v", withKey, createErr)
+	} else if !shouldSucceed && createErr == nil {
+		err = utils.ReformatError("Creation of Cloud Storage bucket %s a customer-managed default key succeeded, expected it to be refused", withKey)
+	} else if !shouldSucceed && statusCode(createErr) != http.StatusPreconditionFailed {
+		err = utils.ReformatError("Creation of Cloud Storage bucket %s a customer-managed default key failed with an unexpected error, expected it to be refused by organization policy: %v", withKey, createErr)
+	}
+	return err
+}
+
+func (scenario *scenarioState) aCloudStorageBucketIsCreatedWithAnApprovedCustomerManagedDefaultKey() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	bucketName := gcputil.BucketName()
+	keyName := gcputil.KMSKeyName()
+
+	// Audit log
+	payload = struct {
+		BucketName        string
+		DefaultKMSKeyName string
+	}{
+		BucketName:        bucketName,
+		DefaultKMSKeyName: keyName,
+	}
+
+	stepTrace.WriteString("Check that the key for new buckets is set in config and is approved; ")
+	if keyName == "" {
+		err = utils.ReformatError("GCP KMS key config var not set")
+		return err
+	}
+	if !gcputil.IsApprovedKey(keyName, gcputil.ApprovedKMSKeys()) {
+		err = utils.ReformatError("GCP KMS key '%s' is not one of the approved keys", keyName)
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Create Cloud Storage bucket '%s' with default key '%s'; ", bucketName, keyName))
+	attrs := gcputil.DefaultBucketAttrs()
+	attrs.Encryption = &storage.BucketEncryption{DefaultKMSKeyName: keyName}
+	createErr := gcpConnection.CreateBucket(bucketName, attrs)
+	if createErr != nil {
+		err = utils.ReformatError("Failed to create Cloud Storage bucket '%s': %v", bucketName, createErr)
+		return err
+	}
+	scenario.buckets = append(scenario.buckets, bucketName)
+	scenario.bucketName = bucketName
+
+	return nil
+}
+
+func (scenario *scenarioState) anObjectIsUploadedToTheBucket() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	scenario.objectKey = "probr-" + strings.ToLower(utils.RandomString(6))
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		ObjectKey  string
+	}{
+		BucketName: scenario.bucketName,
+		ObjectKey:  scenario.objectKey,
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Upload object '%s' to Cloud Storage bucket '%s' without specifying a key; ", scenario.objectKey, scenario.bucketName))
+	putErr := gcpConnection.PutObject(scenario.bucketName, scenario.objectKey, []byte("Probr encryption at rest test object"))
+	if putErr != nil {
+		err = utils.ReformatError("Failed to upload object '%s' to Cloud Storage bucket '%s': %v", scenario.objectKey, scenario.bucketName, putErr)
+	}
+	return err
+}
+
+func (scenario *scenarioState) theObjectIsEncryptedWithTheCustomerManagedDefaultKey() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString(fmt.Sprintf("Read the key used to encrypt object '%s'; ", scenario.objectKey))
+	attrs, attrsErr := gcpConnection.GetObjectAttrs(scenario.bucketName, scenario.objectKey)
+	if attrsErr != nil {
+		err = utils.ReformatError("Failed to read the attributes of object '%s': %v", scenario.objectKey, attrsErr)
+		return err
+	}
+
+	// Audit log
+	payload = struct {
+		BucketName        string
+		ObjectKey         string
+		DefaultKMSKeyName string
+		ObjectKMSKeyName  string
+	}{
+		BucketName:        scenario.bucketName,
+		ObjectKey:         scenario.objectKey,
+		DefaultKMSKeyName: gcputil.KMSKeyName(),
+		ObjectKMSKeyName:  attrs.KMSKeyName,
+	}
+
+	// The object key name includes the key version, so is compared as a key within the configured key
+	stepTrace.WriteString("Check that the object is encrypted with a version of the default key; ")
+	if attrs.KMSKeyName == "" || !gcputil.IsApprovedKey(attrs.KMSKeyName, []string{gcputil.KMSKeyName()}) {
+		err = utils.ReformatError("Object '%s' is encrypted with '%s', expected the customer-managed default key '%s'", scenario.objectKey, attrs.KMSKeyName, gcputil.KMSKeyName())
+	}
+	return err
+}
+
+// statusCode returns the HTTP status code of a failed Cloud Storage request, such as 412 Precondition Failed
+func statusCode(err error) int {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		return gerr.Code
+	}
+	return 0
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.bucketName = ""
+	s.objectKey = ""
+	s.buckets = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "encryption_at_rest"
+}
+
+// Path returns the probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "gcp", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize gcp connection
+		gcpConnection = connection.NewGCPConnection(
+			context.Background(),
+			gcputil.ProjectID(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^a GCP project is available$`, scenario.aGCPProjectIsAvailable)
+	ctx.Step(`^the GCP project specified in config exists$`, scenario.theGCPProjectSpecifiedInConfigExists)
+
+	// Steps
+	ctx.Step(`^every Cloud Storage bucket has an approved customer-managed default key$`, scenario.everyCloudStorageBucketHasAnApprovedCustomerManagedDefaultKey)
+	ctx.Step(`^an attempt to create a Cloud Storage bucket "([^"]*)" a customer-managed default key "([^"]*)"$`, scenario.anAttemptToCreateACloudStorageBucketXACustomerManagedDefaultKeyY)
+	ctx.Step(`^a Cloud Storage bucket is created with an approved customer-managed default key$`, scenario.aCloudStorageBucketIsCreatedWithAnApprovedCustomerManagedDefaultKey)
+	ctx.Step(`^an object is uploaded to the bucket$`, scenario.anObjectIsUploadedToTheBucket)
+	ctx.Step(`^the object is encrypted with the customer-managed default key$`, scenario.theObjectIsEncryptedWithTheCustomerManagedDefaultKey)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing Cloud Storage buckets used during tests")
+
+	for _, bucketName := range scenario.buckets {
+		log.Printf("[DEBUG] need to delete the Cloud Storage bucket: %s", bucketName)
+		err := gcpConnection.DeleteBucket(bucketName)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the Cloud Storage bucket: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
@@ -0,0 +1,39 @@
+package gcp
+
+import (
+	"testing"
+
+	"cloud.google.com/go/storage"
+)
+
+func TestEvaluateEncryption(t *testing.T) {
+	ring := "projects/probr/locations/us/keyRings/storage"
+	key := ring + "/cryptoKeys/buckets"
+	attrs := func(keyName string) *storage.BucketAttrs {
+		return &storage.BucketAttrs{Encryption: &storage.BucketEncryption{DefaultKMSKeyName: keyName}}
+	}
+
+	tests := []struct {
+		name          string
+		attrs         *storage.BucketAttrs
+		approvedKeys  []string
+		wantCompliant bool
+	}{
+		{"TestCase1_ApprovedKey_ShouldPass", attrs(key), []string{key}, true},
+		{"TestCase2_KeyInApprovedKeyRing_ShouldPass", attrs(key), []string{ring}, true},
+		{"TestCase3_AnyKeyWithEmptyList_ShouldPass", attrs(key), nil, true},
+		{"TestCase4_KeyVersionOfApprovedKey_ShouldPass", attrs(key + "/cryptoKeyVersions/1"), []string{key}, true},
+		{"TestCase5_UnapprovedKey_ShouldFail", attrs(ring + "/cryptoKeys/other"), []string{key}, false},
+		{"TestCase6_KeyWithApprovedKeyAsPrefix_ShouldFail", attrs(key + "-old"), []string{key}, false},
+		{"TestCase7_GoogleManagedKey_ShouldFail", &storage.BucketAttrs{}, nil, false},
+		{"TestCase8_NoAttributes_ShouldFail", nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := EvaluateEncryption(tt.attrs, tt.approvedKeys)
+			if e.Compliant != tt.wantCompliant {
+				t.Errorf("EvaluateEncryption() = %+v, want compliant %v", e, tt.wantCompliant)
+			}
+		})
+	}
+}
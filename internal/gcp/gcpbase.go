@@ -0,0 +1,77 @@
+package gcp
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/cucumber/godog"
+
+	"github.com/citihub/probr-pack-storage/internal/envvar"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+//ProjectID returns the GCP project in which the tests should be executed, set by the environment variable GOOGLE_CLOUD_PROJECT.
+func ProjectID() string {
+	return envvar.GetOrDefault("GOOGLE_CLOUD_PROJECT", "")
+}
+
+//StorageEmulatorHost returns the address of a Cloud Storage emulator, such as fake-gcs-server, set by the environment variable STORAGE_EMULATOR_HOST. When set the Cloud Storage client sends unauthenticated requests to the emulator instead of GCP.
+func StorageEmulatorHost() string {
+	return envvar.GetOrDefault("STORAGE_EMULATOR_HOST", "")
+}
+
+//RequireCloudStorage returns godog.ErrPending if the probes run against a Cloud Storage emulator, so that steps which need features the emulator does not implement, such as IAM policies and public access prevention, are skipped.
+func RequireCloudStorage() error {
+	if StorageEmulatorHost() != "" {
+		return godog.ErrPending
+	}
+	return nil
+}
+
+//Location returns the location of buckets created by probes, defaults to 'US' and may be set by the environment variable GCP_STORAGE_LOCATION.
+func Location() string {
+	return envvar.GetOrDefault("GCP_STORAGE_LOCATION", "US")
+}
+
+//ApprovedKMSKeys returns the Cloud KMS keys which may be used as the default key of a bucket, as full key or key ring resource names, set as a comma separated list by the environment variable GCP_APPROVED_KMS_KEYS. When empty any customer-managed key is accepted.
+func ApprovedKMSKeys() []string {
+	return envvar.SplitList(envvar.GetOrDefault("GCP_APPROVED_KMS_KEYS", ""))
+}
+
+//KMSKeyName returns the Cloud KMS key used as the default key of buckets created by probes, set by the environment variable GCP_KMS_KEY_NAME. Defaults to the first approved key.
+func KMSKeyName() string {
+	defaultValue := ""
+	if keys := ApprovedKMSKeys(); len(keys) > 0 {
+		defaultValue = keys[0]
+	}
+	return envvar.GetOrDefault("GCP_KMS_KEY_NAME", defaultValue)
+}
+
+//RegulatedBuckets returns the names of the buckets holding regulated data, which may include '*' wildcards, set as a comma separated list by the environment variable GCP_REGULATED_BUCKETS. When empty every bucket is treated as regulated.
+func RegulatedBuckets() []string {
+	return envvar.SplitList(envvar.GetOrDefault("GCP_REGULATED_BUCKETS", ""))
+}
+
+//MinimumRetentionDays returns the minimum retention period for regulated buckets, defaults to 1 day and may be set by the environment variable GCP_MINIMUM_RETENTION_DAYS.
+func MinimumRetentionDays() int {
+	days, err := strconv.Atoi(envvar.GetOrDefault("GCP_MINIMUM_RETENTION_DAYS", "1"))
+	if err != nil || days < 1 {
+		log.Printf("[ERROR] Unexpected value for GCP_MINIMUM_RETENTION_DAYS: %v. Using 1", err)
+		return 1
+	}
+	return days
+}
+
+//BucketName returns a new random name for a bucket created by a probe. Cloud Storage bucket names must be lower case and globally unique.
+func BucketName() string {
+	return "probr-" + strings.ToLower(utils.RandomString(10))
+}
+
+//DefaultBucketAttrs returns the attributes of a bucket created by a probe with default settings, which only sets its location.
+func DefaultBucketAttrs() *storage.BucketAttrs {
+	return &storage.BucketAttrs{
+		Location: Location(),
+	}
+}
@@ -0,0 +1,22 @@
+@s-gcpis
+Feature: Object Storage Holding Regulated Data Is Immutable
+
+  As a Cloud Security Architect
+  I want to ensure that suitable security controls are applied to Object Storage
+  So that my organisation's regulated data cannot be altered or deleted before its retention period ends
+
+    Background:
+      Given a GCP project is available
+      And the GCP project specified in config exists
+      And the regulated Cloud Storage buckets in config are listed
+
+    @s-gcpis-001
+    Scenario: Detect Regulated Cloud Storage Buckets Without a Locked Retention Policy
+      Then every regulated Cloud Storage bucket has a locked retention policy of at least the minimum retention period
+
+    @s-gcpis-002
+    Scenario: Prevent Early Deletion of Objects in Object Storage
+      Given a Cloud Storage bucket is created with a retention policy of 30 seconds
+      And the retention policy of the bucket is locked
+      And an object is uploaded to the bucket
+      Then an attempt to delete the object "fails"
@@ -0,0 +1,473 @@
+package gcpis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/cucumber/godog"
+	"google.golang.org/api/googleapi"
+
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	gcputil "github.com/citihub/probr-pack-storage/internal/gcp"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+type scenarioState struct {
+	name             string
+	currentStep      string
+	audit            *audit.ScenarioAudit
+	probe            *audit.Probe
+	ctx              context.Context
+	regulatedBuckets []string
+	bucketName       string
+	objectKey        string
+	retentionPeriod  time.Duration
+	retainUntil      time.Time // When the uploaded object may be deleted
+	buckets          []string
+}
+
+// ProbeStruct meets the interface allowing this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+// bucketResult records the retention policy of a regulated bucket
+type bucketResult struct {
+	BucketName string
+	Retention  gcputil.Retention
+	Error      string
+}
+
+// Probe meets the interface allowing this probe to be added to the ProbeStore
+var Probe probeStruct
+var scenario scenarioState       // Local container of scenario state
+var gcpConnection connection.GCP // Provides functionality to interact with GCP
+
+func (scenario *scenarioState) aGCPProjectIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString(fmt.Sprintf("Validate that a Cloud Storage client can be created for GCP; "))
+
+	payload = struct {
+		ProjectID           string
+		StorageEmulatorHost string
+	}{
+		gcputil.ProjectID(),
+		gcputil.StorageEmulatorHost(),
+	}
+
+	err = gcpConnection.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) theGCPProjectSpecifiedInConfigExists() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("Check if value for GCP project is set in config vars; ")
+	if gcputil.ProjectID() == "" {
+		err = utils.ReformatError("GCP project config var not set")
+		return err
+	}
+
+	stepTrace.WriteString("Check the project exists and its buckets can be listed; ")
+	existsErr := gcpConnection.ProjectExists()
+	if existsErr != nil {
+		err = utils.ReformatError("GCP project '%s' does not exist or is not accessible. Error: %v", gcputil.ProjectID(), existsErr)
+		return err
+	}
+
+	// Audit log
+	payload = struct {
+		ProjectID string
+	}{
+		ProjectID: gcputil.ProjectID(),
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) theRegulatedCloudStorageBucketsInConfigAreListed() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString("List the Cloud Storage buckets in the project; ")
+	bucketNames, listErr := gcpConnection.ListBuckets()
+	if listErr != nil {
+		err = utils.ReformatError("Failed to list Cloud Storage buckets: %v", listErr)
+		return err
+	}
+
+	patterns := gcputil.RegulatedBuckets()
+	stepTrace.WriteString("Select the buckets matching the regulated bucket names in config; ")
+	scenario.regulatedBuckets = make([]string, 0)
+	for _, bucketName := range bucketNames {
+		if isRegulated(bucketName, patterns) {
+			scenario.regulatedBuckets = append(scenario.regulatedBuckets, bucketName)
+		}
+	}
+
+	// Audit log
+	payload = struct {
+		RegulatedBucketPatterns []string
+		RegulatedBuckets        []string
+	}{
+		RegulatedBucketPatterns: patterns,
+		RegulatedBuckets:        scenario.regulatedBuckets,
+	}
+
+	return nil
+}
+
+func (scenario *scenarioState) everyRegulatedCloudStorageBucketHasALockedRetentionPolicyOfAtLeastTheMinimumRetentionPeriod() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	minimumDays := gcputil.MinimumRetentionDays()
+	stepTrace.WriteString(fmt.Sprintf("Check that every regulated bucket has a locked retention policy of at least %d days; ", minimumDays))
+	results := make([]bucketResult, 0, len(scenario.regulatedBuckets))
+	var flagged []string
+	for _, bucketName := range scenario.regulatedBuckets {
+		result := bucketResult{BucketName: bucketName}
+		attrs, attrsErr := gcpConnection.GetBucketAttrs(bucketName)
+		if attrsErr != nil {
+			result.Error = attrsErr.Error()
+		} else {
+			result.Retention = gcputil.EvaluateRetention(attrs, minimumDays)
+		}
+		if !result.Retention.Compliant {
+			flagged = append(flagged, bucketName)
+		}
+		results = append(results, result)
+	}
+
+	// Audit log
+	payload = struct {
+		MinimumRetentionDays int
+		Buckets              []bucketResult
+	}{
+		MinimumRetentionDays: minimumDays,
+		Buckets:              results,
+	}
+
+	if len(flagged) > 0 {
+		err = utils.ReformatError("%d of %d regulated Cloud Storage buckets do not have a locked retention policy of at least %d days: %s", len(flagged), len(scenario.regulatedBuckets), minimumDays, strings.Join(flagged, ", "))
+	}
+	return err
+}
+
+func (scenario *scenarioState) aCloudStorageBucketIsCreatedWithARetentionPolicyOfNSeconds(seconds int) error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	bucketName := gcputil.BucketName()
+	period := time.Duration(seconds) * time.Second
+
+	// Audit log
+	payload = struct {
+		BucketName             string
+		RetentionPeriodSeconds int
+	}{
+		BucketName:             bucketName,
+		RetentionPeriodSeconds: seconds,
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Create Cloud Storage bucket '%s' with a retention policy of %d seconds; ", bucketName, seconds))
+	attrs := gcputil.DefaultBucketAttrs()
+	attrs.RetentionPolicy = &storage.RetentionPolicy{RetentionPeriod: period}
+	createErr := gcpConnection.CreateBucket(bucketName, attrs)
+	if createErr != nil {
+		err = utils.ReformatError("Failed to create Cloud Storage bucket '%s' with a retention policy: %v", bucketName, createErr)
+		return err
+	}
+	scenario.buckets = append(scenario.buckets, bucketName)
+	scenario.bucketName = bucketName
+	scenario.retentionPeriod = period
+
+	return nil
+}
+
+func (scenario *scenarioState) theRetentionPolicyOfTheBucketIsLocked() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Audit log
+	payload = struct {
+		BucketName string
+	}{
+		BucketName: scenario.bucketName,
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Lock the retention policy of Cloud Storage bucket '%s'; ", scenario.bucketName))
+	lockErr := gcpConnection.LockRetentionPolicy(scenario.bucketName)
+	if lockErr != nil {
+		err = utils.ReformatError("Failed to lock the retention policy of Cloud Storage bucket '%s': %v", scenario.bucketName, lockErr)
+	}
+	return err
+}
+
+func (scenario *scenarioState) anObjectIsUploadedToTheBucket() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	scenario.objectKey = "probr-" + strings.ToLower(utils.RandomString(6))
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		ObjectKey  string
+	}{
+		BucketName: scenario.bucketName,
+		ObjectKey:  scenario.objectKey,
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Upload object '%s' to Cloud Storage bucket '%s'; ", scenario.objectKey, scenario.bucketName))
+	putErr := gcpConnection.PutObject(scenario.bucketName, scenario.objectKey, []byte("Probr immutable storage test object"))
+	if putErr != nil {
+		err = utils.ReformatError("Failed to upload object '%s' to Cloud Storage bucket '%s': %v", scenario.objectKey, scenario.bucketName, putErr)
+		return err
+	}
+
+	// Objects are retained for the retention period from when they are uploaded
+	scenario.retainUntil = time.Now().Add(scenario.retentionPeriod)
+	return nil
+}
+
+func (scenario *scenarioState) anAttemptToDeleteTheObjectX(expectedResult string) error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values
+	var shouldSucceed bool
+	switch expectedResult {
+	case "succeeds":
+		shouldSucceed = true
+	case "fails":
+		shouldSucceed = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Delete object '%s' from Cloud Storage bucket '%s' before its retention period ends; ", scenario.objectKey, scenario.bucketName))
+	deleteErr := gcpConnection.DeleteObject(scenario.bucketName, scenario.objectKey)
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		ObjectKey  string
+		StatusCode int
+		Error      string
+	}{
+		BucketName: scenario.bucketName,
+		ObjectKey:  scenario.objectKey,
+		StatusCode: statusCode(deleteErr),
+		Error:      fmt.Sprintf("%v", deleteErr),
+	}
+
+	// An object under retention is refused with 403 Forbidden. Any other error does not show that the control is in place.
+	if shouldSucceed && deleteErr != nil {
+		err = utils.ReformatError("Deletion of the retained object failed: %v", deleteErr)
+	} else if !shouldSucceed && deleteErr == nil {
+		err = utils.ReformatError("Deletion of the retained object succeeded, expected it to be refused")
+	} else if !shouldSucceed && statusCode(deleteErr) != http.StatusForbidden {
+		err = utils.ReformatError("Deletion of the retained object failed with an unexpected error, expected it to be refused by the retention policy: %v", deleteErr)
+	}
+	return err
+}
+
+// isRegulated returns whether a bucket name matches one of the regulated bucket patterns. Every bucket is regulated if there are no patterns.
+func isRegulated(bucketName string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, bucketName); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// statusCode returns the HTTP status code of a failed Cloud Storage request, such as 403 Forbidden
+func statusCode(err error) int {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		return gerr.Code
+	}
+	return 0
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.regulatedBuckets = make([]string, 0)
+	s.bucketName = ""
+	s.objectKey = ""
+	s.retentionPeriod = 0
+	s.retainUntil = time.Time{}
+	s.buckets = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+// Name returns this probe's name
+func (probe probeStruct) Name() string {
+	return "immutable_storage"
+}
+
+// Path returns the probe's feature file path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "gcp", probe.Name())
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize gcp connection
+		gcpConnection = connection.NewGCPConnection(
+			context.Background(),
+			gcputil.ProjectID(),
+		)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^a GCP project is available$`, scenario.aGCPProjectIsAvailable)
+	ctx.Step(`^the GCP project specified in config exists$`, scenario.theGCPProjectSpecifiedInConfigExists)
+	ctx.Step(`^the regulated Cloud Storage buckets in config are listed$`, scenario.theRegulatedCloudStorageBucketsInConfigAreListed)
+
+	// Steps
+	ctx.Step(`^every regulated Cloud Storage bucket has a locked retention policy of at least the minimum retention period$`, scenario.everyRegulatedCloudStorageBucketHasALockedRetentionPolicyOfAtLeastTheMinimumRetentionPeriod)
+	ctx.Step(`^a Cloud Storage bucket is created with a retention policy of (\d+) seconds$`, scenario.aCloudStorageBucketIsCreatedWithARetentionPolicyOfNSeconds)
+	ctx.Step(`^the retention policy of the bucket is locked$`, scenario.theRetentionPolicyOfTheBucketIsLocked)
+	ctx.Step(`^an object is uploaded to the bucket$`, scenario.anObjectIsUploadedToTheBucket)
+	ctx.Step(`^an attempt to delete the object "([^"]*)"$`, scenario.anAttemptToDeleteTheObjectX)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing Cloud Storage buckets used during tests")
+
+	// Retained objects cannot be deleted, and so neither can their bucket, until the retention period ends
+	if wait := time.Until(scenario.retainUntil); wait > 0 {
+		log.Printf("[DEBUG] waiting %v for the retention period of the test object to end", wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+
+	for _, bucketName := range scenario.buckets {
+		log.Printf("[DEBUG] need to delete the Cloud Storage bucket: %s", bucketName)
+		err := gcpConnection.DeleteBucket(bucketName)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the Cloud Storage bucket: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
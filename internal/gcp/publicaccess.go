@@ -0,0 +1,57 @@
+package gcp
+
+import (
+	"cloud.google.com/go/iam"
+	"cloud.google.com/go/storage"
+)
+
+// Members which make a bucket public when granted an IAM role
+const (
+	AllUsers              = "allUsers"              // Anyone on the internet, without authentication
+	AllAuthenticatedUsers = "allAuthenticatedUsers" // Anyone signed in to a Google account
+)
+
+// PublicAccess records the settings which prevent a bucket from being made public
+type PublicAccess struct {
+	UniformBucketLevelAccess bool
+	PublicAccessPrevention   string   // enforced, inherited, or unspecified
+	PublicMembers            []string // Public members granted any role in the bucket IAM policy
+	Compliant                bool
+}
+
+// EvaluatePublicAccess compares the attributes and IAM policy of a bucket with the public access requirements. A bucket is
+// compliant if uniform bucket-level access is enabled, so object ACLs are ignored, public access prevention is enforced, and
+// no role is granted to a public member. The policy may be nil when it could not be read.
+func EvaluatePublicAccess(attrs *storage.BucketAttrs, policy *iam.Policy) PublicAccess {
+	var p PublicAccess
+	if attrs != nil {
+		p.UniformBucketLevelAccess = attrs.UniformBucketLevelAccess.Enabled
+		p.PublicAccessPrevention = attrs.PublicAccessPrevention.String()
+	}
+	p.PublicMembers = PublicMembers(policy)
+	p.Compliant = p.UniformBucketLevelAccess &&
+		p.PublicAccessPrevention == storage.PublicAccessPreventionEnforced.String() &&
+		len(p.PublicMembers) == 0
+	return p
+}
+
+// PublicMembers returns the public members granted any role in an IAM policy
+func PublicMembers(policy *iam.Policy) []string {
+	var members []string
+	if policy == nil {
+		return members
+	}
+	for _, role := range policy.Roles() {
+		for _, member := range policy.Members(role) {
+			if IsPublicMember(member) {
+				members = append(members, member)
+			}
+		}
+	}
+	return members
+}
+
+// IsPublicMember returns whether an IAM member represents the public
+func IsPublicMember(member string) bool {
+	return member == AllUsers || member == AllAuthenticatedUsers
+}
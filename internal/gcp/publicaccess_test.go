@@ -0,0 +1,47 @@
+package gcp
+
+import (
+	"testing"
+
+	"cloud.google.com/go/iam"
+	"cloud.google.com/go/storage"
+)
+
+func TestEvaluatePublicAccess(t *testing.T) {
+	attrs := func(uniform bool, prevention storage.PublicAccessPrevention) *storage.BucketAttrs {
+		return &storage.BucketAttrs{
+			UniformBucketLevelAccess: storage.UniformBucketLevelAccess{Enabled: uniform},
+			PublicAccessPrevention:   prevention,
+		}
+	}
+	policy := func(members ...string) *iam.Policy {
+		p := &iam.Policy{}
+		for _, member := range members {
+			p.Add(member, iam.Viewer)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name          string
+		attrs         *storage.BucketAttrs
+		policy        *iam.Policy
+		wantCompliant bool
+	}{
+		{"TestCase1_UniformAccessAndPreventionEnforced_ShouldPass", attrs(true, storage.PublicAccessPreventionEnforced), policy("projectViewer:probr"), true},
+		{"TestCase2_NoPolicy_ShouldPass", attrs(true, storage.PublicAccessPreventionEnforced), nil, true},
+		{"TestCase3_PreventionInherited_ShouldFail", attrs(true, storage.PublicAccessPreventionInherited), policy(), false},
+		{"TestCase4_FineGrainedAccess_ShouldFail", attrs(false, storage.PublicAccessPreventionEnforced), policy(), false},
+		{"TestCase5_AllUsersGranted_ShouldFail", attrs(true, storage.PublicAccessPreventionEnforced), policy(AllUsers), false},
+		{"TestCase6_AllAuthenticatedUsersGranted_ShouldFail", attrs(true, storage.PublicAccessPreventionEnforced), policy(AllAuthenticatedUsers), false},
+		{"TestCase7_NoAttributes_ShouldFail", nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := EvaluatePublicAccess(tt.attrs, tt.policy)
+			if p.Compliant != tt.wantCompliant {
+				t.Errorf("EvaluatePublicAccess() = %+v, want compliant %v", p, tt.wantCompliant)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package gcp
+
+import (
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// Retention records the retention policy of a bucket
+type Retention struct {
+	RetentionPolicy        bool
+	RetentionPeriodSeconds int64
+	Locked                 bool
+	Compliant              bool // A locked retention policy of at least the minimum period
+}
+
+// EvaluateRetention checks that a bucket has a locked retention policy of at least the minimum period in days. An
+// unlocked retention policy can be removed or shortened, so does not stop early deletion.
+func EvaluateRetention(attrs *storage.BucketAttrs, minimumDays int) Retention {
+	var r Retention
+	var period time.Duration
+	if attrs != nil && attrs.RetentionPolicy != nil {
+		period = attrs.RetentionPolicy.RetentionPeriod
+		r.RetentionPolicy = true
+		r.RetentionPeriodSeconds = int64(period / time.Second)
+		r.Locked = attrs.RetentionPolicy.IsLocked
+	}
+	r.Compliant = r.Locked && period >= time.Duration(minimumDays)*24*time.Hour
+	return r
+}
@@ -0,0 +1,36 @@
+package gcp
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+func TestEvaluateRetention(t *testing.T) {
+	day := 24 * time.Hour
+	attrs := func(period time.Duration, locked bool) *storage.BucketAttrs {
+		return &storage.BucketAttrs{RetentionPolicy: &storage.RetentionPolicy{RetentionPeriod: period, IsLocked: locked}}
+	}
+
+	tests := []struct {
+		name          string
+		attrs         *storage.BucketAttrs
+		wantCompliant bool
+	}{
+		{"TestCase1_LockedAboveMinimum_ShouldPass", attrs(60*day, true), true},
+		{"TestCase2_LockedAtMinimum_ShouldPass", attrs(30*day, true), true},
+		{"TestCase3_LockedBelowMinimum_ShouldFail", attrs(30*day-time.Second, true), false},
+		{"TestCase4_Unlocked_ShouldFail", attrs(60*day, false), false},
+		{"TestCase5_NoRetentionPolicy_ShouldFail", &storage.BucketAttrs{}, false},
+		{"TestCase6_NoAttributes_ShouldFail", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := EvaluateRetention(tt.attrs, 30)
+			if r.Compliant != tt.wantCompliant {
+				t.Errorf("EvaluateRetention() = %+v, want compliant %v", r, tt.wantCompliant)
+			}
+		})
+	}
+}
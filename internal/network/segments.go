@@ -0,0 +1,90 @@
+// Package network holds the network segment config shared by the allowed network access probes of each cloud provider.
+package network
+
+import (
+	"net"
+
+	"github.com/citihub/probr-pack-storage/internal/envvar"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// Segments represents the allowed and disallowed network segments config settings. This shall be removed and replaced with actual config vars once sdk refactor is complete.
+type Segments struct {
+	Allowed    []string `yaml:"Allowed"`    // A list of allowed network segments, as IP addresses or CIDR ranges
+	Disallowed []string `yaml:"Disallowed"` // A list of disallowed network segments, as IP addresses or CIDR ranges
+}
+
+// ConfiguredSegments returns the allowed and disallowed network segments. Either list may be overridden as a comma
+// separated list by the environment variables PROBR_ALLOWED_NETWORK_SEGMENTS and PROBR_DISALLOWED_NETWORK_SEGMENTS.
+func ConfiguredSegments() Segments {
+
+	//return config.Vars.ServicePacks.Storage.NetworkSegments
+
+	// TODO: This is here until config refactoring in SDK is finished
+	segments := Segments{
+		Allowed: []string{
+			"219.79.19.0/24",
+			"170.74.231.168",
+		},
+		Disallowed: []string{
+			"219.79.20.1",
+			"219.108.32.1",
+		},
+	}
+	if allowed := envvar.SplitList(envvar.GetOrDefault("PROBR_ALLOWED_NETWORK_SEGMENTS", "")); len(allowed) > 0 {
+		segments.Allowed = allowed
+	}
+	if disallowed := envvar.SplitList(envvar.GetOrDefault("PROBR_DISALLOWED_NETWORK_SEGMENTS", "")); len(disallowed) > 0 {
+		segments.Disallowed = disallowed
+	}
+	return segments
+}
+
+// Validate returns an error if a segment is not an IP address or CIDR range, or if a disallowed segment overlaps an
+// allowed segment, as requests from the overlap cannot be both allowed and denied
+func (s Segments) Validate() error {
+	for _, disallowed := range s.Disallowed {
+		disallowedNet, err := parseSegment(disallowed)
+		if err != nil {
+			return err
+		}
+		for _, allowed := range s.Allowed {
+			allowedNet, err := parseSegment(allowed)
+			if err != nil {
+				return err
+			}
+			if allowedNet.Contains(disallowedNet.IP) || disallowedNet.Contains(allowedNet.IP) {
+				return utils.ReformatError("Disallowed network segment '%s' overlaps allowed network segment '%s'", disallowed, allowed)
+			}
+		}
+	}
+	return nil
+}
+
+// SampleAddress returns an IP address within a network segment given as an IP address or CIDR range
+func SampleAddress(segment string) (string, error) {
+	if ip := net.ParseIP(segment); ip != nil {
+		return ip.String(), nil
+	}
+	_, ipNet, err := net.ParseCIDR(segment)
+	if err != nil {
+		return "", utils.ReformatError("Network segment '%s' is not an IP address or CIDR range", segment)
+	}
+	return ipNet.IP.String(), nil
+}
+
+// parseSegment returns the range of a network segment given as an IP address or CIDR range
+func parseSegment(segment string) (*net.IPNet, error) {
+	if ip := net.ParseIP(segment); ip != nil {
+		bits := 8 * net.IPv4len
+		if ip.To4() == nil {
+			bits = 8 * net.IPv6len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(segment)
+	if err != nil {
+		return nil, utils.ReformatError("Network segment '%s' is not an IP address or CIDR range", segment)
+	}
+	return ipNet, nil
+}
@@ -0,0 +1,72 @@
+package network
+
+import (
+	"os"
+	"testing"
+)
+
+func TestConfiguredSegments(t *testing.T) {
+	defaults := ConfiguredSegments()
+	if len(defaults.Allowed) == 0 || len(defaults.Disallowed) == 0 {
+		t.Fatalf("ConfiguredSegments() returned no default segments: %+v", defaults)
+	}
+
+	os.Setenv("PROBR_ALLOWED_NETWORK_SEGMENTS", "10.0.0.0/16, 192.168.1.1")
+	defer os.Unsetenv("PROBR_ALLOWED_NETWORK_SEGMENTS")
+
+	segments := ConfiguredSegments()
+	if len(segments.Allowed) != 2 || segments.Allowed[0] != "10.0.0.0/16" || segments.Allowed[1] != "192.168.1.1" {
+		t.Errorf("ConfiguredSegments() Allowed = %v, want [10.0.0.0/16 192.168.1.1]", segments.Allowed)
+	}
+	if len(segments.Disallowed) != len(defaults.Disallowed) {
+		t.Errorf("ConfiguredSegments() Disallowed = %v, want defaults %v", segments.Disallowed, defaults.Disallowed)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments Segments
+		wantErr  bool
+	}{
+		{"TestCase1_Defaults_ShouldBeValid", ConfiguredSegments(), false},
+		{"TestCase2_DisallowedAddressInAllowedRange_ShouldReturnError", Segments{Allowed: []string{"219.79.19.0/24"}, Disallowed: []string{"219.79.19.1"}}, true},
+		{"TestCase3_DisallowedRangeContainingAllowedAddress_ShouldReturnError", Segments{Allowed: []string{"10.1.2.3"}, Disallowed: []string{"10.1.0.0/16"}}, true},
+		{"TestCase4_SameAddress_ShouldReturnError", Segments{Allowed: []string{"170.74.231.168"}, Disallowed: []string{"170.74.231.168"}}, true},
+		{"TestCase5_AdjacentRanges_ShouldBeValid", Segments{Allowed: []string{"219.79.19.0/24"}, Disallowed: []string{"219.79.20.0/24"}}, false},
+		{"TestCase6_InvalidSegment_ShouldReturnError", Segments{Allowed: []string{"not-a-segment"}, Disallowed: []string{"219.108.32.1"}}, true},
+		{"TestCase7_IPv4AndIPv6_ShouldBeValid", Segments{Allowed: []string{"2001:db8::/32"}, Disallowed: []string{"219.108.32.1"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.segments.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSampleAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		segment string
+		want    string
+		wantErr bool
+	}{
+		{"TestCase1_IPAddress_ShouldReturnAddress", "170.74.231.168", "170.74.231.168", false},
+		{"TestCase2_CIDRRange_ShouldReturnNetworkAddress", "219.79.19.0/24", "219.79.19.0", false},
+		{"TestCase3_HostBitsSet_ShouldReturnNetworkAddress", "10.1.2.3/16", "10.1.0.0", false},
+		{"TestCase4_Invalid_ShouldReturnError", "not-a-segment", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SampleAddress(tt.segment)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SampleAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SampleAddress() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
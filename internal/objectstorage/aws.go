@@ -0,0 +1,189 @@
+package objectstorage
+
+import (
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	awsutil "github.com/citihub/probr-pack-storage/internal/aws"
+	"github.com/citihub/probr-pack-storage/internal/aws/bucketpolicy"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	"github.com/citihub/probr-pack-storage/internal/network"
+)
+
+// AWS maps buckets to S3 buckets. Settings which S3 has no bucket property for are applied as bucket policy statements.
+type AWS struct {
+	connection connection.AWS
+}
+
+// NewAWS provides a new instance of AWS
+func NewAWS(conn connection.AWS) *AWS {
+	return &AWS{
+		connection: conn,
+	}
+}
+
+// Provider returns the name of the provider
+func (a *AWS) Provider() string {
+	if awsutil.S3Compatible() {
+		return awsutil.S3CompatibleProvider
+	}
+	return awsutil.AWSProvider
+}
+
+// IsCloudAvailable verifies that the connection instantiation did not report a failure
+func (a *AWS) IsCloudAvailable() error {
+	return a.connection.IsCloudAvailable()
+}
+
+// CreateBucket creates a bucket and applies the requested settings. S3 compatible servers do not implement Block Public
+// Access, so public access is only prevented there by not granting it in the bucket policy.
+func (a *AWS) CreateBucket(bucketName string, spec Spec) error {
+	if err := a.connection.CreateBucket(bucketName); err != nil {
+		return err
+	}
+
+	if spec.PublicAccessBlocked && !awsutil.S3Compatible() {
+		err := a.connection.PutPublicAccessBlock(bucketName, &s3.PublicAccessBlockConfiguration{
+			BlockPublicAcls:       aws.Bool(true),
+			IgnorePublicAcls:      aws.Bool(true),
+			BlockPublicPolicy:     aws.Bool(true),
+			RestrictPublicBuckets: aws.Bool(true),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var statements []bucketpolicy.Statement
+	if spec.HTTPSOnly {
+		statements = append(statements, bucketpolicy.DenyInsecureTransport(bucketName))
+	}
+	if spec.KMSKeyID != "" {
+		if err := a.connection.PutBucketEncryption(bucketName, bucketpolicy.SSEKMS, spec.KMSKeyID); err != nil {
+			return err
+		}
+		statements = append(statements, bucketpolicy.DenyUploadsWithoutKMSEncryption(bucketName))
+	}
+	if len(spec.AllowedNetworks) > 0 {
+		statements = append(statements, bucketpolicy.DenyOutsideNetwork(bucketName, spec.AllowedNetworks, awsutil.AllowedVpcEndpoints()))
+	}
+	if len(statements) == 0 {
+		return nil
+	}
+	return a.connection.PutBucketPolicy(bucketName, bucketpolicy.New(statements...).String())
+}
+
+// DeleteBucket deletes a bucket and all object versions within it. When the bucket policy restricts networks, objects can
+// only be deleted from an allowed network.
+func (a *AWS) DeleteBucket(bucketName string) error {
+	return a.connection.DeleteBucket(bucketName)
+}
+
+// GetBucketConfig reads the effective settings of a bucket. Settings applied by the bucket policy are evaluated offline,
+// and network restriction is evaluated against the network segments in config, which must not overlap.
+func (a *AWS) GetBucketConfig(bucketName string) (Config, error) {
+	config := Config{
+		Provider:   a.Provider(),
+		BucketName: bucketName,
+	}
+
+	policy, err := a.connection.GetBucketPolicy(bucketName)
+	if err != nil {
+		return config, err
+	}
+	document := bucketpolicy.New()
+	if policy != "" {
+		if document, err = bucketpolicy.Parse(policy); err != nil {
+			return config, err
+		}
+	}
+	config.HTTPSOnly = document.DeniesInsecureTransport(bucketName)
+
+	config.PublicAccessBlocked = !document.AllowsPublicAccess(bucketName)
+	if !awsutil.S3Compatible() {
+		block, blockErr := a.connection.GetPublicAccessBlock(bucketName)
+		if blockErr != nil {
+			return config, blockErr
+		}
+		config.PublicAccessBlocked = config.PublicAccessBlocked && block != nil &&
+			aws.BoolValue(block.BlockPublicAcls) && aws.BoolValue(block.IgnorePublicAcls) &&
+			aws.BoolValue(block.BlockPublicPolicy) && aws.BoolValue(block.RestrictPublicBuckets)
+	}
+
+	encryption, err := a.connection.GetBucketEncryption(bucketName)
+	if err != nil {
+		return config, err
+	}
+	if algorithm, kmsKeyID := defaultEncryption(encryption); algorithm == bucketpolicy.SSEKMS {
+		config.KMSKeyID = kmsKeyID
+	}
+
+	segments := network.ConfiguredSegments()
+	checks, err := document.NetworkChecks(bucketName, segments.Allowed, segments.Disallowed, awsutil.AllowedVpcEndpoints())
+	if err != nil {
+		return config, err
+	}
+	config.NetworkRestricted = bucketpolicy.RestrictsNetworkAccess(checks)
+	return config, nil
+}
+
+// PutObject uploads an object to a bucket. If the default encryption of the bucket uses KMS, the upload requests the
+// same encryption, as the bucket policy applied by CreateBucket denies uploads without it.
+func (a *AWS) PutObject(bucketName, key string, content []byte) error {
+	encryption, err := a.connection.GetBucketEncryption(bucketName)
+	if err != nil {
+		return err
+	}
+	if algorithm, kmsKeyID := defaultEncryption(encryption); algorithm == bucketpolicy.SSEKMS {
+		return a.connection.PutObjectWithEncryption(bucketName, key, content, algorithm, kmsKeyID)
+	}
+	return a.connection.PutObject(bucketName, key, content)
+}
+
+// GetObject downloads an object from a bucket
+func (a *AWS) GetObject(bucketName, key string) ([]byte, error) {
+	return a.connection.GetObject(bucketName, key)
+}
+
+// ServesPlainHTTP returns true, as S3 accepts requests over plain HTTP unless they are denied by the bucket policy
+func (a *AWS) ServesPlainHTTP() bool {
+	return true
+}
+
+// GetObjectOverHTTP requests an object from a bucket over plain HTTP. It fails if the configured endpoint does not use
+// https, as requests over plain HTTP could not be told apart.
+func (a *AWS) GetObjectOverHTTP(bucketName, key string) error {
+	if err := awsutil.RequireHTTPSEndpoint(); err != nil {
+		return err
+	}
+	return a.connection.GetObjectOverHTTP(bucketName, key)
+}
+
+// IsInsecureTransportRefusal returns whether a request was refused with 403 AccessDenied, as it is by a bucket policy denying insecure transport
+func (a *AWS) IsInsecureTransportRefusal(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok || aerr.Code() != "AccessDenied" {
+		return false
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.StatusCode() == http.StatusForbidden
+	}
+	return true
+}
+
+// defaultEncryption returns the algorithm and KMS key of the default encryption of a bucket, or empty strings if it has none
+func defaultEncryption(encryption *s3.ServerSideEncryptionConfiguration) (algorithm, kmsKeyID string) {
+	if encryption == nil {
+		return
+	}
+	for _, rule := range encryption.Rules {
+		if rule.ApplyServerSideEncryptionByDefault != nil {
+			algorithm = aws.StringValue(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm)
+			kmsKeyID = aws.StringValue(rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID)
+		}
+	}
+	return
+}
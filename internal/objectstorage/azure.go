@@ -0,0 +1,134 @@
+package objectstorage
+
+import (
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2021-09-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/citihub/probr-sdk/utils"
+
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+)
+
+// azureContainerName is the container created in each storage account to hold objects
+const azureContainerName = "probr"
+
+// azureRequiresHTTPSCode is the error code returned by the blob service for requests over plain HTTP to a storage account with secure transfer required
+const azureRequiresHTTPSCode = "AccountRequiresHttps"
+
+// Azure maps buckets to storage accounts in the configured resource group, each holding a single container
+type Azure struct {
+	connection    connection.Azure
+	resourceGroup string
+}
+
+// NewAzure provides a new instance of Azure
+func NewAzure(conn connection.Azure, resourceGroup string) *Azure {
+	return &Azure{
+		connection:    conn,
+		resourceGroup: resourceGroup,
+	}
+}
+
+// Provider returns the name of the provider
+func (a *Azure) Provider() string {
+	return "Azure"
+}
+
+// IsCloudAvailable verifies that the connection instantiation did not report a failure
+func (a *Azure) IsCloudAvailable() error {
+	return a.connection.IsCloudAvailable()
+}
+
+// CreateBucket creates a storage account with the requested settings and a container for objects. Customer managed
+// keys are not supported, as they require a managed identity with access to the key vault.
+func (a *Azure) CreateBucket(bucketName string, spec Spec) error {
+	if spec.KMSKeyID != "" {
+		return utils.ReformatError("Customer managed keys are not supported when creating Azure storage accounts")
+	}
+
+	properties := &storage.AccountPropertiesCreateParameters{
+		EnableHTTPSTrafficOnly: to.BoolPtr(spec.HTTPSOnly),
+	}
+	if spec.PublicAccessBlocked {
+		properties.AllowBlobPublicAccess = to.BoolPtr(false)
+	}
+	if len(spec.AllowedNetworks) > 0 {
+		var ipRules []storage.IPRule
+		for _, ipRange := range spec.AllowedNetworks {
+			ipRules = append(ipRules, storage.IPRule{
+				Action:           storage.ActionAllow,
+				IPAddressOrRange: to.StringPtr(ipRange),
+			})
+		}
+		properties.NetworkRuleSet = &storage.NetworkRuleSet{
+			DefaultAction: storage.DefaultActionDeny,
+			IPRules:       &ipRules,
+		}
+	}
+
+	_, err := a.connection.CreateStorageAccountWithParameters(bucketName, a.resourceGroup, storage.AccountCreateParameters{
+		AccountPropertiesCreateParameters: properties,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = a.connection.CreateBlobContainer(a.resourceGroup, bucketName, azureContainerName)
+	return err
+}
+
+// DeleteBucket deletes a storage account and all data within it
+func (a *Azure) DeleteBucket(bucketName string) error {
+	return a.connection.DeleteStorageAccount(a.resourceGroup, bucketName)
+}
+
+// GetBucketConfig reads the effective settings of a storage account
+func (a *Azure) GetBucketConfig(bucketName string) (Config, error) {
+	config := Config{
+		Provider:   a.Provider(),
+		BucketName: bucketName,
+	}
+
+	account, err := a.connection.GetStorageAccountProperties(a.resourceGroup, bucketName)
+	if err != nil {
+		return config, err
+	}
+	properties := account.AccountProperties
+	if properties == nil {
+		return config, nil
+	}
+
+	config.HTTPSOnly = to.Bool(properties.EnableHTTPSTrafficOnly)
+	config.PublicAccessBlocked = properties.AllowBlobPublicAccess != nil && !*properties.AllowBlobPublicAccess
+	if properties.Encryption != nil && properties.Encryption.KeySource == storage.KeySourceMicrosoftKeyvault && properties.Encryption.KeyVaultProperties != nil {
+		config.KMSKeyID = strings.TrimSuffix(to.String(properties.Encryption.KeyVaultProperties.KeyVaultURI), "/") + "/keys/" + to.String(properties.Encryption.KeyVaultProperties.KeyName)
+	}
+	config.NetworkRestricted = properties.NetworkRuleSet != nil && properties.NetworkRuleSet.DefaultAction == storage.DefaultActionDeny
+	return config, nil
+}
+
+// PutObject uploads a blob to the container of a storage account
+func (a *Azure) PutObject(bucketName, key string, content []byte) error {
+	return a.connection.UploadBlob(a.resourceGroup, bucketName, azureContainerName, key, "application/octet-stream", content)
+}
+
+// GetObject downloads a blob from the container of a storage account
+func (a *Azure) GetObject(bucketName, key string) ([]byte, error) {
+	return a.connection.DownloadBlob(a.resourceGroup, bucketName, azureContainerName, key)
+}
+
+// ServesPlainHTTP returns true, as the blob service accepts requests over plain HTTP unless secure transfer is required
+func (a *Azure) ServesPlainHTTP() bool {
+	return true
+}
+
+// GetObjectOverHTTP requests a blob from the container of a storage account over plain HTTP
+func (a *Azure) GetObjectOverHTTP(bucketName, key string) error {
+	return a.connection.DownloadBlobOverHTTP(a.resourceGroup, bucketName, azureContainerName, key)
+}
+
+// IsInsecureTransportRefusal returns whether a request was refused with AccountRequiresHttps, as it is when secure transfer is required
+func (a *Azure) IsInsecureTransportRefusal(err error) bool {
+	return azureutil.ServiceErrorCode(err) == azureRequiresHTTPSCode
+}
@@ -0,0 +1,29 @@
+@s-oseif
+Feature: Object Storage Encryption in Flight on Any Provider
+
+    As a Cloud Security Architect
+    I want to ensure that suitable security controls are applied to Object Storage
+    So that my organisation is not vulnerable to interception of data in transit
+
+    Background:
+      Given an object storage provider is available
+
+    @s-oseif-001
+    Scenario: Create Object Storage Which Only Accepts Requests Over HTTPS
+
+      Security Standard References:
+        - CHC2-AGP140 : Ensure cryptographic controls are in place to protect the confidentiality and integrity of data in-transit, stored, generated and processed in the cloud
+
+      Given a bucket is created which only accepts requests over HTTPS
+      Then the bucket only accepts requests over HTTPS
+      And an object uploaded to the bucket can be read back
+
+    @s-oseif-002
+    Scenario: Refuse Requests for Objects Over Plain HTTP
+
+      Security Standard References:
+        - CHC2-AGP140 : Ensure cryptographic controls are in place to protect the confidentiality and integrity of data in-transit, stored, generated and processed in the cloud
+
+      Given a bucket is created which only accepts requests over HTTPS
+      And an object uploaded to the bucket can be read back
+      Then a request for the object over plain HTTP "fails"
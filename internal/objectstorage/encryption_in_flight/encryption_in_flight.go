@@ -0,0 +1,336 @@
+package oseif
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/cucumber/godog"
+
+	"github.com/citihub/probr-pack-storage/internal/objectstorage"
+	"github.com/citihub/probr-sdk/audit"
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/probeengine"
+	"github.com/citihub/probr-sdk/utils"
+)
+
+type scenarioState struct {
+	name        string
+	currentStep string
+	audit       *audit.ScenarioAudit
+	probe       *audit.Probe
+	ctx         context.Context
+	bucketName  string
+	objectKey   string
+	spec        objectstorage.Spec
+	buckets     []string
+}
+
+// ProbeStruct allows this probe to be added to the ProbeStore
+type probeStruct struct {
+}
+
+// Probe allows this probe to be added to the ProbeStore
+var Probe probeStruct
+var scenario scenarioState            // Local container of scenario state
+var store objectstorage.ObjectStorage // Provides functionality to interact with the object storage of the configured provider
+var storeErr error                    // Error returned when connecting to the configured provider
+
+const testObjectContent = "Probr encryption in flight test object"
+
+func (scenario *scenarioState) anObjectStorageProviderIsAvailable() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+	stepTrace.WriteString("Validate that the object storage of the provider in config is available; ")
+
+	payload = struct {
+		Provider string
+	}{
+		config.Vars.ServicePacks.Storage.Provider,
+	}
+
+	if storeErr != nil {
+		err = storeErr
+		return err
+	}
+
+	err = store.IsCloudAvailable() // Must be assigned to 'err' be audited
+	return err
+}
+
+func (scenario *scenarioState) aBucketIsCreatedWhichOnlyAcceptsRequestsOverHTTPS() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	bucketName := objectstorage.BucketName()
+	scenario.spec = objectstorage.Spec{HTTPSOnly: true}
+
+	// Audit log
+	payload = struct {
+		Provider   string
+		BucketName string
+		Spec       objectstorage.Spec
+	}{
+		Provider:   store.Provider(),
+		BucketName: bucketName,
+		Spec:       scenario.spec,
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Create bucket '%s' which only accepts requests over HTTPS; ", bucketName))
+	createErr := store.CreateBucket(bucketName, scenario.spec)
+	if createErr != nil {
+		err = utils.ReformatError("Failed to create bucket '%s': %v", bucketName, createErr)
+		return err
+	}
+	scenario.buckets = append(scenario.buckets, bucketName)
+	scenario.bucketName = bucketName
+
+	return nil
+}
+
+func (scenario *scenarioState) theBucketOnlyAcceptsRequestsOverHTTPS() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	stepTrace.WriteString(fmt.Sprintf("Read the effective settings of bucket '%s' and compare them with the requested settings; ", scenario.bucketName))
+	effective, configErr := store.GetBucketConfig(scenario.bucketName)
+	unmet := objectstorage.Unmet(scenario.spec, effective)
+
+	// Audit log
+	payload = struct {
+		Spec      objectstorage.Spec
+		Effective objectstorage.Config
+		Unmet     []string
+	}{
+		Spec:      scenario.spec,
+		Effective: effective,
+		Unmet:     unmet,
+	}
+
+	if configErr != nil {
+		err = utils.ReformatError("Failed to read the settings of bucket '%s': %v", scenario.bucketName, configErr)
+	} else if len(unmet) > 0 {
+		err = utils.ReformatError("Bucket '%s' was created without the requested settings in effect: %s", scenario.bucketName, strings.Join(unmet, ", "))
+	}
+	return err
+}
+
+func (scenario *scenarioState) anObjectUploadedToTheBucketCanBeReadBack() error {
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	objectKey := "probr-" + strings.ToLower(utils.RandomString(6))
+	scenario.objectKey = objectKey
+
+	// Audit log
+	payload = struct {
+		BucketName string
+		ObjectKey  string
+	}{
+		BucketName: scenario.bucketName,
+		ObjectKey:  objectKey,
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Upload object '%s' to bucket '%s'; ", objectKey, scenario.bucketName))
+	putErr := store.PutObject(scenario.bucketName, objectKey, []byte(testObjectContent))
+	if putErr != nil {
+		err = utils.ReformatError("Failed to upload object '%s' to bucket '%s': %v", objectKey, scenario.bucketName, putErr)
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Download object '%s' and compare it with the uploaded content; ", objectKey))
+	content, getErr := store.GetObject(scenario.bucketName, objectKey)
+	if getErr != nil {
+		err = utils.ReformatError("Failed to download object '%s' from bucket '%s': %v", objectKey, scenario.bucketName, getErr)
+	} else if !bytes.Equal(content, []byte(testObjectContent)) {
+		err = utils.ReformatError("Object '%s' downloaded from bucket '%s' does not match the uploaded content", objectKey, scenario.bucketName)
+	}
+	return err
+}
+
+func (scenario *scenarioState) aRequestForTheObjectOverPlainHTTPX(expectedResult string) error {
+
+	// Supported values for 'expectedResult':
+	//	'succeeds'
+	//	'fails'
+
+	// Providers which only serve HTTPS have no plain HTTP endpoint to request, so skip before auditing the step
+	if store != nil && !store.ServesPlainHTTP() {
+		log.Printf("[WARN] Skipping step, as provider '%s' does not serve requests over plain HTTP", store.Provider())
+		return godog.ErrPending
+	}
+
+	// Standard auditing logic to ensures panics are also audited
+	stepTrace, payload, err := utils.AuditPlaceholders()
+	defer func() {
+		// Catching any errors from panic
+		if panicErr := recover(); panicErr != nil {
+			err = utils.ReformatError("Unexpected error occured: ", panicErr)
+		}
+		scenario.audit.AuditScenarioStep(scenario.currentStep, stepTrace.String(), payload, err)
+	}()
+
+	// Validate input values
+	var shouldSucceed bool
+	switch expectedResult {
+	case "succeeds":
+		shouldSucceed = true
+	case "fails":
+		shouldSucceed = false
+	default:
+		err = utils.ReformatError("Unexpected value provided for expectedResult: '%s' Expected values: ['succeeds', 'fails']", expectedResult)
+		return err
+	}
+
+	stepTrace.WriteString(fmt.Sprintf("Request object '%s' from bucket '%s' over plain HTTP; ", scenario.objectKey, scenario.bucketName))
+	getErr := store.GetObjectOverHTTP(scenario.bucketName, scenario.objectKey)
+	refused := getErr != nil && store.IsInsecureTransportRefusal(getErr)
+
+	// Audit log
+	payload = struct {
+		Provider   string
+		BucketName string
+		ObjectKey  string
+		Refused    bool
+		Error      string
+	}{
+		Provider:   store.Provider(),
+		BucketName: scenario.bucketName,
+		ObjectKey:  scenario.objectKey,
+		Refused:    refused,
+		Error:      fmt.Sprintf("%v", getErr),
+	}
+
+	if shouldSucceed && getErr != nil {
+		err = utils.ReformatError("Request for object over plain HTTP failed: %v", getErr)
+	} else if !shouldSucceed && getErr == nil {
+		err = utils.ReformatError("Request for object over plain HTTP succeeded, expected it to be refused")
+	} else if !shouldSucceed && !refused {
+		// Ensure the request reached the data plane and was refused by the HTTPS only setting, rather than failing to connect
+		err = utils.ReformatError("Request for object over plain HTTP failed with an unexpected error, expected it to be refused by the HTTPS only setting: %v", getErr)
+	}
+	return err
+}
+
+func beforeScenario(s *scenarioState, probeName string, gs *godog.Scenario) {
+	s.name = gs.Name
+	s.probe = audit.State.GetProbeLog(probeName)
+	s.audit = audit.State.GetProbeLog(probeName).InitializeAuditor(gs.Name, gs.Tags)
+	s.ctx = context.Background()
+	s.bucketName = ""
+	s.objectKey = ""
+	s.spec = objectstorage.Spec{}
+	s.buckets = make([]string, 0)
+	probeengine.LogScenarioStart(gs)
+}
+
+// Name will return this probe's name. It differs from the directory name, so that it is distinct from the
+// provider specific encryption_in_flight probes run for the same provider.
+func (probe probeStruct) Name() string {
+	return "objectstorage_encryption_in_flight"
+}
+
+// Path will return this probe's feature path
+func (probe probeStruct) Path() string {
+	return probeengine.GetFeaturePath("internal", "objectstorage", "encryption_in_flight")
+}
+
+// ProbeInitialize handles any overall Test Suite initialisation steps.  This is registered with the
+// test handler as part of the init() function.
+func (probe probeStruct) ProbeInitialize(ctx *godog.TestSuiteContext) {
+
+	ctx.BeforeSuite(func() {
+
+		// Initialize the object storage of the configured provider
+		store, storeErr = objectstorage.New(config.Vars.ServicePacks.Storage.Provider)
+	})
+
+	ctx.AfterSuite(func() {
+	})
+}
+
+// ScenarioInitialize initialises the scenario
+func (probe probeStruct) ScenarioInitialize(ctx *godog.ScenarioContext) {
+
+	ctx.BeforeScenario(func(s *godog.Scenario) {
+		beforeScenario(&scenario, probe.Name(), s)
+	})
+
+	// Background
+	ctx.Step(`^an object storage provider is available$`, scenario.anObjectStorageProviderIsAvailable)
+
+	// Steps
+	ctx.Step(`^a bucket is created which only accepts requests over HTTPS$`, scenario.aBucketIsCreatedWhichOnlyAcceptsRequestsOverHTTPS)
+	ctx.Step(`^the bucket only accepts requests over HTTPS$`, scenario.theBucketOnlyAcceptsRequestsOverHTTPS)
+	ctx.Step(`^an object uploaded to the bucket can be read back$`, scenario.anObjectUploadedToTheBucketCanBeReadBack)
+	ctx.Step(`^a request for the object over plain HTTP "([^"]*)"$`, scenario.aRequestForTheObjectOverPlainHTTPX)
+
+	ctx.AfterScenario(func(s *godog.Scenario, err error) {
+		afterScenario(scenario, probe, s, err)
+	})
+
+	ctx.BeforeStep(func(st *godog.Step) {
+		scenario.currentStep = st.Text
+	})
+
+	ctx.AfterStep(func(st *godog.Step, err error) {
+		scenario.currentStep = ""
+	})
+}
+
+func afterScenario(scenario scenarioState, probe probeStruct, gs *godog.Scenario, err error) {
+
+	teardown()
+
+	probeengine.LogScenarioEnd(gs)
+}
+
+func teardown() {
+
+	log.Printf("[DEBUG] Cleanup - removing buckets used during tests")
+
+	for _, bucketName := range scenario.buckets {
+		log.Printf("[DEBUG] need to delete the bucket: %s", bucketName)
+		err := store.DeleteBucket(bucketName)
+
+		if err != nil {
+			log.Printf("[ERROR] error deleting the bucket: %v", err)
+		}
+	}
+
+	log.Println("[DEBUG] Teardown completed")
+}
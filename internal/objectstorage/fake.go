@@ -0,0 +1,145 @@
+package objectstorage
+
+import (
+	"sync"
+
+	"github.com/citihub/probr-sdk/utils"
+)
+
+// FakeProvider is the provider name reported by Fake
+const FakeProvider = "Fake"
+
+// errFakeRequiresHTTPS is returned by Fake for requests over plain HTTP to a bucket which only accepts requests over HTTPS
+var errFakeRequiresHTTPS = utils.ReformatError("Bucket only accepts requests over HTTPS")
+
+// Fake is an in-memory ObjectStorage, which applies each spec exactly as requested. It is used to test probe logic
+// without a cloud provider.
+type Fake struct {
+	// Policy, if set, is called before a bucket is created, and a returned error refuses the creation, in the same way
+	// as a preventive control such as Azure Policy or an organization policy constraint
+	Policy func(bucketName string, spec Spec) error
+
+	mutex   sync.Mutex
+	buckets map[string]*fakeBucket
+}
+
+type fakeBucket struct {
+	config  Config
+	objects map[string][]byte
+}
+
+// NewFake provides a new instance of Fake with no buckets
+func NewFake() *Fake {
+	return &Fake{
+		buckets: make(map[string]*fakeBucket),
+	}
+}
+
+// Provider returns the name of the provider
+func (f *Fake) Provider() string {
+	return FakeProvider
+}
+
+// IsCloudAvailable always succeeds
+func (f *Fake) IsCloudAvailable() error {
+	return nil
+}
+
+// CreateBucket creates a bucket with the requested settings, unless refused by the Policy
+func (f *Fake) CreateBucket(bucketName string, spec Spec) error {
+	if f.Policy != nil {
+		if err := f.Policy(bucketName, spec); err != nil {
+			return err
+		}
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, exists := f.buckets[bucketName]; exists {
+		return utils.ReformatError("Bucket '%s' already exists", bucketName)
+	}
+	f.buckets[bucketName] = &fakeBucket{
+		config: Config{
+			Provider:            FakeProvider,
+			BucketName:          bucketName,
+			HTTPSOnly:           spec.HTTPSOnly,
+			PublicAccessBlocked: spec.PublicAccessBlocked,
+			KMSKeyID:            spec.KMSKeyID,
+			NetworkRestricted:   len(spec.AllowedNetworks) > 0,
+		},
+		objects: make(map[string][]byte),
+	}
+	return nil
+}
+
+// DeleteBucket deletes a bucket and its objects
+func (f *Fake) DeleteBucket(bucketName string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, exists := f.buckets[bucketName]; !exists {
+		return utils.ReformatError("Bucket '%s' does not exist", bucketName)
+	}
+	delete(f.buckets, bucketName)
+	return nil
+}
+
+// GetBucketConfig returns the settings a bucket was created with
+func (f *Fake) GetBucketConfig(bucketName string) (Config, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	bucket, exists := f.buckets[bucketName]
+	if !exists {
+		return Config{}, utils.ReformatError("Bucket '%s' does not exist", bucketName)
+	}
+	return bucket.config, nil
+}
+
+// PutObject stores a copy of the content of an object
+func (f *Fake) PutObject(bucketName, key string, content []byte) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	bucket, exists := f.buckets[bucketName]
+	if !exists {
+		return utils.ReformatError("Bucket '%s' does not exist", bucketName)
+	}
+	bucket.objects[key] = append([]byte(nil), content...)
+	return nil
+}
+
+// GetObject returns a copy of the content of an object
+func (f *Fake) GetObject(bucketName, key string) ([]byte, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	bucket, exists := f.buckets[bucketName]
+	if !exists {
+		return nil, utils.ReformatError("Bucket '%s' does not exist", bucketName)
+	}
+	content, exists := bucket.objects[key]
+	if !exists {
+		return nil, utils.ReformatError("Object '%s' does not exist in bucket '%s'", key, bucketName)
+	}
+	return append([]byte(nil), content...), nil
+}
+
+// ServesPlainHTTP returns true
+func (f *Fake) ServesPlainHTTP() bool {
+	return true
+}
+
+// GetObjectOverHTTP refuses the request if the bucket only accepts requests over HTTPS, otherwise it checks that the object exists
+func (f *Fake) GetObjectOverHTTP(bucketName, key string) error {
+	f.mutex.Lock()
+	bucket, exists := f.buckets[bucketName]
+	httpsOnly := exists && bucket.config.HTTPSOnly
+	f.mutex.Unlock()
+	if httpsOnly {
+		return errFakeRequiresHTTPS
+	}
+	_, err := f.GetObject(bucketName, key)
+	return err
+}
+
+// IsInsecureTransportRefusal returns whether a request was refused because the bucket only accepts requests over HTTPS
+func (f *Fake) IsInsecureTransportRefusal(err error) bool {
+	return err == errFakeRequiresHTTPS
+}
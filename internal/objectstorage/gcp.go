@@ -0,0 +1,101 @@
+package objectstorage
+
+import (
+	"cloud.google.com/go/storage"
+	"github.com/citihub/probr-sdk/utils"
+
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	gcputil "github.com/citihub/probr-pack-storage/internal/gcp"
+)
+
+// GCP maps buckets to Cloud Storage buckets in the configured project
+type GCP struct {
+	connection connection.GCP
+}
+
+// NewGCP provides a new instance of GCP
+func NewGCP(conn connection.GCP) *GCP {
+	return &GCP{
+		connection: conn,
+	}
+}
+
+// Provider returns the name of the provider
+func (g *GCP) Provider() string {
+	return "GCP"
+}
+
+// IsCloudAvailable verifies that the connection instantiation did not report a failure
+func (g *GCP) IsCloudAvailable() error {
+	return g.connection.IsCloudAvailable()
+}
+
+// CreateBucket creates a bucket with the requested settings. Cloud Storage only serves HTTPS, so HTTPSOnly needs no
+// setting. Network restriction is not a bucket setting in Cloud Storage, as it is applied by VPC Service Controls.
+func (g *GCP) CreateBucket(bucketName string, spec Spec) error {
+	if len(spec.AllowedNetworks) > 0 {
+		return utils.ReformatError("Network restrictions are not supported when creating Cloud Storage buckets")
+	}
+
+	attrs := gcputil.DefaultBucketAttrs()
+	if spec.PublicAccessBlocked {
+		attrs.UniformBucketLevelAccess = storage.UniformBucketLevelAccess{Enabled: true}
+		attrs.PublicAccessPrevention = storage.PublicAccessPreventionEnforced
+	}
+	if spec.KMSKeyID != "" {
+		attrs.Encryption = &storage.BucketEncryption{DefaultKMSKeyName: spec.KMSKeyID}
+	}
+	return g.connection.CreateBucket(bucketName, attrs)
+}
+
+// DeleteBucket deletes a bucket and all object versions within it
+func (g *GCP) DeleteBucket(bucketName string) error {
+	return g.connection.DeleteBucket(bucketName)
+}
+
+// GetBucketConfig reads the effective settings of a bucket
+func (g *GCP) GetBucketConfig(bucketName string) (Config, error) {
+	config := Config{
+		Provider:   g.Provider(),
+		BucketName: bucketName,
+		HTTPSOnly:  true,
+	}
+
+	attrs, err := g.connection.GetBucketAttrs(bucketName)
+	if err != nil {
+		return config, err
+	}
+	policy, err := g.connection.GetBucketIAMPolicy(bucketName)
+	if err != nil {
+		return config, err
+	}
+
+	config.PublicAccessBlocked = gcputil.EvaluatePublicAccess(attrs, policy).Compliant
+	config.KMSKeyID = gcputil.EvaluateEncryption(attrs, nil).DefaultKMSKeyName
+	return config, nil
+}
+
+// PutObject uploads an object to a bucket
+func (g *GCP) PutObject(bucketName, key string, content []byte) error {
+	return g.connection.PutObject(bucketName, key, content)
+}
+
+// GetObject downloads an object from a bucket
+func (g *GCP) GetObject(bucketName, key string) ([]byte, error) {
+	return g.connection.GetObject(bucketName, key)
+}
+
+// ServesPlainHTTP returns false, as Cloud Storage only serves HTTPS
+func (g *GCP) ServesPlainHTTP() bool {
+	return false
+}
+
+// GetObjectOverHTTP always fails, as Cloud Storage only serves HTTPS
+func (g *GCP) GetObjectOverHTTP(bucketName, key string) error {
+	return utils.ReformatError("Cloud Storage does not serve requests over plain HTTP")
+}
+
+// IsInsecureTransportRefusal always returns false, as Cloud Storage has no plain HTTP endpoint to refuse requests
+func (g *GCP) IsInsecureTransportRefusal(err error) bool {
+	return false
+}
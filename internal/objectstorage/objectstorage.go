@@ -0,0 +1,124 @@
+// Package objectstorage provides a provider neutral view of object storage, so that a probe for a control can run
+// against whichever provider is selected by config.Vars.ServicePacks.Storage.Provider.
+package objectstorage
+
+import (
+	"context"
+	"strings"
+
+	"github.com/citihub/probr-sdk/config"
+	"github.com/citihub/probr-sdk/utils"
+
+	awsutil "github.com/citihub/probr-pack-storage/internal/aws"
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	"github.com/citihub/probr-pack-storage/internal/connection"
+	gcputil "github.com/citihub/probr-pack-storage/internal/gcp"
+)
+
+// Names of the bucket settings compared by Unmet
+const (
+	HTTPSOnlySetting           = "https only"
+	PublicAccessBlockedSetting = "public access blocked"
+	CustomerManagedKeySetting  = "customer managed key"
+	NetworkRestrictedSetting   = "network restricted"
+)
+
+// Spec describes the security settings requested for a new bucket. Zero values leave the provider defaults in place.
+type Spec struct {
+	HTTPSOnly           bool     // Refuse requests over plain HTTP
+	PublicAccessBlocked bool     // Prevent the bucket and its objects from being made public
+	KMSKeyID            string   // Customer managed key for default encryption, in the form the provider expects
+	AllowedNetworks     []string // IP addresses and CIDR ranges from which data may be accessed. Empty allows any network
+}
+
+// Config records the effective security settings of a bucket, as read back from the provider
+type Config struct {
+	Provider            string
+	BucketName          string
+	HTTPSOnly           bool
+	PublicAccessBlocked bool
+	KMSKeyID            string // Empty when data is encrypted with a provider managed key
+	NetworkRestricted   bool   // Data access from networks outside the allowed list is denied
+}
+
+// ObjectStorage is implemented for each provider. A bucket is the unit to which security settings apply: an S3 or
+// Cloud Storage bucket, or an Azure storage account holding a single container. GetObjectOverHTTP requests an object
+// over plain HTTP rather than TLS, and IsInsecureTransportRefusal tells whether the error it returned is the refusal
+// of an HTTPS only bucket, rather than a failure to connect. It is only called for providers which ServesPlainHTTP.
+type ObjectStorage interface {
+	Provider() string
+	IsCloudAvailable() error
+	CreateBucket(bucketName string, spec Spec) error
+	DeleteBucket(bucketName string) error
+	GetBucketConfig(bucketName string) (Config, error)
+	PutObject(bucketName, key string, content []byte) error
+	GetObject(bucketName, key string) ([]byte, error)
+	ServesPlainHTTP() bool
+	GetObjectOverHTTP(bucketName, key string) error
+	IsInsecureTransportRefusal(err error) bool
+}
+
+// New returns the ObjectStorage implementation for a provider, connected with the provider configuration.
+// Supported providers are Azure, AWS, S3Compatible and GCP.
+func New(provider string) (ObjectStorage, error) {
+	switch provider {
+	case "Azure":
+		return NewAzure(
+			connection.NewAzureConnection(
+				context.Background(),
+				azureutil.SubscriptionID(),
+				azureutil.TenantID(),
+				azureutil.ClientID(),
+				azureutil.ClientSecret(),
+			),
+			azureutil.ResourceGroup(),
+		), nil
+	case awsutil.AWSProvider, awsutil.S3CompatibleProvider:
+		return NewAWS(
+			connection.NewAWSConnection(
+				context.Background(),
+				awsutil.S3Endpoint(),
+				awsutil.S3ForcePathStyle(),
+			),
+		), nil
+	case "GCP":
+		return NewGCP(
+			connection.NewGCPConnection(
+				context.Background(),
+				gcputil.ProjectID(),
+			),
+		), nil
+	default:
+		return nil, utils.ReformatError("Unexpected value provided for provider: '%s' Expected values: ['Azure', '%s', '%s', 'GCP']", provider, awsutil.AWSProvider, awsutil.S3CompatibleProvider)
+	}
+}
+
+// NewFromConfig returns the ObjectStorage implementation for the provider selected in config
+func NewFromConfig() (ObjectStorage, error) {
+	return New(config.Vars.ServicePacks.Storage.Provider)
+}
+
+// BucketName returns a new random name for a bucket created by a probe, which is valid for every provider:
+// lower case letters and digits only, as Azure storage account names may not contain hyphens.
+func BucketName() string {
+	return "probr" + strings.ToLower(utils.RandomString(10))
+}
+
+// Unmet returns the names of the settings requested by a spec which are not in effect on a bucket. Settings the
+// spec leaves at their zero value are not compared.
+func Unmet(spec Spec, effective Config) []string {
+	var unmet []string
+	if spec.HTTPSOnly && !effective.HTTPSOnly {
+		unmet = append(unmet, HTTPSOnlySetting)
+	}
+	if spec.PublicAccessBlocked && !effective.PublicAccessBlocked {
+		unmet = append(unmet, PublicAccessBlockedSetting)
+	}
+	if spec.KMSKeyID != "" && effective.KMSKeyID != spec.KMSKeyID {
+		unmet = append(unmet, CustomerManagedKeySetting)
+	}
+	if len(spec.AllowedNetworks) > 0 && !effective.NetworkRestricted {
+		unmet = append(unmet, NetworkRestrictedSetting)
+	}
+	return unmet
+}
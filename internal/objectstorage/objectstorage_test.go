@@ -0,0 +1,157 @@
+package objectstorage
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFakeBucketLifecycle(t *testing.T) {
+	var store ObjectStorage = NewFake()
+	spec := Spec{
+		HTTPSOnly:           true,
+		PublicAccessBlocked: true,
+		KMSKeyID:            "probr-key",
+		AllowedNetworks:     []string{"10.0.0.0/8"},
+	}
+
+	if err := store.CreateBucket("probrbucket", spec); err != nil {
+		t.Fatalf("CreateBucket() error = %v", err)
+	}
+	if err := store.CreateBucket("probrbucket", spec); err == nil {
+		t.Errorf("CreateBucket() of an existing bucket succeeded, expected an error")
+	}
+
+	config, err := store.GetBucketConfig("probrbucket")
+	if err != nil {
+		t.Fatalf("GetBucketConfig() error = %v", err)
+	}
+	if unmet := Unmet(spec, config); len(unmet) > 0 {
+		t.Errorf("Unmet() = %v, want none", unmet)
+	}
+
+	content := []byte("probr")
+	if err := store.PutObject("probrbucket", "object", content); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	content[0] = 'P'
+	got, err := store.GetObject("probrbucket", "object")
+	if err != nil {
+		t.Fatalf("GetObject() error = %v", err)
+	}
+	if !bytes.Equal(got, []byte("probr")) {
+		t.Errorf("GetObject() = %q, want %q", got, "probr")
+	}
+	if _, err := store.GetObject("probrbucket", "missing"); err == nil {
+		t.Errorf("GetObject() of a missing object succeeded, expected an error")
+	}
+
+	if err := store.DeleteBucket("probrbucket"); err != nil {
+		t.Fatalf("DeleteBucket() error = %v", err)
+	}
+	if _, err := store.GetBucketConfig("probrbucket"); err == nil {
+		t.Errorf("GetBucketConfig() of a deleted bucket succeeded, expected an error")
+	}
+}
+
+func TestFakePolicy(t *testing.T) {
+	store := NewFake()
+	store.Policy = func(bucketName string, spec Spec) error {
+		if !spec.HTTPSOnly {
+			return errors.New("https only is required")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name        string
+		spec        Spec
+		wantCreated bool
+	}{
+		{"TestCase1_CompliantSpec_ShouldBeCreated", Spec{HTTPSOnly: true}, true},
+		{"TestCase2_NonCompliantSpec_ShouldBeRefused", Spec{HTTPSOnly: false}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucketName := BucketName()
+			err := store.CreateBucket(bucketName, tt.spec)
+			if (err == nil) != tt.wantCreated {
+				t.Errorf("CreateBucket() error = %v, want created %v", err, tt.wantCreated)
+			}
+			if _, getErr := store.GetBucketConfig(bucketName); (getErr == nil) != tt.wantCreated {
+				t.Errorf("GetBucketConfig() error = %v, want bucket to exist %v", getErr, tt.wantCreated)
+			}
+		})
+	}
+}
+
+func TestFakeGetObjectOverHTTP(t *testing.T) {
+	store := NewFake()
+
+	tests := []struct {
+		name        string
+		spec        Spec
+		wantErr     bool
+		wantRefusal bool
+	}{
+		{"TestCase1_HTTPSOnlyBucket_ShouldBeRefused", Spec{HTTPSOnly: true}, true, true},
+		{"TestCase2_DefaultBucket_ShouldBeServed", Spec{}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucketName := BucketName()
+			if err := store.CreateBucket(bucketName, tt.spec); err != nil {
+				t.Fatalf("CreateBucket() error = %v", err)
+			}
+			if err := store.PutObject(bucketName, "object", []byte("probr")); err != nil {
+				t.Fatalf("PutObject() error = %v", err)
+			}
+			err := store.GetObjectOverHTTP(bucketName, "object")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetObjectOverHTTP() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := store.IsInsecureTransportRefusal(err); got != tt.wantRefusal {
+				t.Errorf("IsInsecureTransportRefusal() = %v, want %v", got, tt.wantRefusal)
+			}
+		})
+	}
+
+	if err := store.GetObjectOverHTTP("missing", "object"); err == nil || store.IsInsecureTransportRefusal(err) {
+		t.Errorf("GetObjectOverHTTP() of a missing bucket error = %v, want an error other than a refusal", err)
+	}
+}
+
+func TestUnmet(t *testing.T) {
+	spec := Spec{
+		HTTPSOnly:           true,
+		PublicAccessBlocked: true,
+		KMSKeyID:            "probr-key",
+		AllowedNetworks:     []string{"10.0.0.0/8"},
+	}
+	compliant := Config{
+		HTTPSOnly:           true,
+		PublicAccessBlocked: true,
+		KMSKeyID:            "probr-key",
+		NetworkRestricted:   true,
+	}
+
+	tests := []struct {
+		name   string
+		spec   Spec
+		config Config
+		want   []string
+	}{
+		{"TestCase1_AllSettingsInEffect_ShouldReturnNone", spec, compliant, nil},
+		{"TestCase2_EmptySpec_ShouldReturnNone", Spec{}, Config{}, nil},
+		{"TestCase3_ProviderDefaults_ShouldReturnAll", spec, Config{}, []string{HTTPSOnlySetting, PublicAccessBlockedSetting, CustomerManagedKeySetting, NetworkRestrictedSetting}},
+		{"TestCase4_DifferentKey_ShouldReturnKey", spec, Config{HTTPSOnly: true, PublicAccessBlocked: true, KMSKeyID: "other-key", NetworkRestricted: true}, []string{CustomerManagedKeySetting}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unmet(tt.spec, tt.config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
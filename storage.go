@@ -1,8 +1,29 @@
 package pack
 
 import (
+	awsutil "github.com/citihub/probr-pack-storage/internal/aws"
+	awsac "github.com/citihub/probr-pack-storage/internal/aws/access_control"
+	awsana "github.com/citihub/probr-pack-storage/internal/aws/allowed_network_access"
+	awsear "github.com/citihub/probr-pack-storage/internal/aws/encryption_at_rest"
+	awseif "github.com/citihub/probr-pack-storage/internal/aws/encryption_in_flight"
+	awsis "github.com/citihub/probr-pack-storage/internal/aws/immutable_storage"
+	azureutil "github.com/citihub/probr-pack-storage/internal/azure"
+	azureac "github.com/citihub/probr-pack-storage/internal/azure/access_control"
+	azureaat "github.com/citihub/probr-pack-storage/internal/azure/allowed_account_types"
 	azureana "github.com/citihub/probr-pack-storage/internal/azure/allowed_network_access"
+	azurecors "github.com/citihub/probr-pack-storage/internal/azure/cors_rules"
+	azurectr "github.com/citihub/probr-pack-storage/internal/azure/cross_tenant_replication"
+	azuredp "github.com/citihub/probr-pack-storage/internal/azure/disabled_protocols"
 	azureeif "github.com/citihub/probr-pack-storage/internal/azure/encryption_in_flight"
+	azureesa "github.com/citihub/probr-pack-storage/internal/azure/existing_storage_accounts"
+	azureiac "github.com/citihub/probr-pack-storage/internal/azure/iac_scan"
+	azurekse "github.com/citihub/probr-pack-storage/internal/azure/key_and_sas_expiration"
+	"github.com/citihub/probr-pack-storage/internal/azure/policyassignment"
+	azuresw "github.com/citihub/probr-pack-storage/internal/azure/static_website"
+	gcpac "github.com/citihub/probr-pack-storage/internal/gcp/access_control"
+	gcpear "github.com/citihub/probr-pack-storage/internal/gcp/encryption_at_rest"
+	gcpis "github.com/citihub/probr-pack-storage/internal/gcp/immutable_storage"
+	oseif "github.com/citihub/probr-pack-storage/internal/objectstorage/encryption_in_flight"
 	"github.com/citihub/probr-sdk/config"
 	"github.com/citihub/probr-sdk/probeengine"
 	"github.com/markbates/pkger"
@@ -15,22 +36,89 @@ func GetProbes() []probeengine.Probe {
 	}
 	switch config.Vars.ServicePacks.Storage.Provider {
 	case "Azure":
-		return []probeengine.Probe{
-			//azureac.Probe,
+		switch azureutil.ProbeMode() {
+		case azureutil.DetectiveMode:
+			return []probeengine.Probe{
+				azureesa.Probe,
+			}
+		case azureutil.IaCMode:
+			return []probeengine.Probe{
+				azureiac.Probe,
+			}
+		}
+		probes := []probeengine.Probe{
+			azureac.Probe,
+			azureaat.Probe,
 			azureana.Probe,
+			azurecors.Probe,
+			azurectr.Probe,
+			azuredp.Probe,
 			//azureear.Probe,
 			azureeif.Probe,
+			azurekse.Probe,
+			azuresw.Probe,
+		}
+		// The provider neutral probe always provisions its storage accounts, so it is not run in the validate and whatif creation modes
+		if azureutil.CreationMode() == azureutil.CreationModeCreate {
+			probes = append(probes, oseif.Probe)
+		}
+		return probes
+	case awsutil.AWSProvider:
+		return []probeengine.Probe{
+			awsac.Probe,
+			awsana.Probe,
+			awsear.Probe,
+			awseif.Probe,
+			awsis.Probe,
+			oseif.Probe,
+		}
+	case awsutil.S3CompatibleProvider:
+		return []probeengine.Probe{
+			awsac.Probe,
+			awsana.Probe,
+			awsear.Probe,
+			awseif.Probe,
+			oseif.Probe,
+		}
+	case "GCP":
+		return []probeengine.Probe{
+			gcpac.Probe,
+			gcpear.Probe,
+			gcpis.Probe,
+			oseif.Probe,
 		}
 	default:
 		return nil
 	}
 }
 
+// Teardown removes resources shared by the probes for the duration of the test run, such as ephemeral policy assignments
+func Teardown() {
+	policyassignment.Remove()
+}
+
 func init() {
 	// This line will ensure that all static files are bundled into pked.go file when using pkger cli tool
 	// See: https://github.com/markbates/pkger
+	pkger.Include("/internal/aws/access_control/access_control.feature")
+	pkger.Include("/internal/aws/allowed_network_access/allowed_network_access.feature")
+	pkger.Include("/internal/aws/encryption_at_rest/encryption_at_rest.feature")
+	pkger.Include("/internal/aws/encryption_in_flight/encryption_in_flight.feature")
+	pkger.Include("/internal/aws/immutable_storage/immutable_storage.feature")
 	pkger.Include("/internal/azure/access_control/access_control.feature")
+	pkger.Include("/internal/azure/allowed_account_types/allowed_account_types.feature")
 	pkger.Include("/internal/azure/allowed_network_access/allowed_network_access.feature")
+	pkger.Include("/internal/azure/cors_rules/cors_rules.feature")
+	pkger.Include("/internal/azure/cross_tenant_replication/cross_tenant_replication.feature")
+	pkger.Include("/internal/azure/disabled_protocols/disabled_protocols.feature")
 	//pkger.Include("/internal/azure/encryption_at_rest/encryption_at_rest.feature")
 	pkger.Include("/internal/azure/encryption_in_flight/encryption_in_flight.feature")
+	pkger.Include("/internal/azure/existing_storage_accounts/existing_storage_accounts.feature")
+	pkger.Include("/internal/azure/iac_scan/iac_scan.feature")
+	pkger.Include("/internal/azure/key_and_sas_expiration/key_and_sas_expiration.feature")
+	pkger.Include("/internal/azure/static_website/static_website.feature")
+	pkger.Include("/internal/gcp/access_control/access_control.feature")
+	pkger.Include("/internal/gcp/encryption_at_rest/encryption_at_rest.feature")
+	pkger.Include("/internal/gcp/immutable_storage/immutable_storage.feature")
+	pkger.Include("/internal/objectstorage/encryption_in_flight/encryption_in_flight.feature")
 }
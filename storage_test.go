@@ -1,6 +1,7 @@
 package pack
 
 import (
+	"os"
 	"testing"
 
 	"github.com/citihub/probr-sdk/config"
@@ -16,9 +17,116 @@ func TestGetProbes(t *testing.T) {
 	}
 
 	config.Vars.ServicePacks.Storage.Provider = "Azure"
+	defer func() { config.Vars.ServicePacks.Storage.Provider = "" }()
 	pack = GetProbes()
 	if len(pack) == 0 {
 		t.Logf("Expected value not returned from GetProbes")
 		t.Fail()
 	}
 }
+
+func TestGetProbesDetectiveMode(t *testing.T) {
+	config.Vars.ServicePacks.Storage.Provider = "Azure"
+	defer func() { config.Vars.ServicePacks.Storage.Provider = "" }()
+
+	defer restoreEnv("PROBR_STORAGE_MODE")()
+	os.Setenv("PROBR_STORAGE_MODE", "detective")
+
+	pack := GetProbes()
+	if len(pack) != 1 || pack[0].Name() != "existing_storage_accounts" {
+		t.Logf("Expected only the existing_storage_accounts probe to be returned in detective mode")
+		t.Fail()
+	}
+}
+
+func TestGetProbesIaCMode(t *testing.T) {
+	config.Vars.ServicePacks.Storage.Provider = "Azure"
+	defer func() { config.Vars.ServicePacks.Storage.Provider = "" }()
+
+	defer restoreEnv("PROBR_STORAGE_MODE")()
+	os.Setenv("PROBR_STORAGE_MODE", "iac")
+
+	pack := GetProbes()
+	if len(pack) != 1 || pack[0].Name() != "iac_scan" {
+		t.Logf("Expected only the iac_scan probe to be returned in IaC mode")
+		t.Fail()
+	}
+}
+
+func TestGetProbesAWS(t *testing.T) {
+	config.Vars.ServicePacks.Storage.Provider = "AWS"
+	defer func() { config.Vars.ServicePacks.Storage.Provider = "" }()
+
+	pack := GetProbes()
+	if len(pack) == 0 {
+		t.Logf("Expected AWS probes to be returned from GetProbes")
+		t.Fail()
+	}
+}
+
+func TestGetProbesGCP(t *testing.T) {
+	config.Vars.ServicePacks.Storage.Provider = "GCP"
+	defer func() { config.Vars.ServicePacks.Storage.Provider = "" }()
+
+	pack := GetProbes()
+	if len(pack) == 0 {
+		t.Logf("Expected GCP probes to be returned from GetProbes")
+		t.Fail()
+	}
+}
+
+func TestGetProbesS3Compatible(t *testing.T) {
+	config.Vars.ServicePacks.Storage.Provider = "S3Compatible"
+	defer func() { config.Vars.ServicePacks.Storage.Provider = "" }()
+
+	pack := GetProbes()
+	if len(pack) == 0 {
+		t.Logf("Expected S3 compatible probes to be returned from GetProbes")
+		t.Fail()
+	}
+}
+
+func TestGetProbesObjectStorage(t *testing.T) {
+	defer func() { config.Vars.ServicePacks.Storage.Provider = "" }()
+
+	for _, provider := range []string{"Azure", "AWS", "S3Compatible", "GCP"} {
+		config.Vars.ServicePacks.Storage.Provider = provider
+		found := false
+		for _, probe := range GetProbes() {
+			if probe.Name() == "objectstorage_encryption_in_flight" {
+				found = true
+			}
+		}
+		if !found {
+			t.Logf("Expected the provider neutral encryption in flight probe to be returned for provider '%s'", provider)
+			t.Fail()
+		}
+	}
+}
+
+func TestGetProbesObjectStorageAzureValidateMode(t *testing.T) {
+	config.Vars.ServicePacks.Storage.Provider = "Azure"
+	defer func() { config.Vars.ServicePacks.Storage.Provider = "" }()
+
+	os.Setenv("AZURE_CREATION_MODE", "validate")
+	defer os.Unsetenv("AZURE_CREATION_MODE")
+
+	for _, probe := range GetProbes() {
+		if probe.Name() == "objectstorage_encryption_in_flight" {
+			t.Logf("Expected the provider neutral encryption in flight probe not to be returned in the validate creation mode")
+			t.Fail()
+		}
+	}
+}
+
+// restoreEnv returns a function which restores an environment variable to its current value, or unsets it if it is not set
+func restoreEnv(varName string) func() {
+	value, set := os.LookupEnv(varName)
+	return func() {
+		if set {
+			os.Setenv(varName, value)
+		} else {
+			os.Unsetenv(varName)
+		}
+	}
+}